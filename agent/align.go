@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"time"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// nextTick returns the first boundary after now of period plus offset. The
+// boundaries are multiples of period since the unix epoch, so every agent with
+// the same interval gathers at the same wall clock times, e.g. :00/:15/:30/:45.
+func nextTick(now time.Time, period, offset time.Duration) time.Time {
+	if period <= 0 {
+		return now
+	}
+	period = period.Truncate(time.Millisecond)
+	if period <= 0 {
+		return now
+	}
+	ms := int64(period / time.Millisecond)
+	t := time.UnixMilli(now.UnixMilli() / ms * ms).Add(offset % period)
+	for !t.After(now) {
+		t = t.Add(period)
+	}
+	return t
+}
+
+// stampSamples sets the timestamp of the samples not stamped by the input
+func stampSamples(slist *types.SampleList, t time.Time) {
+	ss := slist.PopBackAll()
+	for _, s := range ss {
+		if s != nil && s.Timestamp.IsZero() {
+			s.Timestamp = t
+		}
+	}
+	slist.PushFrontN(ss)
+}
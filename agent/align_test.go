@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestNextTick(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 7, 300, time.UTC)
+	require.Equal(t, time.Date(2024, 5, 1, 10, 0, 15, 0, time.UTC), nextTick(now, 15*time.Second, 0).UTC())
+	require.Equal(t, time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC), nextTick(now, time.Minute, 0).UTC())
+	require.Equal(t, time.Date(2024, 5, 1, 10, 0, 17, 0, time.UTC), nextTick(now, 15*time.Second, 2*time.Second).UTC())
+	require.Equal(t, time.Date(2024, 5, 1, 10, 0, 10, 0, time.UTC), nextTick(now, 15*time.Second, -5*time.Second).UTC())
+
+	// a boundary itself is already taken
+	boundary := time.Date(2024, 5, 1, 10, 0, 15, 0, time.UTC)
+	require.Equal(t, boundary.Add(15*time.Second), nextTick(boundary, 15*time.Second, 0).UTC())
+}
+
+func TestStampSamples(t *testing.T) {
+	own := time.Unix(1000, 0)
+	stamp := time.Unix(2000, 0)
+	slist := types.NewSampleList()
+	slist.PushSample("", "a", 1)
+	slist.PushFront(types.NewSample("", "b", 2).SetTime(own))
+
+	stampSamples(slist, stamp)
+	got := map[string]time.Time{}
+	for _, s := range slist.PopBackAll() {
+		got[s.Metric] = s.Timestamp
+	}
+	require.Equal(t, stamp, got["a"])
+	require.Equal(t, own, got["b"])
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime/metrics"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+// metrics reported per input while backoff is enabled
+const (
+	backoffFactorMetric  = "agent_input_backoff_factor"
+	gatherSecondsMetric  = "agent_input_gather_seconds"
+	gatherCPUMetric      = "agent_input_gather_cpu_seconds"
+	gatherAllocMetric    = "agent_input_gather_alloc_bytes"
+	heapAllocsMetricName = "/gc/heap/allocs:bytes"
+)
+
+var self, _ = process.NewProcess(int32(os.Getpid()))
+
+// usage is what one gather cost. Go has no cpu time per goroutine, so cpu and
+// allocations are those of the whole process during the gather, inputs
+// gathering at the same time are charged for each other.
+type usage struct {
+	wall  time.Duration
+	cpu   time.Duration
+	alloc uint64
+	// instances skipped since their previous gather was still running
+	skipped uint64
+}
+
+type usageProbe struct {
+	start time.Time
+	cpu   time.Duration
+	alloc uint64
+}
+
+func startUsage() usageProbe {
+	return usageProbe{start: time.Now(), cpu: processCPU(), alloc: heapAllocs()}
+}
+
+func (p usageProbe) stop() usage {
+	u := usage{wall: time.Since(p.start)}
+	if cpu := processCPU(); cpu > p.cpu {
+		u.cpu = cpu - p.cpu
+	}
+	if alloc := heapAllocs(); alloc > p.alloc {
+		u.alloc = alloc - p.alloc
+	}
+	return u
+}
+
+func processCPU() time.Duration {
+	if self == nil {
+		return 0
+	}
+	times, err := self.Times()
+	if err != nil {
+		return 0
+	}
+	return time.Duration((times.User + times.System) * float64(time.Second))
+}
+
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: heapAllocsMetricName}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// backoff stretches the interval of an input which keeps exceeding its
+// interval or budget, instead of running its gathers back to back
+type backoff struct {
+	factor int
+	// gathers in a row over and within the limits
+	exceeded int
+	healthy  int
+}
+
+// observe accounts a gather and returns the interval to wait for the next one
+func (b *backoff) observe(name string, interval time.Duration, u usage, opt config.Backoff) time.Duration {
+	if b.factor == 0 {
+		b.factor = 1
+	}
+
+	reason := ""
+	switch {
+	case u.wall > interval:
+		reason = "gather took " + u.wall.String()
+	case u.skipped > 0:
+		reason = fmt.Sprintf("%d instances still gathering", u.skipped)
+	case opt.CPUBudget > 0 && u.cpu > time.Duration(opt.CPUBudget):
+		reason = "gather used cpu " + u.cpu.String()
+	case opt.AllocBudgetMB > 0 && u.alloc > uint64(opt.AllocBudgetMB)*1024*1024:
+		reason = "gather allocated " + humanBytes(u.alloc)
+	}
+
+	if reason != "" {
+		b.healthy = 0
+		b.exceeded++
+		if b.exceeded >= opt.Threshold && b.factor < opt.MaxFactor {
+			b.factor *= 2
+			if b.factor > opt.MaxFactor {
+				b.factor = opt.MaxFactor
+			}
+			b.exceeded = 0
+			log.Printf("W! %s: %s, interval stretched to %s", name, reason, interval*time.Duration(b.factor))
+		}
+	} else {
+		b.exceeded = 0
+		b.healthy++
+		if b.healthy >= opt.Threshold && b.factor > 1 {
+			b.factor /= 2
+			b.healthy = 0
+			log.Printf("I! %s: within interval and budget again, interval shrunk to %s", name, interval*time.Duration(b.factor))
+		}
+	}
+	return b.stretch(interval)
+}
+
+// stretch is the interval as stretched by the gathers observed so far
+func (b *backoff) stretch(interval time.Duration) time.Duration {
+	if b.factor <= 1 {
+		return interval
+	}
+	return interval * time.Duration(b.factor)
+}
+
+func (b *backoff) samples(name string, u usage) *types.SampleList {
+	labels := map[string]string{"input": name}
+	slist := types.NewSampleList()
+	slist.PushSample("", backoffFactorMetric, b.factor, labels)
+	slist.PushSample("", gatherSecondsMetric, u.wall.Seconds(), labels)
+	slist.PushSample("", gatherCPUMetric, u.cpu.Seconds(), labels)
+	slist.PushSample("", gatherAllocMetric, u.alloc, labels)
+	return slist
+}
+
+func humanBytes(n uint64) string {
+	return fmt.Sprintf("%.1fMiB", float64(n)/1024/1024)
+}
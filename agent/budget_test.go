@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestBackoff(t *testing.T) {
+	opt := config.Backoff{Enable: true, CPUBudget: config.Duration(time.Second), Threshold: 2, MaxFactor: 4}
+	interval := 10 * time.Second
+	slow := usage{wall: 12 * time.Second}
+	busy := usage{wall: time.Second, cpu: 2 * time.Second}
+	fine := usage{wall: time.Second}
+
+	var b backoff
+	require.Equal(t, interval, b.stretch(interval))
+	require.Equal(t, interval, b.observe("test", interval, slow, opt))
+	require.Equal(t, 2*interval, b.observe("test", interval, busy, opt))
+	require.Equal(t, 2*interval, b.observe("test", interval, slow, opt))
+	require.Equal(t, 4*interval, b.observe("test", interval, slow, opt))
+	require.Equal(t, 4*interval, b.stretch(interval))
+	// capped by max_factor
+	b.observe("test", interval, slow, opt)
+	require.Equal(t, 4*interval, b.observe("test", interval, slow, opt))
+
+	require.Equal(t, 4*interval, b.observe("test", interval, fine, opt))
+	require.Equal(t, 2*interval, b.observe("test", interval, fine, opt))
+	b.observe("test", interval, fine, opt)
+	require.Equal(t, interval, b.observe("test", interval, fine, opt))
+
+	// every limit stretches the interval on its own
+	opt = config.Backoff{Enable: true, AllocBudgetMB: 256, Threshold: 1, MaxFactor: 8}
+	var c backoff
+	require.Equal(t, 2*interval, c.observe("test", interval, usage{wall: time.Second, alloc: 512 << 20}, opt))
+	require.Equal(t, 4*interval, c.observe("test", interval, usage{wall: time.Second, skipped: 1}, opt))
+
+	u := startUsage().stop()
+	require.GreaterOrEqual(t, u.wall, time.Duration(0))
+}
@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/toolkits/pkg/file"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/cfg"
+)
+
+const inputDirPrefix = "input."
+
+// ConfigChecker validates config.toml (and the other files beside it) plus every
+// conf/input.<name> directory without starting any input. Type errors, missing
+// required fields (`required:"true"` tags) and invalid filters/relabel configs
+// are errors; unknown keys and unsupported inputs are warnings unless Strict.
+// An instance without any of its required fields set is skipped by the agent,
+// so it's a warning only.
+type ConfigChecker struct {
+	ConfigDir string
+	// Strict treats unknown keys and unsupported inputs as errors
+	Strict bool
+	// Dump prints the effective config with defaults applied
+	Dump bool
+	Out  io.Writer
+
+	errors   int
+	warnings int
+	inputs   map[string]inputs.Input
+}
+
+// Check returns false if any error is found, the caller should exit non-zero
+func (cc *ConfigChecker) Check() bool {
+	cc.inputs = make(map[string]inputs.Input)
+
+	if cc.checkMainConfig() {
+		cc.checkInputs()
+	}
+
+	if cc.Dump && cc.errors == 0 {
+		cc.dump()
+	}
+
+	fmt.Fprintf(cc.Out, "%d error(s), %d warning(s)\n", cc.errors, cc.warnings)
+	return cc.errors == 0
+}
+
+func (cc *ConfigChecker) errorf(format string, a ...interface{}) {
+	cc.errors++
+	fmt.Fprintf(cc.Out, "E! "+format+"\n", a...)
+}
+
+func (cc *ConfigChecker) warnf(format string, a ...interface{}) {
+	cc.warnings++
+	fmt.Fprintf(cc.Out, "W! "+format+"\n", a...)
+}
+
+func (cc *ConfigChecker) unknownKeys(fpath string, keys []string) {
+	for _, k := range keys {
+		if cc.Strict {
+			cc.errorf("%s: unknown key %s", fpath, k)
+		} else {
+			cc.warnf("%s: unknown key %s", fpath, k)
+		}
+	}
+}
+
+func (cc *ConfigChecker) checkMainConfig() bool {
+	files, err := file.FilesUnder(cc.ConfigDir)
+	if err != nil {
+		cc.errorf("failed to list files under %s: %v", cc.ConfigDir, err)
+		return false
+	}
+
+	for _, f := range files {
+		format := cfg.GuessFormat(f)
+		if format == cfg.TomlFormat && !strings.HasSuffix(f, ".toml") {
+			continue
+		}
+		fpath := path.Join(cc.ConfigDir, f)
+		bs, err := file.ReadBytes(fpath)
+		if err != nil {
+			cc.errorf("%s: %v", fpath, err)
+			continue
+		}
+		keys, err := cfg.UndecodedKeys(cfg.ConfigWithFormat{Config: string(bs), Format: format}, &config.ConfigType{})
+		if err != nil {
+			cc.errorf("%s: %v", fpath, err)
+			continue
+		}
+		cc.unknownKeys(fpath, keys)
+	}
+
+	// same code path as the agent, applies the defaults used by dump
+	if err := config.InitConfig(cc.ConfigDir, 0, false, false, 0, ""); err != nil {
+		cc.errorf("%v", err)
+		return false
+	}
+	return true
+}
+
+func (cc *ConfigChecker) checkInputs() {
+	dirs, err := file.DirsUnder(cc.ConfigDir)
+	if err != nil {
+		cc.errorf("failed to list dirs under %s: %v", cc.ConfigDir, err)
+		return
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir, inputDirPrefix) {
+			continue
+		}
+		cc.checkInput(dir[len(inputDirPrefix):], path.Join(cc.ConfigDir, dir))
+	}
+}
+
+func (cc *ConfigChecker) checkInput(name, dir string) {
+	creator, has := inputs.InputCreators[name]
+	if !has {
+		// some inputs are only built for particular platforms, the agent skips them too
+		if cc.Strict {
+			cc.errorf("%s: input %s not supported", dir, name)
+		} else {
+			cc.warnf("%s: input %s not supported", dir, name)
+		}
+		return
+	}
+
+	files, err := file.FilesUnder(dir)
+	if err != nil {
+		cc.errorf("failed to list files under %s: %v", dir, err)
+		return
+	}
+
+	configs := make([]cfg.ConfigWithFormat, 0, len(files))
+	for _, f := range files {
+		format := cfg.GuessFormat(f)
+		if format == cfg.TomlFormat && !strings.HasSuffix(f, ".toml") {
+			continue
+		}
+		fpath := path.Join(dir, f)
+		bs, err := file.ReadBytes(fpath)
+		if err != nil {
+			cc.errorf("%s: %v", fpath, err)
+			continue
+		}
+		c := cfg.ConfigWithFormat{Config: string(bs), Format: format}
+
+		keys, err := cfg.UndecodedKeys(c, creator())
+		if err != nil {
+			cc.errorf("%s: %v", fpath, err)
+			continue
+		}
+		cc.unknownKeys(fpath, keys)
+		configs = append(configs, c)
+	}
+
+	if len(configs) == 0 {
+		return
+	}
+
+	// merged the same way as the local provider does
+	input := creator()
+	if err := cfg.LoadConfigs(configs, input); err != nil {
+		cc.errorf("%s: %v", dir, err)
+		return
+	}
+	if err := input.InitInternalConfig(); err != nil {
+		cc.errorf("%s: %v", dir, err)
+		return
+	}
+	for i, ins := range inputs.MayGetInstances(input) {
+		if err := ins.InitInternalConfig(); err != nil {
+			cc.errorf("%s: instances[%d]: %v", dir, i, err)
+		}
+		set, missing := cfg.RequiredKeys(ins)
+		if len(missing) > 0 && len(set) == 0 {
+			cc.warnf("%s: instances[%d]: %s not set, the instance is skipped", dir, i, strings.Join(missing, ", "))
+			continue
+		}
+		for _, key := range missing {
+			cc.errorf("%s: instances[%d]: %s is required", dir, i, key)
+		}
+	}
+	cc.inputs[name] = input
+}
+
+func (cc *ConfigChecker) dump() {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	bs, err := json.MarshalIndent(map[string]interface{}{
+		"config": config.Config,
+		"inputs": cc.inputs,
+	}, "", "    ")
+	if err != nil {
+		cc.errorf("failed to dump config: %v", err)
+		return
+	}
+	fmt.Fprintln(cc.Out, string(bs))
+}
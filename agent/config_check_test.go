@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) {
+	fpath := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fpath), 0755))
+	require.NoError(t, os.WriteFile(fpath, []byte(content), 0644))
+}
+
+func TestConfigCheckRequired(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.toml", "[global]\nprint_configs = false\n")
+	writeConfig(t, dir, "input.kafka_consumer/kafka_consumer.toml", `
+[[instances]]
+brokers = ["127.0.0.1:9092"]
+
+[[instances]]
+brokers = []
+topics = []
+`)
+	writeConfig(t, dir, "input.mqtt_consumer/mqtt_consumer.toml", `
+[[instances]]
+servers = ["tcp://127.0.0.1:1883"]
+topics = ["sensors/#"]
+
+[[instances.topic_parsing]]
+tags = "_/site"
+`)
+
+	var out bytes.Buffer
+	cc := &ConfigChecker{ConfigDir: dir, Out: &out}
+	require.False(t, cc.Check())
+	require.Equal(t, 2, cc.errors, out.String())
+	require.Equal(t, 1, cc.warnings, out.String())
+	require.Contains(t, out.String(), "input.kafka_consumer: instances[0]: topics is required")
+	require.Contains(t, out.String(), "input.kafka_consumer: instances[1]: brokers, topics not set, the instance is skipped")
+	require.Contains(t, out.String(), "input.mqtt_consumer: instances[0]: topic_parsing[0].topic is required")
+}
@@ -0,0 +1,39 @@
+package install
+
+import (
+	"time"
+)
+
+// Options tunes the generated service definition, set by `categraf service install`
+type Options struct {
+	// ConfigDir is passed to the service as -configs, default is conf under the binary dir
+	ConfigDir string
+	// DelayedStart uses delayed auto start on windows, and waits StartDelay(default 30s) on systemd
+	DelayedStart bool
+	// StartDelay sleeps before the agent starts, systemd only
+	StartDelay time.Duration
+	// StopTimeout is how long the service manager waits for a graceful shutdown before killing the agent
+	StopTimeout time.Duration
+}
+
+const defaultDelayedStart = 30 * time.Second
+
+func (o Options) arguments(defaultConfigDir string) []string {
+	if o.ConfigDir != "" {
+		return []string{"-configs", o.ConfigDir}
+	}
+	if defaultConfigDir != "" {
+		return []string{"-configs", defaultConfigDir}
+	}
+	return nil
+}
+
+func (o Options) startDelay() time.Duration {
+	if o.StartDelay > 0 {
+		return o.StartDelay
+	}
+	if o.DelayedStart {
+		return defaultDelayedStart
+	}
+	return 0
+}
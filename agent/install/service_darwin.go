@@ -19,6 +19,8 @@ var (
 	}
 )
 
-func ServiceConfig() *service.Config {
-	return serviceConfig
+func ServiceConfig(opts Options) *service.Config {
+	cfg := *serviceConfig
+	cfg.Arguments = opts.arguments("")
+	return &cfg
 }
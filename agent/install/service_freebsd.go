@@ -42,6 +42,7 @@ var (
 	}
 )
 
-func ServiceConfig() *service.Config {
+func ServiceConfig(_ Options) *service.Config {
+	// start_cmd of SysvScript is fixed to /opt/categraf
 	return serviceConfig
 }
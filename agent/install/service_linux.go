@@ -27,6 +27,7 @@ StandardOutput=journal
 StandardError=journal
 StartLimitInterval=3600
 StartLimitBurst=10
+{{with index .Option "StartDelaySec"}}ExecStartPre=/bin/sleep {{.}}{{end}}
 ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
 {{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
 {{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
@@ -44,6 +45,8 @@ SyslogIdentifier={{.Name}}
 RestartSec=120
 EnvironmentFile=-/etc/sysconfig/{{.Name}}
 KillMode=process
+KillSignal=SIGTERM
+{{with index .Option "TimeoutStopSec"}}TimeoutStopSec={{.}}{{end}}
 [Install]
 WantedBy=multi-user.target
 `
@@ -183,7 +186,7 @@ func isSystemd() bool {
 	return false
 }
 
-func ServiceConfig() *service.Config {
+func ServiceConfig(opts Options) *service.Config {
 	ServiceName := "categraf"
 	depends := []string{}
 	option := make(service.KeyValue)
@@ -201,6 +204,13 @@ func ServiceConfig() *service.Config {
 		// REMEMBER: Explicit disable LogOutput option of kardianos/service, and
 		// use StandardOutput/StandardError settings manually written above.
 		option["LogOutput"] = false
+
+		if delay := opts.startDelay(); delay > 0 {
+			option["StartDelaySec"] = int64(delay.Seconds())
+		}
+		if opts.StopTimeout > 0 {
+			option["TimeoutStopSec"] = int64(opts.StopTimeout.Seconds())
+		}
 	} else {
 		ServiceName = "categraf"
 		option["SysvScript"] = sysvScript
@@ -227,6 +237,6 @@ func ServiceConfig() *service.Config {
 	} else {
 		log.Println("E! get exeutable path error:", err)
 	}
-	cfg.Arguments = []string{"-configs", filepath.Dir(ov) + "/conf"}
+	cfg.Arguments = opts.arguments(filepath.Dir(ov) + "/conf")
 	return cfg
 }
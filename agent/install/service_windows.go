@@ -19,6 +19,14 @@ var (
 	}
 )
 
-func ServiceConfig() *service.Config {
-	return serviceConfig
+func ServiceConfig(opts Options) *service.Config {
+	cfg := *serviceConfig
+	cfg.Arguments = opts.arguments("")
+	cfg.Option = service.KeyValue{
+		"DelayedAutoStart": opts.DelayedStart,
+		// restart the agent if it crashed, like systemd Restart=on-failure
+		"OnFailure":              "restart",
+		"OnFailureDelayDuration": "1m",
+	}
+	return &cfg
 }
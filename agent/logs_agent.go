@@ -18,6 +18,7 @@ import (
 	"flashcat.cloud/categraf/logs/auditor"
 	"flashcat.cloud/categraf/logs/client"
 	"flashcat.cloud/categraf/logs/diagnostic"
+	"flashcat.cloud/categraf/logs/input/channel"
 	"flashcat.cloud/categraf/logs/input/container"
 	"flashcat.cloud/categraf/logs/input/file"
 	"flashcat.cloud/categraf/logs/input/journald"
@@ -118,6 +119,7 @@ func NewLogsAgent() AgentModule {
 			file.DefaultSleepDuration, validatePodContainerID, time.Duration(time.Duration(coreconfig.FileScanPeriod())*time.Second)),
 		listener.NewLauncher(sources, coreconfig.LogFrameSize(), pipelineProvider),
 		journald.NewLauncher(sources, pipelineProvider, auditor),
+		channel.NewLauncher(sources, pipelineProvider),
 	}
 	if coreconfig.EnableCollectContainer() {
 		log.Println("collect docker logs...")
@@ -167,6 +169,9 @@ func (la *LogsAgent) Start() error {
 		}
 		la.sources.AddSource(source)
 	}
+
+	// messages forwarded by metric inputs, e.g. syslog
+	logsconfig.SetChannelSourceSink(la.sources.AddSource)
 	return nil
 }
 
@@ -188,6 +193,9 @@ func (a *LogsAgent) Flush(ctx context.Context) {
 // Stop stops all the elements of the data pipeline
 // in the right order to prevent data loss
 func (a *LogsAgent) Stop() error {
+	// the channel launcher closes the channels of its sources
+	logsconfig.SetChannelSourceSink(nil)
+
 	inputs := restart.NewParallelStopper()
 	for _, input := range a.inputs {
 		inputs.Add(input)
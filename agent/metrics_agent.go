@@ -5,6 +5,7 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
@@ -17,8 +18,10 @@ import (
 	_ "flashcat.cloud/categraf/inputs/arp_packet"
 	_ "flashcat.cloud/categraf/inputs/bind"
 	_ "flashcat.cloud/categraf/inputs/cadvisor"
+	_ "flashcat.cloud/categraf/inputs/canary"
 	_ "flashcat.cloud/categraf/inputs/chrony"
 	_ "flashcat.cloud/categraf/inputs/clickhouse"
+	_ "flashcat.cloud/categraf/inputs/clock_skew"
 	_ "flashcat.cloud/categraf/inputs/cloudwatch"
 	_ "flashcat.cloud/categraf/inputs/conntrack"
 	_ "flashcat.cloud/categraf/inputs/consul"
@@ -32,6 +35,7 @@ import (
 	_ "flashcat.cloud/categraf/inputs/ethtool"
 	_ "flashcat.cloud/categraf/inputs/exec"
 	_ "flashcat.cloud/categraf/inputs/filecount"
+	_ "flashcat.cloud/categraf/inputs/filestat"
 	_ "flashcat.cloud/categraf/inputs/googlecloud"
 	_ "flashcat.cloud/categraf/inputs/greenplum"
 	_ "flashcat.cloud/categraf/inputs/haproxy"
@@ -44,6 +48,7 @@ import (
 	_ "flashcat.cloud/categraf/inputs/jolokia_agent"
 	_ "flashcat.cloud/categraf/inputs/jolokia_proxy"
 	_ "flashcat.cloud/categraf/inputs/kafka"
+	_ "flashcat.cloud/categraf/inputs/kafka_consumer"
 	_ "flashcat.cloud/categraf/inputs/kernel"
 	_ "flashcat.cloud/categraf/inputs/kernel_vmstat"
 	_ "flashcat.cloud/categraf/inputs/kubernetes"
@@ -51,12 +56,15 @@ import (
 	_ "flashcat.cloud/categraf/inputs/linux_sysctl_fs"
 	_ "flashcat.cloud/categraf/inputs/logstash"
 	_ "flashcat.cloud/categraf/inputs/mem"
+	_ "flashcat.cloud/categraf/inputs/mock"
 	_ "flashcat.cloud/categraf/inputs/mongodb"
+	_ "flashcat.cloud/categraf/inputs/mqtt_consumer"
 	_ "flashcat.cloud/categraf/inputs/mtail"
 	_ "flashcat.cloud/categraf/inputs/mysql"
 	_ "flashcat.cloud/categraf/inputs/nats"
 	_ "flashcat.cloud/categraf/inputs/net"
 	_ "flashcat.cloud/categraf/inputs/net_response"
+	_ "flashcat.cloud/categraf/inputs/netstack"
 	_ "flashcat.cloud/categraf/inputs/netstat"
 	_ "flashcat.cloud/categraf/inputs/netstat_filter"
 	_ "flashcat.cloud/categraf/inputs/nfsclient"
@@ -70,26 +78,32 @@ import (
 	_ "flashcat.cloud/categraf/inputs/phpfpm"
 	_ "flashcat.cloud/categraf/inputs/ping"
 	_ "flashcat.cloud/categraf/inputs/postgresql"
+	_ "flashcat.cloud/categraf/inputs/process_connections"
 	_ "flashcat.cloud/categraf/inputs/processes"
 	_ "flashcat.cloud/categraf/inputs/procstat"
 	_ "flashcat.cloud/categraf/inputs/prometheus"
 	_ "flashcat.cloud/categraf/inputs/rabbitmq"
 	_ "flashcat.cloud/categraf/inputs/redis"
+	_ "flashcat.cloud/categraf/inputs/redis_cluster"
 	_ "flashcat.cloud/categraf/inputs/redis_sentinel"
 	_ "flashcat.cloud/categraf/inputs/rocketmq_offset"
 	_ "flashcat.cloud/categraf/inputs/self_metrics"
+	_ "flashcat.cloud/categraf/inputs/sidecar"
 	_ "flashcat.cloud/categraf/inputs/smart"
 	_ "flashcat.cloud/categraf/inputs/snmp"
+	_ "flashcat.cloud/categraf/inputs/snmp_interface"
 	_ "flashcat.cloud/categraf/inputs/snmp_trap"
 	_ "flashcat.cloud/categraf/inputs/sockstat"
 	_ "flashcat.cloud/categraf/inputs/sqlserver"
 	_ "flashcat.cloud/categraf/inputs/supervisor"
 	_ "flashcat.cloud/categraf/inputs/switch_legacy"
+	_ "flashcat.cloud/categraf/inputs/syslog"
 	_ "flashcat.cloud/categraf/inputs/system"
 	_ "flashcat.cloud/categraf/inputs/systemd"
 	_ "flashcat.cloud/categraf/inputs/tengine"
 	_ "flashcat.cloud/categraf/inputs/tomcat"
 	_ "flashcat.cloud/categraf/inputs/traffic_server"
+	_ "flashcat.cloud/categraf/inputs/uwsgi"
 	_ "flashcat.cloud/categraf/inputs/vsphere"
 	_ "flashcat.cloud/categraf/inputs/whois"
 	_ "flashcat.cloud/categraf/inputs/xskyapi"
@@ -218,13 +232,32 @@ func (ma *MetricsAgent) start(idx int) error {
 }
 
 func (ma *MetricsAgent) Stop() error {
+	return ma.Drain(0)
+}
+
+// Drain stops the inputs like Stop, but waits at most timeout for the gathers
+// running before dropping the inputs, so their samples reach the writer queue
+func (ma *MetricsAgent) Drain(timeout time.Duration) error {
 	for idx := range ma.InputProviders {
 		ma.InputProviders[idx].StopReloader()
 	}
+
+	readers := make(map[string]map[string]*InputReader)
 	for name := range ma.InputReaders.Iter() {
 		inputs, _ := ma.InputReaders.GetInput(name)
+		readers[name] = inputs
+		for _, r := range inputs {
+			r.quit()
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for name, inputs := range readers {
 		for sum, r := range inputs {
-			r.Stop()
+			if timeout > 0 && !r.wait(deadline) {
+				log.Println("W! input:", name, "still gathering at shutdown, its samples are dropped")
+			}
+			r.drop()
 			ma.InputReaders.Del(name, sum)
 		}
 	}
@@ -266,6 +299,11 @@ func (ma *MetricsAgent) RegisterInput(name string, configs []cfg.ConfigWithForma
 
 func (ma *MetricsAgent) inputGo(name string, sum string, input inputs.Input) {
 	var err error
+	if inputs.MayDisabled(input) {
+		log.Println("I! input:", name, "disabled")
+		return
+	}
+
 	if err = input.InitInternalConfig(); err != nil {
 		log.Println("E! failed to init input:", name, "error:", err)
 		return
@@ -287,6 +325,10 @@ func (ma *MetricsAgent) inputGo(name string, sum string, input inputs.Input) {
 	if instances != nil {
 		empty := true
 		for i := 0; i < len(instances); i++ {
+			// not initialized, so never gathered
+			if inputs.MayDisabled(instances[i]) {
+				continue
+			}
 			if err := instances[i].InitInternalConfig(); err != nil {
 				log.Println("E! failed to init input:", name, "error:", err)
 				continue
@@ -13,35 +13,80 @@ import (
 	"flashcat.cloud/categraf/writer"
 )
 
+// counts the gathers of instances skipped since their previous one was still running
+const skippedMetric = "agent_input_gather_skipped_total"
+
 type InputReader struct {
 	inputName  string
+	inputKey   string
 	input      inputs.Input
 	quitChan   chan struct{}
 	runCounter uint64
-	waitGroup  sync.WaitGroup
+	backoff    backoff
+
+	// instances whose gather is still running, they are skipped by the next rounds
+	gathering   sync.Map
+	skipped     uint64
+	skippedOnce uint64
+	// gathers of the plugin and its instances running, waited for by shutdown
+	inflight int64
+
+	// debug logging of the input, switched at runtime by config.SetInputDebug
+	debug bool
+	// instances not switched to debug yet, since they were still gathering
+	debugPending []inputs.Instance
 }
 
 func newInputReader(inputName string, in inputs.Input) *InputReader {
+	_, inputKey := inputs.ParseInputName(inputName)
 	return &InputReader{
 		inputName: inputName,
+		inputKey:  inputKey,
 		input:     in,
 		quitChan:  make(chan struct{}, 1),
+		debug:     config.Config.DebugMode,
 	}
 }
 
 func (r *InputReader) Stop() {
+	r.quit()
+	r.drop()
+}
+
+// quit stops scheduling gathers, the ones running go on
+func (r *InputReader) quit() {
 	r.quitChan <- struct{}{}
+}
+
+func (r *InputReader) drop() {
 	inputs.MayDrop(r.input)
 }
 
+// wait waits until deadline for the gathers running, and reports if they are done
+func (r *InputReader) wait(deadline time.Time) bool {
+	for atomic.LoadInt64(&r.inflight) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
 func (r *InputReader) startInput() {
 	interval := config.GetInterval()
 	if r.input.GetInterval() > 0 {
 		interval = time.Duration(r.input.GetInterval())
 	}
-	timer := time.NewTimer(0 * time.Second)
+	global := config.Config.Global
+	offset := time.Duration(global.AlignOffset)
+
+	var start, tick time.Time
+	if global.AlignInterval {
+		tick = nextTick(time.Now(), interval, offset)
+	}
+	timer := time.NewTimer(time.Until(tick))
 	defer timer.Stop()
-	var start time.Time
 
 	for {
 		select {
@@ -50,17 +95,49 @@ func (r *InputReader) startInput() {
 			return
 		case <-timer.C:
 			start = time.Now()
-			if config.Config.DebugMode {
+			if r.applyDebug() {
 				log.Println("D!", r.inputName, ": before gather once")
 			}
 
-			r.gatherOnce()
+			// the time samples are stamped with, if align_timestamp is on
+			var stamp time.Time
+			switch {
+			case !global.AlignTimestamp:
+			case global.AlignInterval:
+				stamp = tick
+			default:
+				stamp = start.Round(interval)
+			}
+
+			opt := global.Backoff
+			timeout := interval
+			var probe usageProbe
+			if opt.Enable {
+				// the instances get the stretched interval, timed out at the base
+				// one they'd look fine again and the factor would swing back
+				timeout = r.backoff.stretch(interval)
+				probe = startUsage()
+			}
+			r.gatherOnce(timeout, stamp)
 
-			if config.Config.DebugMode {
+			if r.debug {
 				log.Println("D!", r.inputName, ": after gather once,", "duration:", time.Since(start))
 			}
 
-			next := interval - time.Since(start)
+			wait := interval
+			if opt.Enable {
+				u := probe.stop()
+				u.skipped = atomic.SwapUint64(&r.skippedOnce, 0)
+				wait = r.backoff.observe(r.inputName, interval, u, opt)
+				r.forward(r.backoff.samples(r.inputName, u))
+			}
+			if global.AlignInterval {
+				// an overrunning gather moves on to the next boundary
+				tick = nextTick(time.Now(), wait, offset)
+				timer.Reset(time.Until(tick))
+				continue
+			}
+			next := wait - time.Since(start)
 			if next < 0 {
 				next = 0
 			}
@@ -69,16 +146,69 @@ func (r *InputReader) startInput() {
 	}
 }
 
-func (r *InputReader) gatherOnce() {
+// applyDebug switches debug logging of the plugin and its instances between
+// gathers, when it was turned on or off at runtime. An instance left gathering
+// by a previous round still reads its DebugMod, so it's switched by a later round.
+func (r *InputReader) applyDebug() bool {
+	debug := config.InputDebug(r.inputKey)
+	if debug != r.debug {
+		r.debug = debug
+		if d, ok := r.input.(debugSetter); ok {
+			d.SetDebugMod(debug)
+		}
+		r.debugPending = inputs.MayGetInstances(r.input)
+		if debug {
+			log.Println("I!", r.inputName, ": debug logging enabled")
+		} else {
+			log.Println("I!", r.inputName, ": debug logging disabled")
+		}
+	}
+	if len(r.debugPending) == 0 {
+		return debug
+	}
+	var pending []inputs.Instance
+	for _, ins := range r.debugPending {
+		// held as gathering meanwhile, no gather of it can start
+		if _, running := r.gathering.LoadOrStore(ins, struct{}{}); running {
+			pending = append(pending, ins)
+			continue
+		}
+		if d, ok := ins.(debugSetter); ok {
+			d.SetDebugMod(debug)
+		}
+		r.gathering.Delete(ins)
+	}
+	r.debugPending = pending
+	return debug
+}
+
+type debugSetter interface {
+	SetDebugMod(bool)
+}
+
+// gatherOnce gathers the plugin and its instances, waiting at most timeout for
+// the instances. An instance still gathering then is left running and skipped
+// by the next rounds until it's done, so a slow target is never gathered twice
+// at the same time and doesn't hold back the other instances. Samples without
+// a timestamp get stamp unless it's zero.
+func (r *InputReader) gatherOnce(timeout time.Duration, stamp time.Time) {
+	atomic.AddInt64(&r.inflight, 1)
+	defer atomic.AddInt64(&r.inflight, -1)
 	defer func() {
 		if rc := recover(); rc != nil {
-			log.Println("E!", r.inputName, ": gather metrics panic:", r, string(runtimex.Stack(3)))
+			log.Println("E!", r.inputName, ": gather metrics panic:", rc, string(runtimex.Stack(3)))
+			slist := types.NewSampleList()
+			inputs.PushError(slist, "", inputs.ReasonPanic)
+			r.forward(slist)
 		}
 	}()
 
 	// plugin level, for system plugins
 	slist := types.NewSampleList()
 	inputs.MayGather(r.input, slist)
+	if !stamp.IsZero() {
+		stampSamples(slist, stamp)
+	}
 	r.forward(r.input.Process(slist))
 
 	instances := inputs.MayGetInstances(r.input)
@@ -88,19 +218,45 @@ func (r *InputReader) gatherOnce() {
 
 	concurrency := config.GetConcurrency()
 	concurrencyLimiter := make(chan struct{}, concurrency)
+	// closed at the timeout, instances still waiting for a slot give up
+	expired := make(chan struct{})
+	defer close(expired)
 
 	atomic.AddUint64(&r.runCounter, 1)
 
+	var wg sync.WaitGroup
+	started := make([]int, 0, len(instances))
 	for i := 0; i < len(instances); i++ {
 		if !instances[i].Initialized() {
 			continue
 		}
-		concurrencyLimiter <- struct{}{}
-		r.waitGroup.Add(1)
+		if _, running := r.gathering.LoadOrStore(instances[i], struct{}{}); running {
+			r.skip(i)
+			continue
+		}
+		started = append(started, i)
+		wg.Add(1)
+		atomic.AddInt64(&r.inflight, 1)
 		go func(ins inputs.Instance) {
 			defer func() {
-				r.waitGroup.Done()
-				<- concurrencyLimiter
+				atomic.AddInt64(&r.inflight, -1)
+				r.gathering.Delete(ins)
+				wg.Done()
+			}()
+			// acquired here, so hung instances holding the slots can't block the round
+			select {
+			case concurrencyLimiter <- struct{}{}:
+				defer func() { <-concurrencyLimiter }()
+			case <-expired:
+				return
+			}
+			defer func() {
+				if rc := recover(); rc != nil {
+					log.Println("E!", r.inputName, ": gather instance panic:", rc, string(runtimex.Stack(3)))
+					slist := types.NewSampleList()
+					inputs.PushError(slist, "", inputs.ReasonPanic)
+					r.forward(ins.Process(slist))
+				}
 			}()
 
 			it := ins.GetIntervalTimes()
@@ -113,11 +269,58 @@ func (r *InputReader) gatherOnce() {
 
 			insList := types.NewSampleList()
 			inputs.MayGather(ins, insList)
+			if insList.Len() == 0 {
+				// most inputs only log their failures, a gather without
+				// samples is the sign of them the agent can see
+				slist := types.NewSampleList()
+				inputs.PushError(slist, inputs.InstanceLabel(ins), inputs.ReasonNoSamples)
+				r.forward(slist)
+				return
+			}
+			if !stamp.IsZero() {
+				stampSamples(insList, stamp)
+			}
 			r.forward(ins.Process(insList))
 		}(instances[i])
 	}
 
-	r.waitGroup.Wait()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		r.forward(r.timedOut(instances, started))
+	}
+
+	if skipped := atomic.LoadUint64(&r.skipped); skipped > 0 {
+		slist := types.NewSampleList()
+		slist.PushFront(types.NewSample("", skippedMetric, skipped, map[string]string{"input": r.inputName}).SetType(types.Counter))
+		r.forward(slist)
+	}
+}
+
+// timedOut reports the instances of started still gathering as agent_input_error,
+// so a hung target is alertable whatever the input logs
+func (r *InputReader) timedOut(instances []inputs.Instance, started []int) *types.SampleList {
+	slist := types.NewSampleList()
+	for _, i := range started {
+		if _, running := r.gathering.Load(instances[i]); running {
+			label := inputs.InstanceLabel(instances[i])
+			log.Printf("W! %s: instance #%d (%s) is not gathered in time", r.inputName, i, label)
+			inputs.PushError(slist, label, inputs.ReasonTimeout)
+		}
+	}
+	return slist
+}
+
+// skip counts a gather of instance #i not started since its previous one is still running
+func (r *InputReader) skip(i int) {
+	atomic.AddUint64(&r.skipped, 1)
+	atomic.AddUint64(&r.skippedOnce, 1)
+	log.Printf("W! %s: instance #%d is still gathering, skipped", r.inputName, i)
 }
 
 func (r *InputReader) forward(slist *types.SampleList) {
@@ -125,5 +328,10 @@ func (r *InputReader) forward(slist *types.SampleList) {
 		return
 	}
 	arr := slist.PopBackAll()
+	for _, s := range arr {
+		if s.Metric == inputs.ErrorMetric && s.Labels["input"] == "" {
+			s.Labels["input"] = r.inputName
+		}
+	}
 	writer.WriteSamples(arr)
 }
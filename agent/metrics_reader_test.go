@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+type slowInstance struct {
+	config.InstanceConfig
+	Targets  []string
+	release  chan struct{}
+	gathered int32
+}
+
+func (ins *slowInstance) Gather(slist *types.SampleList) {
+	atomic.AddInt32(&ins.gathered, 1)
+	<-ins.release
+}
+
+type slowPlugin struct {
+	inputs.PluginBase[*slowInstance]
+}
+
+func (p *slowPlugin) Clone() inputs.Input { return &slowPlugin{} }
+func (p *slowPlugin) Name() string        { return "slow" }
+
+func TestGatherOnceSkipsRunning(t *testing.T) {
+	config.Config = &config.ConfigType{TestMode: true}
+	defer func() { config.Config = nil }()
+
+	slow := &slowInstance{release: make(chan struct{})}
+	fast := &slowInstance{release: make(chan struct{})}
+	close(fast.release)
+	slow.SetInitialized()
+	fast.SetInitialized()
+
+	p := &slowPlugin{}
+	p.Instances = []*slowInstance{slow, fast}
+	r := newInputReader("slow", p)
+
+	r.gatherOnce(50*time.Millisecond, time.Time{})
+	r.gatherOnce(50*time.Millisecond, time.Time{})
+	require.Equal(t, int32(1), atomic.LoadInt32(&slow.gathered))
+	require.Equal(t, int32(2), atomic.LoadInt32(&fast.gathered))
+	require.Equal(t, uint64(1), atomic.LoadUint64(&r.skipped))
+
+	close(slow.release)
+	require.Eventually(t, func() bool {
+		_, running := r.gathering.Load(slow)
+		return !running
+	}, time.Second, 5*time.Millisecond)
+	r.gatherOnce(50*time.Millisecond, time.Time{})
+	require.Equal(t, int32(2), atomic.LoadInt32(&slow.gathered))
+}
+
+func TestTimedOut(t *testing.T) {
+	config.Config = &config.ConfigType{TestMode: true}
+	defer func() { config.Config = nil }()
+
+	slow := &slowInstance{Targets: []string{"10.0.0.1:9100"}, release: make(chan struct{})}
+	fast := &slowInstance{release: make(chan struct{})}
+	p := &slowPlugin{}
+	p.Instances = []*slowInstance{fast, slow}
+	r := newInputReader("slow", p)
+	instances := p.GetInstances()
+
+	r.gathering.Store(instances[1], struct{}{})
+	samples := r.timedOut(instances, []int{0, 1}).PopBackAll()
+	require.Len(t, samples, 1)
+	require.Equal(t, inputs.ErrorMetric, samples[0].Metric)
+	require.Equal(t, "10.0.0.1:9100", samples[0].Labels["instance"])
+	require.Equal(t, inputs.ReasonTimeout, samples[0].Labels["reason"])
+}
+
+func TestGatherOnceHungOverConcurrency(t *testing.T) {
+	config.Config = &config.ConfigType{TestMode: true}
+	config.Config.Global.Concurrency = 1
+	defer func() { config.Config = nil }()
+
+	release := make(chan struct{})
+	p := &slowPlugin{}
+	for i := 0; i < 3; i++ {
+		ins := &slowInstance{release: release}
+		ins.SetInitialized()
+		p.Instances = append(p.Instances, ins)
+	}
+	r := newInputReader("slow", p)
+
+	done := make(chan struct{})
+	go func() {
+		r.gatherOnce(50*time.Millisecond, time.Time{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the round is blocked by the hung instances")
+	}
+	// only the instance holding the slot started, the others gave up
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&r.inflight) == 1 }, time.Second, 5*time.Millisecond)
+	gathered := 0
+	for _, ins := range p.Instances {
+		gathered += int(atomic.LoadInt32(&ins.gathered))
+	}
+	require.Equal(t, 1, gathered)
+
+	close(release)
+	require.True(t, r.wait(time.Now().Add(time.Second)))
+}
+
+func TestApplyDebug(t *testing.T) {
+	config.Config = &config.ConfigType{TestMode: true}
+	defer func() { config.Config = nil }()
+
+	configured := &slowInstance{}
+	configured.DebugMod = true
+	busy := &slowInstance{}
+	p := &slowPlugin{}
+	p.Instances = []*slowInstance{configured, busy}
+	r := newInputReader("slow", p)
+	instances := p.GetInstances()
+
+	// a gather of busy is left running by the previous round
+	r.gathering.Store(instances[1], struct{}{})
+	config.SetInputDebug("slow", time.Minute)
+	defer config.ClearInputDebug("slow")
+	require.True(t, r.applyDebug())
+	require.True(t, configured.DebugMod)
+	require.False(t, busy.DebugMod)
+
+	r.gathering.Delete(instances[1])
+	r.applyDebug()
+	require.True(t, busy.DebugMod)
+
+	// switched off, the instances go back to what they were configured with
+	config.ClearInputDebug("slow")
+	require.False(t, r.applyDebug())
+	require.True(t, configured.DebugMod)
+	require.False(t, busy.DebugMod)
+}
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/heartbeat"
+	"flashcat.cloud/categraf/writer"
+)
+
+// drainer is an agent module waiting for its work in flight when stopping
+type drainer interface {
+	Drain(timeout time.Duration) error
+}
+
+// Shutdown stops the agent for good: the inputs stop gathering, the gathers
+// running get gather_timeout to finish, the writer queue gets flush_timeout
+// to drain, then the shutdown hooks run in order
+func (a *Agent) Shutdown() {
+	conf := config.Config.Shutdown
+	log.Println("I! agent shutting down")
+	for _, agent := range a.agents {
+		if agent == nil {
+			continue
+		}
+		var err error
+		if d, ok := agent.(drainer); ok {
+			err = d.Drain(time.Duration(conf.GatherTimeout))
+		} else {
+			err = agent.Stop()
+		}
+		if err != nil {
+			log.Printf("E! stop [%T] err: [%+v]", agent, err)
+		} else {
+			log.Printf("I! [%T] stopped", agent)
+		}
+	}
+
+	begun := time.Now()
+	if writer.Flush(time.Duration(conf.FlushTimeout)) {
+		log.Println("I! writer queue flushed, cost:", time.Since(begun))
+	} else {
+		log.Println("W! writer queue not flushed in", time.Duration(conf.FlushTimeout), "the series left are dropped")
+	}
+
+	for _, h := range conf.Hooks {
+		if err := runHook(h); err != nil {
+			log.Println("E! shutdown hook", h.Name, "failed:", err)
+		} else {
+			log.Println("I! shutdown hook", h.Name, "done")
+		}
+	}
+	log.Println("I! agent shut down")
+}
+
+func runHook(h config.ShutdownHook) error {
+	timeout := time.Duration(h.Timeout)
+	switch h.Type {
+	case "heartbeat":
+		return heartbeat.GoingDown(timeout)
+	case "exec":
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v, output: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	req, err := http.NewRequest(h.Method, h.Url, strings.NewReader(h.Body))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(h.Headers); i += 2 {
+		req.Header.Add(h.Headers[i], h.Headers[i+1])
+		if h.Headers[i] == "Host" {
+			req.Host = h.Headers[i+1]
+		}
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status code: %d, response: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestRunHook(t *testing.T) {
+	var method, body, token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, token = r.Method, r.Header.Get("X-Consul-Token")
+		bs, _ := io.ReadAll(r.Body)
+		body = string(bs)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	h := config.ShutdownHook{
+		Type:    "http",
+		Url:     ts.URL + "/v1/agent/service/deregister/categraf",
+		Method:  http.MethodPut,
+		Headers: []string{"X-Consul-Token", "secret"},
+		Body:    "bye",
+		Timeout: config.Duration(time.Second),
+	}
+	require.NoError(t, runHook(h))
+	require.Equal(t, http.MethodPut, method)
+	require.Equal(t, "secret", token)
+	require.Equal(t, "bye", body)
+
+	h.Url = ts.URL + "/fail"
+	require.Error(t, runHook(h))
+
+	require.NoError(t, runHook(config.ShutdownHook{Type: "exec", Command: []string{"true"}, Timeout: config.Duration(time.Second)}))
+	require.Error(t, runHook(config.ShutdownHook{Type: "exec", Command: []string{"false"}, Timeout: config.Duration(time.Second)}))
+}
+
+func TestReaderWaitsGathers(t *testing.T) {
+	config.Config = &config.ConfigType{TestMode: true}
+	defer func() { config.Config = nil }()
+
+	slow := &slowInstance{release: make(chan struct{})}
+	slow.SetInitialized()
+	p := &slowPlugin{}
+	p.Instances = []*slowInstance{slow}
+	r := newInputReader("slow", p)
+
+	r.gatherOnce(10*time.Millisecond, time.Time{})
+	require.False(t, r.wait(time.Now().Add(20*time.Millisecond)))
+
+	close(slow.release)
+	require.True(t, r.wait(time.Now().Add(time.Second)))
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/writer"
+)
+
+// getCardinality reports the metric names and label keys with the most series,
+// e.g. GET /api/cardinality?k=20
+func getCardinality(c *gin.Context) {
+	k := config.Config.Cardinality.TopK
+	if s := c.Query("k"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			c.String(http.StatusBadRequest, "invalid k: %s", s)
+			return
+		}
+		k = n
+	}
+	c.JSON(http.StatusOK, writer.CardinalityStatus(k))
+}
+
+// startCardinality tracks the written series for a while, e.g. PUT /api/cardinality?ttl=30m
+func startCardinality(c *gin.Context) {
+	ttl, ok := debugTTL(c)
+	if !ok {
+		return
+	}
+	writer.StartCardinality(ttl)
+	getCardinality(c)
+}
+
+func stopCardinality(c *gin.Context) {
+	writer.StopCardinality()
+	getCardinality(c)
+}
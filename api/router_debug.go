@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/pprof"
+)
+
+const (
+	defaultDebugTTL = 10 * time.Minute
+	maxDebugTTL     = 24 * time.Hour
+)
+
+type debugStatus struct {
+	// inputs logging at debug level and when it ends, * stands for all of them
+	Inputs map[string]time.Time `json:"inputs"`
+	Pprof  pprof.Status         `json:"pprof"`
+}
+
+func getDebug(c *gin.Context) {
+	c.JSON(http.StatusOK, debugStatus{
+		Inputs: config.InputDebugStatus(),
+		Pprof:  pprof.GetStatus(),
+	})
+}
+
+// debugTTL reads ?ttl=, default 10m and at most 24h
+func debugTTL(c *gin.Context) (time.Duration, bool) {
+	s := c.Query("ttl")
+	if s == "" {
+		return defaultDebugTTL, true
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		c.String(http.StatusBadRequest, "invalid ttl: %s", s)
+		return 0, false
+	}
+	if ttl > maxDebugTTL {
+		ttl = maxDebugTTL
+	}
+	return ttl, true
+}
+
+// setInputDebug turns on debug logging of an input, e.g.
+// PUT /api/debug/inputs/mysql?ttl=30m, the name * means all inputs
+func setInputDebug(c *gin.Context) {
+	ttl, ok := debugTTL(c)
+	if !ok {
+		return
+	}
+	config.SetInputDebug(c.Param("name"), ttl)
+	getDebug(c)
+}
+
+func deleteInputDebug(c *gin.Context) {
+	config.ClearInputDebug(c.Param("name"))
+	getDebug(c)
+}
+
+// startPprof serves /debug/pprof on a random local port for ttl, the address
+// is in the response
+func startPprof(c *gin.Context) {
+	ttl, ok := debugTTL(c)
+	if !ok {
+		return
+	}
+	if _, err := pprof.Start(ttl); err != nil {
+		c.String(http.StatusInternalServerError, "failed to start pprof: %v", err)
+		return
+	}
+	getDebug(c)
+}
+
+func stopPprof(c *gin.Context) {
+	pprof.Stop()
+	getDebug(c)
+}
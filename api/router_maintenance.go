@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"flashcat.cloud/categraf/writer"
+)
+
+type maintenanceStatus struct {
+	Active           bool    `json:"active"`
+	RemainingSeconds float64 `json:"remaining_seconds"`
+	writer.Maintenance
+}
+
+func getMaintenance(c *gin.Context) {
+	m, on := writer.MaintenanceStatus()
+	status := maintenanceStatus{Active: on, Maintenance: m}
+	if on {
+		status.RemainingSeconds = time.Until(m.Until).Seconds()
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// setMaintenance starts a maintenance, e.g. PUT /api/maintenance?ttl=2h&reason=kernel+upgrade
+func setMaintenance(c *gin.Context) {
+	var ttl time.Duration
+	if s := c.Query("ttl"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			c.String(http.StatusBadRequest, "invalid ttl: %s", s)
+			return
+		}
+		ttl = d
+	}
+	writer.SetMaintenance(ttl, c.Query("reason"))
+	getMaintenance(c)
+}
+
+func deleteMaintenance(c *gin.Context) {
+	writer.ClearMaintenance()
+	getMaintenance(c)
+}
@@ -17,8 +17,14 @@ func remoteWrite(c *gin.Context) {
 		return
 	}
 
+	writer.RecordMetadata(req.Metadata)
+
 	count := len(req.Timeseries)
 	if count == 0 {
+		if len(req.Metadata) != 0 {
+			c.String(200, "metadata recorded")
+			return
+		}
 		c.String(http.StatusBadRequest, "payload empty")
 		return
 	}
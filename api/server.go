@@ -3,6 +3,7 @@ package api
 import (
 	"crypto/tls"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -11,6 +12,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/pkg/aop"
+	"flashcat.cloud/categraf/pkg/auth"
 )
 
 func Start() {
@@ -39,7 +41,15 @@ func Start() {
 		r.Use(aop.Logger())
 	}
 
-	configRoutes(r)
+	if conf.TLSCert == "" && conf.TLSKey == "" {
+		conf.TLSCert, conf.TLSKey = conf.CertFile, conf.KeyFile
+	}
+	if err := conf.InitServerConfig(); err != nil {
+		log.Println("E! failed to init http server auth config:", err)
+		return
+	}
+
+	configRoutes(r, &conf.ServerConfig)
 
 	srv := &http.Server{
 		Addr:         conf.Address,
@@ -52,9 +62,13 @@ func Start() {
 	log.Println("I! http server listening on:", conf.Address)
 
 	var err error
-	if conf.CertFile != "" && conf.KeyFile != "" {
-		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
-		err = srv.ListenAndServeTLS(conf.CertFile, conf.KeyFile)
+	if tlsConfig := conf.ServerTLSConfig(); tlsConfig != nil {
+		if tlsConfig.MinVersion == 0 {
+			tlsConfig.MinVersion = tls.VersionTLS12
+		}
+		srv.TLSConfig = tlsConfig
+		// certificates are already loaded into tlsConfig
+		err = srv.ListenAndServeTLS("", "")
 	} else {
 		err = srv.ListenAndServe()
 	}
@@ -64,12 +78,12 @@ func Start() {
 	}
 }
 
-func configRoutes(r *gin.Engine) {
+func configRoutes(r *gin.Engine, ac *auth.ServerConfig) {
 	r.GET("/ping", func(c *gin.Context) {
 		c.String(200, "pong")
 	})
 
-	g := r.Group("/api/push")
+	g := r.Group("/api/push", authorize(ac))
 	g.POST("/opentsdb", openTSDB)
 	g.POST("/openfalcon", openFalcon)
 	g.POST("/remotewrite", remoteWrite)
@@ -80,4 +94,61 @@ func configRoutes(r *gin.Engine) {
 	g.POST("/pushgateway/metrics/:jobtype/:job", pushgateway)
 	g.PUT("/pushgateway/metrics/:jobtype/:job/*labels", pushgateway)
 	g.POST("/pushgateway/metrics/:jobtype/:job/*labels", pushgateway)
+
+	// silence the host during planned maintenance
+	admin := authorizeAdmin(ac)
+	m := r.Group("/api/maintenance", authorize(ac))
+	m.GET("", getMaintenance)
+	m.PUT("", admin, setMaintenance)
+	m.POST("", admin, setMaintenance)
+	m.DELETE("", admin, deleteMaintenance)
+
+	// debug logging of inputs and pprof, switched on for a while without a restart
+	d := r.Group("/api/debug", authorize(ac))
+	d.GET("", getDebug)
+	d.PUT("/inputs/:name", admin, setInputDebug)
+	d.POST("/inputs/:name", admin, setInputDebug)
+	d.DELETE("/inputs/:name", admin, deleteInputDebug)
+	d.PUT("/pprof", admin, startPprof)
+	d.POST("/pprof", admin, startPprof)
+	d.DELETE("/pprof", admin, stopPprof)
+
+	// unique series by metric name and label key, to find what drives the cardinality
+	cr := r.Group("/api/cardinality", authorize(ac))
+	cr.GET("", getCardinality)
+	cr.PUT("", admin, startCardinality)
+	cr.POST("", admin, startCardinality)
+	cr.DELETE("", admin, stopCardinality)
+}
+
+// authorizeAdmin guards the routes changing the state of the agent: without
+// credentials, client certificates or allowed_ips only loopback clients may use them
+func authorizeAdmin(ac *auth.ServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ac.CredentialsRequired() || len(ac.TLSAllowedCACerts) > 0 || len(ac.AllowedIPs) > 0 {
+			c.Next()
+			return
+		}
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorize rejects requests not matching allowed_ips or without valid credentials
+func authorize(ac *auth.ServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code, ok := ac.Authorize(c.Request)
+		if ok {
+			c.Next()
+			return
+		}
+		if code == http.StatusUnauthorized && ac.BasicAuthUser != "" {
+			c.Header("WWW-Authenticate", `Basic realm="categraf"`)
+		}
+		c.AbortWithStatus(code)
+	}
 }
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/pkg/auth"
+)
+
+func TestAuthorizeAdmin(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	serve := func(ac *auth.ServerConfig, remoteAddr string) int {
+		r := gin.New()
+		r.PUT("/admin", authorizeAdmin(ac), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		req := httptest.NewRequest(http.MethodPut, "/admin", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	open := &auth.ServerConfig{}
+	require.Equal(t, http.StatusNoContent, serve(open, "127.0.0.1:1234"))
+	require.Equal(t, http.StatusNoContent, serve(open, "[::1]:1234"))
+	require.Equal(t, http.StatusForbidden, serve(open, "10.0.0.1:1234"))
+
+	// authorize checks the credentials and addresses then
+	require.Equal(t, http.StatusNoContent, serve(&auth.ServerConfig{BearerTokens: []string{"x"}}, "10.0.0.1:1234"))
+	require.Equal(t, http.StatusNoContent, serve(&auth.ServerConfig{IPFilter: auth.IPFilter{AllowedIPs: []string{"10.0.0.0/8"}}}, "10.0.0.1:1234"))
+}
+
+func TestAdminRoutes(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	configRoutes(r, &auth.ServerConfig{})
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPut, "/api/maintenance"},
+		{http.MethodPost, "/api/maintenance"},
+		{http.MethodDelete, "/api/maintenance"},
+		{http.MethodPut, "/api/debug/inputs/mysql"},
+		{http.MethodDelete, "/api/debug/inputs/mysql"},
+		{http.MethodPut, "/api/debug/pprof"},
+		{http.MethodDelete, "/api/debug/pprof"},
+		{http.MethodPut, "/api/cardinality"},
+		{http.MethodPost, "/api/cardinality"},
+		{http.MethodDelete, "/api/cardinality"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusForbidden, w.Code, "%s %s", route.method, route.path)
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A config bundle is the whole config directory in one file, either a tar.gz
+// or a toml file holding the files as strings:
+//
+//	[files]
+//	"config.toml" = '''
+//	[global]
+//	interval = 15
+//	'''
+//	"input.cpu/cpu.toml" = '''
+//	collect_per_cpu = false
+//	'''
+type bundleFile struct {
+	Files map[string]string `toml:"files"`
+}
+
+// ExtractBundle verifies the checksum of bundle and unpacks it into dir,
+// replacing the content of dir. The expected sha256 is sum, or the first
+// field of <bundle>.sha256 if sum is empty; without both the bundle is not
+// verified. It returns the sha256 of the bundle.
+func ExtractBundle(bundle, sum, dir string) (string, error) {
+	data, err := os.ReadFile(bundle)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	actual := hex.EncodeToString(digest[:])
+
+	if sum == "" {
+		if bs, err := os.ReadFile(bundle + ".sha256"); err == nil {
+			if fields := strings.Fields(string(bs)); len(fields) > 0 {
+				sum = fields[0]
+			}
+		}
+	}
+	if sum != "" && !strings.EqualFold(sum, actual) {
+		return actual, fmt.Errorf("checksum mismatch of config bundle %s: expected %s, got %s", bundle, sum, actual)
+	}
+
+	var files map[string][]byte
+	switch {
+	case strings.HasSuffix(bundle, ".toml"):
+		files, err = readTomlBundle(data)
+	case strings.HasSuffix(bundle, ".tar.gz"), strings.HasSuffix(bundle, ".tgz"):
+		files, err = readTarBundle(data)
+	default:
+		err = fmt.Errorf("config bundle should be a .tar.gz, .tgz or .toml file")
+	}
+	if err != nil {
+		return actual, fmt.Errorf("failed to read config bundle %s: %v", bundle, err)
+	}
+
+	files = stripBundlePrefix(files)
+	if _, has := files["config.toml"]; !has {
+		return actual, fmt.Errorf("config bundle %s has no config.toml", bundle)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return actual, err
+	}
+	for name, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return actual, err
+		}
+		if err := os.WriteFile(target, content, 0600); err != nil {
+			return actual, err
+		}
+	}
+	return actual, nil
+}
+
+func readTomlBundle(data []byte) (map[string][]byte, error) {
+	var b bundleFile
+	if _, err := toml.Decode(string(data), &b); err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(b.Files))
+	for name, content := range b.Files {
+		clean, err := cleanBundlePath(name)
+		if err != nil {
+			return nil, err
+		}
+		files[clean] = []byte(content)
+	}
+	return files, nil
+}
+
+func readTarBundle(data []byte) (map[string][]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		clean, err := cleanBundlePath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[clean] = content
+	}
+	return files, nil
+}
+
+// cleanBundlePath rejects paths leaving the config directory
+func cleanBundlePath(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid path in config bundle: %s", name)
+	}
+	return clean, nil
+}
+
+// stripBundlePrefix removes the top directory of archives like conf/config.toml
+func stripBundlePrefix(files map[string][]byte) map[string][]byte {
+	if _, has := files["config.toml"]; has {
+		return files
+	}
+	prefix := ""
+	for name := range files {
+		i := strings.Index(name, "/")
+		if i < 0 {
+			return files
+		}
+		if prefix == "" {
+			prefix = name[:i+1]
+		} else if !strings.HasPrefix(name, prefix) {
+			return files
+		}
+	}
+	ret := make(map[string][]byte, len(files))
+	for name, content := range files {
+		ret[strings.TrimPrefix(name, prefix)] = content
+	}
+	return ret
+}
+
+// PackBundle writes the files under dir as a tar.gz bundle to w, in a stable
+// order, so the same config always has the same checksum
+func PackBundle(dir string, w io.Writer) error {
+	var names []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
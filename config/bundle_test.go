@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	tmp := t.TempDir()
+	conf := filepath.Join(tmp, "conf")
+	require.NoError(t, os.MkdirAll(filepath.Join(conf, "input.cpu"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(conf, "config.toml"), []byte("[global]\ninterval = 15\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(conf, "input.cpu", "cpu.toml"), []byte("collect_per_cpu = true\n"), 0644))
+
+	var buf bytes.Buffer
+	require.NoError(t, PackBundle(conf, &buf))
+	var again bytes.Buffer
+	require.NoError(t, PackBundle(conf, &again))
+	require.Equal(t, buf.Bytes(), again.Bytes())
+
+	bundle := filepath.Join(tmp, "conf.tar.gz")
+	require.NoError(t, os.WriteFile(bundle, buf.Bytes(), 0644))
+	digest := sha256.Sum256(buf.Bytes())
+	sum := hex.EncodeToString(digest[:])
+
+	dir := filepath.Join(tmp, "bundle")
+	got, err := ExtractBundle(bundle, sum, dir)
+	require.NoError(t, err)
+	require.Equal(t, sum, got)
+	bs, err := os.ReadFile(filepath.Join(dir, "input.cpu", "cpu.toml"))
+	require.NoError(t, err)
+	require.Equal(t, "collect_per_cpu = true\n", string(bs))
+
+	// the checksum file is used without an explicit sum
+	require.NoError(t, os.WriteFile(bundle+".sha256", []byte("0000  conf.tar.gz\n"), 0644))
+	_, err = ExtractBundle(bundle, "", dir)
+	require.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestTomlBundle(t *testing.T) {
+	tmp := t.TempDir()
+	bundle := filepath.Join(tmp, "categraf.toml")
+	require.NoError(t, os.WriteFile(bundle, []byte(`
+[files]
+"conf/config.toml" = '''
+[global]
+interval = 15
+'''
+"conf/input.mem/mem.toml" = '''
+collect_platform_fields = true
+'''
+`), 0644))
+
+	dir := filepath.Join(tmp, "bundle")
+	_, err := ExtractBundle(bundle, "", dir)
+	require.NoError(t, err)
+	bs, err := os.ReadFile(filepath.Join(dir, "config.toml"))
+	require.NoError(t, err)
+	require.Equal(t, "[global]\ninterval = 15\n", string(bs))
+	require.FileExists(t, filepath.Join(dir, "input.mem", "mem.toml"))
+
+	require.NoError(t, os.WriteFile(bundle, []byte("[files]\n\"../config.toml\" = \"\"\n"), 0644))
+	_, err = ExtractBundle(bundle, "", dir)
+	require.Error(t, err)
+}
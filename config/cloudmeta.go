@@ -0,0 +1,300 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	cloudAWS    = "aws"
+	cloudGCP    = "gcp"
+	cloudAzure  = "azure"
+	cloudAliyun = "aliyun"
+
+	cloudProviderLabelKey = "cloud_provider"
+)
+
+// metadata endpoints, variables so that tests can point them to a local server
+var (
+	awsMetadataURL    = "http://169.254.169.254"
+	gcpMetadataURL    = "http://metadata.google.internal"
+	azureMetadataURL  = "http://169.254.169.254"
+	aliyunMetadataURL = "http://100.100.100.200"
+)
+
+// CloudMetadata detects the cloud the agent runs on from the instance metadata
+// endpoint and attaches instance_id, region, zone and instance_type to all samples
+type CloudMetadata struct {
+	Enable bool `toml:"enable"`
+	// providers by preference, empty means aws, gcp, azure, aliyun
+	Providers []string `toml:"providers"`
+	// timeout of the detection, the providers are probed at once and the
+	// first one in order found within it wins, default 2s
+	Timeout Duration `toml:"timeout"`
+	// metadata is fetched again every refresh_interval, default 1h, negative disables refreshing
+	RefreshInterval Duration `toml:"refresh_interval"`
+}
+
+type CloudMetaCache struct {
+	labels map[string]string
+	sync.RWMutex
+}
+
+var CloudMeta = &CloudMetaCache{}
+
+func (c *CloudMetaCache) GetLabels() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.labels
+}
+
+func (c *CloudMetaCache) SetLabels(labels map[string]string) {
+	c.Lock()
+	c.labels = labels
+	c.Unlock()
+}
+
+type cloudDetector func(ctx context.Context, cli *http.Client) (map[string]string, error)
+
+var cloudDetectors = map[string]cloudDetector{
+	cloudAWS:    detectAWS,
+	cloudGCP:    detectGCP,
+	cloudAzure:  detectAzure,
+	cloudAliyun: detectAliyun,
+}
+
+func InitCloudMetadata() error {
+	cm := Config.CloudMetadata
+	if cm == nil || !cm.Enable {
+		return nil
+	}
+	if len(cm.Providers) == 0 {
+		cm.Providers = []string{cloudAWS, cloudGCP, cloudAzure, cloudAliyun}
+	}
+	for _, p := range cm.Providers {
+		if _, has := cloudDetectors[p]; !has {
+			return fmt.Errorf("unknown cloud_metadata provider %q", p)
+		}
+	}
+	if cm.Timeout <= 0 {
+		cm.Timeout = Duration(2 * time.Second)
+	}
+	if cm.RefreshInterval == 0 {
+		cm.RefreshInterval = Duration(time.Hour)
+	}
+
+	provider := cm.refresh(cm.Providers)
+	if provider == "" {
+		log.Println("W! cloud_metadata: no cloud provider detected, labels will not be added")
+		return nil
+	}
+	log.Println("I! cloud_metadata: running on", provider)
+
+	if cm.RefreshInterval > 0 {
+		go func() {
+			for {
+				time.Sleep(time.Duration(cm.RefreshInterval))
+				cm.refresh([]string{provider})
+			}
+		}()
+	}
+	return nil
+}
+
+// refresh probes the providers at once and returns the first one in order
+// detected within the timeout, so startup is held back by one timeout at most
+func (cm *CloudMetadata) refresh(providers []string) string {
+	// metadata endpoints are link-local, never go through a proxy
+	cli := &http.Client{Transport: &http.Transport{Proxy: nil}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cm.Timeout))
+	defer cancel()
+
+	found := make([]map[string]string, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			labels, err := cloudDetectors[p](ctx, cli)
+			if err != nil {
+				if Config.DebugMode {
+					log.Printf("D! cloud_metadata: %s not detected: %v", p, err)
+				}
+				return
+			}
+			found[i] = labels
+		}(i, p)
+	}
+	wg.Wait()
+	cli.CloseIdleConnections()
+
+	for i, labels := range found {
+		if labels == nil {
+			continue
+		}
+		labels[cloudProviderLabelKey] = providers[i]
+		for k, v := range labels {
+			if v == "" {
+				delete(labels, k)
+			}
+		}
+		CloudMeta.SetLabels(labels)
+		return providers[i]
+	}
+	return ""
+}
+
+func cloudMetaGet(ctx context.Context, cli *http.Client, url string, headers map[string]string) ([]byte, error) {
+	return cloudMetaDo(ctx, cli, http.MethodGet, url, headers)
+}
+
+func cloudMetaDo(ctx context.Context, cli *http.Client, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: unexpected status %s", method, url, resp.Status)
+	}
+	return body, nil
+}
+
+func detectAWS(ctx context.Context, cli *http.Client) (map[string]string, error) {
+	headers := map[string]string{}
+	// prefer IMDSv2, fall back to IMDSv1 if no token can be obtained
+	token, err := cloudMetaDo(ctx, cli, http.MethodPut, awsMetadataURL+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "21600"})
+	if err == nil {
+		headers["X-aws-ec2-metadata-token"] = string(token)
+	}
+	body, err := cloudMetaGet(ctx, cli, awsMetadataURL+"/latest/dynamic/instance-identity/document", headers)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		InstanceID       string `json:"instanceId"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceType     string `json:"instanceType"`
+	}
+	if err := jsoniter.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if doc.InstanceID == "" {
+		return nil, fmt.Errorf("empty instance id in identity document")
+	}
+	return map[string]string{
+		"instance_id":   doc.InstanceID,
+		"region":        doc.Region,
+		"zone":          doc.AvailabilityZone,
+		"instance_type": doc.InstanceType,
+	}, nil
+}
+
+func detectGCP(ctx context.Context, cli *http.Client) (map[string]string, error) {
+	body, err := cloudMetaGet(ctx, cli, gcpMetadataURL+"/computeMetadata/v1/instance/?recursive=true",
+		map[string]string{"Metadata-Flavor": "Google"})
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		ID          jsoniter.Number `json:"id"`
+		Zone        string          `json:"zone"`
+		MachineType string          `json:"machineType"`
+	}
+	if err := jsoniter.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if doc.ID == "" {
+		return nil, fmt.Errorf("empty instance id in metadata")
+	}
+	// zone and machineType are resource paths, e.g. projects/123/zones/us-central1-a
+	zone := lastPathElement(doc.Zone)
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return map[string]string{
+		"instance_id":   doc.ID.String(),
+		"region":        region,
+		"zone":          zone,
+		"instance_type": lastPathElement(doc.MachineType),
+	}, nil
+}
+
+func detectAzure(ctx context.Context, cli *http.Client) (map[string]string, error) {
+	body, err := cloudMetaGet(ctx, cli, azureMetadataURL+"/metadata/instance/compute?api-version=2021-02-01",
+		map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		VMID     string `json:"vmId"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMSize   string `json:"vmSize"`
+	}
+	if err := jsoniter.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if doc.VMID == "" {
+		return nil, fmt.Errorf("empty vm id in metadata")
+	}
+	return map[string]string{
+		"instance_id":   doc.VMID,
+		"region":        doc.Location,
+		"zone":          doc.Zone,
+		"instance_type": doc.VMSize,
+	}, nil
+}
+
+func detectAliyun(ctx context.Context, cli *http.Client) (map[string]string, error) {
+	labels := make(map[string]string)
+	// instance_id first, without it the others are not fetched
+	for _, item := range []struct{ key, path string }{
+		{"instance_id", "instance-id"},
+		{"region", "region-id"},
+		{"zone", "zone-id"},
+		{"instance_type", "instance/instance-type"},
+	} {
+		body, err := cloudMetaGet(ctx, cli, aliyunMetadataURL+"/latest/meta-data/"+item.path, nil)
+		if err != nil {
+			if item.key == "instance_id" {
+				return nil, err
+			}
+			continue
+		}
+		labels[item.key] = strings.TrimSpace(string(body))
+	}
+	if labels["instance_id"] == "" {
+		return nil, fmt.Errorf("empty instance id in metadata")
+	}
+	return labels, nil
+}
+
+func lastPathElement(s string) string {
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudMetadataAWS(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Write([]byte("token"))
+		case "/latest/dynamic/instance-identity/document":
+			if r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"instanceId":"i-0abc","region":"us-east-1","availabilityZone":"us-east-1a","instanceType":"m5.large"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	awsMetadataURL = ts.URL
+
+	Config = &ConfigType{
+		Global:        Global{Labels: map[string]string{"region": "override"}},
+		CloudMetadata: &CloudMetadata{Enable: true, Providers: []string{"aws"}, RefreshInterval: -1},
+	}
+	require.NoError(t, InitCloudMetadata())
+
+	HostInfo = &HostInfoCache{}
+	labels := CloudMeta.GetLabels()
+	require.Equal(t, "aws", labels["cloud_provider"])
+	require.Equal(t, "i-0abc", labels["instance_id"])
+	require.Equal(t, "us-east-1a", labels["zone"])
+	require.Equal(t, "m5.large", labels["instance_type"])
+	require.Equal(t, "override", GlobalLabels()["region"])
+}
+
+func TestCloudMetadataGCP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"id":4520031799277581759,"zone":"projects/123/zones/us-central1-a","machineType":"projects/123/machineTypes/n1-standard-1"}`))
+	}))
+	defer ts.Close()
+	gcpMetadataURL = ts.URL
+
+	labels, err := detectGCP(context.Background(), ts.Client())
+	require.NoError(t, err)
+	require.Equal(t, "4520031799277581759", labels["instance_id"])
+	require.Equal(t, "us-central1", labels["region"])
+	require.Equal(t, "us-central1-a", labels["zone"])
+	require.Equal(t, "n1-standard-1", labels["instance_type"])
+}
+
+func TestCloudMetadataUnknownProvider(t *testing.T) {
+	Config = &ConfigType{CloudMetadata: &CloudMetadata{Enable: true, Providers: []string{"oracle"}}}
+	require.Error(t, InitCloudMetadata())
+}
+
+func TestCloudMetadataDeadline(t *testing.T) {
+	// gcp, preferred, hangs: the detection ends at the timeout with azure
+	hang := make(chan struct{})
+	gcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer gcp.Close()
+	defer close(hang)
+	gcpMetadataURL = gcp.URL
+
+	azure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"vmId":"vm-1","location":"westeurope","zone":"1","vmSize":"Standard_D2s_v3"}`))
+	}))
+	defer azure.Close()
+	azureMetadataURL = azure.URL
+
+	Config = &ConfigType{CloudMetadata: &CloudMetadata{
+		Enable:          true,
+		Providers:       []string{"gcp", "azure"},
+		Timeout:         Duration(200 * time.Millisecond),
+		RefreshInterval: -1,
+	}}
+	start := time.Now()
+	require.NoError(t, InitCloudMetadata())
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, "azure", CloudMeta.GetLabels()["cloud_provider"])
+	require.Equal(t, "vm-1", CloudMeta.GetLabels()["instance_id"])
+}
+
+func TestCloudMetadataAliyun(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/latest/meta-data/") + "\n"))
+	}))
+	defer ts.Close()
+	aliyunMetadataURL = ts.URL
+
+	labels, err := detectAliyun(context.Background(), ts.Client())
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/latest/meta-data/instance-id",
+		"/latest/meta-data/region-id",
+		"/latest/meta-data/zone-id",
+		"/latest/meta-data/instance/instance-type",
+	}, paths)
+	require.Equal(t, "instance-id", labels["instance_id"])
+	require.Equal(t, "instance/instance-type", labels["instance_type"])
+}
@@ -8,11 +8,14 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
+	"flashcat.cloud/categraf/pkg/auth"
 	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/toolkits/pkg/file"
@@ -36,6 +39,55 @@ type Global struct {
 	Interval     Duration          `toml:"interval"`
 	Providers    []string          `toml:"providers"`
 	Concurrency  int               `toml:"concurrency"`
+	// emit prometheus staleness markers when a series disappears, for all inputs
+	StaleMarkers bool `toml:"stale_markers"`
+
+	// start gathers at multiples of the interval since the unix epoch plus
+	// align_offset, e.g. :00/:15/:30/:45 with a 15s interval
+	AlignInterval bool     `toml:"align_interval"`
+	AlignOffset   Duration `toml:"align_offset"`
+	// stamp samples with the aligned time of their gather instead of the time
+	// they were processed, series of all hosts then share their timestamps
+	AlignTimestamp bool `toml:"align_timestamp"`
+
+	// default proxy of writers, heartbeat and http based inputs
+	HTTPProxy
+
+	// stretch the interval of inputs overrunning it or their budget
+	Backoff Backoff `toml:"backoff"`
+
+	// state of the inputs persisted across restarts, default run/state.db next to the config dir
+	StateFile string `toml:"state_file"`
+
+	// pool, retries, logging and trace header of the http clients of inputs and writers
+	HTTPClient HTTPClient `toml:"http_client"`
+}
+
+type HTTPClient struct {
+	// default 100 idle connections, 5 per host, closed after 90s
+	MaxIdleConns        int      `toml:"max_idle_conns"`
+	MaxIdleConnsPerHost int      `toml:"max_idle_conns_per_host"`
+	IdleConnTimeout     Duration `toml:"idle_conn_timeout"`
+	// retries of idempotent requests, the delay doubles every retry, default 200ms
+	Retries    int      `toml:"retries"`
+	RetryDelay Duration `toml:"retry_delay"`
+	// log every request at debug level
+	LogRequests bool `toml:"log_requests"`
+	// e.g. X-Request-Id or traceparent, every request gets a trace id in it
+	TraceHeader string `toml:"trace_header"`
+}
+
+type Backoff struct {
+	Enable bool `toml:"enable"`
+	// cpu time of the process during one gather of an input, 0 means unlimited
+	CPUBudget Duration `toml:"cpu_budget"`
+	// heap allocations during one gather of an input, 0 means unlimited
+	AllocBudgetMB int64 `toml:"alloc_budget_mb"`
+	// gathers in a row over budget or interval before the interval is doubled,
+	// and within them before it is halved again, default 3
+	Threshold int `toml:"threshold"`
+	// the interval is stretched to at most max_factor times, default 8
+	MaxFactor int `toml:"max_factor"`
 }
 
 type Log struct {
@@ -61,7 +113,58 @@ type WriterOption struct {
 	Timeout             int64 `toml:"timeout"`
 	DialTimeout         int64 `toml:"dial_timeout"`
 	MaxIdleConnsPerHost int   `toml:"max_idle_conns_per_host"`
+	// retry failed requests with the retries of global.http_client, which
+	// retries GET requests only otherwise
+	Retry bool `toml:"retry"`
+
+	// attach type/unit metadata of the metric families to every remote write request
+	SendMetadata bool `toml:"send_metadata"`
+
+	// writers of the same shard group share the series by hash of metric name and labels,
+	// writers without a group receive every series
+	ShardGroup string `toml:"shard_group"`
+	// max series per second sent to this writer, 0 means unlimited
+	RateLimit int `toml:"rate_limit"`
+	// bucket size of rate_limit, default rate_limit
+	RateBurst int `toml:"rate_burst"`
+
+	// snappy(default), zstd or gzip, only snappy is part of the remote write protocol
+	Compression string `toml:"compression"`
+	// max series per request to this writer, 0 means writer_opt.batch
+	Batch int `toml:"batch"`
+
+	// only series matching all these label regexps are sent to this writer
+	MatchLabels map[string]string `toml:"match_labels"`
+	// receive the series no writer with match_labels receives
+	Fallback bool `toml:"fallback"`
+	// series are grouped by the value of this label and sent with it in tenant_header
+	TenantLabel string `toml:"tenant_label"`
+	// default X-Scope-OrgID
+	TenantHeader string `toml:"tenant_header"`
+	// tenant of the series without tenant_label, or of all series if tenant_label
+	// is not set, no header is sent if empty
+	DefaultTenant string `toml:"default_tenant"`
+
+	// copy series to this writer besides the others, e.g. to try a new backend:
+	// its writes never hold back the others and it takes no part in shard_group or fallback
+	Shadow bool `toml:"shadow"`
+	// percent of the series copied to a shadow writer, picked by series hash, default 100
+	ShadowPercent float64 `toml:"shadow_percent"`
+
+	// prometheus(remote write, default), vm_import or influxdb
+	Format string `toml:"format"`
+	// influxdb v1 database and retention policy
+	InfluxDatabase        string `toml:"influx_database"`
+	InfluxRetentionPolicy string `toml:"influx_retention_policy"`
+	// influxdb v2 token, org and bucket
+	InfluxToken  string `toml:"influx_token"`
+	InfluxOrg    string `toml:"influx_org"`
+	InfluxBucket string `toml:"influx_bucket"`
+	// graphite dot path templates, e.g. "cpu_* ident.name.cpu", tagged names without any
+	GraphiteTemplates []string `toml:"graphite_templates"`
+	GraphitePrefix    string   `toml:"graphite_prefix"`
 
+	HTTPProxy
 	tls.ClientConfig
 }
 
@@ -79,6 +182,9 @@ type HTTP struct {
 	ReadTimeout        int    `toml:"read_timeout"`
 	WriteTimeout       int    `toml:"write_timeout"`
 	IdleTimeout        int    `toml:"idle_timeout"`
+
+	// client certificate verification, basic auth, bearer tokens and ip allowlist
+	auth.ServerConfig
 }
 
 type IbexConfig struct {
@@ -103,6 +209,53 @@ type HeartbeatConfig struct {
 	tls.ClientConfig
 }
 
+type MaintenanceConfig struct {
+	// drop(default) writes nothing but the self metrics of categraf,
+	// tag attaches maintenance="true" to every series instead
+	Mode string `toml:"mode"`
+	// maintenance is on while this file exists, it may contain the ttl, e.g. 2h
+	MarkerFile string `toml:"marker_file"`
+	// ttl of the marker file without one and of api requests without one, default 1h
+	DefaultTTL Duration `toml:"default_ttl"`
+	// upper bound of requested ttls, so a forgotten maintenance ends, default 24h
+	MaxTTL Duration `toml:"max_ttl"`
+}
+
+type CardinalityConfig struct {
+	// track the series written from start, otherwise only while turned on by the api
+	Enable bool `toml:"enable"`
+	// series are counted over the last windows intervals, default 5
+	Windows int `toml:"windows"`
+	// metric names and label keys reported by default, default 10
+	TopK int `toml:"top_k"`
+	// series tracked per interval at most, to bound the memory, default 500000
+	MaxSeries int `toml:"max_series"`
+}
+
+type ShutdownConfig struct {
+	// how long to wait for the gathers in flight once the inputs are stopped, default 10s
+	GatherTimeout Duration `toml:"gather_timeout"`
+	// how long to wait for the writer queue to drain, default 30s
+	FlushTimeout Duration `toml:"flush_timeout"`
+	// run in order once the queue is flushed
+	Hooks []ShutdownHook `toml:"hooks"`
+}
+
+type ShutdownHook struct {
+	Name string `toml:"name"`
+	// http requests url, exec runs command, heartbeat sends a last heartbeat
+	// with status going_down
+	Type    string   `toml:"type"`
+	Url     string   `toml:"url"`
+	Method  string   `toml:"method"`
+	Headers []string `toml:"headers"`
+	Body    string   `toml:"body"`
+	// program and arguments, run without a shell
+	Command []string `toml:"command"`
+	// default 5s
+	Timeout Duration `toml:"timeout"`
+}
+
 type ConfigType struct {
 	// from console args
 	ConfigDir    string
@@ -122,15 +275,92 @@ type ConfigType struct {
 	Heartbeat  *HeartbeatConfig `toml:"heartbeat"`
 	Log        Log              `toml:"log"`
 
+	Maintenance MaintenanceConfig `toml:"maintenance"`
+	Cardinality CardinalityConfig `toml:"cardinality"`
+	Shutdown    ShutdownConfig    `toml:"shutdown"`
+
+	CloudMetadata *CloudMetadata `toml:"cloud_metadata"`
+
 	HTTPProviderConfig *HTTPProviderConfig `toml:"http_provider"`
 }
 
 var Config *ConfigType
 
+func initShutdown(c *ShutdownConfig) error {
+	if c.GatherTimeout <= 0 {
+		c.GatherTimeout = Duration(10 * time.Second)
+	}
+	if c.FlushTimeout <= 0 {
+		c.FlushTimeout = Duration(30 * time.Second)
+	}
+	for i := range c.Hooks {
+		h := &c.Hooks[i]
+		if h.Name == "" {
+			h.Name = h.Type
+		}
+		if h.Timeout <= 0 {
+			h.Timeout = Duration(5 * time.Second)
+		}
+		switch h.Type {
+		case "http":
+			if h.Url == "" {
+				return fmt.Errorf("shutdown hook %s: url is required", h.Name)
+			}
+			if h.Method == "" {
+				h.Method = "POST"
+			}
+			if len(h.Headers)%2 != 0 {
+				return fmt.Errorf("shutdown hook %s: headers should be pairs of key and value", h.Name)
+			}
+		case "exec":
+			if len(h.Command) == 0 {
+				return fmt.Errorf("shutdown hook %s: command is required", h.Name)
+			}
+		case "heartbeat":
+		default:
+			return fmt.Errorf("shutdown hook %s: type should be http, exec or heartbeat, got %q", h.Name, h.Type)
+		}
+	}
+	return nil
+}
+
+func initHTTPClient(c *HTTPClient) {
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = 100
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = 5
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = Duration(90 * time.Second)
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = Duration(200 * time.Millisecond)
+	}
+	httpx.SetDefaults(httpx.Settings{
+		MaxIdleConns:        c.MaxIdleConns,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(c.IdleConnTimeout),
+		Retries:             c.Retries,
+		RetryDelay:          time.Duration(c.RetryDelay),
+		LogRequests:         c.LogRequests,
+		TraceHeader:         c.TraceHeader,
+	})
+}
+
+// hasMainConfig tells if dir has config.toml, or its yaml or json equivalent
+func hasMainConfig(dir string) bool {
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml", "config.json"} {
+		if file.IsExist(path.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
 func InitConfig(configDir string, debugLevel int, debugMode, testMode bool, interval int64, inputFilters string) error {
-	configFile := path.Join(configDir, "config.toml")
-	if !file.IsExist(configFile) {
-		return fmt.Errorf("configuration file(%s) not found", configFile)
+	if !hasMainConfig(configDir) {
+		return fmt.Errorf("configuration file(%s) not found", path.Join(configDir, "config.toml"))
 	}
 
 	Config = &ConfigType{
@@ -161,12 +391,57 @@ func InitConfig(configDir string, debugLevel int, debugMode, testMode bool, inte
 		Config.WriterOpt.Batch = 1000
 	}
 
+	if Config.Global.StateFile == "" {
+		Config.Global.StateFile = filepath.Join(filepath.Dir(filepath.Clean(configDir)), "run", "state.db")
+	}
+
+	if Config.Global.Backoff.Threshold <= 0 {
+		Config.Global.Backoff.Threshold = 3
+	}
+	if Config.Global.Backoff.MaxFactor <= 0 {
+		Config.Global.Backoff.MaxFactor = 8
+	}
+
+	initHTTPClient(&Config.Global.HTTPClient)
+
+	switch Config.Maintenance.Mode {
+	case "":
+		Config.Maintenance.Mode = "drop"
+	case "drop", "tag":
+	default:
+		return fmt.Errorf("maintenance mode should be drop or tag, got %q", Config.Maintenance.Mode)
+	}
+	if Config.Maintenance.DefaultTTL <= 0 {
+		Config.Maintenance.DefaultTTL = Duration(time.Hour)
+	}
+	if Config.Maintenance.MaxTTL <= 0 {
+		Config.Maintenance.MaxTTL = Duration(24 * time.Hour)
+	}
+
+	if Config.Cardinality.Windows <= 0 {
+		Config.Cardinality.Windows = 5
+	}
+	if Config.Cardinality.TopK <= 0 {
+		Config.Cardinality.TopK = 10
+	}
+	if Config.Cardinality.MaxSeries <= 0 {
+		Config.Cardinality.MaxSeries = 500000
+	}
+
+	if err := initShutdown(&Config.Shutdown); err != nil {
+		return err
+	}
+
 	Config.Global.Hostname = strings.TrimSpace(Config.Global.Hostname)
 
 	if err := InitHostInfo(); err != nil {
 		return err
 	}
 
+	if err := InitCloudMetadata(); err != nil {
+		return err
+	}
+
 	if Config.Global.PrintConfigs {
 		json := jsoniter.ConfigCompatibleWithStandardLibrary
 		bs, err := json.MarshalIndent(Config, "", "    ")
@@ -269,13 +544,17 @@ func GetOutboundIP() (net.IP, error) {
 		log.Printf("E! writers is not configured, use %s as default probe address", defaultProbeAddr)
 	}
 	for _, v := range Config.Writers {
+		// a shadow writer may be a backend on trial
+		if v.Shadow {
+			continue
+		}
 		if len(v.Url) != 0 {
 			u, err := url.Parse(v.Url)
 			if err != nil {
 				log.Printf("W! parse writers url %s error %s", v.Url, err)
 				continue
 			} else {
-				if strings.Contains(u.Host, "localhost") || strings.Contains(u.Host, "127.0.0.1") {
+				if strings.Contains(u.Host, "localhost") || strings.Contains(u.Host, "127.0.0.1") || strings.Contains(u.Host, "[::1]") {
 					continue
 				}
 				if len(u.Port()) == 0 {
@@ -350,6 +629,10 @@ func GetBiosSn() (string, error) {
 
 func GlobalLabels() map[string]string {
 	ret := make(map[string]string)
+	// labels of cloud_metadata, overridden by global.labels
+	for k, v := range CloudMeta.GetLabels() {
+		ret[k] = v
+	}
 	for k, v := range Config.Global.Labels {
 		ret[k] = Expand(v)
 	}
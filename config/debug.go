@@ -0,0 +1,67 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// AllInputs turns on debug logging of every input
+const AllInputs = "*"
+
+// debug logging switched on at runtime per input, by the api or SIGUSR1,
+// ending at the time kept here
+var inputDebug = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+// SetInputDebug turns on debug logging of input for ttl, input is the name of
+// an input or AllInputs
+func SetInputDebug(input string, ttl time.Duration) time.Time {
+	until := time.Now().Add(ttl)
+	inputDebug.Lock()
+	defer inputDebug.Unlock()
+	inputDebug.until[input] = until
+	return until
+}
+
+// ClearInputDebug turns off debug logging of input switched on by SetInputDebug
+func ClearInputDebug(input string) {
+	inputDebug.Lock()
+	defer inputDebug.Unlock()
+	delete(inputDebug.until, input)
+}
+
+// InputDebug tells if input should log at debug level, either by --debug or
+// switched on at runtime
+func InputDebug(input string) bool {
+	if Config != nil && Config.DebugMode {
+		return true
+	}
+	now := time.Now()
+	inputDebug.Lock()
+	defer inputDebug.Unlock()
+	for _, name := range []string{input, AllInputs} {
+		if until, has := inputDebug.until[name]; has {
+			if now.Before(until) {
+				return true
+			}
+			delete(inputDebug.until, name)
+		}
+	}
+	return false
+}
+
+// InputDebugStatus returns the inputs with debug logging switched on and when it ends
+func InputDebugStatus() map[string]time.Time {
+	now := time.Now()
+	inputDebug.Lock()
+	defer inputDebug.Unlock()
+	ret := make(map[string]time.Time, len(inputDebug.until))
+	for name, until := range inputDebug.until {
+		if now.Before(until) {
+			ret[name] = until
+		}
+	}
+	return ret
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputDebug(t *testing.T) {
+	require.False(t, InputDebug("mysql"))
+
+	SetInputDebug("mysql", time.Minute)
+	require.True(t, InputDebug("mysql"))
+	require.False(t, InputDebug("redis"))
+
+	SetInputDebug(AllInputs, time.Minute)
+	require.True(t, InputDebug("redis"))
+	ClearInputDebug(AllInputs)
+	require.False(t, InputDebug("redis"))
+
+	// expired entries are dropped
+	SetInputDebug("mysql", -time.Second)
+	require.False(t, InputDebug("mysql"))
+	require.Empty(t, InputDebugStatus())
+}
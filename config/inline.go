@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/prometheus/common/model"
@@ -21,6 +22,9 @@ type ProcessorEnum struct {
 }
 
 type InternalConfig struct {
+	// enable = false turns off the plugin or instance without removing its config
+	Enable *bool `toml:"enable"`
+
 	// append labels
 	Labels map[string]string `toml:"labels"`
 
@@ -36,6 +40,17 @@ type InternalConfig struct {
 	// mapping value
 	ProcessorEnum []*ProcessorEnum `toml:"processor_enum"`
 
+	// convert values between units, e.g. ms to seconds, and rename the metrics
+	ProcessorUnit []*ProcessorUnit `toml:"processor_unit"`
+
+	// convert counters to per second rates
+	ProcessorRate []*ProcessorRate `toml:"processor_rate"`
+	rates         *rateState       `toml:"-"`
+
+	// emit staleness markers for series which disappear, global.stale_markers enables it for all
+	StaleMarkers bool        `toml:"stale_markers"`
+	stale        *staleState `toml:"-"`
+
 	// whether instance initial success
 	inited bool `toml:"-"`
 
@@ -44,6 +59,8 @@ type InternalConfig struct {
 
 	// whether debug
 	DebugMod bool `toml:"-"`
+	// DebugMod before it was first switched at runtime, restored when switched off
+	debugMod *bool `toml:"-"`
 }
 type RelabelConfig struct {
 	// A list of labels from which values are taken and concatenated
@@ -99,6 +116,33 @@ func (ic *InternalConfig) InitInternalConfig() error {
 			}
 		}
 	}
+	for _, pu := range ic.ProcessorUnit {
+		if len(pu.Metrics) == 0 {
+			continue
+		}
+		if err := pu.init(); err != nil {
+			return err
+		}
+	}
+	for _, pr := range ic.ProcessorRate {
+		if len(pr.Metrics) == 0 {
+			continue
+		}
+		var err error
+		pr.MetricsFilter, err = filter.Compile(pr.Metrics)
+		if err != nil {
+			return err
+		}
+		if len(pr.Suffix) == 0 {
+			pr.Suffix = defaultRateSuffix
+		}
+		ic.rates = newRateState()
+	}
+
+	if ic.StaleMarkers || Config.Global.StaleMarkers {
+		ic.stale = newStaleState()
+	}
+
 	if len(ic.RelabelConfigs) != 0 {
 		for _, rc := range ic.RelabelConfigs {
 			if len(rc.Regex) == 0 {
@@ -137,11 +181,18 @@ func (ic *InternalConfig) InitInternalConfig() error {
 func (ic *InternalConfig) Process(slist *types.SampleList) *types.SampleList {
 	nlst := types.NewSampleList()
 	if slist.Len() == 0 {
+		if ic.stale != nil {
+			nlst.PushFrontN(ic.stale.update(nil, time.Now()))
+		}
 		return nlst
 	}
 
 	now := time.Now()
 	ss := slist.PopBackAll()
+	var out []*types.Sample
+	if ic.stale != nil {
+		out = make([]*types.Sample, 0, len(ss))
+	}
 
 	for i := range ss {
 		if ss[i] == nil {
@@ -176,6 +227,14 @@ func (ic *InternalConfig) Process(slist *types.SampleList) *types.SampleList {
 			ss[i].Timestamp = now
 		}
 
+		// convert units, before rates so a rate is per second of the new unit
+		ic.processUnit(ss[i])
+
+		// counter to rate
+		if ic.rates != nil && !ic.processRate(ss[i]) {
+			continue
+		}
+
 		// name prefix
 		if len(ic.MetricsNamePrefix) > 0 {
 			ss[i].Metric = ic.MetricsNamePrefix + ss[i].Metric
@@ -222,11 +281,63 @@ func (ic *InternalConfig) Process(slist *types.SampleList) *types.SampleList {
 		}
 
 		nlst.PushFront(ss[i])
+		if ic.stale != nil {
+			out = append(out, ss[i])
+		}
+	}
+
+	if ic.rates != nil {
+		ic.rates.expire(now)
+	}
+	if ic.stale != nil {
+		nlst.PushFrontN(ic.stale.update(out, now))
 	}
 
 	return nlst
 }
 
+func (ic *InternalConfig) processUnit(s *types.Sample) {
+	for _, pu := range ic.ProcessorUnit {
+		if pu.MetricsFilter == nil || !pu.MetricsFilter.Match(s.Metric) {
+			continue
+		}
+		if !pu.convert(s) && ic.DebugMod {
+			log.Println("D! processor_unit skips", s.Metric, "whose value", s.Value, "is not a number")
+		}
+		return
+	}
+}
+
+// processRate returns false if the sample should be dropped, e.g. the first
+// point of a counter which has no rate yet
+func (ic *InternalConfig) processRate(s *types.Sample) bool {
+	for _, pr := range ic.ProcessorRate {
+		if pr.MetricsFilter == nil || !pr.MetricsFilter.Match(s.Metric) {
+			continue
+		}
+		if !s.IsCounter() {
+			if ic.DebugMod {
+				log.Println("D! processor_rate skips", s.Metric, "which is", s.Type.String(), "rather than counter")
+			}
+			return true
+		}
+		v, ok := ic.rates.rate(s)
+		if !ok {
+			return false
+		}
+		s.Value = v
+		s.Metric += pr.Suffix
+		s.Type = types.Gauge
+		return true
+	}
+	return true
+}
+
+// Disabled reports whether enable is explicitly false
+func (ic *InternalConfig) Disabled() bool {
+	return ic.Enable != nil && !*ic.Enable
+}
+
 func (ic *InternalConfig) Initialized() bool {
 	return ic.inited
 }
@@ -235,6 +346,16 @@ func (ic *InternalConfig) SetInitialized() {
 	ic.inited = true
 }
 
+// SetDebugMod switches debug logging at runtime, see SetInputDebug. Switching
+// it off goes back to the DebugMod the input had before.
+func (ic *InternalConfig) SetDebugMod(debug bool) {
+	if ic.debugMod == nil {
+		configured := ic.DebugMod
+		ic.debugMod = &configured
+	}
+	ic.DebugMod = debug || *ic.debugMod
+}
+
 type PluginConfig struct {
 	InternalConfig
 	Interval Duration `toml:"interval"`
@@ -0,0 +1,82 @@
+//go:build !no_logs
+
+package logs
+
+import "sync"
+
+// channelSources let metric inputs (e.g. syslog) forward raw messages into the logs
+// pipeline. A source is registered once by name and survives input reloads, the logs
+// agent gets a fresh string_channel LogSource for it every time it starts.
+var channelSources = struct {
+	sync.RWMutex
+	entries map[string]*channelSource
+	sink    func(*LogSource)
+}{entries: make(map[string]*channelSource)}
+
+type channelSource struct {
+	name    string
+	source  string
+	service string
+	tags    []string
+	ch      chan *ChannelMessage
+}
+
+const channelSourceBuffer = 10000
+
+// RegisterChannelSource declares a source messages can be forwarded to with
+// ForwardToChannel. Registering an existing name again is a no-op.
+func RegisterChannelSource(name, source, service string, tags []string) {
+	channelSources.Lock()
+	defer channelSources.Unlock()
+	if _, has := channelSources.entries[name]; has {
+		return
+	}
+	e := &channelSource{name: name, source: source, service: service, tags: tags}
+	channelSources.entries[name] = e
+	if channelSources.sink != nil {
+		e.attach(channelSources.sink)
+	}
+}
+
+// SetChannelSourceSink is called by the logs agent with its AddSource when it starts,
+// and with nil before it stops, so that no message is sent to a closed channel.
+func SetChannelSourceSink(sink func(*LogSource)) {
+	channelSources.Lock()
+	defer channelSources.Unlock()
+	channelSources.sink = sink
+	for _, e := range channelSources.entries {
+		if sink == nil {
+			e.ch = nil
+			continue
+		}
+		e.attach(sink)
+	}
+}
+
+func (e *channelSource) attach(sink func(*LogSource)) {
+	e.ch = make(chan *ChannelMessage, channelSourceBuffer)
+	sink(NewLogSource(e.name, &LogsConfig{
+		Type:    StringChannelType,
+		Source:  e.source,
+		Service: e.service,
+		Tags:    e.tags,
+		Channel: e.ch,
+	}))
+}
+
+// ForwardToChannel sends content to the registered source without blocking. It returns
+// false if the message is dropped: the logs agent is not running or the buffer is full.
+func ForwardToChannel(name string, content []byte) bool {
+	channelSources.RLock()
+	defer channelSources.RUnlock()
+	e, has := channelSources.entries[name]
+	if !has || e.ch == nil {
+		return false
+	}
+	select {
+	case e.ch <- &ChannelMessage{Content: content}:
+		return true
+	default:
+		return false
+	}
+}
@@ -10,6 +10,8 @@ package logs
 import (
 	"fmt"
 	"strings"
+
+	"flashcat.cloud/categraf/pkg/auth"
 )
 
 // Logs source types
@@ -47,9 +49,11 @@ type (
 
 		Port        int    // Network
 		IdleTimeout string `mapstructure:"idle_timeout" json:"idle_timeout" toml:"idle_timeout"` // Network
-		Path        string // File, Journald
-		Topic       string `mapstructure:"topic" json:"topic" toml:"topic"`
-		Accuracy    string `mapstructure:"accuracy" json:"accuracy" toml:"accuracy"`
+		// Network, tls and credentials only apply to tcp, allowed_ips applies to both
+		auth.ServerConfig `mapstructure:",squash"`
+		Path              string // File, Journald
+		Topic             string `mapstructure:"topic" json:"topic" toml:"topic"`
+		Accuracy          string `mapstructure:"accuracy" json:"accuracy" toml:"accuracy"`
 
 		Encoding     string   `mapstructure:"encoding" json:"encoding" toml:"encoding"`                   // File
 		ExcludePaths []string `mapstructure:"exclude_paths" json:"exclude_paths" toml:"exclude_paths"`    // File
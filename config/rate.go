@@ -0,0 +1,92 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	defaultRateSuffix = "_rate"
+	// series not seen for this long are forgotten
+	rateStateTTL = 10 * time.Minute
+)
+
+// ProcessorRate converts counters into per second rates. Only samples typed as
+// counters are converted, gauges and untyped samples pass through unchanged.
+type ProcessorRate struct {
+	Metrics       []string `toml:"metrics"` // support glob
+	MetricsFilter filter.Filter
+	// appended to the metric name, default _rate
+	Suffix string `toml:"suffix"`
+}
+
+type ratePoint struct {
+	value float64
+	ts    time.Time
+}
+
+type rateState struct {
+	sync.Mutex
+	last map[string]ratePoint
+}
+
+func newRateState() *rateState {
+	return &rateState{last: make(map[string]ratePoint)}
+}
+
+// rate returns false for the first point of a series and after a counter reset
+func (r *rateState) rate(s *types.Sample) (float64, bool) {
+	value, err := conv.ToFloat64(s.Value)
+	if err != nil {
+		return 0, false
+	}
+
+	key := seriesKey(s)
+	r.Lock()
+	defer r.Unlock()
+
+	prev, has := r.last[key]
+	r.last[key] = ratePoint{value: value, ts: s.Timestamp}
+	if !has || value < prev.value {
+		return 0, false
+	}
+	elapsed := s.Timestamp.Sub(prev.ts).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (value - prev.value) / elapsed, true
+}
+
+func (r *rateState) expire(now time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	for k, p := range r.last {
+		if now.Sub(p.ts) > rateStateTTL {
+			delete(r.last, k)
+		}
+	}
+}
+
+func seriesKey(s *types.Sample) string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(s.Metric)
+	for _, k := range keys {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(s.Labels[k])
+	}
+	return sb.String()
+}
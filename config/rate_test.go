@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestProcessRate(t *testing.T) {
+	Config = &ConfigType{Global: Global{OmitHostname: true}}
+
+	ic := &InternalConfig{ProcessorRate: []*ProcessorRate{{Metrics: []string{"req_*"}}}}
+	require.NoError(t, ic.InitInternalConfig())
+
+	now := time.Now()
+	gather := func(ts time.Time, counter, gauge float64) map[string]*types.Sample {
+		slist := types.NewSampleList()
+		slist.PushFront(types.NewSample("", "req_total", counter).SetTime(ts).SetType(types.Counter))
+		slist.PushFront(types.NewSample("", "req_inflight", gauge).SetTime(ts).SetType(types.Gauge))
+		ret := make(map[string]*types.Sample)
+		for _, s := range ic.Process(slist).PopBackAll() {
+			ret[s.Metric] = s
+		}
+		return ret
+	}
+
+	// first point of the counter has no rate yet, the gauge passes through
+	ret := gather(now, 100, 3)
+	require.Len(t, ret, 1)
+	require.Equal(t, 3.0, ret["req_inflight"].Value)
+
+	ret = gather(now.Add(10*time.Second), 150, 4)
+	require.Len(t, ret, 2)
+	require.Equal(t, 5.0, ret["req_total_rate"].Value)
+	require.Equal(t, types.Gauge, ret["req_total_rate"].Type)
+
+	// counter reset
+	ret = gather(now.Add(20*time.Second), 10, 4)
+	require.NotContains(t, ret, "req_total_rate")
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// staleState remembers the series of the last gather, series missing from the
+// next one get a staleness marker so graphs end right away instead of
+// drawing the last value for 5 minutes.
+type staleState struct {
+	sync.Mutex
+	last map[string]*types.Sample
+}
+
+func newStaleState() *staleState {
+	return &staleState{last: make(map[string]*types.Sample)}
+}
+
+// update records the samples of this gather and returns the markers of the series gone
+func (st *staleState) update(current []*types.Sample, now time.Time) []*types.Sample {
+	seen := make(map[string]*types.Sample, len(current))
+	for _, s := range current {
+		seen[seriesKey(s)] = s
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	var markers []*types.Sample
+	for key, s := range st.last {
+		if _, has := seen[key]; has {
+			continue
+		}
+		markers = append(markers, &types.Sample{
+			Metric:    s.Metric,
+			Timestamp: now,
+			Value:     types.StaleNaN,
+			Labels:    s.Labels,
+			Type:      s.Type,
+			Unit:      s.Unit,
+		})
+	}
+	st.last = seen
+	return markers
+}
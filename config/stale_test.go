@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestStaleMarkers(t *testing.T) {
+	Config = &ConfigType{Global: Global{OmitHostname: true}}
+
+	ic := &InternalConfig{StaleMarkers: true}
+	require.NoError(t, ic.InitInternalConfig())
+
+	gather := func(disks ...string) []*types.Sample {
+		slist := types.NewSampleList()
+		for _, d := range disks {
+			slist.PushFront(types.NewSample("disk", "used", 1, map[string]string{"path": d}))
+		}
+		return ic.Process(slist).PopBackAll()
+	}
+
+	require.Len(t, gather("/", "/data"), 2)
+
+	ss := gather("/")
+	require.Len(t, ss, 2)
+	var stale *types.Sample
+	for _, s := range ss {
+		if s.Labels["path"] == "/data" {
+			stale = s
+		}
+	}
+	require.NotNil(t, stale)
+	require.True(t, types.IsStaleNaN(stale.Value.(float64)))
+
+	// marked once only
+	require.Len(t, gather("/"), 1)
+	// everything is gone
+	require.Len(t, gather(), 1)
+}
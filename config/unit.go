@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/types"
+)
+
+// ProcessorUnit converts the values of the matched metrics from one unit to
+// another of the same kind and renames them, e.g. zk_avg_latency in ms to
+// zk_avg_latency_seconds. A suffix naming the old unit is replaced by the one
+// of the new unit, _total of counters stays last.
+type ProcessorUnit struct {
+	Metrics       []string `toml:"metrics"` // support glob
+	MetricsFilter filter.Filter
+	From          string `toml:"from"`
+	To            string `toml:"to"`
+	// keep the metric name, only convert the value
+	KeepName bool `toml:"keep_name"`
+
+	factor float64
+	from   unit
+	to     unit
+}
+
+type unit struct {
+	kind string
+	// value of one unit in the base unit of the kind
+	scale float64
+	// the first one is appended, all are replaced
+	suffixes []string
+}
+
+var units = map[string]unit{
+	"bytes": {"bytes", 1, []string{"_bytes"}},
+	"kb":    {"bytes", 1e3, []string{"_kb", "_kilobytes"}},
+	"mb":    {"bytes", 1e6, []string{"_mb", "_megabytes"}},
+	"gb":    {"bytes", 1e9, []string{"_gb", "_gigabytes"}},
+	"kib":   {"bytes", 1 << 10, []string{"_kib", "_kibibytes"}},
+	"mib":   {"bytes", 1 << 20, []string{"_mib", "_mebibytes"}},
+	"gib":   {"bytes", 1 << 30, []string{"_gib", "_gibibytes"}},
+	"tib":   {"bytes", 1 << 40, []string{"_tib", "_tebibytes"}},
+
+	"ns":      {"time", 1e-9, []string{"_nanoseconds", "_ns"}},
+	"us":      {"time", 1e-6, []string{"_microseconds", "_us"}},
+	"ms":      {"time", 1e-3, []string{"_milliseconds", "_ms", "_millis"}},
+	"seconds": {"time", 1, []string{"_seconds", "_sec"}},
+	"minutes": {"time", 60, []string{"_minutes"}},
+	"hours":   {"time", 3600, []string{"_hours"}},
+
+	"ratio":   {"ratio", 1, []string{"_ratio"}},
+	"percent": {"ratio", 0.01, []string{"_percent", "_pct"}},
+}
+
+// unit aliases accepted in from and to
+var unitAliases = map[string]string{
+	"b":            "bytes",
+	"byte":         "bytes",
+	"s":            "seconds",
+	"second":       "seconds",
+	"sec":          "seconds",
+	"milliseconds": "ms",
+	"microseconds": "us",
+	"nanoseconds":  "ns",
+	"min":          "minutes",
+	"h":            "hours",
+	"%":            "percent",
+}
+
+func lookupUnit(name string) (unit, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, has := unitAliases[name]; has {
+		name = alias
+	}
+	u, has := units[name]
+	if !has {
+		return unit{}, fmt.Errorf("unknown unit %q", name)
+	}
+	return u, nil
+}
+
+func (pu *ProcessorUnit) init() error {
+	var err error
+	if pu.MetricsFilter, err = filter.Compile(pu.Metrics); err != nil {
+		return err
+	}
+	if pu.from, err = lookupUnit(pu.From); err != nil {
+		return fmt.Errorf("processor_unit from: %v", err)
+	}
+	if pu.to, err = lookupUnit(pu.To); err != nil {
+		return fmt.Errorf("processor_unit to: %v", err)
+	}
+	if pu.from.kind != pu.to.kind {
+		return fmt.Errorf("processor_unit can't convert %s to %s", pu.From, pu.To)
+	}
+	pu.factor = pu.from.scale / pu.to.scale
+	return nil
+}
+
+// convert returns false if the value of s isn't a number
+func (pu *ProcessorUnit) convert(s *types.Sample) bool {
+	v, err := conv.ToFloat64(s.Value)
+	if err != nil {
+		return false
+	}
+	s.Value = v * pu.factor
+	s.Unit = pu.to.suffixes[0][1:]
+	if !pu.KeepName {
+		s.Metric = pu.rename(s.Metric)
+	}
+	return true
+}
+
+func (pu *ProcessorUnit) rename(name string) string {
+	total := strings.HasSuffix(name, "_total")
+	name = strings.TrimSuffix(name, "_total")
+	for _, suffix := range pu.from.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	if !strings.HasSuffix(name, pu.to.suffixes[0]) {
+		name += pu.to.suffixes[0]
+	}
+	if total {
+		name += "_total"
+	}
+	return name
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestProcessUnit(t *testing.T) {
+	Config = &ConfigType{Global: Global{OmitHostname: true}}
+
+	ic := &InternalConfig{ProcessorUnit: []*ProcessorUnit{
+		{Metrics: []string{"zk_*_latency"}, From: "ms", To: "s"},
+		{Metrics: []string{"*_bytes_total"}, From: "bytes", To: "MiB"},
+		{Metrics: []string{"cpu_usage_*"}, From: "percent", To: "ratio", KeepName: true},
+	}}
+	require.NoError(t, ic.InitInternalConfig())
+
+	slist := types.NewSampleList()
+	slist.PushSample("", "zk_avg_latency", 250)
+	slist.PushFront(types.NewSample("", "net_recv_bytes_total", 3*1024*1024).SetType(types.Counter))
+	slist.PushSample("", "cpu_usage_idle", 25.0)
+	slist.PushSample("", "zk_version", "3.6")
+	ret := make(map[string]*types.Sample)
+	for _, s := range ic.Process(slist).PopBackAll() {
+		ret[s.Metric] = s
+	}
+
+	require.Equal(t, 0.25, ret["zk_avg_latency_seconds"].Value)
+	require.Equal(t, "seconds", ret["zk_avg_latency_seconds"].Unit)
+	require.Equal(t, 3.0, ret["net_recv_mib_total"].Value)
+	require.Equal(t, types.Counter, ret["net_recv_mib_total"].Type)
+	require.Equal(t, 0.25, ret["cpu_usage_idle"].Value)
+	require.Equal(t, "3.6", ret["zk_version"].Value)
+}
+
+func TestProcessUnitInvalid(t *testing.T) {
+	Config = &ConfigType{}
+	ic := &InternalConfig{ProcessorUnit: []*ProcessorUnit{{Metrics: []string{"*"}, From: "ms", To: "bytes"}}}
+	require.Error(t, ic.InitInternalConfig())
+
+	ic = &InternalConfig{ProcessorUnit: []*ProcessorUnit{{Metrics: []string{"*"}, From: "furlong", To: "s"}}}
+	require.Error(t, ic.InitInternalConfig())
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net"
@@ -129,10 +130,40 @@ func work(ps *system.SystemPS, client *http.Client) {
 		log.Println("E! failed to collect system info:", err)
 	}
 
+	if err := send(client, data); err != nil {
+		log.Println("E!", err)
+	}
+}
+
+// GoingDown sends a last heartbeat with status going_down, for the server to
+// tell a deploy from a lost agent
+func GoingDown(timeout time.Duration) error {
+	conf := config.Config.Heartbeat
+	if conf == nil || !conf.Enable {
+		return nil
+	}
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+	client.Timeout = timeout
+
+	return send(client, map[string]interface{}{
+		"agent_version": version(),
+		"os":            runtime.GOOS,
+		"arch":          runtime.GOARCH,
+		"hostname":      config.Config.GetHostname(),
+		"cpu_num":       runtime.NumCPU(),
+		"unixtime":      time.Now().UnixMilli(),
+		"host_ip":       config.Config.GetHostIP(),
+		"status":        "going_down",
+	})
+}
+
+func send(client *http.Client, data map[string]interface{}) error {
 	bs, err := json.Marshal(data)
 	if err != nil {
-		log.Println("E! failed to marshal heartbeat request:", err)
-		return
+		return fmt.Errorf("failed to marshal heartbeat request: %v", err)
 	}
 
 	var buf bytes.Buffer
@@ -142,8 +173,7 @@ func work(ps *system.SystemPS, client *http.Client) {
 	}
 
 	if err = g.Close(); err != nil {
-		log.Println("E! failed to close gzip buffer:", err)
-		return
+		return fmt.Errorf("failed to close gzip buffer: %v", err)
 	}
 	if debug() {
 		log.Printf("D! heartbeat request: %s", string(bs))
@@ -151,10 +181,10 @@ func work(ps *system.SystemPS, client *http.Client) {
 
 	req, err := http.NewRequest("POST", config.Config.Heartbeat.Url, &buf)
 	if err != nil {
-		log.Println("E! failed to new heartbeat request:", err)
-		return
+		return fmt.Errorf("failed to new heartbeat request: %v", err)
 	}
 
+	hostIP := config.Config.GetHostIP()
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("User-Agent", "categraf/"+hostIP)
@@ -172,25 +202,23 @@ func work(ps *system.SystemPS, client *http.Client) {
 
 	res, err := client.Do(req)
 	if err != nil {
-		log.Println("E! failed to do heartbeat:", err)
-		return
+		return fmt.Errorf("failed to do heartbeat: %v", err)
 	}
 
 	defer res.Body.Close()
 	bs, err = io.ReadAll(res.Body)
 	if err != nil {
-		log.Println("E! failed to read heartbeat response body:", err, " status code:", res.StatusCode)
-		return
+		return fmt.Errorf("failed to read heartbeat response body: %v status code: %d", err, res.StatusCode)
 	}
 
 	if res.StatusCode/100 != 2 {
-		log.Println("E! heartbeat status code:", res.StatusCode, " response:", string(bs))
-		return
+		return fmt.Errorf("heartbeat status code: %d response: %s", res.StatusCode, string(bs))
 	}
 
 	if debug() {
 		log.Println("D! heartbeat response:", string(bs), "status code:", res.StatusCode)
 	}
+	return nil
 }
 
 func memUsage(ps *system.SystemPS) float64 {
@@ -70,10 +70,10 @@ type (
 	}
 
 	Credential struct {
-		AccessKeyID     *string `toml:"access_key_id"`
-		AccessKeySecret *string `toml:"access_key_secret"`
-		Region          *string `toml:"region"`
-		Endpoint        *string `toml:"endpoint"`
+		AccessKeyID     *string `toml:"access_key_id" required:"true"`
+		AccessKeySecret *string `toml:"access_key_secret" required:"true"`
+		Region          *string `toml:"region" required:"true"`
+		Endpoint        *string `toml:"endpoint" required:"true"`
 	}
 
 	MetricFilter struct {
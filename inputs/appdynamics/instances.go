@@ -16,6 +16,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/stringx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
@@ -171,19 +172,18 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
-	trans := &http.Transport{
-		Proxy:             proxy,
-		DialContext:       dialer.DialContext,
-		DisableKeepAlives: true,
-		TLSClientConfig:   tlsCfg,
-	}
+	trans := httpx.NewTransport()
+	trans.Proxy = proxy
+	trans.DialContext = dialer.DialContext
+	trans.DisableKeepAlives = true
+	trans.TLSClientConfig = tlsCfg
 
 	if ins.UseTLS {
 		trans.TLSClientConfig = tlsCfg
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.Timeout),
 	}
 
@@ -16,6 +16,7 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/pkg/cache"
 	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/kubernetes"
 	util "flashcat.cloud/categraf/pkg/metrics"
 	"flashcat.cloud/categraf/pkg/tls"
@@ -187,7 +188,7 @@ func (ins *Instance) Drop() {
 }
 
 func (ins *Instance) createHTTPClient() (*http.Client, error) {
-	trans := &http.Transport{}
+	trans := httpx.NewTransport()
 
 	if ins.UseTLS {
 		tlsConfig, err := ins.ClientConfig.TLSConfig()
@@ -198,7 +199,7 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.Timeout),
 	}
 
@@ -0,0 +1,244 @@
+package canary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "canary"
+	// self metrics of categraf, they are written during maintenance too
+	prefix = "categraf_canary"
+)
+
+type Canary struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Canary{}
+	})
+}
+
+func (c *Canary) Clone() inputs.Input {
+	return &Canary{}
+}
+
+func (c *Canary) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Canary)
+var _ inputs.InstancesGetter = new(Canary)
+
+type Instance struct {
+	inputs.InstanceBase
+
+	// value of the canary label, defaults to the hostname
+	CanaryID string `toml:"canary_id"`
+	// prometheus compatible query api of the backend, e.g. http://vm:8428,
+	// without it the canary series is only written
+	QueryURL string `toml:"query_url"`
+	// a canary not visible after that is lost, default 60s
+	MaxLatency config.Duration `toml:"max_latency"`
+	// how often the backend is asked for a pending canary, default 1s
+	PollInterval config.Duration `toml:"poll_interval"`
+
+	client *http.Client
+	// closed by Drop, the pending canaries stop polling
+	done chan struct{}
+
+	mu sync.Mutex
+	// value of the next canary
+	seq         int64
+	sent        uint64
+	received    uint64
+	lost        uint64
+	queryErrors uint64
+	pending     int
+	latency     float64
+}
+
+func (ins *Instance) Init() error {
+	if err := ins.InitBase(5 * time.Second); err != nil {
+		return err
+	}
+	if ins.CanaryID == "" {
+		ins.CanaryID = config.Config.GetHostname()
+	}
+	if ins.MaxLatency <= 0 {
+		ins.MaxLatency = config.Duration(time.Minute)
+	}
+	if ins.PollInterval <= 0 {
+		ins.PollInterval = config.Duration(time.Second)
+	}
+	// counting on from the start time, so canaries of a previous run can't be
+	// taken for the ones of this run
+	ins.seq = time.Now().Unix()
+	ins.done = make(chan struct{})
+	if ins.QueryURL == "" {
+		return nil
+	}
+	client, err := ins.HTTPClient()
+	if err != nil {
+		return err
+	}
+	ins.client = client
+	return nil
+}
+
+// Drop stops polling for the pending canaries
+func (ins *Instance) Drop() {
+	if ins.done != nil {
+		close(ins.done)
+	}
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	tags := map[string]string{"canary": ins.CanaryID}
+	sent := time.Now()
+
+	ins.mu.Lock()
+	defer ins.mu.Unlock()
+	seq := ins.seq
+	ins.seq++
+	slist.PushSample(prefix, "sequence", seq, tags)
+	ins.sent++
+	slist.PushFront(types.NewSample(prefix, "sent_total", ins.sent, tags).SetType(types.Counter))
+	if ins.QueryURL == "" {
+		return
+	}
+
+	slist.PushFront(types.NewSample(prefix, "received_total", ins.received, tags).SetType(types.Counter))
+	slist.PushFront(types.NewSample(prefix, "lost_total", ins.lost, tags).SetType(types.Counter))
+	slist.PushFront(types.NewSample(prefix, "query_errors_total", ins.queryErrors, tags).SetType(types.Counter))
+	slist.PushSample(prefix, "pending", ins.pending, tags)
+	if ins.received > 0 {
+		slist.PushSample(prefix, "latency_seconds", ins.latency, tags)
+	}
+
+	ins.pending++
+	go ins.await(sent, seq)
+}
+
+// await polls the backend until the canary is visible or max_latency passed
+func (ins *Instance) await(sent time.Time, seq int64) {
+	deadline := sent.Add(time.Duration(ins.MaxLatency))
+	for {
+		visible, err := ins.visible(seq)
+		ins.mu.Lock()
+		if err != nil {
+			ins.queryErrors++
+			if ins.DebugMod {
+				log.Println("D! canary failed to query", ins.QueryURL, "error:", err)
+			}
+		}
+		switch {
+		case visible:
+			ins.received++
+			ins.latency = time.Since(sent).Seconds()
+			ins.pending--
+			ins.mu.Unlock()
+			return
+		case time.Now().After(deadline):
+			ins.lost++
+			ins.pending--
+			ins.mu.Unlock()
+			log.Println("W! canary", seq, "not visible at", ins.QueryURL, "after", time.Duration(ins.MaxLatency))
+			return
+		}
+		ins.mu.Unlock()
+		select {
+		case <-ins.done:
+			return
+		case <-time.After(time.Duration(ins.PollInterval)):
+		}
+	}
+}
+
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Values [][2]interface{} `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// visible asks for the canary samples of the last max_latency and looks for seq
+// among them, the newest sample alone would hide a lost canary behind a later one
+func (ins *Instance) visible(seq int64) (bool, error) {
+	window := time.Duration(ins.MaxLatency) + time.Duration(ins.PollInterval) + time.Minute
+	query := fmt.Sprintf(`%s_sequence{canary=%q}[%ds]`, prefix, ins.CanaryID, int64(window.Seconds()))
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(ins.QueryURL, "/")+"/api/v1/query?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return false, err
+	}
+	ins.SetRequestAuth(req)
+
+	resp, err := ins.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("status code %d, body: %s", resp.StatusCode, body)
+	}
+
+	var qr queryResponse
+	if err := json.Unmarshal(body, &qr); err != nil {
+		return false, err
+	}
+	if qr.Status != "success" {
+		return false, fmt.Errorf("query failed: %s", qr.Error)
+	}
+
+	for _, r := range qr.Data.Result {
+		for _, v := range r.Values {
+			s, ok := v[1].(string)
+			if !ok {
+				continue
+			}
+			// a sequence of 10 digits survives backends keeping 12 significant
+			// digits, e.g. VictoriaMetrics, a millisecond timestamp doesn't
+			if f, err := strconv.ParseFloat(s, 64); err == nil && int64(f) == seq {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// DescribeMetrics lists the metrics of canary
+func (c *Canary) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "categraf_canary_sequence", Type: types.Gauge, Help: "the canary, counting up every gather from the unix time in seconds the instance started at", Tags: []string{"canary"}},
+		{Name: "categraf_canary_sent_total", Type: types.Counter, Help: "canaries sent", Tags: []string{"canary"}},
+		{Name: "categraf_canary_received_total", Type: types.Counter, Help: "canaries which became visible at the query api", Tags: []string{"canary"}},
+		{Name: "categraf_canary_lost_total", Type: types.Counter, Help: "canaries not visible within max_latency", Tags: []string{"canary"}},
+		{Name: "categraf_canary_query_errors_total", Type: types.Counter, Help: "failed queries of the query api", Tags: []string{"canary"}},
+		{Name: "categraf_canary_pending", Type: types.Gauge, Help: "canaries sent but not visible yet", Tags: []string{"canary"}},
+		{Name: "categraf_canary_latency_seconds", Type: types.Gauge, Unit: "seconds", Help: "end to end latency of the last visible canary, accurate to poll_interval", Tags: []string{"canary"}},
+	}
+}
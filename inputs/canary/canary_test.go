@@ -0,0 +1,109 @@
+package canary
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestCanary(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		visible []int64
+		query   string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		query = r.URL.Query().Get("query")
+		values := ""
+		for i, seq := range visible {
+			if i > 0 {
+				values += ","
+			}
+			// VictoriaMetrics formats the values with 12 significant digits
+			values += fmt.Sprintf(`[%d.0,"%s"]`, time.Now().Unix(), strconv.FormatFloat(float64(seq), 'g', 12, 64))
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[%s]}]}}`, values)
+	}))
+	defer srv.Close()
+
+	ins := &Instance{
+		CanaryID:     "web01",
+		QueryURL:     srv.URL,
+		MaxLatency:   config.Duration(200 * time.Millisecond),
+		PollInterval: config.Duration(10 * time.Millisecond),
+	}
+	require.NoError(t, ins.Init())
+	defer ins.Drop()
+
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+	var seq int64
+	for _, s := range slist.PopBackAll() {
+		if s.Metric == "categraf_canary_sequence" {
+			seq = s.Value.(int64)
+			require.Equal(t, "web01", s.Labels["canary"])
+		}
+	}
+	require.NotZero(t, seq)
+
+	// the first canary shows up, the second never does
+	time.Sleep(30 * time.Millisecond)
+	mu.Lock()
+	visible = append(visible, seq)
+	mu.Unlock()
+	ins.Gather(types.NewSampleList())
+
+	require.Eventually(t, func() bool {
+		ins.mu.Lock()
+		defer ins.mu.Unlock()
+		return ins.pending == 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	ins.mu.Lock()
+	defer ins.mu.Unlock()
+	require.Equal(t, uint64(2), ins.sent)
+	require.Equal(t, uint64(1), ins.received)
+	require.Equal(t, uint64(1), ins.lost)
+	require.Greater(t, ins.latency, 0.02)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, `categraf_canary_sequence{canary="web01"}[60s]`, query)
+}
+
+func TestDropStopsPolling(t *testing.T) {
+	var queries int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[]}}`)
+	}))
+	defer srv.Close()
+
+	ins := &Instance{
+		CanaryID:     "web01",
+		QueryURL:     srv.URL,
+		MaxLatency:   config.Duration(time.Minute),
+		PollInterval: config.Duration(10 * time.Millisecond),
+	}
+	require.NoError(t, ins.Init())
+	ins.Gather(types.NewSampleList())
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&queries) > 0 }, time.Second, 5*time.Millisecond)
+
+	ins.Drop()
+	time.Sleep(30 * time.Millisecond)
+	stopped := atomic.LoadInt32(&queries)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, stopped, atomic.LoadInt32(&queries))
+}
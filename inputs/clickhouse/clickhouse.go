@@ -2,10 +2,12 @@ package clickhouse
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
@@ -17,6 +19,7 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/stringx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
@@ -47,9 +50,17 @@ type Instance struct {
 	ClusterInclude []string        `toml:"cluster_include"`
 	ClusterExclude []string        `toml:"cluster_exclude"`
 	Timeout        config.Duration `toml:"timeout"`
-	Metrics        []MetricConfig  `toml:"metrics"`
-	HTTPClient     *http.Client
+	// max execution time of every query, enforced by the server (max_execution_time)
+	// and the client, a metrics block can override it with its own timeout
+	QueryTimeout config.Duration `toml:"query_timeout"`
+	Metrics      []MetricConfig  `toml:"metrics"`
+	// cluster label of the servers when auto_discovery is off
+	Cluster string `toml:"cluster"`
+	// extra labels per cluster name
+	ClusterLabels map[string]map[string]string `toml:"cluster_labels"`
+	HTTPClient    *http.Client
 	tls.ClientConfig
+	config.HTTPProxy
 }
 
 type connect struct {
@@ -72,14 +83,18 @@ func (ins *Instance) Init() error {
 	if err != nil {
 		return err
 	}
+	proxy, err := ins.Proxy()
+	if err != nil {
+		return err
+	}
 
+	trans := httpx.NewTransport()
+	trans.TLSClientConfig = tlsCfg
+	trans.Proxy = proxy
+	trans.MaxIdleConnsPerHost = 1
 	ins.HTTPClient = &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			TLSClientConfig:     tlsCfg,
-			Proxy:               http.ProxyFromEnvironment,
-			MaxIdleConnsPerHost: 1,
-		},
+		Timeout:   timeout,
+		Transport: httpx.WrapDefault(trans),
 	}
 	return nil
 }
@@ -157,6 +172,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 			}
 		default:
 			connects = append(connects, connect{
+				Cluster:  ins.Cluster,
 				Hostname: u.Hostname(),
 				url:      u,
 			})
@@ -168,6 +184,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 			ins.tables,
 			ins.zookeeper,
 			ins.replicationQueue,
+			ins.replicas,
 			ins.detachedParts,
 			ins.dictionaries,
 			ins.mutations,
@@ -187,15 +204,14 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 				log.Println("E! failed to exec query commonMetrics error:", err)
 			}
 		}
-		log.Println("E!metrics=", len(ins.Metrics))
 		waitMetrics := new(sync.WaitGroup)
-
-		for i := 0; i < len(ins.Metrics); i++ {
-			m := ins.Metrics[i]
+		for j := 0; j < len(ins.Metrics); j++ {
 			waitMetrics.Add(1)
-			//tags := map[string]string{"address": ins.Address}
-			//go ins.scrapeMetric(waitMetrics, slist, m, tags)
-			go ins.execCustomQuery(&connects[i], waitMetrics, slist, m)
+			go func(conn *connect, m MetricConfig) {
+				if err := ins.execCustomQuery(conn, waitMetrics, slist, m); err != nil {
+					log.Println("E! failed to exec custom query:", m.Mesurement, "server:", conn.Hostname, "error:", err)
+				}
+			}(&connects[i], ins.Metrics[j])
 		}
 		waitMetrics.Wait()
 
@@ -316,6 +332,42 @@ func (ins *Instance) replicationQueue(slist *types.SampleList, conn *connect) er
 	return nil
 }
 
+func (ins *Instance) replicas(slist *types.SampleList, conn *connect) error {
+	var replicas []struct {
+		Database         string   `json:"database"`
+		Table            string   `json:"table"`
+		IsReadonly       chUInt64 `json:"is_readonly"`
+		IsSessionExpired chUInt64 `json:"is_session_expired"`
+		AbsoluteDelay    chUInt64 `json:"absolute_delay"`
+		QueueSize        chUInt64 `json:"queue_size"`
+		InsertsInQueue   chUInt64 `json:"inserts_in_queue"`
+		MergesInQueue    chUInt64 `json:"merges_in_queue"`
+		LogDelay         chUInt64 `json:"log_delay"`
+		TotalReplicas    chUInt64 `json:"total_replicas"`
+		ActiveReplicas   chUInt64 `json:"active_replicas"`
+	}
+	if err := ins.execQuery(conn.url, systemReplicasSQL, &replicas); err != nil {
+		return err
+	}
+
+	for _, r := range replicas {
+		tags := ins.makeDefaultTags(conn)
+		tags["database"] = r.Database
+		tags["table"] = r.Table
+
+		slist.PushFront(types.NewSample("clickhouse_replicas", "is_readonly", uint64(r.IsReadonly), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "is_session_expired", uint64(r.IsSessionExpired), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "absolute_delay", uint64(r.AbsoluteDelay), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "queue_size", uint64(r.QueueSize), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "inserts_in_queue", uint64(r.InsertsInQueue), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "merges_in_queue", uint64(r.MergesInQueue), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "log_delay", uint64(r.LogDelay), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "total_replicas", uint64(r.TotalReplicas), tags))
+		slist.PushFront(types.NewSample("clickhouse_replicas", "active_replicas", uint64(r.ActiveReplicas), tags))
+	}
+	return nil
+}
+
 func (ins *Instance) detachedParts(slist *types.SampleList, conn *connect) error {
 	var detachedParts []struct {
 		DetachedParts chUInt64 `json:"detached_parts"`
@@ -489,6 +541,9 @@ func (ins *Instance) makeDefaultTags(conn *connect) map[string]string {
 	if conn.ShardNum != 0 {
 		tags["shard_num"] = strconv.Itoa(conn.ShardNum)
 	}
+	for k, v := range ins.ClusterLabels[conn.Cluster] {
+		tags[k] = v
+	}
 	return tags
 }
 
@@ -501,11 +556,25 @@ func (e *clickhouseError) Error() string {
 	return fmt.Sprintf("received error code %d: %s", e.StatusCode, e.body)
 }
 
-func (ins *Instance) execQuery(address *url.URL, query string, i interface{}) error {
-	q := address.Query()
+// query runs a query over the http interface and returns the body. The url is
+// copied since the queries of one server run concurrently.
+func (ins *Instance) query(address *url.URL, query string, timeout time.Duration) ([]byte, error) {
+	u := *address
+	q := u.Query()
 	q.Set("query", query+" FORMAT JSON")
-	address.RawQuery = q.Encode()
-	req, _ := http.NewRequest("GET", address.String(), nil)
+	ctx := context.Background()
+	if timeout > 0 {
+		q.Set("max_execution_time", strconv.Itoa(int(math.Ceil(timeout.Seconds()))))
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 	if ins.Username != "" {
 		req.Header.Add("X-ClickHouse-User", ins.Username)
 	}
@@ -514,67 +583,53 @@ func (ins *Instance) execQuery(address *url.URL, query string, i interface{}) er
 	}
 	resp, err := ins.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 	if resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
-		return &clickhouseError{
+		return nil, &clickhouseError{
 			StatusCode: resp.StatusCode,
 			body:       body,
 		}
 	}
-	var response struct {
-		Data json.RawMessage
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	return io.ReadAll(resp.Body)
+}
+
+func (ins *Instance) execQuery(address *url.URL, query string, i interface{}) error {
+	body, err := ins.query(address, query, time.Duration(ins.QueryTimeout))
+	if err != nil {
 		return err
 	}
-	if err := json.Unmarshal(response.Data, i); err != nil {
-		return err
+	var response struct {
+		Data json.RawMessage
 	}
-
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+	if err := json.Unmarshal(body, &response); err != nil {
 		return err
 	}
-	return nil
+	return json.Unmarshal(response.Data, i)
 }
 
 func (ins *Instance) execCustomQuery(conn *connect, waitMetrics *sync.WaitGroup, slist *types.SampleList, metricConf MetricConfig) error {
 	defer waitMetrics.Done()
-	address := conn.url
-	q := address.Query()
-	q.Set("query", metricConf.Request+" FORMAT JSON")
-	address.RawQuery = q.Encode()
-	req, _ := http.NewRequest("GET", address.String(), nil)
-	if ins.Username != "" {
-		req.Header.Add("X-ClickHouse-User", ins.Username)
-	}
-	if ins.Password != "" {
-		req.Header.Add("X-ClickHouse-Key", ins.Password)
+
+	timeout := time.Duration(ins.QueryTimeout)
+	if metricConf.Timeout > 0 {
+		timeout = time.Duration(metricConf.Timeout)
 	}
-	resp, err := ins.HTTPClient.Do(req)
+	body, err := ins.query(conn.url, metricConf.Request, timeout)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
-		return &clickhouseError{
-			StatusCode: resp.StatusCode,
-			body:       body,
-		}
-	}
 	var response struct {
 		Data []json.RawMessage `json:"data"`
 	}
-	tags := ins.makeDefaultTags(conn)
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := json.Unmarshal(body, &response); err != nil {
 		return err
 	}
-	log.Println("content:", len(response.Data))
+
 	for _, item := range response.Data {
-		localTags := tags
+		localTags := ins.makeDefaultTags(conn)
 		for _, label := range metricConf.LabelFields {
 			localTags[label] = gjson.Get(string(item), label).String()
 		}
@@ -594,14 +649,9 @@ func (ins *Instance) execCustomQuery(conn *connect, waitMetrics *sync.WaitGroup,
 			}
 		}
 	}
-
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		return err
-	}
-
-	//slist.PushFront(types.NewSample("clickhouse_zookeeper", "root_nodes", uint64(zkRootNodes[0].ZkRootNodes), tags))
 	return nil
 }
+
 func cleanName(s string) string {
 	s = strings.Replace(s, " ", "_", -1) // Remove spaces
 	s = strings.Replace(s, "(", "", -1)  // Remove open parenthesis
@@ -652,6 +702,9 @@ const (
 	systemReplicationNumTriesSQL = "SELECT countIf(num_tries>1) AS replication_num_tries_replicas, countIf(num_tries>100) " +
 		"AS replication_too_many_tries_replicas FROM system.replication_queue SETTINGS empty_result_for_aggregation_by_empty_set=0"
 
+	systemReplicasSQL = "SELECT database, table, is_readonly, is_session_expired, absolute_delay, queue_size, " +
+		"inserts_in_queue, merges_in_queue, log_max_index - log_pointer AS log_delay, total_replicas, active_replicas FROM system.replicas"
+
 	systemDetachedPartsSQL = "SELECT count() AS detached_parts FROM system.detached_parts SETTINGS empty_result_for_aggregation_by_empty_set=0"
 
 	systemDictionariesSQL = "SELECT origin, status, bytes_allocated FROM system.dictionaries"
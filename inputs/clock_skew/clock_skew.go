@@ -0,0 +1,174 @@
+package clock_skew
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "clock_skew"
+
+type ClockSkew struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &ClockSkew{}
+	})
+}
+
+func (c *ClockSkew) Clone() inputs.Input {
+	return &ClockSkew{}
+}
+
+func (c *ClockSkew) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(ClockSkew)
+var _ inputs.InstancesGetter = new(ClockSkew)
+
+type Instance struct {
+	inputs.InstanceBase
+
+	// endpoints whose Date header is trusted, better run by different parties
+	URLs   []string `toml:"urls"`
+	Method string   `toml:"method"`
+	// endpoints with a larger round trip are ignored, their Date says little
+	MaxRTT int64 `toml:"max_rtt_ms"`
+
+	client *http.Client
+}
+
+// probe is the result of one endpoint
+type probe struct {
+	offset float64
+	rtt    float64
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.URLs) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if err := ins.InitBase(5 * time.Second); err != nil {
+		return err
+	}
+	if ins.Method == "" {
+		ins.Method = http.MethodHead
+	}
+	if ins.MaxRTT <= 0 {
+		ins.MaxRTT = 2000
+	}
+	// a retried request would distort the round trip
+	client, err := ins.HTTPClient(httpx.Retries(0))
+	if err != nil {
+		return err
+	}
+	ins.client = client
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	var mu sync.Mutex
+	var offsets []float64
+
+	inputs.GatherTargets(slist, ins.URLs, func(slist *types.SampleList, url string) {
+		tags := map[string]string{"url": url}
+		p, err := ins.probe(url)
+		if err != nil {
+			log.Println("E! clock_skew failed to probe", url, "error:", err)
+			inputs.PushGatherError(slist, url, err)
+			slist.PushSample(inputName, "up", 0, tags)
+			return
+		}
+		slist.PushSample(inputName, "up", 1, tags)
+		slist.PushSample(inputName, "offset_seconds", p.offset, tags)
+		slist.PushSample(inputName, "rtt_seconds", p.rtt, tags)
+
+		if p.rtt*1000 > float64(ins.MaxRTT) {
+			if ins.DebugMod {
+				log.Printf("D! clock_skew ignore %s, rtt %.3fs exceeds max_rtt_ms", url, p.rtt)
+			}
+			return
+		}
+		mu.Lock()
+		offsets = append(offsets, p.offset)
+		mu.Unlock()
+	})
+
+	slist.PushSample(inputName, "endpoints_used", len(offsets))
+	if len(offsets) == 0 {
+		return
+	}
+	// the median tolerates a minority of endpoints with a wrong clock
+	slist.PushSample(inputName, "estimated_offset_seconds", median(offsets))
+}
+
+// probe estimates how far the local clock is behind the endpoint, positive
+// means the local clock is slow. Date has a resolution of one second, so the
+// server time is taken as the middle of that second and compared with the
+// middle of the request.
+func (ins *Instance) probe(url string) (probe, error) {
+	req, err := http.NewRequest(ins.Method, url, nil)
+	if err != nil {
+		return probe{}, err
+	}
+	ins.SetRequestAuth(req)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	start := time.Now()
+	resp, err := ins.client.Do(req)
+	if err != nil {
+		return probe{}, err
+	}
+	end := time.Now()
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return probe{}, fmt.Errorf("no Date header in response")
+	}
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return probe{}, fmt.Errorf("invalid Date header %q: %v", date, err)
+	}
+
+	rtt := end.Sub(start)
+	local := start.Add(rtt / 2)
+	remote := server.Add(500 * time.Millisecond)
+	return probe{
+		offset: remote.Sub(local).Seconds(),
+		rtt:    rtt.Seconds(),
+	}, nil
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// DescribeMetrics lists the metrics of clock_skew
+func (c *ClockSkew) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "clock_skew_up", Type: types.Gauge, Help: "whether the endpoint returned a usable Date header", Tags: []string{"url"}},
+		{Name: "clock_skew_offset_seconds", Type: types.Gauge, Unit: "seconds", Help: "endpoint time minus local time, accurate to about half a second", Tags: []string{"url"}},
+		{Name: "clock_skew_rtt_seconds", Type: types.Gauge, Unit: "seconds", Help: "round trip of the request", Tags: []string{"url"}},
+		{Name: "clock_skew_endpoints_used", Type: types.Gauge, Help: "endpoints the estimation is based on"},
+		{Name: "clock_skew_estimated_offset_seconds", Type: types.Gauge, Unit: "seconds", Help: "median offset of the endpoints within max_rtt_ms"},
+	}
+}
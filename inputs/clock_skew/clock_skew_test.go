@@ -0,0 +1,55 @@
+package clock_skew
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestGather(t *testing.T) {
+	newServer := func(skew time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Date", time.Now().Add(skew).UTC().Format(http.TimeFormat))
+		}))
+	}
+	// one endpoint has a wrong clock, the median ignores it
+	servers := []*httptest.Server{newServer(10 * time.Second), newServer(10 * time.Second), newServer(-time.Hour)}
+	ins := &Instance{}
+	for _, s := range servers {
+		defer s.Close()
+		ins.URLs = append(ins.URLs, s.URL)
+	}
+	require.NoError(t, ins.Init())
+
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+
+	var estimated interface{}
+	for _, s := range slist.PopBackAll() {
+		if s.Metric == "clock_skew_estimated_offset_seconds" {
+			estimated = s.Value
+		}
+		if s.Metric == "clock_skew_endpoints_used" {
+			require.Equal(t, 3, s.Value)
+		}
+	}
+	require.NotNil(t, estimated)
+	require.InDelta(t, 10, estimated.(float64), 1)
+}
+
+func TestMissingDate(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+	}))
+	defer s.Close()
+
+	ins := &Instance{URLs: []string{s.URL}}
+	require.NoError(t, ins.Init())
+	_, err := ins.probe(s.URL)
+	require.Error(t, err)
+}
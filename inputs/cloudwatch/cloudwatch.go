@@ -8,6 +8,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -91,6 +92,10 @@ type Metric struct {
 	StatisticInclude *[]string    `toml:"statistic_include"`
 	MetricNames      []string     `toml:"names"`
 	Dimensions       []*Dimension `toml:"dimensions"`
+	// Namespace restricts the metrics to one namespace, empty means all namespaces of the instance
+	Namespace string `toml:"namespace"`
+	// Period overrides the period of the instance for these metrics
+	Period config.Duration `toml:"period"`
 }
 
 // Dimension defines a simplified Cloudwatch dimension (provides metric filtering).
@@ -139,6 +144,30 @@ func (ins *Instance) Init() error {
 	if ins.SdkRateLimitTokens <= 0 {
 		ins.SdkRateLimitTokens = 1000000
 	}
+	if ins.Period == 0 {
+		ins.Period = config.Duration(5 * time.Minute)
+	}
+	if ins.Delay == 0 {
+		ins.Delay = config.Duration(5 * time.Minute)
+	}
+	if err := validatePeriod(ins.Period); err != nil {
+		return err
+	}
+	for _, m := range ins.Metrics {
+		if m.Period != 0 {
+			if err := validatePeriod(m.Period); err != nil {
+				return err
+			}
+			if m.Period > ins.Period {
+				return fmt.Errorf("period %s of metrics %v is longer than the instance period %s",
+					time.Duration(m.Period), m.MetricNames, time.Duration(ins.Period))
+			}
+		}
+		// metrics of other namespaces are listed as well
+		if len(m.Namespace) != 0 && !slices.Contains(ins.Namespaces, m.Namespace) {
+			ins.Namespaces = append(ins.Namespaces, m.Namespace)
+		}
+	}
 
 	if len(ins.Namespaces) == 0 {
 		return internalTypes.ErrInstancesEmpty
@@ -305,6 +334,7 @@ func (ins *Instance) initializeCloudWatch() error {
 type filteredMetric struct {
 	metrics    []types.Metric
 	statFilter filter.Filter
+	period     int32
 }
 
 // getFilteredMetrics returns metrics specified in the config file or metrics listed from Cloudwatch.
@@ -322,6 +352,10 @@ func getFilteredMetrics(c *Instance) ([]filteredMetric, error) {
 	if c.Metrics != nil {
 		for _, m := range c.Metrics {
 			metrics := []types.Metric{}
+			namespaces := c.Namespaces
+			if len(m.Namespace) != 0 {
+				namespaces = []string{m.Namespace}
+			}
 			if !hasWildcard(m.Dimensions) {
 				dimensions := make([]types.Dimension, 0, len(m.Dimensions))
 				for _, d := range m.Dimensions {
@@ -331,7 +365,7 @@ func getFilteredMetrics(c *Instance) ([]filteredMetric, error) {
 					})
 				}
 				for _, name := range m.MetricNames {
-					for _, namespace := range c.Namespaces {
+					for _, namespace := range namespaces {
 						metrics = append(metrics, types.Metric{
 							Namespace:  aws.String(namespace),
 							MetricName: aws.String(name),
@@ -343,14 +377,13 @@ func getFilteredMetrics(c *Instance) ([]filteredMetric, error) {
 				allMetrics := c.fetchNamespaceMetrics()
 				for _, name := range m.MetricNames {
 					for _, metric := range allMetrics {
-						if isSelected(name, metric, m.Dimensions) {
-							for _, namespace := range c.Namespaces {
-								metrics = append(metrics, types.Metric{
-									Namespace:  aws.String(namespace),
-									MetricName: aws.String(name),
-									Dimensions: metric.Dimensions,
-								})
-							}
+						// listed metrics carry their own namespace, don't copy them into the others
+						if isSelected(name, metric, m.Dimensions) && slices.Contains(namespaces, aws.ToString(metric.Namespace)) {
+							metrics = append(metrics, types.Metric{
+								Namespace:  metric.Namespace,
+								MetricName: aws.String(name),
+								Dimensions: metric.Dimensions,
+							})
 						}
 					}
 				}
@@ -367,9 +400,14 @@ func getFilteredMetrics(c *Instance) ([]filteredMetric, error) {
 				return nil, err
 			}
 
+			period := c.Period
+			if m.Period != 0 {
+				period = m.Period
+			}
 			fMetrics = append(fMetrics, filteredMetric{
 				metrics:    metrics,
 				statFilter: statFilter,
+				period:     int32(time.Duration(period).Seconds()),
 			})
 		}
 	} else {
@@ -378,6 +416,7 @@ func getFilteredMetrics(c *Instance) ([]filteredMetric, error) {
 			{
 				metrics:    metrics,
 				statFilter: c.statFilter,
+				period:     int32(time.Duration(c.Period).Seconds()),
 			},
 		}
 	}
@@ -456,7 +495,7 @@ func (ins *Instance) getDataQueries(filteredMetrics []filteredMetric) map[string
 					Label: aws.String(snakeCase(*metric.MetricName + "_average")),
 					MetricStat: &types.MetricStat{
 						Metric: &filtered.metrics[j],
-						Period: aws.Int32(int32(time.Duration(ins.Period).Seconds())),
+						Period: aws.Int32(filtered.period),
 						Stat:   aws.String(StatisticAverage),
 					},
 				})
@@ -468,7 +507,7 @@ func (ins *Instance) getDataQueries(filteredMetrics []filteredMetric) map[string
 					Label: aws.String(snakeCase(*metric.MetricName + "_maximum")),
 					MetricStat: &types.MetricStat{
 						Metric: &filtered.metrics[j],
-						Period: aws.Int32(int32(time.Duration(ins.Period).Seconds())),
+						Period: aws.Int32(filtered.period),
 						Stat:   aws.String(StatisticMaximum),
 					},
 				})
@@ -480,7 +519,7 @@ func (ins *Instance) getDataQueries(filteredMetrics []filteredMetric) map[string
 					Label: aws.String(snakeCase(*metric.MetricName + "_minimum")),
 					MetricStat: &types.MetricStat{
 						Metric: &filtered.metrics[j],
-						Period: aws.Int32(int32(time.Duration(ins.Period).Seconds())),
+						Period: aws.Int32(filtered.period),
 						Stat:   aws.String(StatisticMinimum),
 					},
 				})
@@ -492,7 +531,7 @@ func (ins *Instance) getDataQueries(filteredMetrics []filteredMetric) map[string
 					Label: aws.String(snakeCase(*metric.MetricName + "_sum")),
 					MetricStat: &types.MetricStat{
 						Metric: &filtered.metrics[j],
-						Period: aws.Int32(int32(time.Duration(ins.Period).Seconds())),
+						Period: aws.Int32(filtered.period),
 						Stat:   aws.String(StatisticSum),
 					},
 				})
@@ -504,7 +543,7 @@ func (ins *Instance) getDataQueries(filteredMetrics []filteredMetric) map[string
 					Label: aws.String(snakeCase(*metric.MetricName + "_sample_count")),
 					MetricStat: &types.MetricStat{
 						Metric: &filtered.metrics[j],
-						Period: aws.Int32(int32(time.Duration(ins.Period).Seconds())),
+						Period: aws.Int32(filtered.period),
 						Stat:   aws.String(StatisticSampleCount),
 					},
 				})
@@ -642,6 +681,22 @@ func (ins *Instance) getDataInputs(dataQueries []types.MetricDataQuery) *cwClien
 	}
 }
 
+// validatePeriod checks the period is one CloudWatch accepts: 1, 5, 10, 30 seconds
+// for high resolution metrics, or a multiple of 60 seconds.
+func validatePeriod(period config.Duration) error {
+	d := time.Duration(period)
+	if d%time.Second != 0 {
+		return fmt.Errorf("invalid period %s, must be whole seconds", d)
+	}
+	switch sec := int64(d.Seconds()); {
+	case sec == 1, sec == 5, sec == 10, sec == 30:
+	case sec > 0 && sec%60 == 0:
+	default:
+		return fmt.Errorf("invalid period %s, must be 1s, 5s, 10s, 30s or a multiple of 60s", d)
+	}
+	return nil
+}
+
 // isValid checks the validity of the metric cache.
 func (f *metricCache) isValid() bool {
 	return f.metrics != nil && time.Since(f.built) < f.ttl
@@ -10,6 +10,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 
@@ -83,9 +84,9 @@ func (ins *Instance) Init() error {
 		return err
 	}
 
-	conf.Transport = &http.Transport{
-		TLSClientConfig: tlsCfg,
-	}
+	trans := httpx.NewTransport()
+	trans.TLSClientConfig = tlsCfg
+	conf.HttpClient = &http.Client{Transport: httpx.WrapDefault(trans)}
 
 	client, err := api.NewClient(conf)
 	if err != nil {
@@ -18,6 +18,7 @@ import (
 	"flashcat.cloud/categraf/inputs/elasticsearch/pkg/clusterinfo"
 	"flashcat.cloud/categraf/inputs/elasticsearch/pkg/roundtripper"
 	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 
@@ -71,6 +72,7 @@ type (
 		EsURL *url.URL
 		*http.Client
 		tls.ClientConfig
+		config.HTTPProxy
 		indexMatchers   map[string]filter.Filter
 		serverInfo      map[string]serverInfo
 		hasRunBefore    bool
@@ -338,11 +340,14 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 
 func (ins *Instance) createHTTPClient() (*http.Client, error) {
 	var httpTransport http.RoundTripper
-	var err error
-	httpTransport = &http.Transport{
-		Proxy:               http.ProxyFromEnvironment,
-		MaxIdleConnsPerHost: 1,
+	proxy, err := ins.Proxy()
+	if err != nil {
+		return nil, err
 	}
+	trans := httpx.NewTransport()
+	trans.Proxy = proxy
+	trans.MaxIdleConnsPerHost = 1
+	httpTransport = httpx.WrapDefault(trans)
 	if ins.ApiKey != "" {
 		httpTransport = &transportWithAPIKey{
 			underlyingTransport: httpTransport,
@@ -355,11 +360,8 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		if err != nil {
 			return nil, err
 		}
-		httpTransport = &http.Transport{
-			TLSClientConfig:     tlsConfig,
-			Proxy:               http.ProxyFromEnvironment,
-			MaxIdleConnsPerHost: 1,
-		}
+		trans.TLSClientConfig = tlsConfig
+		httpTransport = httpx.WrapDefault(trans)
 	}
 
 	client := &http.Client{
@@ -0,0 +1,150 @@
+package inputs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// ErrorMetric is pushed with value 1 by inputs failing to gather, so failures
+// are alertable without scraping the log. The agent fills the input label.
+const ErrorMetric = "agent_input_error"
+
+// Reasons of ErrorReason, inputs may use their own low cardinality ones too
+const (
+	ReasonTimeout    = "timeout"
+	ReasonRefused    = "connection_refused"
+	ReasonDNS        = "dns"
+	ReasonTLS        = "tls"
+	ReasonPermission = "permission"
+	ReasonNotFound   = "not_found"
+	ReasonPanic      = "panic"
+	ReasonError      = "error"
+	// the instance gathered without a single sample, reported by the agent
+	ReasonNoSamples = "no_samples"
+)
+
+// PushError records a failure of instance (e.g. the address of the target)
+// next to the log line, reason must be of low cardinality, never err.Error()
+func PushError(slist *types.SampleList, instance, reason string, labels ...map[string]string) {
+	s := types.NewSample("", ErrorMetric, 1, labels...)
+	s.Labels["instance"] = instance
+	s.Labels["reason"] = reason
+	slist.PushFront(s)
+}
+
+// PushGatherError is PushError with the reason derived from err
+func PushGatherError(slist *types.SampleList, instance string, err error, labels ...map[string]string) {
+	PushError(slist, instance, ErrorReason(err), labels...)
+}
+
+// ErrorReason classifies the common network and filesystem errors
+func ErrorReason(err error) string {
+	var (
+		netErr       net.Error
+		dnsErr       *net.DNSError
+		unknownCA    x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidCert  x509.CertificateInvalidError
+		recordHdrErr tls.RecordHeaderError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &dnsErr):
+		return ReasonDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonRefused
+	case errors.As(err, &unknownCA), errors.As(err, &hostnameErr), errors.As(err, &invalidCert),
+		errors.As(err, &recordHdrErr):
+		return ReasonTLS
+	case errors.Is(err, os.ErrPermission):
+		return ReasonPermission
+	case errors.Is(err, os.ErrNotExist):
+		return ReasonNotFound
+	}
+	return ReasonError
+}
+
+// targetFields are the config fields naming the targets of an instance
+var targetFields = []string{"Address", "Addresses", "Server", "Servers", "Target", "Targets",
+	"URL", "URLs", "Url", "Urls", "Endpoint"}
+
+// InstanceLabel names an instance for the instance label of the errors the
+// agent reports: the targets it's configured with, without credentials, or a
+// checksum of its config if it has no plain target. Both stay the same across
+// reloads, unlike its position among the instances.
+func InstanceLabel(ins interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(ins))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for _, name := range targetFields {
+		sf, ok := v.Type().FieldByName(name)
+		if !ok || !sf.IsExported() {
+			continue
+		}
+		var targets []string
+		f := reflect.Indirect(v.FieldByIndex(sf.Index))
+		switch {
+		case f.Kind() == reflect.String:
+			targets = strings.Fields(f.String())
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+			for i := 0; i < f.Len(); i++ {
+				targets = append(targets, f.Index(i).String())
+			}
+		}
+		if label, ok := plainTargets(targets); ok {
+			return label
+		}
+	}
+
+	bs, err := json.Marshal(ins)
+	if err != nil {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write(bs)
+	return fmt.Sprintf("config-%08x", h.Sum32())
+}
+
+// plainTargets joins targets which are host:port or urls, the user info of
+// urls dropped. Anything else may be a dsn holding a password.
+func plainTargets(targets []string) (string, bool) {
+	if len(targets) == 0 {
+		return "", false
+	}
+	plain := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if strings.Contains(t, "://") {
+			u, err := url.Parse(t)
+			if err != nil {
+				return "", false
+			}
+			u.User = nil
+			u.RawQuery = ""
+			plain = append(plain, u.String())
+			continue
+		}
+		if strings.ContainsAny(t, "=@;() \t") {
+			return "", false
+		}
+		plain = append(plain, t)
+	}
+	return strings.Join(plain, ","), true
+}
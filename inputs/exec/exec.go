@@ -16,9 +16,9 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/parser"
-	"flashcat.cloud/categraf/parser/falcon"
-	"flashcat.cloud/categraf/parser/influx"
-	"flashcat.cloud/categraf/parser/prometheus"
+	_ "flashcat.cloud/categraf/parser/falcon"
+	_ "flashcat.cloud/categraf/parser/influx"
+	_ "flashcat.cloud/categraf/parser/prometheus"
 	"flashcat.cloud/categraf/pkg/cmdx"
 	"flashcat.cloud/categraf/types"
 )
@@ -84,15 +84,11 @@ func (ins *Instance) Init() error {
 		return types.ErrInstancesEmpty
 	}
 
-	if ins.DataFormat == "" || ins.DataFormat == "influx" {
-		ins.parser = influx.NewParser()
-	} else if ins.DataFormat == "falcon" {
-		ins.parser = falcon.NewParser()
-	} else if strings.HasPrefix(ins.DataFormat, "prom") {
-		ins.parser = prometheus.EmptyParser()
-	} else {
-		return fmt.Errorf("data_format(%s) not supported", ins.DataFormat)
+	p, err := parser.New(ins.DataFormat)
+	if err != nil {
+		return err
 	}
+	ins.parser = p
 
 	if ins.Timeout == 0 {
 		ins.Timeout = config.Duration(time.Second * 5)
@@ -281,6 +281,11 @@ func (ins *Instance) count(slist *types.SampleList, basedir string, glob globpat
 
 			gauge["oldest_file_timestamp"] = oldestFileTimestamp[path]
 			gauge["newest_file_timestamp"] = newestFileTimestamp[path]
+			if childCount[path] > 0 {
+				now := time.Now().UnixNano()
+				gauge["oldest_file_age_seconds"] = float64(now-oldestFileTimestamp[path]) / float64(time.Second)
+				gauge["newest_file_age_seconds"] = float64(now-newestFileTimestamp[path]) / float64(time.Second)
+			}
 
 			slist.PushSamples(inputName, gauge, tags)
 		}
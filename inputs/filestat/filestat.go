@@ -0,0 +1,236 @@
+package filestat
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/inputs/filecount"
+	"flashcat.cloud/categraf/pkg/globpath"
+	"flashcat.cloud/categraf/pkg/state"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "filestat"
+
+type FileStat struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &FileStat{}
+	})
+}
+
+func (fs *FileStat) Clone() inputs.Input {
+	return &FileStat{}
+}
+
+func (fs *FileStat) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(FileStat)
+var _ inputs.InstancesGetter = new(FileStat)
+
+type Instance struct {
+	config.InstanceConfig
+
+	// files or globs, ** matches any number of directories
+	Files  []string `toml:"files"`
+	MD5    bool     `toml:"md5"`
+	SHA256 bool     `toml:"sha256"`
+	// files larger than that are not hashed, defaults to 100MiB, negative means unlimited
+	MaxChecksumSize filecount.Size `toml:"max_checksum_size"`
+
+	globs []*globpath.GlobPath
+	// checksums are only computed again when size or mtime changes, they are
+	// persisted so a restart doesn't hash every file again
+	checksums map[string]checksum
+	store     *state.Namespace
+}
+
+type checksum struct {
+	Size   int64     `json:"size"`
+	Mtime  time.Time `json:"mtime"`
+	MD5    string    `json:"md5,omitempty"`
+	SHA256 string    `json:"sha256,omitempty"`
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Files) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	ins.globs = ins.globs[:0]
+	for _, f := range ins.Files {
+		g, err := globpath.Compile(filepath.Clean(f))
+		if err != nil {
+			return err
+		}
+		ins.globs = append(ins.globs, g)
+	}
+	if ins.MaxChecksumSize == 0 {
+		ins.MaxChecksumSize = 100 * 1024 * 1024
+	}
+	ins.checksums = make(map[string]checksum)
+	if ins.MD5 || ins.SHA256 {
+		if store, err := state.Default(); err != nil {
+			log.Println("W! filestat checksums are not persisted:", err)
+		} else {
+			ins.store = store.Namespace(inputName)
+		}
+	}
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	now := time.Now()
+	seen := make(map[string]struct{})
+	for i, g := range ins.globs {
+		files := g.Match()
+		if len(files) == 0 {
+			// a missing file or a glob without matches, labeled by the glob itself
+			slist.PushSample(inputName, "exists", 0, map[string]string{"file": ins.Files[i]})
+			continue
+		}
+		for _, file := range files {
+			if _, has := seen[file]; has {
+				continue
+			}
+			seen[file] = struct{}{}
+			ins.gatherFile(slist, file, now)
+		}
+	}
+
+	for file := range ins.checksums {
+		if _, has := seen[file]; !has {
+			delete(ins.checksums, file)
+			if ins.store != nil {
+				ins.store.Delete(file)
+			}
+		}
+	}
+}
+
+func (ins *Instance) gatherFile(slist *types.SampleList, file string, now time.Time) {
+	tags := map[string]string{"file": file}
+	info, err := os.Stat(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("E! failed to stat file:", file, "error:", err)
+		}
+		slist.PushSample(inputName, "exists", 0, tags)
+		return
+	}
+
+	fields := map[string]interface{}{
+		"exists":                   1,
+		"size_bytes":               info.Size(),
+		"modification_time":        info.ModTime().Unix(),
+		"modification_age_seconds": now.Sub(info.ModTime()).Seconds(),
+	}
+	slist.PushSamples(inputName, fields, tags)
+
+	if (!ins.MD5 && !ins.SHA256) || !info.Mode().IsRegular() {
+		return
+	}
+	if ins.MaxChecksumSize > 0 && info.Size() > int64(ins.MaxChecksumSize) {
+		if ins.DebugMod {
+			log.Println("D! skip checksum of", file, "larger than max_checksum_size")
+		}
+		return
+	}
+
+	sum, has := ins.checksums[file]
+	if !has {
+		sum, has = ins.loadChecksum(file)
+	}
+	if !has || !ins.valid(sum, info) {
+		sum, err = ins.checksum(file)
+		if err != nil {
+			log.Println("E! failed to compute checksum of file:", file, "error:", err)
+			return
+		}
+		sum.Size, sum.Mtime = info.Size(), info.ModTime()
+		ins.saveChecksum(file, sum)
+	}
+	ins.checksums[file] = sum
+
+	// checksums are strings, so they are labels of an info series
+	labels := map[string]string{"file": file}
+	if ins.MD5 {
+		labels["md5"] = sum.MD5
+	}
+	if ins.SHA256 {
+		labels["sha256"] = sum.SHA256
+	}
+	slist.PushSample(inputName, "checksum_info", 1, labels)
+}
+
+func (ins *Instance) checksum(file string) (checksum, error) {
+	var sum checksum
+	f, err := os.Open(file)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	var hashes []io.Writer
+	var m, s hash.Hash
+	if ins.MD5 {
+		m = md5.New()
+		hashes = append(hashes, m)
+	}
+	if ins.SHA256 {
+		s = sha256.New()
+		hashes = append(hashes, s)
+	}
+	if _, err := io.Copy(io.MultiWriter(hashes...), f); err != nil {
+		return sum, err
+	}
+	if m != nil {
+		sum.MD5 = hex.EncodeToString(m.Sum(nil))
+	}
+	if s != nil {
+		sum.SHA256 = hex.EncodeToString(s.Sum(nil))
+	}
+	return sum, nil
+}
+
+// valid tells whether sum is of the current content and has the configured hashes
+func (ins *Instance) valid(sum checksum, info os.FileInfo) bool {
+	return sum.Size == info.Size() && sum.Mtime.Equal(info.ModTime()) &&
+		(!ins.MD5 || sum.MD5 != "") && (!ins.SHA256 || sum.SHA256 != "")
+}
+
+func (ins *Instance) loadChecksum(file string) (checksum, bool) {
+	var sum checksum
+	if ins.store == nil {
+		return sum, false
+	}
+	has, err := ins.store.GetJSON(file, &sum)
+	if err != nil {
+		log.Println("W! failed to load checksum of file:", file, "error:", err)
+		return sum, false
+	}
+	return sum, has
+}
+
+func (ins *Instance) saveChecksum(file string, sum checksum) {
+	if ins.store == nil {
+		return
+	}
+	if err := ins.store.PutJSON(file, sum); err != nil {
+		log.Println("W! failed to save checksum of file:", file, "error:", err)
+	}
+}
@@ -0,0 +1,35 @@
+package filestat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestGather(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte("hello"), 0644))
+
+	ins := &Instance{
+		Files: []string{filepath.Join(dir, "*.log"), filepath.Join(dir, "missing.csv")},
+		MD5:   true,
+	}
+	require.NoError(t, ins.Init())
+
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+
+	values := make(map[string]*types.Sample)
+	for _, s := range slist.PopBackAll() {
+		values[s.Metric+" "+filepath.Base(s.Labels["file"])] = s
+	}
+	require.Equal(t, 1, values["filestat_exists a.log"].Value)
+	require.Equal(t, int64(5), values["filestat_size_bytes a.log"].Value)
+	require.Equal(t, 0, values["filestat_exists missing.csv"].Value)
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", values["filestat_checksum_info a.log"].Labels["md5"])
+	require.NotContains(t, values["filestat_checksum_info a.log"].Labels, "sha256")
+}
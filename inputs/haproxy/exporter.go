@@ -32,6 +32,8 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/pkg/httpx"
 )
 
 const (
@@ -241,7 +243,7 @@ type Exporter struct {
 }
 
 // NewExporter returns an initialized Exporter.
-func NewExporter(uri string, sslVerify, proxyFromEnv bool, selectedServerMetrics map[int]metricInfo, excludedServerStates string, timeout time.Duration) (*Exporter, error) {
+func NewExporter(uri string, sslVerify bool, proxy func(*http.Request) (*url.URL, error), selectedServerMetrics map[int]metricInfo, excludedServerStates string, timeout time.Duration) (*Exporter, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
@@ -251,7 +253,7 @@ func NewExporter(uri string, sslVerify, proxyFromEnv bool, selectedServerMetrics
 	var fetchStat func() (io.ReadCloser, error)
 	switch u.Scheme {
 	case "http", "https", "file":
-		fetchStat = fetchHTTP(uri, sslVerify, proxyFromEnv, timeout)
+		fetchStat = fetchHTTP(uri, sslVerify, proxy, timeout)
 	case "unix":
 		fetchInfo = fetchUnix("unix", u.Path, showInfoCmd, timeout)
 		fetchStat = fetchUnix("unix", u.Path, showStatCmd, timeout)
@@ -322,14 +324,13 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- e.csvParseFailures
 }
 
-func fetchHTTP(uri string, sslVerify, proxyFromEnv bool, timeout time.Duration) func() (io.ReadCloser, error) {
-	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: !sslVerify}}
-	if proxyFromEnv {
-		tr.Proxy = http.ProxyFromEnvironment
-	}
+func fetchHTTP(uri string, sslVerify bool, proxy func(*http.Request) (*url.URL, error), timeout time.Duration) func() (io.ReadCloser, error) {
+	tr := httpx.NewTransport()
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: !sslVerify}
+	tr.Proxy = proxy
 	client := http.Client{
 		Timeout:   timeout,
-		Transport: tr,
+		Transport: httpx.WrapDefault(tr),
 	}
 
 	return func() (io.ReadCloser, error) {
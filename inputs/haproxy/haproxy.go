@@ -3,6 +3,8 @@ package haproxy
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"time"
 
 	"flashcat.cloud/categraf/config"
@@ -59,6 +61,8 @@ type Instance struct {
 
 	// Flag that enables using HTTP proxy settings from environment variables ($http_proxy, $https_proxy, $no_proxy)
 	HTTPProxyFromEnv bool `toml:"proxy_from_env"`
+	// http_proxy of the instance or global.http_proxy apply even without proxy_from_env
+	config.HTTPProxy
 
 	e *Exporter `toml:"-"`
 }
@@ -85,10 +89,17 @@ func (ins *Instance) Init() error {
 		return fmt.Errorf("failed to filtering server metrics: %s", err)
 	}
 
+	var proxy func(*http.Request) (*url.URL, error)
+	if ins.HTTPProxyFromEnv || ins.HTTPProxyURL != "" || (config.Config != nil && config.Config.Global.HTTPProxyURL != "") {
+		if proxy, err = ins.Proxy(); err != nil {
+			return err
+		}
+	}
+
 	e, err := NewExporter(
 		ins.URI,
 		ins.SSLVerify,
-		ins.HTTPProxyFromEnv,
+		proxy,
 		selectedServerMetrics,
 		ins.ServerExcludeStates,
 		time.Duration(ins.Timeout),
@@ -12,6 +12,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/set"
 	"flashcat.cloud/categraf/pkg/tls"
 )
@@ -151,11 +152,11 @@ func (hrp *HTTPProvider) check() error {
 		return err
 	}
 
+	trans := httpx.NewTransport()
+	trans.TLSClientConfig = tlsc
 	hrp.client = &http.Client{
-		Timeout: time.Duration(hrp.Timeout) * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: tlsc,
-		},
+		Timeout:   time.Duration(hrp.Timeout) * time.Second,
+		Transport: httpx.WrapDefault(trans),
 	}
 
 	return nil
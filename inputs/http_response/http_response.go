@@ -126,7 +126,9 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 
 		httpx.DisableKeepAlives(*ins.DisableKeepAlives),
 		httpx.Timeout(time.Duration(ins.Timeout)),
-		httpx.FollowRedirects(*ins.FollowRedirects))
+		httpx.FollowRedirects(*ins.FollowRedirects),
+		// the probe reports the outcome and time of a single request
+		httpx.Retries(0))
 	return client, err
 }
 
@@ -223,6 +225,7 @@ func (ins *Instance) gather(slist *types.SampleList, target string) {
 	returnTags, fields, err = ins.httpGather(target)
 	if err != nil {
 		log.Println("E! failed to gather http target:", target, "error:", err)
+		inputs.PushGatherError(slist, target, err)
 	}
 
 	for k, v := range returnTags {
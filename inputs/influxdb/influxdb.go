@@ -11,6 +11,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -66,12 +67,12 @@ func (ins *Instance) Init() error {
 		if err != nil {
 			return err
 		}
+		trans := httpx.NewTransport()
+		trans.ResponseHeaderTimeout = time.Duration(ins.Timeout)
+		trans.TLSClientConfig = tlsCfg
 		ins.client = &http.Client{
-			Transport: &http.Transport{
-				ResponseHeaderTimeout: time.Duration(ins.Timeout),
-				TLSClientConfig:       tlsCfg,
-			},
-			Timeout: time.Duration(ins.Timeout),
+			Transport: httpx.WrapDefault(trans),
+			Timeout:   time.Duration(ins.Timeout),
 		}
 	}
 
@@ -21,6 +21,16 @@ type InstancesGetter interface {
 	GetInstances() []Instance
 }
 
+// Disabler is implemented by plugins and instances embedding config.InternalConfig
+type Disabler interface {
+	Disabled() bool
+}
+
+// MetricsDescriber is implemented by inputs that declare the metrics they emit
+type MetricsDescriber interface {
+	DescribeMetrics() []types.MetricDesc
+}
+
 func MayInit(t interface{}) error {
 	if initializer, ok := t.(Initializer); ok {
 		return initializer.Init()
@@ -28,6 +38,13 @@ func MayInit(t interface{}) error {
 	return nil
 }
 
+func MayDisabled(t interface{}) bool {
+	if disabler, ok := t.(Disabler); ok {
+		return disabler.Disabled()
+	}
+	return false
+}
+
 func MayGather(t interface{}, slist *types.SampleList) {
 	if gather, ok := t.(SampleGatherer); ok {
 		gather.Gather(slist)
@@ -40,6 +57,13 @@ func MayDrop(t interface{}) {
 	}
 }
 
+func MayDescribeMetrics(t interface{}) ([]types.MetricDesc, bool) {
+	if describer, ok := t.(MetricsDescriber); ok {
+		return describer.DescribeMetrics(), true
+	}
+	return nil, false
+}
+
 func MayGetInstances(t interface{}) []Instance {
 	if instancesGetter, ok := t.(InstancesGetter); ok {
 		return instancesGetter.GetInstances()
@@ -15,6 +15,7 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -115,12 +116,12 @@ func (ins *Instance) newHTTPClient() (*http.Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parse jenkins config[%s]: %v", ins.URL, err)
 	}
+	trans := httpx.NewTransport()
+	trans.TLSClientConfig = tlsCfg
+	trans.MaxIdleConns = ins.MaxConnections
 	return &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsCfg,
-			MaxIdleConns:    ins.MaxConnections,
-		},
-		Timeout: time.Duration(ins.ResponseTimeout),
+		Transport: httpx.WrapDefault(trans),
+		Timeout:   time.Duration(ins.ResponseTimeout),
 	}, nil
 }
 
@@ -10,6 +10,7 @@ import (
 	"path"
 	"time"
 
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 )
 
@@ -101,13 +102,12 @@ func NewClient(address string, config *ClientConfig) (*Client, error) {
 		return nil, err
 	}
 
-	transport := &http.Transport{
-		ResponseHeaderTimeout: config.ResponseTimeout,
-		TLSClientConfig:       tlsConfig,
-	}
+	transport := httpx.NewTransport()
+	transport.ResponseHeaderTimeout = config.ResponseTimeout
+	transport.TLSClientConfig = tlsConfig
 
 	client := &http.Client{
-		Transport: transport,
+		Transport: httpx.WrapDefault(transport),
 		Timeout:   config.ResponseTimeout,
 	}
 
@@ -0,0 +1,389 @@
+package kafka_consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/inputs/kafka/exporter"
+	"flashcat.cloud/categraf/parser"
+	_ "flashcat.cloud/categraf/parser/falcon"
+	_ "flashcat.cloud/categraf/parser/influx"
+	_ "flashcat.cloud/categraf/parser/prometheus"
+	"flashcat.cloud/categraf/pkg/tls"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "kafka_consumer"
+
+const (
+	// offsets are marked as soon as the message is parsed and committed every commit_interval
+	commitImmediate = "immediate"
+	// offsets are marked once the samples of the message are handed over by Gather,
+	// messages are consumed again if categraf dies in between
+	commitAfterGather = "after_gather"
+)
+
+type KafkaConsumer struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &KafkaConsumer{}
+	})
+}
+
+func (k *KafkaConsumer) Clone() inputs.Input {
+	return &KafkaConsumer{}
+}
+
+func (k *KafkaConsumer) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(KafkaConsumer)
+var _ inputs.InstancesGetter = new(KafkaConsumer)
+
+type Instance struct {
+	config.InstanceConfig
+
+	Brokers       []string `toml:"brokers" required:"true"`
+	Topics        []string `toml:"topics" required:"true"`
+	ConsumerGroup string   `toml:"consumer_group"`
+	ClientID      string   `toml:"client_id"`
+	KafkaVersion  string   `toml:"kafka_version"`
+	// where a new consumer group starts, oldest or newest
+	Offset string `toml:"offset"`
+	// range, roundrobin or sticky
+	BalanceStrategy string `toml:"balance_strategy"`
+
+	// immediate or after_gather
+	CommitStrategy         string          `toml:"commit_strategy"`
+	CommitInterval         config.Duration `toml:"commit_interval"`
+	MaxUndeliveredMessages int             `toml:"max_undelivered_messages"`
+	MaxMessageLen          int             `toml:"max_message_len"`
+
+	// influx, falcon or prometheus
+	DataFormat string `toml:"data_format"`
+	// label holding the topic of the message, empty means no label
+	TopicTag string `toml:"topic_tag"`
+
+	UseSASL          bool   `toml:"use_sasl"`
+	UseSASLHandshake *bool  `toml:"use_sasl_handshake"`
+	SASLUsername     string `toml:"sasl_username"`
+	SASLPassword     string `toml:"sasl_password"`
+	// plain, scram-sha256 or scram-sha512
+	SASLMechanism string `toml:"sasl_mechanism"`
+
+	tls.ClientConfig
+
+	parser parser.Parser
+	group  sarama.ConsumerGroup
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	buffer *types.SampleList
+
+	// messages parsed but not handed over yet, pending waits to be marked with
+	// after_gather, buffered counts the messages already marked with immediate
+	pendingMu   sync.Mutex
+	pending     []pendingMessage
+	buffered    int
+	undelivered chan struct{}
+
+	countersMu sync.Mutex
+	received   uint64
+	parseErrs  uint64
+	tooLong    uint64
+}
+
+type pendingMessage struct {
+	session sarama.ConsumerGroupSession
+	msg     *sarama.ConsumerMessage
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Brokers) == 0 || len(ins.Topics) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.ConsumerGroup == "" {
+		ins.ConsumerGroup = "categraf_metrics_consumers"
+	}
+	if ins.ClientID == "" {
+		ins.ClientID = "categraf"
+	}
+	if ins.CommitStrategy == "" {
+		ins.CommitStrategy = commitImmediate
+	}
+	if ins.CommitStrategy != commitImmediate && ins.CommitStrategy != commitAfterGather {
+		return fmt.Errorf("commit_strategy %q is invalid, should be immediate or after_gather", ins.CommitStrategy)
+	}
+	if ins.CommitInterval <= 0 {
+		ins.CommitInterval = config.Duration(time.Second)
+	}
+	if ins.MaxUndeliveredMessages <= 0 {
+		ins.MaxUndeliveredMessages = 1000
+	}
+
+	p, err := parser.New(ins.DataFormat)
+	if err != nil {
+		return err
+	}
+	ins.parser = p
+
+	cfg, err := ins.saramaConfig()
+	if err != nil {
+		return err
+	}
+	group, err := sarama.NewConsumerGroup(ins.Brokers, ins.ConsumerGroup, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer group %s: %v", ins.ConsumerGroup, err)
+	}
+	ins.group = group
+	ins.buffer = types.NewSampleList()
+	ins.undelivered = make(chan struct{}, ins.MaxUndeliveredMessages)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ins.cancel = cancel
+	ins.wg.Add(2)
+	go ins.consume(ctx)
+	go func() {
+		defer ins.wg.Done()
+		for err := range group.Errors() {
+			log.Println("E! kafka_consumer group", ins.ConsumerGroup, "error:", err)
+		}
+	}()
+	return nil
+}
+
+func (ins *Instance) saramaConfig() (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = ins.ClientID
+	cfg.Consumer.Return.Errors = true
+	cfg.Consumer.Offsets.AutoCommit.Enable = true
+	cfg.Consumer.Offsets.AutoCommit.Interval = time.Duration(ins.CommitInterval)
+
+	if ins.KafkaVersion != "" {
+		version, err := sarama.ParseKafkaVersion(ins.KafkaVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Version = version
+	}
+
+	switch ins.Offset {
+	case "", "oldest":
+		cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "newest":
+		cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("offset %q is invalid, should be oldest or newest", ins.Offset)
+	}
+
+	switch ins.BalanceStrategy {
+	case "", "range":
+		cfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "roundrobin":
+		cfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "sticky":
+		cfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return nil, fmt.Errorf("balance_strategy %q is invalid, should be range, roundrobin or sticky", ins.BalanceStrategy)
+	}
+
+	if ins.UseSASL {
+		switch strings.ToLower(ins.SASLMechanism) {
+		case "", "plain":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case "scram-sha256":
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &exporter.XDGSCRAMClient{HashGeneratorFcn: exporter.SHA256}
+			}
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		case "scram-sha512":
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &exporter.XDGSCRAMClient{HashGeneratorFcn: exporter.SHA512}
+			}
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		default:
+			return nil, fmt.Errorf("sasl_mechanism %q is invalid, should be plain, scram-sha256 or scram-sha512", ins.SASLMechanism)
+		}
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.Handshake = ins.UseSASLHandshake == nil || *ins.UseSASLHandshake
+		cfg.Net.SASL.User = ins.SASLUsername
+		cfg.Net.SASL.Password = ins.SASLPassword
+	}
+
+	tlsConfig, err := ins.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsConfig
+	}
+	return cfg, nil
+}
+
+// consume joins the group again after every rebalance or error until Drop
+func (ins *Instance) consume(ctx context.Context) {
+	defer ins.wg.Done()
+	for {
+		err := ins.group.Consume(ctx, ins.Topics, ins)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Println("E! kafka_consumer failed to consume", ins.Topics, "error:", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
+
+func (ins *Instance) Drop() {
+	if ins.cancel == nil {
+		return
+	}
+	ins.cancel()
+	if err := ins.group.Close(); err != nil {
+		log.Println("E! kafka_consumer failed to close consumer group:", err)
+	}
+	ins.wg.Wait()
+}
+
+// Setup implements sarama.ConsumerGroupHandler
+func (ins *Instance) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup implements sarama.ConsumerGroupHandler, the pending messages of the
+// ended session can't be marked any more and are consumed again
+func (ins *Instance) Cleanup(sarama.ConsumerGroupSession) error {
+	ins.pendingMu.Lock()
+	defer ins.pendingMu.Unlock()
+	for range ins.pending {
+		<-ins.undelivered
+	}
+	ins.pending = nil
+	return nil
+}
+
+// ConsumeClaim implements sarama.ConsumerGroupHandler, it's called for every
+// partition concurrently
+func (ins *Instance) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			// wait for Gather when too many messages are not handed over yet
+			select {
+			case ins.undelivered <- struct{}{}:
+			case <-session.Context().Done():
+				return nil
+			}
+			if !ins.handle(msg) {
+				<-ins.undelivered
+				session.MarkMessage(msg, "")
+				continue
+			}
+			ins.pendingMu.Lock()
+			if ins.CommitStrategy == commitAfterGather {
+				ins.pending = append(ins.pending, pendingMessage{session: session, msg: msg})
+			} else {
+				session.MarkMessage(msg, "")
+				ins.buffered++
+			}
+			ins.pendingMu.Unlock()
+		}
+	}
+}
+
+// handle parses the message into the buffer, false means it's dropped
+func (ins *Instance) handle(msg *sarama.ConsumerMessage) bool {
+	ins.countersMu.Lock()
+	ins.received++
+	ins.countersMu.Unlock()
+
+	if ins.MaxMessageLen > 0 && len(msg.Value) > ins.MaxMessageLen {
+		ins.countersMu.Lock()
+		ins.tooLong++
+		ins.countersMu.Unlock()
+		return false
+	}
+
+	slist := types.NewSampleList()
+	var err error
+	if len(msg.Value) == 0 {
+		err = fmt.Errorf("empty message")
+	} else if err = ins.parser.Parse(msg.Value, slist); err == nil && slist.Len() == 0 {
+		err = fmt.Errorf("no metrics in message")
+	}
+	if err != nil {
+		ins.countersMu.Lock()
+		ins.parseErrs++
+		ins.countersMu.Unlock()
+		if ins.DebugMod {
+			log.Printf("D! kafka_consumer failed to parse message of %s/%d offset %d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		}
+		return false
+	}
+
+	samples := slist.PopBackAll()
+	for _, s := range samples {
+		if ins.TopicTag != "" {
+			s.Labels[ins.TopicTag] = msg.Topic
+		}
+		if s.Timestamp.IsZero() {
+			s.Timestamp = msg.Timestamp
+		}
+	}
+	ins.buffer.PushFrontN(samples)
+	return true
+}
+
+// Gather hands over the samples consumed since the last gather, and marks
+// their messages with after_gather
+func (ins *Instance) Gather(slist *types.SampleList) {
+	ins.pendingMu.Lock()
+	slist.PushFrontN(ins.buffer.PopBackAll())
+	for _, p := range ins.pending {
+		p.session.MarkMessage(p.msg, "")
+		<-ins.undelivered
+	}
+	for ; ins.buffered > 0; ins.buffered-- {
+		<-ins.undelivered
+	}
+	ins.pending = nil
+	ins.pendingMu.Unlock()
+
+	ins.countersMu.Lock()
+	defer ins.countersMu.Unlock()
+	slist.PushFront(types.NewSample(inputName, "messages_received_total", ins.received).SetType(types.Counter))
+	slist.PushFront(types.NewSample(inputName, "parse_errors_total", ins.parseErrs).SetType(types.Counter))
+	slist.PushFront(types.NewSample(inputName, "messages_too_long_total", ins.tooLong).SetType(types.Counter))
+}
+
+// DescribeMetrics lists the self metrics of kafka_consumer, the consumed ones depend on the payloads
+func (k *KafkaConsumer) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "kafka_consumer_messages_received_total", Type: types.Counter, Help: "messages received from kafka"},
+		{Name: "kafka_consumer_parse_errors_total", Type: types.Counter, Help: "messages which couldn't be parsed as data_format"},
+		{Name: "kafka_consumer_messages_too_long_total", Type: types.Counter, Help: "messages dropped for exceeding max_message_len"},
+	}
+}
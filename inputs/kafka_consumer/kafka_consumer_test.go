@@ -0,0 +1,137 @@
+package kafka_consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/parser/influx"
+	"flashcat.cloud/categraf/types"
+)
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) Context() context.Context {
+	return context.Background()
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	ch chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.ch
+}
+
+func TestCommitAfterGather(t *testing.T) {
+	ins := &Instance{
+		CommitStrategy:         commitAfterGather,
+		MaxUndeliveredMessages: 10,
+		TopicTag:               "topic",
+		parser:                 influx.NewParser(),
+		buffer:                 types.NewSampleList(),
+	}
+	ins.undelivered = make(chan struct{}, ins.MaxUndeliveredMessages)
+
+	ts := time.Unix(1700000000, 0)
+	claim := &fakeClaim{ch: make(chan *sarama.ConsumerMessage, 3)}
+	claim.ch <- &sarama.ConsumerMessage{Topic: "metrics", Offset: 1, Timestamp: ts, Value: []byte("cpu,host=a usage=1")}
+	claim.ch <- &sarama.ConsumerMessage{Topic: "metrics", Offset: 2, Timestamp: ts, Value: []byte("not a metric")}
+	claim.ch <- &sarama.ConsumerMessage{Topic: "metrics", Offset: 3, Timestamp: ts, Value: []byte("cpu,host=b usage=2 1700000001000000000")}
+	close(claim.ch)
+
+	session := &fakeSession{}
+	require.NoError(t, ins.ConsumeClaim(session, claim))
+	// the unparsable message is marked right away, the others wait for Gather
+	require.Equal(t, []int64{2}, session.marked)
+
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+	require.Equal(t, []int64{2, 1, 3}, session.marked)
+	require.Len(t, ins.undelivered, 0)
+
+	var samples []*types.Sample
+	for _, s := range slist.PopBackAll() {
+		if s.Metric == "cpu_usage" {
+			samples = append(samples, s)
+		}
+	}
+	require.Len(t, samples, 2)
+	for _, s := range samples {
+		require.Equal(t, "metrics", s.Labels["topic"])
+		if s.Labels["host"] == "b" {
+			require.Equal(t, int64(1700000001), s.Timestamp.Unix())
+		}
+	}
+}
+
+func TestImmediateUndeliveredCap(t *testing.T) {
+	ins := &Instance{
+		CommitStrategy:         commitImmediate,
+		MaxUndeliveredMessages: 2,
+		parser:                 influx.NewParser(),
+		buffer:                 types.NewSampleList(),
+	}
+	ins.undelivered = make(chan struct{}, ins.MaxUndeliveredMessages)
+
+	claim := &fakeClaim{ch: make(chan *sarama.ConsumerMessage, 3)}
+	for i := int64(1); i <= 3; i++ {
+		claim.ch <- &sarama.ConsumerMessage{Offset: i, Value: []byte("cpu usage=1")}
+	}
+	close(claim.ch)
+
+	session := &fakeSession{}
+	done := make(chan error)
+	go func() { done <- ins.ConsumeClaim(session, claim) }()
+
+	// the third message waits for the gather
+	require.Eventually(t, func() bool {
+		ins.pendingMu.Lock()
+		defer ins.pendingMu.Unlock()
+		return ins.buffered == 2
+	}, time.Second, time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("consumed more than max_undelivered_messages")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ins.Gather(types.NewSampleList())
+	require.NoError(t, <-done)
+	require.Equal(t, []int64{1, 2, 3}, session.marked)
+	require.Equal(t, 1, ins.buffer.Len())
+}
+
+type fakeGroup struct {
+	sarama.ConsumerGroup
+	closed bool
+}
+
+func (g *fakeGroup) Close() error {
+	g.closed = true
+	return nil
+}
+
+func TestDropClosesGroup(t *testing.T) {
+	group := &fakeGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &KafkaConsumer{}
+	p.Instances = []*Instance{{group: group, cancel: cancel}}
+
+	// what the agent does on reload and shutdown
+	inputs.MayDrop(p)
+	require.True(t, group.closed)
+	require.Error(t, ctx.Err())
+}
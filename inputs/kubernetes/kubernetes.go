@@ -12,6 +12,7 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -292,11 +293,11 @@ func (ins *Instance) LoadJSON(url string, v interface{}) error {
 		if ins.ResponseTimeout < config.Duration(time.Second) {
 			ins.ResponseTimeout = config.Duration(time.Second * 5)
 		}
-		ins.RoundTripper = &http.Transport{
-			TLSHandshakeTimeout:   5 * time.Second,
-			TLSClientConfig:       tlsCfg,
-			ResponseHeaderTimeout: time.Duration(ins.ResponseTimeout),
-		}
+		trans := httpx.NewTransport()
+		trans.TLSHandshakeTimeout = 5 * time.Second
+		trans.TLSClientConfig = tlsCfg
+		trans.ResponseHeaderTimeout = time.Duration(ins.ResponseTimeout)
+		ins.RoundTripper = httpx.WrapDefault(trans)
 	}
 	req.Header.Set("Authorization", "Bearer "+ins.BearerTokenString)
 	req.Header.Add("Accept", "application/json")
@@ -13,6 +13,7 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/choice"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/jsonx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
@@ -211,11 +212,11 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
+	trans := httpx.NewTransport()
+	trans.TLSClientConfig = tlsConfig
 	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-		},
-		Timeout: time.Duration(ins.Timeout),
+		Transport: httpx.WrapDefault(trans),
+		Timeout:   time.Duration(ins.Timeout),
 	}
 
 	return client, nil
@@ -0,0 +1,254 @@
+package mock
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "mock"
+
+	// hardMaxSeries bounds max_series, a typo in the config should not be
+	// able to take down the backends
+	hardMaxSeries = 1000000
+	// maxLabelKeys bounds label_keys, every series carries them all
+	maxLabelKeys = 32
+
+	distConstant = "constant"
+	distUniform  = "uniform"
+	distNormal   = "normal"
+	distCounter  = "counter"
+)
+
+// budget holds the series of the mock instances running, together they
+// should not exceed hardMaxSeries either
+var budget struct {
+	sync.Mutex
+	series int
+}
+
+type Mock struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Mock{}
+	})
+}
+
+func (m *Mock) Clone() inputs.Input {
+	return &Mock{}
+}
+
+func (m *Mock) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Mock)
+var _ inputs.InstancesGetter = new(Mock)
+
+type Instance struct {
+	config.InstanceConfig
+
+	// metric names, mock_metric_0 to mock_metric_<metrics-1>, default 1
+	Metrics int `toml:"metrics"`
+	// series of every metric, default 100
+	Series int `toml:"series"`
+	// extra labels of every series, label_0 to label_<label_keys-1>, at most 32
+	LabelKeys int `toml:"label_keys"`
+
+	// constant(mean), uniform(min, max), normal(mean, stddev), or
+	// counter increasing by uniform(min, max) every gather, default uniform
+	Distribution string  `toml:"distribution"`
+	Min          float64 `toml:"min"`
+	Max          float64 `toml:"max"`
+	Mean         float64 `toml:"mean"`
+	Stddev       float64 `toml:"stddev"`
+
+	// percent of the series replaced by new ones every gather
+	ChurnPercent float64 `toml:"churn_percent"`
+	// metrics * series should not exceed it, default 10000; the series of all
+	// mock instances together are bounded by 1000000
+	MaxSeries int `toml:"max_series"`
+	// seed of the values, 0 seeds by time
+	Seed int64 `toml:"seed"`
+
+	rnd *rand.Rand
+	// series taken from budget by Init, given back by Drop
+	reserved int
+	// ids of the series in the slots, churn replaces the oldest
+	ids    []uint64
+	oldest int
+	nextID uint64
+	churn  float64
+	// values of counters, by metric and slot
+	counters [][]float64
+}
+
+func (ins *Instance) Init() error {
+	if ins.Metrics == 0 && ins.Series == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.Metrics <= 0 {
+		ins.Metrics = 1
+	}
+	if ins.Series <= 0 {
+		ins.Series = 100
+	}
+	if ins.MaxSeries <= 0 {
+		ins.MaxSeries = 10000
+	}
+	if ins.MaxSeries > hardMaxSeries {
+		return fmt.Errorf("max_series should not exceed %d, got %d", hardMaxSeries, ins.MaxSeries)
+	}
+	// compared one by one first, so the product can't overflow
+	if ins.Metrics > ins.MaxSeries || ins.Series > ins.MaxSeries || ins.Metrics > ins.MaxSeries/ins.Series {
+		return fmt.Errorf("metrics %d * series %d is more than max_series %d", ins.Metrics, ins.Series, ins.MaxSeries)
+	}
+	if ins.LabelKeys < 0 || ins.LabelKeys > maxLabelKeys {
+		return fmt.Errorf("label_keys should be between 0 and %d, got %d", maxLabelKeys, ins.LabelKeys)
+	}
+	if ins.ChurnPercent < 0 || ins.ChurnPercent > 100 {
+		return fmt.Errorf("churn_percent should be between 0 and 100, got %v", ins.ChurnPercent)
+	}
+
+	switch ins.Distribution {
+	case "":
+		ins.Distribution = distUniform
+		fallthrough
+	case distUniform, distCounter:
+		if ins.Min == 0 && ins.Max == 0 {
+			ins.Max = 100
+		}
+		if ins.Max < ins.Min {
+			return fmt.Errorf("max %v should not be less than min %v", ins.Max, ins.Min)
+		}
+	case distConstant:
+	case distNormal:
+		if ins.Stddev < 0 {
+			return fmt.Errorf("stddev should not be negative, got %v", ins.Stddev)
+		}
+	default:
+		return fmt.Errorf("distribution should be constant, uniform, normal or counter, got %q", ins.Distribution)
+	}
+
+	if err := ins.reserve(ins.Metrics * ins.Series); err != nil {
+		return err
+	}
+
+	seed := ins.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	ins.rnd = rand.New(rand.NewSource(seed))
+
+	ins.ids = make([]uint64, ins.Series)
+	for i := range ins.ids {
+		ins.ids[i] = uint64(i)
+	}
+	ins.nextID = uint64(ins.Series)
+	if ins.Distribution == distCounter {
+		ins.counters = make([][]float64, ins.Metrics)
+		for i := range ins.counters {
+			ins.counters[i] = make([]float64, ins.Series)
+		}
+	}
+
+	log.Printf("W! mock input generates %d synthetic series every gather, labeled synthetic=\"true\"", ins.Metrics*ins.Series)
+	return nil
+}
+
+// reserve takes series from the budget shared by all mock instances
+func (ins *Instance) reserve(series int) error {
+	budget.Lock()
+	defer budget.Unlock()
+	if budget.series+series > hardMaxSeries {
+		return fmt.Errorf("mock instances would generate %d series, more than %d", budget.series+series, hardMaxSeries)
+	}
+	budget.series += series
+	ins.reserved = series
+	return nil
+}
+
+// Drop gives the series of the instance back to the budget
+func (ins *Instance) Drop() {
+	budget.Lock()
+	defer budget.Unlock()
+	budget.series -= ins.reserved
+	ins.reserved = 0
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	ins.rotate()
+	valueType := types.Gauge
+	if ins.Distribution == distCounter {
+		valueType = types.Counter
+	}
+	for slot, id := range ins.ids {
+		tags := ins.seriesTags(id)
+		for metric := 0; metric < ins.Metrics; metric++ {
+			slist.PushFront(types.NewSample(inputName, "metric_"+strconv.Itoa(metric), ins.value(metric, slot), tags).SetType(valueType))
+		}
+	}
+	slist.PushFront(types.NewSample(inputName, "series", ins.Metrics*ins.Series, map[string]string{"synthetic": "true"}))
+}
+
+// rotate replaces churn_percent of the series by new ones, the fraction of a
+// series left over is carried to the next gather
+func (ins *Instance) rotate() {
+	ins.churn += float64(ins.Series) * ins.ChurnPercent / 100
+	for ; ins.churn >= 1; ins.churn-- {
+		ins.ids[ins.oldest] = ins.nextID
+		ins.nextID++
+		for _, values := range ins.counters {
+			values[ins.oldest] = 0
+		}
+		ins.oldest = (ins.oldest + 1) % len(ins.ids)
+	}
+}
+
+func (ins *Instance) seriesTags(id uint64) map[string]string {
+	series := strconv.FormatUint(id, 10)
+	tags := map[string]string{
+		"synthetic": "true",
+		"series":    series,
+	}
+	for i := 0; i < ins.LabelKeys; i++ {
+		tags["label_"+strconv.Itoa(i)] = "value_" + series
+	}
+	return tags
+}
+
+func (ins *Instance) value(metric, slot int) float64 {
+	switch ins.Distribution {
+	case distConstant:
+		return ins.Mean
+	case distNormal:
+		return ins.rnd.NormFloat64()*ins.Stddev + ins.Mean
+	case distCounter:
+		ins.counters[metric][slot] += ins.uniform()
+		return ins.counters[metric][slot]
+	}
+	return ins.uniform()
+}
+
+func (ins *Instance) uniform() float64 {
+	return ins.Min + ins.rnd.Float64()*(ins.Max-ins.Min)
+}
+
+func (m *Mock) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "mock_metric_<n>", Type: types.Gauge, Help: "synthetic values, a counter if distribution is counter", Tags: []string{"synthetic", "series", "label_<n>"}},
+		{Name: "mock_series", Type: types.Gauge, Help: "synthetic series generated every gather", Tags: []string{"synthetic"}},
+	}
+}
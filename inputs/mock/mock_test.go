@@ -0,0 +1,86 @@
+package mock
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestInitSafetyCap(t *testing.T) {
+	ins := &Instance{Metrics: 10, Series: 2000}
+	require.Error(t, ins.Init())
+
+	ins = &Instance{Metrics: 10, Series: 2000, MaxSeries: hardMaxSeries + 1}
+	require.Error(t, ins.Init())
+
+	ins = &Instance{Metrics: 10, Series: 2000, MaxSeries: 20000}
+	require.NoError(t, ins.Init())
+	ins.Drop()
+
+	// would overflow if multiplied first
+	ins = &Instance{Metrics: math.MaxInt, Series: math.MaxInt, MaxSeries: hardMaxSeries}
+	require.Error(t, ins.Init())
+
+	ins = &Instance{Series: 1, LabelKeys: maxLabelKeys + 1}
+	require.Error(t, ins.Init())
+
+	ins = &Instance{Series: 1, LabelKeys: maxLabelKeys}
+	require.NoError(t, ins.Init())
+	ins.Drop()
+}
+
+func TestInitBudget(t *testing.T) {
+	m := &Mock{}
+	for i := 0; i < 2; i++ {
+		m.Instances = append(m.Instances, &Instance{Metrics: 1, Series: hardMaxSeries / 2, MaxSeries: hardMaxSeries, Seed: 1})
+		require.NoError(t, m.Instances[i].Init())
+	}
+	third := &Instance{Metrics: 1, Series: 1, Seed: 1}
+	require.Error(t, third.Init())
+
+	m.Drop()
+	require.NoError(t, third.Init())
+	third.Drop()
+}
+
+func TestGatherChurn(t *testing.T) {
+	ins := &Instance{Metrics: 2, Series: 10, LabelKeys: 1, ChurnPercent: 25, Seed: 1}
+	require.NoError(t, ins.Init())
+
+	series := func() map[string]bool {
+		slist := types.NewSampleList()
+		ins.Gather(slist)
+		ids := make(map[string]bool)
+		for _, s := range slist.PopBackAll() {
+			if s.Metric == "mock_metric_0" {
+				require.Equal(t, "true", s.Labels["synthetic"])
+				require.Equal(t, "value_"+s.Labels["series"], s.Labels["label_0"])
+				ids[s.Labels["series"]] = true
+			}
+		}
+		require.Len(t, ids, 10)
+		return ids
+	}
+
+	// 2.5 series a gather, 2 then 3 are replaced
+	first, second, third := series(), series(), series()
+	require.False(t, first["0"])
+	require.False(t, first["1"])
+	require.True(t, first["2"])
+	require.True(t, first["10"])
+	require.False(t, second["2"])
+	require.True(t, second["12"])
+	require.False(t, third["6"])
+	require.True(t, third["16"])
+}
+
+func TestCounter(t *testing.T) {
+	ins := &Instance{Series: 1, Distribution: distCounter, Min: 1, Max: 1}
+	require.NoError(t, ins.Init())
+	for i := 1; i <= 3; i++ {
+		require.Equal(t, float64(i), ins.value(0, 0))
+	}
+}
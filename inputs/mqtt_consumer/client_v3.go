@@ -0,0 +1,78 @@
+package mqtt_consumer
+
+import (
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// clientV3 speaks MQTT 3.1 and 3.1.1, paho reconnects and resubscribes by itself
+type clientV3 struct {
+	ins    *Instance
+	client mqtt.Client
+}
+
+func newClientV3(ins *Instance) *clientV3 {
+	return &clientV3{ins: ins}
+}
+
+func (c *clientV3) start() error {
+	ins := c.ins
+	opts := mqtt.NewClientOptions()
+	for _, server := range ins.Servers {
+		opts.AddBroker(server)
+	}
+	opts.SetClientID(ins.ClientID)
+	opts.SetUsername(ins.Username)
+	opts.SetPassword(ins.Password)
+	opts.SetCleanSession(!ins.PersistentSession)
+	opts.SetConnectTimeout(time.Duration(ins.ConnectionTimeout))
+	opts.SetKeepAlive(time.Duration(ins.KeepAlive))
+	opts.SetAutoReconnect(true)
+	// keep trying when the broker is down while categraf starts
+	opts.SetConnectRetry(true)
+	opts.SetMaxReconnectInterval(time.Minute)
+	if ins.tlsConfig != nil {
+		opts.SetTLSConfig(ins.tlsConfig)
+	}
+	if ins.ProtocolVersion == "3.1" {
+		opts.SetProtocolVersion(3)
+	} else {
+		opts.SetProtocolVersion(4)
+	}
+
+	opts.SetOnConnectHandler(c.subscribe)
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Println("W! mqtt_consumer lost connection to", ins.Servers, "reconnecting:", err)
+	})
+
+	c.client = mqtt.NewClient(opts)
+	// with ConnectRetry the token completes once connected, don't wait for it
+	c.client.Connect()
+	return nil
+}
+
+// subscribe is called on every (re)connection, the broker forgets the
+// subscriptions of clean sessions
+func (c *clientV3) subscribe(client mqtt.Client) {
+	ins := c.ins
+	log.Println("I! mqtt_consumer connected to", ins.Servers)
+
+	filters := make(map[string]byte, len(ins.Topics))
+	for _, topic := range ins.Topics {
+		filters[topic] = byte(ins.QoS)
+	}
+	token := client.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+		ins.onMessage(msg.Topic(), msg.Payload())
+	})
+	if token.WaitTimeout(time.Duration(ins.ConnectionTimeout)) && token.Error() != nil {
+		log.Println("E! mqtt_consumer failed to subscribe", ins.Topics, "error:", token.Error())
+	}
+}
+
+func (c *clientV3) stop() {
+	if c.client != nil {
+		c.client.Disconnect(200)
+	}
+}
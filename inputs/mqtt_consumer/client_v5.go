@@ -0,0 +1,102 @@
+package mqtt_consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// clientV5 speaks MQTT 5, autopaho reconnects and calls OnConnectionUp to resubscribe
+type clientV5 struct {
+	ins    *Instance
+	cm     *autopaho.ConnectionManager
+	cancel context.CancelFunc
+}
+
+func newClientV5(ins *Instance) *clientV5 {
+	return &clientV5{ins: ins}
+}
+
+func (c *clientV5) start() error {
+	ins := c.ins
+	cfg := autopaho.ClientConfig{
+		TlsCfg:            ins.tlsConfig,
+		KeepAlive:         uint16(time.Duration(ins.KeepAlive) / time.Second),
+		ConnectRetryDelay: 10 * time.Second,
+		ConnectTimeout:    time.Duration(ins.ConnectionTimeout),
+		OnConnectionUp:    c.subscribe,
+		OnConnectError: func(err error) {
+			log.Println("W! mqtt_consumer failed to connect to", ins.Servers, "error:", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: ins.ClientID,
+			Router: paho.NewSingleHandlerRouter(func(p *paho.Publish) {
+				ins.onMessage(p.Topic, p.Payload)
+			}),
+			OnClientError: func(err error) {
+				log.Println("W! mqtt_consumer lost connection to", ins.Servers, "reconnecting:", err)
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				log.Println("W! mqtt_consumer disconnected by server, reason code:", d.ReasonCode)
+			},
+		},
+	}
+	for _, server := range ins.Servers {
+		u, err := url.Parse(server)
+		if err != nil {
+			return fmt.Errorf("invalid server %q: %v", server, err)
+		}
+		cfg.BrokerUrls = append(cfg.BrokerUrls, u)
+	}
+	if ins.Username != "" {
+		cfg.SetUsernamePassword(ins.Username, []byte(ins.Password))
+	}
+	if ins.PersistentSession {
+		cfg.SetConnectPacketConfigurator(func(cp *paho.Connect) *paho.Connect {
+			// keep the session for a day after disconnecting
+			expiry := uint32(24 * time.Hour / time.Second)
+			cp.CleanStart = false
+			cp.Properties = &paho.ConnectProperties{SessionExpiryInterval: &expiry}
+			return cp
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cm, err := autopaho.NewConnection(ctx, cfg)
+	if err != nil {
+		cancel()
+		return err
+	}
+	c.cm, c.cancel = cm, cancel
+	return nil
+}
+
+func (c *clientV5) subscribe(cm *autopaho.ConnectionManager, _ *paho.Connack) {
+	ins := c.ins
+	log.Println("I! mqtt_consumer connected to", ins.Servers)
+
+	sub := &paho.Subscribe{}
+	for _, topic := range ins.Topics {
+		sub.Subscriptions = append(sub.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: byte(ins.QoS)})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ins.ConnectionTimeout))
+	defer cancel()
+	if _, err := cm.Subscribe(ctx, sub); err != nil {
+		log.Println("E! mqtt_consumer failed to subscribe", ins.Topics, "error:", err)
+	}
+}
+
+func (c *clientV5) stop() {
+	if c.cm == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c.cm.Disconnect(ctx)
+	c.cancel()
+}
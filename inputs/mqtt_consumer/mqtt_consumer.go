@@ -0,0 +1,346 @@
+package mqtt_consumer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/parser"
+	_ "flashcat.cloud/categraf/parser/falcon"
+	_ "flashcat.cloud/categraf/parser/influx"
+	_ "flashcat.cloud/categraf/parser/prometheus"
+	tlsx "flashcat.cloud/categraf/pkg/tls"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "mqtt_consumer"
+
+// dataFormatValue means the payload is a single number, named after the topic
+const dataFormatValue = "value"
+
+type MQTTConsumer struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &MQTTConsumer{}
+	})
+}
+
+func (m *MQTTConsumer) Clone() inputs.Input {
+	return &MQTTConsumer{}
+}
+
+func (m *MQTTConsumer) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(MQTTConsumer)
+var _ inputs.InstancesGetter = new(MQTTConsumer)
+
+type TopicParsing struct {
+	// topic filter the message must match, e.g. "sensors/+/+/temperature"
+	Topic string `toml:"topic" required:"true"`
+	// segment names, "_" skips the segment, e.g. "_/site/device/_"
+	Tags string `toml:"tags"`
+	// segment holding the metric name of data_format value, e.g. "_/_/_/metric"
+	Metric string `toml:"metric"`
+
+	topic    []string
+	wildcard bool
+	tags     []string
+	metric   int
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// tcp://127.0.0.1:1883, ssl://, tls:// or ws://, the first reachable one is used
+	Servers []string `toml:"servers" required:"true"`
+	Topics  []string `toml:"topics" required:"true"`
+	QoS     int      `toml:"qos"`
+	// 3.1, 3.1.1 or 5
+	ProtocolVersion   string          `toml:"protocol_version"`
+	ClientID          string          `toml:"client_id"`
+	Username          string          `toml:"username"`
+	Password          string          `toml:"password"`
+	PersistentSession bool            `toml:"persistent_session"`
+	ConnectionTimeout config.Duration `toml:"connection_timeout"`
+	KeepAlive         config.Duration `toml:"keep_alive"`
+
+	// influx, falcon, prometheus or value
+	DataFormat string `toml:"data_format"`
+	// label holding the topic of the message, empty disables it
+	TopicTag     *string         `toml:"topic_tag"`
+	TopicParsing []*TopicParsing `toml:"topic_parsing"`
+	// samples waiting for the next gather, the ones of further messages are dropped
+	MaxUndelivered int `toml:"max_undelivered"`
+
+	tlsx.ClientConfig
+
+	parser    parser.Parser
+	tlsConfig *tls.Config
+	client    client
+	buffer    *types.SampleList
+
+	countersMu sync.Mutex
+	received   uint64
+	parseErrs  uint64
+	dropped    uint64
+}
+
+// client is implemented by the MQTT 3.1.1 and 5 clients
+type client interface {
+	start() error
+	stop()
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Servers) == 0 || len(ins.Topics) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	for _, server := range ins.Servers {
+		if _, err := url.Parse(server); err != nil {
+			return fmt.Errorf("invalid server %q: %v", server, err)
+		}
+	}
+	if ins.QoS < 0 || ins.QoS > 2 {
+		return fmt.Errorf("qos %d is invalid, should be 0, 1 or 2", ins.QoS)
+	}
+	if ins.PersistentSession && ins.ClientID == "" {
+		return fmt.Errorf("persistent_session requires client_id")
+	}
+	if ins.ClientID == "" {
+		ins.ClientID = "categraf-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	if ins.ConnectionTimeout <= 0 {
+		ins.ConnectionTimeout = config.Duration(30 * time.Second)
+	}
+	if ins.KeepAlive <= 0 {
+		ins.KeepAlive = config.Duration(60 * time.Second)
+	}
+	if ins.MaxUndelivered <= 0 {
+		ins.MaxUndelivered = 100000
+	}
+	if ins.TopicTag == nil {
+		topic := "topic"
+		ins.TopicTag = &topic
+	}
+
+	if err := ins.initParser(); err != nil {
+		return err
+	}
+	for _, tp := range ins.TopicParsing {
+		if err := tp.init(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if ins.tlsConfig, err = ins.ClientConfig.TLSConfig(); err != nil {
+		return err
+	}
+
+	switch ins.ProtocolVersion {
+	case "", "3.1.1", "3.1":
+		ins.client = newClientV3(ins)
+	case "5":
+		ins.client = newClientV5(ins)
+	default:
+		return fmt.Errorf("protocol_version %q is invalid, should be 3.1, 3.1.1 or 5", ins.ProtocolVersion)
+	}
+
+	ins.buffer = types.NewSampleList()
+	return ins.client.start()
+}
+
+func (ins *Instance) initParser() error {
+	if ins.DataFormat == dataFormatValue {
+		return nil
+	}
+	p, err := parser.New(ins.DataFormat)
+	if err != nil {
+		return err
+	}
+	ins.parser = p
+	return nil
+}
+
+func (ins *Instance) Drop() {
+	if ins.client != nil {
+		ins.client.stop()
+	}
+}
+
+// Gather hands over the samples received since the last gather
+func (ins *Instance) Gather(slist *types.SampleList) {
+	slist.PushFrontN(ins.buffer.PopBackAll())
+
+	ins.countersMu.Lock()
+	defer ins.countersMu.Unlock()
+	slist.PushFront(types.NewSample(inputName, "messages_received_total", ins.received).SetType(types.Counter))
+	slist.PushFront(types.NewSample(inputName, "parse_errors_total", ins.parseErrs).SetType(types.Counter))
+	slist.PushFront(types.NewSample(inputName, "dropped_samples_total", ins.dropped).SetType(types.Counter))
+}
+
+// deliver buffers the samples of a message for the next gather, or drops them
+// all if the buffer would hold more than max_undelivered samples
+func (ins *Instance) deliver(samples []*types.Sample) {
+	ins.countersMu.Lock()
+	defer ins.countersMu.Unlock()
+	if ins.buffer.Len()+len(samples) > ins.MaxUndelivered {
+		ins.dropped += uint64(len(samples))
+		return
+	}
+	ins.buffer.PushFrontN(samples)
+}
+
+// onMessage is called by both clients for every message received
+func (ins *Instance) onMessage(topic string, payload []byte) {
+	ins.countersMu.Lock()
+	ins.received++
+	ins.countersMu.Unlock()
+
+	if err := ins.parse(topic, payload); err != nil {
+		ins.countersMu.Lock()
+		ins.parseErrs++
+		ins.countersMu.Unlock()
+		if ins.DebugMod {
+			log.Printf("D! mqtt_consumer failed to parse message of topic %s: %v", topic, err)
+		}
+	}
+}
+
+func (ins *Instance) parse(topic string, payload []byte) error {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return fmt.Errorf("empty payload")
+	}
+
+	labels := make(map[string]string)
+	if *ins.TopicTag != "" {
+		labels[*ins.TopicTag] = topic
+	}
+
+	segments := strings.Split(topic, "/")
+	var tp *TopicParsing
+	for _, p := range ins.TopicParsing {
+		if p.match(segments) {
+			tp = p
+			break
+		}
+	}
+	if tp != nil {
+		for i, name := range tp.tags {
+			// segments named after a trailing # may be missing
+			if name != "_" && name != "" && i < len(segments) {
+				labels[name] = segments[i]
+			}
+		}
+	}
+
+	if ins.DataFormat == dataFormatValue {
+		value, err := strconv.ParseFloat(string(payload), 64)
+		if err != nil {
+			return err
+		}
+		metric := segments[len(segments)-1]
+		if tp != nil && tp.metric >= 0 && tp.metric < len(segments) {
+			metric = segments[tp.metric]
+		}
+		ins.deliver([]*types.Sample{types.NewSample(inputName, metric, value, labels).SetTime(time.Now())})
+		return nil
+	}
+
+	slist := types.NewSampleList()
+	if err := ins.parser.Parse(payload, slist); err != nil {
+		return err
+	}
+	samples := slist.PopBackAll()
+	if len(samples) == 0 {
+		return fmt.Errorf("no metrics in payload")
+	}
+	for _, s := range samples {
+		for k, v := range labels {
+			if _, has := s.Labels[k]; !has {
+				s.Labels[k] = v
+			}
+		}
+		if s.Timestamp.IsZero() {
+			s.Timestamp = time.Now()
+		}
+	}
+	ins.deliver(samples)
+	return nil
+}
+
+func (tp *TopicParsing) init() error {
+	if tp.Topic == "" {
+		return fmt.Errorf("topic_parsing.topic is required")
+	}
+	tp.topic = strings.Split(tp.Topic, "/")
+	for i, seg := range tp.topic {
+		if seg == "#" {
+			if i != len(tp.topic)-1 {
+				return fmt.Errorf("topic_parsing.topic %q: # must be the last segment", tp.Topic)
+			}
+			tp.topic, tp.wildcard = tp.topic[:i], true
+		}
+	}
+
+	tp.tags = nil
+	if tp.Tags != "" {
+		tp.tags = strings.Split(tp.Tags, "/")
+		if !tp.wildcard && len(tp.tags) > len(tp.topic) {
+			return fmt.Errorf("topic_parsing.tags %q has more segments than the topic %q", tp.Tags, tp.Topic)
+		}
+	}
+
+	tp.metric = -1
+	if tp.Metric != "" {
+		segs := strings.Split(tp.Metric, "/")
+		if !tp.wildcard && len(segs) > len(tp.topic) {
+			return fmt.Errorf("topic_parsing.metric %q has more segments than the topic %q", tp.Metric, tp.Topic)
+		}
+		for i, seg := range segs {
+			if seg != "_" && seg != "" {
+				tp.metric = i
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// match tells whether the topic segments match the filter, + matches one
+// segment and a trailing # the rest, none included: a/# matches a as well
+func (tp *TopicParsing) match(segments []string) bool {
+	if len(segments) < len(tp.topic) || (!tp.wildcard && len(segments) != len(tp.topic)) {
+		return false
+	}
+	for i, seg := range tp.topic {
+		if seg != "+" && seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DescribeMetrics lists the self metrics of mqtt_consumer, the consumed ones depend on the payloads
+func (m *MQTTConsumer) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "mqtt_consumer_messages_received_total", Type: types.Counter, Help: "messages received from the broker"},
+		{Name: "mqtt_consumer_parse_errors_total", Type: types.Counter, Help: "messages which couldn't be parsed as data_format"},
+		{Name: "mqtt_consumer_dropped_samples_total", Type: types.Counter, Help: "samples dropped since max_undelivered samples were waiting for the gather"},
+	}
+}
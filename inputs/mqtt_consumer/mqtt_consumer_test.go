@@ -0,0 +1,95 @@
+package mqtt_consumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func newTestInstance(t *testing.T, format string, tps ...*TopicParsing) *Instance {
+	topic := "topic"
+	ins := &Instance{DataFormat: format, TopicTag: &topic, TopicParsing: tps, MaxUndelivered: 100, buffer: types.NewSampleList()}
+	if format != dataFormatValue {
+		require.NoError(t, ins.initParser())
+	}
+	for _, tp := range tps {
+		require.NoError(t, tp.init())
+	}
+	return ins
+}
+
+func TestParseValue(t *testing.T) {
+	ins := newTestInstance(t, dataFormatValue, &TopicParsing{
+		Topic:  "sensors/+/+/#",
+		Tags:   "_/site/device",
+		Metric: "_/_/_/metric",
+	})
+
+	require.NoError(t, ins.parse("sensors/bj/d1/temperature", []byte(" 23.5\n")))
+	require.Error(t, ins.parse("sensors/bj/d1/temperature", []byte("hot")))
+	require.NoError(t, ins.parse("home/humidity", []byte("40")))
+	// the parent of sensors/+/+/#, the metric segment is missing
+	require.NoError(t, ins.parse("sensors/sh/d2", []byte("1")))
+
+	samples := ins.buffer.PopBackAll()
+	require.Len(t, samples, 3)
+	require.Equal(t, "mqtt_consumer_temperature", samples[0].Metric)
+	require.Equal(t, 23.5, samples[0].Value)
+	require.Equal(t, map[string]string{"topic": "sensors/bj/d1/temperature", "site": "bj", "device": "d1"}, samples[0].Labels)
+	// no topic_parsing matched, named after the last segment
+	require.Equal(t, "mqtt_consumer_humidity", samples[1].Metric)
+	require.Equal(t, "mqtt_consumer_d2", samples[2].Metric)
+	require.Equal(t, "sh", samples[2].Labels["site"])
+}
+
+func TestParseInflux(t *testing.T) {
+	ins := newTestInstance(t, "influx")
+	require.NoError(t, ins.parse("telegraf/host1", []byte("cpu,cpu=total usage_idle=90.5")))
+
+	samples := ins.buffer.PopBackAll()
+	require.Len(t, samples, 1)
+	require.Equal(t, "cpu_usage_idle", samples[0].Metric)
+	require.Equal(t, "telegraf/host1", samples[0].Labels["topic"])
+	require.Equal(t, "total", samples[0].Labels["cpu"])
+
+	// a payload without a line of metrics is a parse error, not silently nothing
+	require.Error(t, ins.parse("telegraf/host1", []byte("# just a comment")))
+}
+
+func TestMaxUndelivered(t *testing.T) {
+	ins := newTestInstance(t, "influx")
+	ins.MaxUndelivered = 3
+	require.NoError(t, ins.parse("telegraf/host1", []byte("cpu usage_idle=90.5,usage_user=5")))
+	// both samples of the second message are dropped
+	require.NoError(t, ins.parse("telegraf/host1", []byte("cpu usage_idle=90.5,usage_user=5")))
+	require.Equal(t, 2, ins.buffer.Len())
+	require.Equal(t, uint64(2), ins.dropped)
+
+	ins.buffer.PopBackAll()
+	require.NoError(t, ins.parse("telegraf/host1", []byte("cpu usage_idle=90.5,usage_user=5")))
+	require.Equal(t, 2, ins.buffer.Len())
+}
+
+func TestTopicParsingMatch(t *testing.T) {
+	tp := &TopicParsing{Topic: "a/+/c"}
+	require.NoError(t, tp.init())
+	require.True(t, tp.match([]string{"a", "b", "c"}))
+	require.False(t, tp.match([]string{"a", "b", "c", "d"}))
+	require.False(t, tp.match([]string{"a", "b", "x"}))
+
+	tp = &TopicParsing{Topic: "a/#"}
+	require.NoError(t, tp.init())
+	require.True(t, tp.match([]string{"a", "b", "c"}))
+	// # matches the parent level too
+	require.True(t, tp.match([]string{"a"}))
+	require.False(t, tp.match([]string{"b"}))
+
+	tp = &TopicParsing{Topic: "#"}
+	require.NoError(t, tp.init())
+	require.True(t, tp.match([]string{"a"}))
+
+	require.Error(t, (&TopicParsing{Topic: "a/#/b"}).init())
+	require.Error(t, (&TopicParsing{Topic: "a/b", Tags: "_/_/x"}).init())
+}
@@ -200,6 +200,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	if err != nil {
 		slist.PushSample(inputName, "up", 0, tags)
 		log.Println("E! failed to open mysql:", err)
+		inputs.PushGatherError(slist, ins.Address, err)
 		return
 	}
 
@@ -212,6 +213,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	if err = db.Ping(); err != nil {
 		slist.PushSample(inputName, "up", 0, tags)
 		log.Println("E! failed to ping mysql:", err)
+		inputs.PushGatherError(slist, ins.Address, err)
 		return
 	}
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/types"
 	gnatsd "github.com/nats-io/nats-server/v2/server"
 	"io"
@@ -121,12 +122,11 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 }
 
 func (ins *Instance) createHTTPClient() (*http.Client, error) {
-	tr := &http.Transport{
-		ResponseHeaderTimeout: time.Duration(ins.ResponseTimeout),
-	}
+	tr := httpx.NewTransport()
+	tr.ResponseHeaderTimeout = time.Duration(ins.ResponseTimeout)
 
 	client := &http.Client{
-		Transport: tr,
+		Transport: httpx.WrapDefault(tr),
 		Timeout:   time.Duration(ins.ResponseTimeout),
 	}
 	return client, nil
@@ -0,0 +1,12 @@
+package net_response
+
+import "flashcat.cloud/categraf/types"
+
+// DescribeMetrics lists the metrics of net_response, mappings add extra tags per target
+func (n *NetResponse) DescribeMetrics() []types.MetricDesc {
+	tags := []string{"target", "protocol", "resolved_ip"}
+	return []types.MetricDesc{
+		{Name: "net_response_result_code", Type: types.Gauge, Help: "0: success, 1: timeout, 2: connection failed, 3: read failed, 4: string mismatch", Tags: tags},
+		{Name: "net_response_response_time", Type: types.Gauge, Unit: "seconds", Help: "-1 if the target is not reachable", Tags: tags},
+	}
+}
@@ -2,6 +2,7 @@ package net_response
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -14,6 +15,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/netx"
 	"flashcat.cloud/categraf/types"
 )
 
@@ -36,6 +38,8 @@ type Instance struct {
 	ReadTimeout config.Duration `toml:"read_timeout"`
 	Send        string          `toml:"send"`
 	Expect      string          `toml:"expect"`
+	netx.DialConfig
+	netx.ExpandConfig
 
 	Mappings map[string]map[string]string `toml:"mappings"`
 }
@@ -45,6 +49,10 @@ func (ins *Instance) Init() error {
 		return types.ErrInstancesEmpty
 	}
 
+	if err := ins.CheckDialConfig(); err != nil {
+		return err
+	}
+
 	if ins.Protocol == "" {
 		ins.Protocol = "tcp"
 	}
@@ -65,6 +73,7 @@ func (ins *Instance) Init() error {
 		ins.Expect = ""
 	}
 
+	// tcp and udp have no default port, every target must name one
 	for i := 0; i < len(ins.Targets); i++ {
 		target := ins.Targets[i]
 
@@ -73,13 +82,13 @@ func (ins *Instance) Init() error {
 			return fmt.Errorf("failed to split host port, target: %s, error: %v", target, err)
 		}
 
-		if host == "" {
-			ins.Targets[i] = "localhost:" + port
-		}
-
 		if port == "" {
 			return errors.New("bad port, target: " + target)
 		}
+
+		if host == "" {
+			ins.Targets[i] = "localhost:" + port
+		}
 	}
 
 	return nil
@@ -136,9 +145,10 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	}
 
 	wg := new(sync.WaitGroup)
-	for _, target := range ins.Targets {
+	// no default port, Init rejected the targets without one
+	for _, target := range ins.ExpandTargets(ins.Targets, "") {
 		wg.Add(1)
-		go func(target string) {
+		go func(target netx.Target) {
 			defer wg.Done()
 			ins.gather(slist, target)
 		}(target)
@@ -146,12 +156,16 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	wg.Wait()
 }
 
-func (ins *Instance) gather(slist *types.SampleList, target string) {
+func (ins *Instance) gather(slist *types.SampleList, t netx.Target) {
+	target := t.Origin
 	if ins.DebugMod {
-		log.Println("D! net_response... target:", target)
+		log.Println("D! net_response... target:", target, "address:", t.Address)
 	}
 
 	labels := map[string]string{"target": target}
+	if t.IP != "" {
+		labels["resolved_ip"] = t.IP
+	}
 	fields := map[string]interface{}{}
 	if m, ok := ins.Mappings[target]; ok {
 		for k, v := range m {
@@ -170,14 +184,14 @@ func (ins *Instance) gather(slist *types.SampleList, target string) {
 
 	switch ins.Protocol {
 	case "tcp":
-		returnTags, fields, err = ins.TCPGather(target)
+		returnTags, fields, err = ins.TCPGather(t.Address)
 		if err != nil {
 			log.Println("E! failed to gather:", target, "error:", err)
 			return
 		}
 		labels["protocol"] = "tcp"
 	case "udp":
-		returnTags, fields, err = ins.UDPGather(target)
+		returnTags, fields, err = ins.UDPGather(t.Address)
 		if err != nil {
 			log.Println("E! failed to gather:", target, "error:", err)
 			return
@@ -200,7 +214,7 @@ func (ins *Instance) TCPGather(address string) (map[string]string, map[string]in
 	// Start Timer
 	start := time.Now()
 	// Connecting
-	conn, err := net.DialTimeout("tcp", address, time.Duration(ins.Timeout))
+	conn, err := ins.DialContext(context.Background(), &net.Dialer{Timeout: time.Duration(ins.Timeout)}, "tcp", address)
 	// Stop timer
 	responseTime := time.Since(start).Seconds()
 	// Handle error
@@ -0,0 +1,32 @@
+package net_response
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitTargets(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+		ok     bool
+	}{
+		{"127.0.0.1:22", "127.0.0.1:22", true},
+		{":22", "localhost:22", true},
+		{"[::1]:53", "[::1]:53", true},
+		{"example.com", "", false},
+		{"example.com:", "", false},
+		{"::1", "", false},
+	}
+	for _, tt := range tests {
+		ins := &Instance{Targets: []string{tt.target}}
+		err := ins.Init()
+		if !tt.ok {
+			require.Error(t, err, tt.target)
+			continue
+		}
+		require.NoError(t, err, tt.target)
+		require.Equal(t, tt.want, ins.Targets[0])
+	}
+}
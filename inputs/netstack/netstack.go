@@ -0,0 +1,323 @@
+//go:build linux
+// +build linux
+
+package netstack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "netstack"
+
+// tcpStates are the st column of /proc/net/tcp, see include/net/tcp_states.h
+var tcpStates = map[string]string{
+	"01": "established",
+	"02": "syn_sent",
+	"03": "syn_recv",
+	"04": "fin_wait1",
+	"05": "fin_wait2",
+	"06": "time_wait",
+	"07": "close",
+	"08": "close_wait",
+	"09": "last_ack",
+	"0A": "listen",
+	"0B": "closing",
+	"0C": "new_syn_recv",
+}
+
+// tcpCounters are the fields of /proc/net/snmp (Tcp) and /proc/net/netstat (TcpExt) reported as counters
+var tcpCounters = map[string]map[string]string{
+	"Tcp": {
+		"RetransSegs":  "tcp_retrans_segs_total",
+		"OutSegs":      "tcp_out_segs_total",
+		"InErrs":       "tcp_in_errs_total",
+		"AttemptFails": "tcp_attempt_fails_total",
+		"EstabResets":  "tcp_estab_resets_total",
+	},
+	"TcpExt": {
+		"ListenOverflows": "tcp_listen_overflows_total",
+		"ListenDrops":     "tcp_listen_drops_total",
+		"TCPSynRetrans":   "tcp_syn_retrans_total",
+		"TCPTimeouts":     "tcp_timeouts_total",
+		"SyncookiesSent":  "tcp_syncookies_sent_total",
+		"TCPAbortOnData":  "tcp_abort_on_data_total",
+	},
+}
+
+// conntrackStats are the columns of /proc/net/stat/nf_conntrack summed over all cpus
+var conntrackStats = map[string]string{
+	"drop":          "conntrack_drop_total",
+	"early_drop":    "conntrack_early_drop_total",
+	"insert_failed": "conntrack_insert_failed_total",
+	"invalid":       "conntrack_invalid_total",
+}
+
+type NetStack struct {
+	config.PluginConfig
+
+	DisableConntrack bool `toml:"disable_conntrack"`
+	// counting the sockets reads /proc/net/tcp{,6}, which is slow with a lot of connections
+	DisableTCPStates bool `toml:"disable_tcp_states"`
+	DisableTCPStats  bool `toml:"disable_tcp_stats"`
+
+	procRoot string
+	// /proc/net links to the network namespace of the reader, so the one of
+	// the host is read from /proc/1/net under HOST_MOUNT_PREFIX
+	netDir string
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &NetStack{}
+	})
+}
+
+func (n *NetStack) Clone() inputs.Input {
+	return &NetStack{}
+}
+
+func (n *NetStack) Name() string {
+	return inputName
+}
+
+func (n *NetStack) Init() error {
+	n.procRoot, n.netDir = "/proc", "net"
+	if prefix, ok := os.LookupEnv("HOST_MOUNT_PREFIX"); ok {
+		n.procRoot, n.netDir = filepath.Join(prefix, "proc"), "1/net"
+	}
+	return nil
+}
+
+func (n *NetStack) Gather(slist *types.SampleList) {
+	if !n.DisableConntrack {
+		n.gatherConntrack(slist)
+	}
+	if !n.DisableTCPStates {
+		n.gatherTCPStates(slist)
+	}
+	if !n.DisableTCPStats {
+		n.gatherTCPStats(slist)
+	}
+}
+
+func (n *NetStack) path(elem ...string) string {
+	return filepath.Join(append([]string{n.procRoot}, elem...)...)
+}
+
+// netPath is the path of a file of /proc/net
+func (n *NetStack) netPath(name string) string {
+	return n.path(n.netDir, name)
+}
+
+func (n *NetStack) gatherConntrack(slist *types.SampleList) {
+	count, err1 := readUint(n.path("sys/net/netfilter/nf_conntrack_count"))
+	max, err2 := readUint(n.path("sys/net/netfilter/nf_conntrack_max"))
+	if err1 != nil || err2 != nil {
+		// the conntrack module is not loaded
+		if n.DebugMod {
+			log.Println("D! netstack failed to read conntrack count or max:", err1, err2)
+		}
+		return
+	}
+	slist.PushSample(inputName, "conntrack_entries", count)
+	slist.PushSample(inputName, "conntrack_max", max)
+	if max > 0 {
+		slist.PushSample(inputName, "conntrack_usage_percent", float64(count)/float64(max)*100)
+	}
+
+	stats, err := parseConntrackStat(n.netPath("stat/nf_conntrack"))
+	if err != nil {
+		if n.DebugMod {
+			log.Println("D! netstack failed to read conntrack stats:", err)
+		}
+		return
+	}
+	for field, metric := range conntrackStats {
+		if v, has := stats[field]; has {
+			slist.PushFront(types.NewSample(inputName, metric, v).SetType(types.Counter))
+		}
+	}
+}
+
+func (n *NetStack) gatherTCPStates(slist *types.SampleList) {
+	counts := make(map[string]uint64, len(tcpStates))
+	for _, state := range tcpStates {
+		counts[state] = 0
+	}
+	var listenBacklog uint64
+	found := false
+	for _, name := range []string{"tcp", "tcp6"} {
+		backlog, err := countTCPStates(n.netPath(name), counts)
+		if err != nil {
+			// tcp6 is missing with ipv6 disabled
+			if !os.IsNotExist(err) {
+				log.Println("E! netstack failed to count tcp sockets:", err)
+			}
+			continue
+		}
+		listenBacklog += backlog
+		found = true
+	}
+	if !found {
+		return
+	}
+	for state, v := range counts {
+		slist.PushSample(inputName, "tcp_sockets", v, map[string]string{"state": state})
+	}
+	slist.PushSample(inputName, "tcp_listen_backlog", listenBacklog)
+}
+
+func (n *NetStack) gatherTCPStats(slist *types.SampleList) {
+	for _, name := range []string{"snmp", "netstat"} {
+		stats, err := parseProtoStats(n.netPath(name))
+		if err != nil {
+			log.Println("E! netstack failed to read", name, "error:", err)
+			continue
+		}
+		for proto, fields := range tcpCounters {
+			for field, metric := range fields {
+				if v, has := stats[proto][field]; has {
+					slist.PushFront(types.NewSample(inputName, metric, v).SetType(types.Counter))
+				}
+			}
+		}
+	}
+}
+
+func readUint(path string) (uint64, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(bs)), 10, 64)
+}
+
+// countTCPStates adds the sockets of /proc/net/tcp{,6} to counts by state and
+// returns the connections waiting in the accept queues of the listening sockets
+func countTCPStates(path string, counts map[string]uint64) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var backlog uint64
+	scanner := bufio.NewScanner(f)
+	// skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		state, has := tcpStates[fields[3]]
+		if !has {
+			continue
+		}
+		counts[state]++
+		if state == "listen" {
+			// rx_queue of a listening socket is the accept queue length
+			if _, rx, ok := strings.Cut(fields[4], ":"); ok {
+				if v, err := strconv.ParseUint(rx, 16, 64); err == nil {
+					backlog += v
+				}
+			}
+		}
+	}
+	return backlog, scanner.Err()
+}
+
+// parseProtoStats parses /proc/net/snmp and /proc/net/netstat, every protocol
+// has a line of names followed by a line of values
+func parseProtoStats(path string) (map[string]map[string]uint64, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if !scanner.Scan() {
+			break
+		}
+		values := strings.Fields(scanner.Text())
+		if len(names) == 0 || len(names) != len(values) {
+			return nil, fmt.Errorf("field count mismatch in %s", path)
+		}
+		proto := strings.TrimSuffix(names[0], ":")
+		m := make(map[string]uint64, len(names)-1)
+		for i := 1; i < len(names); i++ {
+			// some fields of Tcp are signed, e.g. MaxConn is -1
+			v, err := strconv.ParseInt(values[i], 10, 64)
+			if err != nil || v < 0 {
+				continue
+			}
+			m[names[i]] = uint64(v)
+		}
+		stats[proto] = m
+	}
+	return stats, scanner.Err()
+}
+
+// parseConntrackStat sums the per cpu hex columns of /proc/net/stat/nf_conntrack
+func parseConntrackStat(path string) (map[string]uint64, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("no data in %s", path)
+	}
+	names := strings.Fields(lines[0])
+	stats := make(map[string]uint64, len(names))
+	for _, line := range lines[1:] {
+		values := strings.Fields(line)
+		if len(values) != len(names) {
+			continue
+		}
+		for i, name := range names {
+			v, err := strconv.ParseUint(values[i], 16, 64)
+			if err != nil {
+				continue
+			}
+			stats[name] += v
+		}
+	}
+	return stats, nil
+}
+
+// DescribeMetrics lists the metrics of netstack
+func (n *NetStack) DescribeMetrics() []types.MetricDesc {
+	descs := []types.MetricDesc{
+		{Name: "netstack_conntrack_entries", Type: types.Gauge, Help: "entries in the conntrack table"},
+		{Name: "netstack_conntrack_max", Type: types.Gauge, Help: "size of the conntrack table"},
+		{Name: "netstack_conntrack_usage_percent", Type: types.Gauge, Unit: "percent", Help: "conntrack table usage"},
+		{Name: "netstack_tcp_sockets", Type: types.Gauge, Help: "tcp sockets by state", Tags: []string{"state"}},
+		{Name: "netstack_tcp_listen_backlog", Type: types.Gauge, Help: "connections waiting in the accept queues of listening sockets"},
+	}
+	for _, metric := range conntrackStats {
+		descs = append(descs, types.MetricDesc{Name: inputName + "_" + metric, Type: types.Counter})
+	}
+	for _, fields := range tcpCounters {
+		for _, metric := range fields {
+			descs = append(descs, types.MetricDesc{Name: inputName + "_" + metric, Type: types.Counter})
+		}
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].Name < descs[j].Name })
+	return descs
+}
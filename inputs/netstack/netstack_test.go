@@ -0,0 +1,57 @@
+//go:build linux
+// +build linux
+
+package netstack
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestGather(t *testing.T) {
+	n := &NetStack{procRoot: "testdata/proc", netDir: "net"}
+	slist := types.NewSampleList()
+	n.Gather(slist)
+
+	values := make(map[string]interface{})
+	for _, s := range slist.PopBackAll() {
+		key := s.Metric
+		if state, has := s.Labels["state"]; has {
+			key += "/" + state
+		}
+		values[key] = s.Value
+	}
+
+	require.Equal(t, uint64(2500), values["netstack_conntrack_entries"])
+	require.Equal(t, 25.0, values["netstack_conntrack_usage_percent"])
+	require.Equal(t, uint64(15), values["netstack_conntrack_invalid_total"])
+	require.Equal(t, uint64(5), values["netstack_conntrack_drop_total"])
+
+	require.Equal(t, uint64(3), values["netstack_tcp_sockets/listen"])
+	require.Equal(t, uint64(1), values["netstack_tcp_sockets/established"])
+	require.Equal(t, uint64(1), values["netstack_tcp_sockets/time_wait"])
+	require.Equal(t, uint64(0), values["netstack_tcp_sockets/close_wait"])
+	require.Equal(t, uint64(4), values["netstack_tcp_listen_backlog"])
+
+	require.Equal(t, uint64(17), values["netstack_tcp_retrans_segs_total"])
+	require.Equal(t, uint64(4000), values["netstack_tcp_out_segs_total"])
+	require.Equal(t, uint64(7), values["netstack_tcp_listen_overflows_total"])
+	require.Equal(t, uint64(8), values["netstack_tcp_listen_drops_total"])
+}
+
+func TestHostMountPrefix(t *testing.T) {
+	t.Setenv("HOST_MOUNT_PREFIX", "/hostfs")
+	n := &NetStack{}
+	require.NoError(t, n.Init())
+	require.Equal(t, "/hostfs/proc/1/net/snmp", n.netPath("snmp"))
+	require.Equal(t, "/hostfs/proc/sys/net/netfilter/nf_conntrack_max", n.path("sys/net/netfilter/nf_conntrack_max"))
+}
+
+func TestDescribeMetricsSorted(t *testing.T) {
+	descs := (&NetStack{}).DescribeMetrics()
+	require.True(t, sort.SliceIsSorted(descs, func(i, j int) bool { return descs[i].Name < descs[j].Name }))
+}
@@ -15,6 +15,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -141,15 +142,14 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
-	trans := &http.Transport{
-		DisableKeepAlives: true,
-	}
+	trans := httpx.NewTransport()
+	trans.DisableKeepAlives = true
 	if ins.UseTLS {
 		trans.TLSClientConfig = tlsCfg
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.ResponseTimeout),
 	}
 
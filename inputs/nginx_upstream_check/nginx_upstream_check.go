@@ -14,6 +14,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/netx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
@@ -127,18 +128,17 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
-	trans := &http.Transport{
-		Proxy:             proxy,
-		DialContext:       dialer.DialContext,
-		DisableKeepAlives: true,
-	}
+	trans := httpx.NewTransport()
+	trans.Proxy = proxy
+	trans.DialContext = dialer.DialContext
+	trans.DisableKeepAlives = true
 
 	if ins.UseTLS {
 		trans.TLSClientConfig = tlsCfg
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.Timeout),
 	}
 
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,7 +20,11 @@ import (
 	"flashcat.cloud/categraf/types"
 )
 
-const inputName = "oracle"
+const (
+	inputName = "oracle"
+
+	defaultQueryTimeout = 5 * time.Second
+)
 
 type Instance struct {
 	config.InstanceConfig
@@ -174,13 +179,16 @@ func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.Samp
 	defer waitMetrics.Done()
 
 	timeout := time.Duration(metricConf.Timeout)
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	rows, err := ins.client.QueryContext(ctx, metricConf.Request)
 
 	if ctx.Err() == context.DeadlineExceeded {
-		log.Printf("E! %s oracle query timeout (more than %d seconds), request: %s", ins.Address, metricConf.Timeout/(1000*1000*1000),
+		log.Printf("E! %s oracle query timeout (more than %v), request: %s", ins.Address, timeout,
 			strings.ReplaceAll(strings.ReplaceAll(metricConf.Request, "\n", " "), "\r", " "))
 		return
 	}
@@ -202,6 +210,7 @@ func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.Samp
 		log.Println("D! columns:", cols)
 	}
 
+	count := 0
 	for rows.Next() {
 		columns := make([]interface{}, len(cols))
 		columnPointers := make([]interface{}, len(cols))
@@ -223,17 +232,20 @@ func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.Samp
 			m[strings.ToLower(colName)] = fmt.Sprint(*val)
 		}
 
-		count := 0
 		if err = ins.parseRow(m, metricConf, slist, tags); err != nil {
 			log.Println("E! failed to parse row:", err)
 			continue
-		} else {
-			count++
 		}
+		count++
+	}
 
-		if !metricConf.IgnoreZeroResult && count == 0 {
-			log.Println("E! no metrics found while parsing")
-		}
+	if err := rows.Err(); err != nil {
+		log.Println("E! failed to iterate rows:", err, "mesurement:", metricConf.Mesurement)
+		return
+	}
+
+	if !metricConf.IgnoreZeroResult && count == 0 {
+		log.Println("E! no metrics found while parsing, mesurement:", metricConf.Mesurement)
 	}
 }
 
@@ -319,17 +331,18 @@ func explode(target string) (ip string, port int, service string, err error) {
 		return "", 0, "", ErrInvalidTarget
 	}
 
-	ipPort := strings.Split(parts[0], ":")
-	if len(ipPort) != 2 {
+	// SplitHostPort also handles bracketed ipv6 literals, e.g. [fd00::10]:1521
+	host, portStr, err := net.SplitHostPort(strings.TrimSpace(parts[0]))
+	if err != nil {
 		return "", 0, "", ErrInvalidTarget
 	}
 
-	port, err = strconv.Atoi(ipPort[1])
+	port, err = strconv.Atoi(portStr)
 	if err != nil {
 		return "", 0, "", ErrInvalidTarget
 	}
 
-	ip = strings.TrimSpace(ipPort[0])
+	ip = strings.TrimSpace(host)
 	if ip == "" {
 		return "", 0, "", ErrInvalidTarget
 	}
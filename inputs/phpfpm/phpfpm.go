@@ -16,6 +16,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 	fcgiclient "github.com/tomasen/fcgi_client"
@@ -36,6 +37,10 @@ const (
 	PfMaxActiveProcesses = "max active processes"
 	PfMaxChildrenReached = "max children reached"
 	PfSlowRequests       = "slow requests"
+
+	// fields of the processes of the full status
+	PfProcessLastRequestCPU    = "last request cpu"
+	PfProcessLastRequestMemory = "last request memory"
 )
 
 type PhpFpm struct {
@@ -79,6 +84,10 @@ type Instance struct {
 	Password        string          `toml:"password"`
 	Headers         []string        `toml:"headers"`
 
+	// request the full status, which lists the processes of the pools, to
+	// report the memory and cpu of their last requests
+	FullStatus bool `toml:"full_status"`
+
 	tls.ClientConfig
 
 	client *http.Client
@@ -160,6 +169,13 @@ func (ins *Instance) gatherHTTP(addr string) (*http.Response, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse url: %s, error: %s", addr, err)
 	}
+	if ins.FullStatus {
+		if u.RawQuery == "" {
+			u.RawQuery = "full"
+		} else {
+			u.RawQuery += "&full"
+		}
+	}
 
 	var body io.Reader
 	request, err := http.NewRequest("GET", u.String(), body)
@@ -211,6 +227,9 @@ func (ins *Instance) gatherFCGI(addr, network string) (*http.Response, error) {
 	env["SCRIPT_NAME"] = scriptName
 	env["SERVER_SOFTWARE"] = "go / fcgiClient "
 	env["REMOTE_ADDR"] = "127.0.0.1"
+	if ins.FullStatus {
+		env["QUERY_STRING"] = "full"
+	}
 
 	fcgi, err := fcgiclient.Dial(network, networkAddr)
 	if err != nil {
@@ -241,15 +260,14 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
-	trans := &http.Transport{
-		DisableKeepAlives: true,
-	}
+	trans := httpx.NewTransport()
+	trans.DisableKeepAlives = true
 	if ins.UseTLS {
 		trans.TLSClientConfig = tlsCfg
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.ResponseTimeout),
 	}
 
@@ -359,13 +377,60 @@ func isUnixSocketFile(info os.FileInfo) bool {
 	return info.Mode().String()[0] == os.ModeSocket.String()[0]
 }
 
+// processStat sums up the processes of a pool listed by the full status
+type processStat struct {
+	// processes which served a request, and the memory and cpu of their last one
+	served    int
+	memorySum int64
+	memoryMax int64
+	cpuSum    float64
+	cpuMax    float64
+}
+
+func (ps *processStat) add(memory int64, cpu float64, served bool) {
+	if !served {
+		return
+	}
+	ps.served++
+	ps.memorySum += memory
+	if memory > ps.memoryMax {
+		ps.memoryMax = memory
+	}
+	ps.cpuSum += cpu
+	if cpu > ps.cpuMax {
+		ps.cpuMax = cpu
+	}
+}
+
 func importMetric(r io.Reader, sList *types.SampleList, addr string) {
 	stats := make(poolStat)
+	processes := make(map[string]*processStat)
 	var currentPool string
+	// the process being parsed, after the ***** line starting each process of the full status
+	var (
+		inProcess bool
+		memory    int64
+		cpu       float64
+		served    bool
+	)
+	endProcess := func() {
+		if inProcess {
+			processes[currentPool].add(memory, cpu, served)
+		}
+		inProcess, memory, cpu, served = false, 0, 0, false
+	}
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		statLine := scanner.Text()
+		if strings.HasPrefix(statLine, "*") {
+			endProcess()
+			if _, has := processes[currentPool]; !has {
+				processes[currentPool] = &processStat{}
+			}
+			inProcess = true
+			continue
+		}
 		keyValue := strings.Split(statLine, ":")
 
 		if len(keyValue) < 2 {
@@ -374,11 +439,26 @@ func importMetric(r io.Reader, sList *types.SampleList, addr string) {
 		fieldName := strings.Trim(keyValue[0], " ")
 		// We start to gather data for a new pool here
 		if fieldName == PfPool {
+			endProcess()
 			currentPool = strings.Trim(keyValue[1], " ")
 			stats[currentPool] = make(metric)
 			continue
 		}
 
+		if inProcess {
+			value := strings.Trim(keyValue[1], " ")
+			switch fieldName {
+			case PfProcessLastRequestMemory:
+				memory, _ = strconv.ParseInt(value, 10, 64)
+			case PfProcessLastRequestCPU:
+				cpu, _ = strconv.ParseFloat(value, 64)
+			case "requests":
+				n, _ := strconv.ParseInt(value, 10, 64)
+				served = n > 0
+			}
+			continue
+		}
+
 		// Start to parse metric for current pool
 		switch fieldName {
 		case PfStartSince,
@@ -398,6 +478,7 @@ func importMetric(r io.Reader, sList *types.SampleList, addr string) {
 			}
 		}
 	}
+	endProcess()
 
 	// Finally, we push the pool metric
 	for pool := range stats {
@@ -409,6 +490,13 @@ func importMetric(r io.Reader, sList *types.SampleList, addr string) {
 		for k, v := range stats[pool] {
 			fields[strings.ReplaceAll(k, " ", "_")] = v
 		}
+		if ps, has := processes[pool]; has && ps.served > 0 {
+			fields["last_request_memory_avg_bytes"] = ps.memorySum / int64(ps.served)
+			fields["last_request_memory_max_bytes"] = ps.memoryMax
+			fields["last_request_memory_sum_bytes"] = ps.memorySum
+			fields["last_request_cpu_avg"] = ps.cpuSum / float64(ps.served)
+			fields["last_request_cpu_max"] = ps.cpuMax
+		}
 		sList.PushSamples("phpfpm", fields, tags)
 	}
 }
@@ -0,0 +1,72 @@
+package phpfpm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+const fullStatus = `pool:                 www
+process manager:      dynamic
+start time:           01/Jan/2024:10:00:00 +0000
+start since:          3600
+accepted conn:        120
+listen queue:         2
+max listen queue:     5
+listen queue len:     128
+idle processes:       1
+active processes:     2
+total processes:      3
+max active processes: 3
+max children reached: 0
+slow requests:        4
+
+************************
+pid:                  101
+state:                Idle
+start time:           01/Jan/2024:10:00:00 +0000
+start since:          10
+requests:             60
+request duration:     2000
+last request cpu:     10.00
+last request memory:  2097152
+
+************************
+pid:                  102
+state:                Running
+start since:          20
+requests:             60
+last request cpu:     30.00
+last request memory:  6291456
+
+************************
+pid:                  103
+state:                Idle
+start since:          30
+requests:             0
+last request cpu:     0.00
+last request memory:  0
+`
+
+func TestImportMetricFullStatus(t *testing.T) {
+	slist := types.NewSampleList()
+	importMetric(strings.NewReader(fullStatus), slist, "fcgi://127.0.0.1:9000")
+
+	ret := make(map[string]interface{})
+	for _, s := range slist.PopBackAll() {
+		require.Equal(t, "www", s.Labels["pool"])
+		ret[s.Metric] = s.Value
+	}
+	// the start since of the processes doesn't override the one of the pool
+	require.Equal(t, int64(3600), ret["phpfpm_start_since"])
+	require.Equal(t, int64(2), ret["phpfpm_active_processes"])
+	require.Equal(t, int64(4), ret["phpfpm_slow_requests"])
+	require.Equal(t, int64(4194304), ret["phpfpm_last_request_memory_avg_bytes"])
+	require.Equal(t, int64(6291456), ret["phpfpm_last_request_memory_max_bytes"])
+	require.Equal(t, int64(8388608), ret["phpfpm_last_request_memory_sum_bytes"])
+	require.Equal(t, 20.0, ret["phpfpm_last_request_cpu_avg"])
+	require.Equal(t, 30.0, ret["phpfpm_last_request_cpu_max"])
+}
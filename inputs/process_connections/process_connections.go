@@ -0,0 +1,342 @@
+package process_connections
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "process_connections"
+
+type ProcessConnections struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &ProcessConnections{}
+	})
+}
+
+func (p *ProcessConnections) Clone() inputs.Input {
+	return &ProcessConnections{}
+}
+
+func (p *ProcessConnections) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(ProcessConnections)
+var _ inputs.InstancesGetter = new(ProcessConnections)
+
+type Instance struct {
+	config.InstanceConfig
+
+	// tcp and/or udp, both by default
+	Protocols []string `toml:"protocols"`
+	// globs of process names, empty means all
+	Processes       []string `toml:"processes"`
+	IgnoreProcesses []string `toml:"ignore_processes"`
+	// label the series by pid too, a restarted process makes new series
+	PidLabel bool `toml:"pid_label"`
+
+	// remote endpoints reported per process, the others are summed up,
+	// default 5, negative disables them
+	TopRemotes int `toml:"top_remotes"`
+	// remote endpoint series of all processes at most, default 1000
+	MaxRemotes int `toml:"max_remotes"`
+
+	Timeout config.Duration `toml:"timeout"`
+
+	processesFilter       filter.Filter
+	ignoreProcessesFilter filter.Filter
+	tcp, udp              bool
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Protocols) == 0 {
+		ins.Protocols = []string{"tcp", "udp"}
+	}
+	for _, p := range ins.Protocols {
+		switch p {
+		case "tcp":
+			ins.tcp = true
+		case "udp":
+			ins.udp = true
+		default:
+			return fmt.Errorf("protocols should be tcp or udp, got %q", p)
+		}
+	}
+	if ins.TopRemotes == 0 {
+		ins.TopRemotes = 5
+	}
+	if ins.MaxRemotes <= 0 {
+		ins.MaxRemotes = 1000
+	}
+	if ins.Timeout <= 0 {
+		ins.Timeout = config.Duration(10 * time.Second)
+	}
+
+	var err error
+	if len(ins.Processes) > 0 {
+		if ins.processesFilter, err = filter.Compile(ins.Processes); err != nil {
+			return err
+		}
+	}
+	if len(ins.IgnoreProcesses) > 0 {
+		if ins.ignoreProcessesFilter, err = filter.Compile(ins.IgnoreProcesses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	kind := "inet"
+	if !ins.udp {
+		kind = "tcp"
+	} else if !ins.tcp {
+		kind = "udp"
+	}
+
+	begun := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ins.Timeout))
+	defer cancel()
+	conns, err := net.ConnectionsWithoutUidsWithContext(ctx, kind)
+	if err != nil {
+		log.Println("E! failed to list the sockets of processes:", err)
+		inputs.PushGatherError(slist, "", err)
+		return
+	}
+
+	names := make(map[int32]string)
+	a := ins.account(conns, func(pid int32) string {
+		name, has := names[pid]
+		if !has {
+			if p, err := process.NewProcess(pid); err == nil {
+				name, _ = p.Name()
+			}
+			names[pid] = name
+		}
+		return name
+	})
+	a.push(slist, ins.PidLabel)
+	slist.PushFront(types.NewSample(inputName, "scan_duration_seconds", time.Since(begun).Seconds()))
+}
+
+// processKey identifies the owner of sockets
+type processKey struct {
+	name string
+	pid  int32
+}
+
+type socketKey struct {
+	protocol string
+	state    string
+}
+
+type remoteKey struct {
+	protocol  string
+	direction string
+	remote    string
+}
+
+type processSockets struct {
+	sockets map[socketKey]int
+	remotes map[remoteKey]int
+	// connections with a remote endpoint
+	connected int
+}
+
+type accounting struct {
+	processes map[processKey]*processSockets
+	// sockets without process, e.g. in TIME_WAIT, or of processes categraf can't see
+	unowned map[socketKey]int
+
+	topRemotes int
+	maxRemotes int
+}
+
+// account groups conns by process, name looks up the name of a pid
+func (ins *Instance) account(conns []net.ConnectionStat, name func(pid int32) string) *accounting {
+	a := &accounting{
+		processes:  make(map[processKey]*processSockets),
+		unowned:    make(map[socketKey]int),
+		topRemotes: ins.TopRemotes,
+		maxRemotes: ins.MaxRemotes,
+	}
+
+	// ports processes listen on, connections to them are inbound
+	listening := make(map[int32]map[uint32]struct{})
+	for _, c := range conns {
+		if c.Pid > 0 && c.Status == "LISTEN" {
+			if listening[c.Pid] == nil {
+				listening[c.Pid] = make(map[uint32]struct{})
+			}
+			listening[c.Pid][c.Laddr.Port] = struct{}{}
+		}
+	}
+
+	for _, c := range conns {
+		protocol := protocolOf(c)
+		if protocol == "" || (protocol == "tcp" && !ins.tcp) || (protocol == "udp" && !ins.udp) {
+			continue
+		}
+		sk := socketKey{protocol: protocol, state: strings.ToLower(c.Status)}
+		if c.Pid <= 0 {
+			a.unowned[sk]++
+			continue
+		}
+
+		key := processKey{name: name(c.Pid)}
+		if key.name == "" || !ins.selected(key.name) {
+			continue
+		}
+		if ins.PidLabel {
+			key.pid = c.Pid
+		}
+		ps, has := a.processes[key]
+		if !has {
+			ps = &processSockets{sockets: make(map[socketKey]int), remotes: make(map[remoteKey]int)}
+			a.processes[key] = ps
+		}
+		ps.sockets[sk]++
+
+		if c.Raddr.IP == "" || c.Raddr.Port == 0 || a.topRemotes < 0 {
+			continue
+		}
+		// the ports of clients are random, so inbound connections are
+		// told apart by ip only
+		rk := remoteKey{protocol: protocol, direction: "outbound", remote: endpoint(c.Raddr)}
+		if _, in := listening[c.Pid][c.Laddr.Port]; in {
+			rk.direction, rk.remote = "inbound", c.Raddr.IP
+		}
+		ps.remotes[rk]++
+		ps.connected++
+	}
+	return a
+}
+
+func (ins *Instance) selected(name string) bool {
+	if ins.processesFilter != nil && !ins.processesFilter.Match(name) {
+		return false
+	}
+	if ins.ignoreProcessesFilter != nil && ins.ignoreProcessesFilter.Match(name) {
+		return false
+	}
+	return true
+}
+
+// endpoint formats addr as host:port, with brackets around ipv6 addresses
+func endpoint(addr net.Addr) string {
+	port := strconv.FormatUint(uint64(addr.Port), 10)
+	if strings.Contains(addr.IP, ":") {
+		return "[" + addr.IP + "]:" + port
+	}
+	return addr.IP + ":" + port
+}
+
+func protocolOf(c net.ConnectionStat) string {
+	switch c.Type {
+	case syscall.SOCK_STREAM:
+		return "tcp"
+	case syscall.SOCK_DGRAM:
+		return "udp"
+	}
+	return ""
+}
+
+func (a *accounting) push(slist *types.SampleList, pidLabel bool) {
+	for sk, n := range a.unowned {
+		slist.PushFront(types.NewSample(inputName, "unowned_sockets", n, map[string]string{"protocol": sk.protocol, "state": sk.state}))
+	}
+	slist.PushFront(types.NewSample(inputName, "processes", len(a.processes)))
+
+	// processes with the most connections get the budget of max_remotes first
+	keys := make([]processKey, 0, len(a.processes))
+	for key := range a.processes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := a.processes[keys[i]].connected, a.processes[keys[j]].connected
+		if ci != cj {
+			return ci > cj
+		}
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].pid < keys[j].pid
+	})
+
+	budget := a.maxRemotes
+	for _, key := range keys {
+		ps := a.processes[key]
+		tags := map[string]string{"process": key.name}
+		if pidLabel {
+			tags["pid"] = strconv.Itoa(int(key.pid))
+		}
+		for sk, n := range ps.sockets {
+			slist.PushFront(types.NewSample(inputName, "sockets", n, tags, map[string]string{"protocol": sk.protocol, "state": sk.state}))
+		}
+		if a.topRemotes < 0 {
+			continue
+		}
+
+		remotes := make([]remoteKey, 0, len(ps.remotes))
+		for rk := range ps.remotes {
+			remotes = append(remotes, rk)
+		}
+		sort.Slice(remotes, func(i, j int) bool {
+			ni, nj := ps.remotes[remotes[i]], ps.remotes[remotes[j]]
+			if ni != nj {
+				return ni > nj
+			}
+			return remotes[i].remote < remotes[j].remote
+		})
+		n := a.topRemotes
+		if n > len(remotes) {
+			n = len(remotes)
+		}
+		if n > budget {
+			n = budget
+		}
+		budget -= n
+
+		others := ps.connected
+		for _, rk := range remotes[:n] {
+			count := ps.remotes[rk]
+			others -= count
+			slist.PushFront(types.NewSample(inputName, "remote_connections", count, tags, map[string]string{
+				"protocol":  rk.protocol,
+				"direction": rk.direction,
+				"remote":    rk.remote,
+			}))
+		}
+		slist.PushFront(types.NewSample(inputName, "remote_others", others, tags))
+	}
+}
+
+func (p *ProcessConnections) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "process_connections_sockets", Type: types.Gauge, Help: "sockets of a process by protocol and state", Tags: []string{"process", "pid", "protocol", "state"}},
+		{Name: "process_connections_remote_connections", Type: types.Gauge, Help: "connections of a process to one of its top remote endpoints", Tags: []string{"process", "pid", "protocol", "direction", "remote"}},
+		{Name: "process_connections_remote_others", Type: types.Gauge, Help: "connections of a process to the endpoints not reported", Tags: []string{"process", "pid"}},
+		{Name: "process_connections_unowned_sockets", Type: types.Gauge, Help: "sockets without a process, e.g. in time_wait", Tags: []string{"protocol", "state"}},
+		{Name: "process_connections_processes", Type: types.Gauge, Help: "processes with sockets"},
+		{Name: "process_connections_scan_duration_seconds", Type: types.Gauge, Unit: "seconds", Help: "time taken to map the sockets to processes"},
+	}
+}
@@ -0,0 +1,89 @@
+package process_connections
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func conn(pid int32, status string, lport uint32, rip string, rport uint32) net.ConnectionStat {
+	return net.ConnectionStat{
+		Type:   syscall.SOCK_STREAM,
+		Pid:    pid,
+		Status: status,
+		Laddr:  net.Addr{IP: "10.0.0.1", Port: lport},
+		Raddr:  net.Addr{IP: rip, Port: rport},
+	}
+}
+
+func TestAccount(t *testing.T) {
+	ins := &Instance{TopRemotes: 1, IgnoreProcesses: []string{"sshd"}}
+	require.NoError(t, ins.Init())
+
+	conns := []net.ConnectionStat{
+		conn(1, "LISTEN", 8080, "", 0),
+		conn(1, "ESTABLISHED", 8080, "10.0.0.7", 50001),
+		conn(1, "ESTABLISHED", 8080, "10.0.0.7", 50002),
+		conn(1, "ESTABLISHED", 8080, "10.0.0.8", 50003),
+		conn(1, "ESTABLISHED", 40000, "10.0.0.9", 3306),
+		conn(2, "ESTABLISHED", 22, "10.0.0.7", 60000),
+		conn(0, "TIME_WAIT", 8080, "10.0.0.7", 50000),
+		{Type: syscall.SOCK_DGRAM, Pid: 1, Status: "NONE", Laddr: net.Addr{IP: "0.0.0.0", Port: 53}},
+	}
+	names := map[int32]string{1: "api", 2: "sshd"}
+	a := ins.account(conns, func(pid int32) string { return names[pid] })
+
+	require.Len(t, a.processes, 1)
+	ps := a.processes[processKey{name: "api"}]
+	require.Equal(t, map[socketKey]int{
+		{"tcp", "listen"}:      1,
+		{"tcp", "established"}: 4,
+		{"udp", "none"}:        1,
+	}, ps.sockets)
+	require.Equal(t, map[remoteKey]int{
+		{"tcp", "inbound", "10.0.0.7"}:       2,
+		{"tcp", "inbound", "10.0.0.8"}:       1,
+		{"tcp", "outbound", "10.0.0.9:3306"}: 1,
+	}, ps.remotes)
+	require.Equal(t, map[socketKey]int{{"tcp", "time_wait"}: 1}, a.unowned)
+
+	slist := types.NewSampleList()
+	a.push(slist, false)
+	var remotes []string
+	others := -1
+	for _, s := range slist.PopBackAll() {
+		switch s.Metric {
+		case "process_connections_remote_connections":
+			remotes = append(remotes, s.Labels["remote"])
+		case "process_connections_remote_others":
+			others = s.Value.(int)
+		}
+	}
+	require.Equal(t, []string{"10.0.0.7"}, remotes)
+	require.Equal(t, 2, others)
+}
+
+func TestAccountMaxRemotes(t *testing.T) {
+	ins := &Instance{MaxRemotes: 1}
+	require.NoError(t, ins.Init())
+	conns := []net.ConnectionStat{
+		conn(1, "ESTABLISHED", 40000, "10.0.0.9", 3306),
+		conn(1, "ESTABLISHED", 40001, "10.0.0.9", 3306),
+		conn(2, "ESTABLISHED", 40002, "10.0.0.10", 6379),
+	}
+	a := ins.account(conns, func(pid int32) string { return map[int32]string{1: "api", 2: "worker"}[pid] })
+
+	slist := types.NewSampleList()
+	a.push(slist, false)
+	var remotes []string
+	for _, s := range slist.PopBackAll() {
+		if s.Metric == "process_connections_remote_connections" {
+			remotes = append(remotes, s.Labels["process"]+" "+s.Labels["remote"])
+		}
+	}
+	require.Equal(t, []string{"api 10.0.0.9:3306"}, remotes)
+}
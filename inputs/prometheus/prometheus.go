@@ -15,6 +15,7 @@ import (
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/parser/prometheus"
 	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -47,6 +48,7 @@ type Instance struct {
 	lock                  sync.Mutex
 	firstRun              bool
 	tls.ClientConfig
+	config.HTTPProxy
 	client *http.Client
 }
 
@@ -111,20 +113,20 @@ func (ins *Instance) Init() error {
 }
 
 func (ins *Instance) createHTTPClient() (*http.Client, error) {
-	trans := &http.Transport{}
-
+	proxy, err := ins.Proxy()
+	if err != nil {
+		return nil, err
+	}
+	opts := []httpx.Option{httpx.Proxy(proxy), httpx.Timeout(time.Duration(ins.Timeout))}
 	if ins.UseTLS {
 		tlsConfig, err := ins.ClientConfig.TLSConfig()
 		if err != nil {
 			return nil, err
 		}
-		trans.TLSClientConfig = tlsConfig
+		opts = append(opts, httpx.TlsConfig(tlsConfig))
 	}
 
-	client := &http.Client{
-		Transport: trans,
-		Timeout:   time.Duration(ins.Timeout),
-	}
+	client := httpx.CreateHTTPClient(opts...)
 
 	return client, nil
 }
@@ -186,6 +188,14 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	}
 }
 
+// errorInstance is the instance label of agent_input_error, without the
+// credentials a scrape url may hold
+func errorInstance(u *url.URL) string {
+	v := *u
+	v.User = nil
+	return v.String()
+}
+
 func (ins *Instance) gatherUrl(urlwg *sync.WaitGroup, slist *types.SampleList, uri ScrapeUrl) {
 	defer urlwg.Done()
 
@@ -217,12 +227,14 @@ func (ins *Instance) gatherUrl(urlwg *sync.WaitGroup, slist *types.SampleList, u
 	if err != nil {
 		slist.PushFront(types.NewSample("", "up", 0, labels))
 		log.Println("E! failed to query url:", u.String(), "error:", err)
+		inputs.PushGatherError(slist, errorInstance(u), err)
 		return
 	}
 
 	if res.StatusCode != http.StatusOK {
 		slist.PushFront(types.NewSample("", "up", 0, labels))
 		log.Println("E! failed to query url:", u.String(), "status code:", res.StatusCode)
+		inputs.PushError(slist, errorInstance(u), "http_status")
 		return
 	}
 
@@ -240,6 +252,7 @@ func (ins *Instance) gatherUrl(urlwg *sync.WaitGroup, slist *types.SampleList, u
 	parser := prometheus.NewParser(ins.NamePrefix, labels, res.Header, ins.DuplicationAllowed, ins.ignoreMetricsFilter, ins.ignoreLabelKeysFilter)
 	if err = parser.Parse(body, slist); err != nil {
 		log.Println("E! failed to parse response body, url:", u.String(), "error:", err)
+		inputs.PushError(slist, errorInstance(u), "parse")
 	}
 }
 
@@ -12,6 +12,7 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -123,9 +124,8 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		ins.ClientTimeout = config.Duration(time.Second * 4)
 	}
 
-	trans := &http.Transport{
-		ResponseHeaderTimeout: time.Duration(ins.HeaderTimeout),
-	}
+	trans := httpx.NewTransport()
+	trans.ResponseHeaderTimeout = time.Duration(ins.HeaderTimeout)
 
 	if ins.UseTLS {
 		tlsConfig, err := ins.ClientConfig.TLSConfig()
@@ -136,7 +136,7 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.ClientTimeout),
 	}
 
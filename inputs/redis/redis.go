@@ -123,6 +123,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	if err != nil {
 		slist.PushFront(types.NewSample(inputName, "up", 0, tags))
 		log.Println("E! failed to ping redis:", ins.Address, "error:", err)
+		inputs.PushGatherError(slist, ins.Address, err)
 		return
 	} else {
 		slist.PushFront(types.NewSample(inputName, "up", 1, tags))
@@ -0,0 +1,237 @@
+package redis_cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"flashcat.cloud/categraf/types"
+)
+
+const clusterSlots = 16384
+
+type clusterNode struct {
+	id       string
+	addr     string
+	flags    map[string]bool
+	masterID string
+	// link-state is connected
+	connected bool
+	slots     int
+	// slots this node is moving out or in, listed by the answering node only
+	migrating int
+	importing int
+}
+
+func (n *clusterNode) master() bool {
+	return n.flags["master"]
+}
+
+// failing is flagged fail by the majority of masters, or fail? by the
+// answering node
+func (n *clusterNode) failing() bool {
+	return n.flags["fail"] || n.flags["fail?"]
+}
+
+func (n *clusterNode) healthy() bool {
+	return n.connected && !n.failing() && !n.flags["handshake"] && !n.flags["noaddr"]
+}
+
+// parseClusterNodes parses the reply of CLUSTER NODES, lines of
+// <id> <ip:port@cport> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> ...
+func parseClusterNodes(text string) ([]*clusterNode, error) {
+	var nodes []*clusterNode
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("invalid line of cluster nodes: %q", line)
+		}
+		n := &clusterNode{
+			id:        fields[0],
+			addr:      strings.SplitN(fields[1], "@", 2)[0],
+			flags:     make(map[string]bool),
+			masterID:  fields[3],
+			connected: fields[7] == "connected",
+		}
+		for _, flag := range strings.Split(fields[2], ",") {
+			n.flags[flag] = true
+		}
+		for _, slot := range fields[8:] {
+			switch {
+			case strings.Contains(slot, "->-"):
+				n.migrating++
+			case strings.Contains(slot, "-<-"):
+				n.importing++
+			default:
+				count, err := slotCount(slot)
+				if err != nil {
+					return nil, fmt.Errorf("invalid slots %q of node %s: %v", slot, n.id, err)
+				}
+				n.slots += count
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// slotCount counts the slots of a single slot or a range like 0-5460
+func slotCount(slot string) (int, error) {
+	from, to, isRange := strings.Cut(slot, "-")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return 0, err
+	}
+	if !isRange {
+		return 1, nil
+	}
+	end, err := strconv.Atoi(to)
+	if err != nil {
+		return 0, err
+	}
+	if end < start {
+		return 0, fmt.Errorf("end before start")
+	}
+	return end - start + 1, nil
+}
+
+// parseClusterInfo parses the key:value lines of CLUSTER INFO
+func parseClusterInfo(text string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if found {
+			info[key] = value
+		}
+	}
+	return info
+}
+
+type clusterHealth struct {
+	stateOK      bool
+	info         map[string]string
+	slotsCovered int
+	masters      int
+	replicas     int
+	failing      int
+	disconnected int
+	degraded     int
+	migrating    int
+	importing    int
+	// master id -> slots and healthy replicas
+	masterSlots    map[string]int
+	masterReplicas map[string]int
+	masterAddrs    map[string]string
+}
+
+// evaluateCluster works out the health of the cluster, masters with slots
+// need minReplicas healthy replicas
+func evaluateCluster(info map[string]string, nodes []*clusterNode, minReplicas int) *clusterHealth {
+	h := &clusterHealth{
+		stateOK:        info["cluster_state"] == "ok",
+		info:           info,
+		masterSlots:    make(map[string]int),
+		masterReplicas: make(map[string]int),
+		masterAddrs:    make(map[string]string),
+	}
+	for _, n := range nodes {
+		if n.failing() {
+			h.failing++
+		}
+		if !n.connected {
+			h.disconnected++
+		}
+		h.migrating += n.migrating
+		h.importing += n.importing
+		if n.master() {
+			h.masters++
+			h.masterSlots[n.id] = n.slots
+			h.masterAddrs[n.id] = n.addr
+			if _, has := h.masterReplicas[n.id]; !has {
+				h.masterReplicas[n.id] = 0
+			}
+			if n.healthy() {
+				h.slotsCovered += n.slots
+			}
+			continue
+		}
+		h.replicas++
+		if n.healthy() && n.masterID != "-" {
+			h.masterReplicas[n.masterID]++
+		}
+	}
+	for id, slots := range h.masterSlots {
+		if slots > 0 && h.masterReplicas[id] < minReplicas {
+			h.degraded++
+		}
+	}
+	return h
+}
+
+func (h *clusterHealth) ok() bool {
+	return h.stateOK && h.slotsCovered == clusterSlots && h.failing == 0 && h.degraded == 0
+}
+
+func (h *clusterHealth) push(slist *types.SampleList, tags map[string]string) {
+	fields := map[string]interface{}{
+		"state_ok":             h.stateOK,
+		"slots_covered":        h.slotsCovered,
+		"slots_coverage_ratio": float64(h.slotsCovered) / clusterSlots,
+		"nodes_failing":        h.failing,
+		"nodes_disconnected":   h.disconnected,
+		"masters_degraded":     h.degraded,
+		"migrating_slots":      h.migrating,
+		"importing_slots":      h.importing,
+		"ok":                   h.ok(),
+	}
+	for field, key := range map[string]string{
+		"slots_assigned": "cluster_slots_assigned",
+		"slots_ok":       "cluster_slots_ok",
+		"slots_pfail":    "cluster_slots_pfail",
+		"slots_fail":     "cluster_slots_fail",
+		"current_epoch":  "cluster_current_epoch",
+	} {
+		if v, err := strconv.ParseInt(h.info[key], 10, 64); err == nil {
+			fields[field] = v
+		}
+	}
+	slist.PushSamples(inputName, fields, tags)
+
+	slist.PushFront(types.NewSample(inputName, "nodes", h.masters, tags, map[string]string{"role": "master"}))
+	slist.PushFront(types.NewSample(inputName, "nodes", h.replicas, tags, map[string]string{"role": "replica"}))
+	for id, slots := range h.masterSlots {
+		node := map[string]string{"node": h.masterAddrs[id]}
+		slist.PushFront(types.NewSample(inputName, "master_slots", slots, tags, node))
+		slist.PushFront(types.NewSample(inputName, "master_replicas", h.masterReplicas[id], tags, node))
+	}
+}
+
+func (ins *Instance) gatherCluster(slist *types.SampleList, tags map[string]string) (string, error) {
+	var info, nodes string
+	server, err := ins.firstAnswer(func(ctx context.Context, opt *redis.Options) error {
+		cli := redis.NewClient(opt)
+		defer cli.Close()
+		var err error
+		if info, err = cli.ClusterInfo(ctx).Result(); err != nil {
+			return err
+		}
+		nodes, err = cli.ClusterNodes(ctx).Result()
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := parseClusterNodes(nodes)
+	if err != nil {
+		return server, err
+	}
+	evaluateCluster(parseClusterInfo(info), parsed, ins.MinReplicas).push(slist, tags)
+	return server, nil
+}
@@ -0,0 +1,181 @@
+package redis_cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/tls"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "redis_cluster"
+
+	modeCluster  = "cluster"
+	modeSentinel = "sentinel"
+)
+
+type RedisCluster struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &RedisCluster{}
+	})
+}
+
+func (r *RedisCluster) Clone() inputs.Input {
+	return &RedisCluster{}
+}
+
+func (r *RedisCluster) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(RedisCluster)
+var _ inputs.InstancesGetter = new(RedisCluster)
+
+type Instance struct {
+	config.InstanceConfig
+
+	// cluster(default) checks a redis cluster, sentinel the masters monitored by sentinels
+	Mode string `toml:"mode"`
+	// host:port of cluster nodes or sentinels, the first one answering is asked
+	Servers  []string `toml:"servers"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	// value of the cluster label, default the first server
+	ClusterName string `toml:"cluster_name"`
+	// healthy replicas each master needs for the topology to be ok
+	MinReplicas int             `toml:"min_replicas"`
+	Timeout     config.Duration `toml:"timeout"`
+
+	tls.ClientConfig
+
+	options []*redis.Options
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Servers) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	switch ins.Mode {
+	case "":
+		ins.Mode = modeCluster
+	case modeCluster, modeSentinel:
+	default:
+		return fmt.Errorf("mode should be cluster or sentinel, got %q", ins.Mode)
+	}
+	if ins.ClusterName == "" {
+		ins.ClusterName = ins.Servers[0]
+	}
+	if ins.MinReplicas < 0 {
+		return fmt.Errorf("min_replicas should not be negative, got %d", ins.MinReplicas)
+	}
+	if ins.Timeout <= 0 {
+		ins.Timeout = config.Duration(3 * time.Second)
+	}
+
+	for _, server := range ins.Servers {
+		opt := &redis.Options{
+			Addr:         server,
+			Username:     ins.Username,
+			Password:     ins.Password,
+			PoolSize:     1,
+			DialTimeout:  time.Duration(ins.Timeout),
+			ReadTimeout:  time.Duration(ins.Timeout),
+			WriteTimeout: time.Duration(ins.Timeout),
+			MaxRetries:   -1,
+		}
+		if ins.UseTLS {
+			tlsConfig, err := ins.TLSConfig()
+			if err != nil {
+				return fmt.Errorf("failed to init tls config: %v", err)
+			}
+			opt.TLSConfig = tlsConfig
+		}
+		ins.options = append(ins.options, opt)
+	}
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	tags := map[string]string{"cluster": ins.ClusterName, "mode": ins.Mode}
+	var (
+		server string
+		err    error
+	)
+	if ins.Mode == modeSentinel {
+		server, err = ins.gatherSentinel(slist, tags)
+	} else {
+		server, err = ins.gatherCluster(slist, tags)
+	}
+	if err != nil {
+		log.Println("E! failed to check the topology of redis", ins.Mode, ins.ClusterName, "error:", err)
+		inputs.PushGatherError(slist, ins.ClusterName, err)
+		slist.PushFront(types.NewSample(inputName, "up", 0, tags))
+		slist.PushFront(types.NewSample(inputName, "ok", 0, tags))
+		return
+	}
+	if ins.DebugMod {
+		log.Println("D! redis", ins.Mode, ins.ClusterName, "topology from", server)
+	}
+	slist.PushFront(types.NewSample(inputName, "up", 1, tags))
+}
+
+// firstAnswer calls query with the servers in turn until one succeeds
+func (ins *Instance) firstAnswer(query func(ctx context.Context, opt *redis.Options) error) (string, error) {
+	var err error
+	for _, opt := range ins.options {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ins.Timeout)*2)
+		err = query(ctx, opt)
+		cancel()
+		if err == nil {
+			return opt.Addr, nil
+		}
+		if ins.DebugMod {
+			log.Println("D! redis", ins.Mode, "server", opt.Addr, "failed:", err)
+		}
+	}
+	return "", err
+}
+
+func (r *RedisCluster) DescribeMetrics() []types.MetricDesc {
+	tags := []string{"cluster", "mode"}
+	master := []string{"cluster", "mode", "master"}
+	return []types.MetricDesc{
+		{Name: "redis_cluster_up", Type: types.Gauge, Help: "whether a server answered", Tags: tags},
+		{Name: "redis_cluster_ok", Type: types.Gauge, Help: "whether the topology is healthy, per cluster and per master of sentinels", Tags: tags},
+		{Name: "redis_cluster_state_ok", Type: types.Gauge, Help: "cluster_state of CLUSTER INFO is ok", Tags: tags},
+		{Name: "redis_cluster_slots_assigned", Type: types.Gauge, Help: "slots assigned to nodes", Tags: tags},
+		{Name: "redis_cluster_slots_ok", Type: types.Gauge, Help: "slots of nodes not in fail or pfail", Tags: tags},
+		{Name: "redis_cluster_slots_pfail", Type: types.Gauge, Help: "slots of nodes in pfail", Tags: tags},
+		{Name: "redis_cluster_slots_fail", Type: types.Gauge, Help: "slots of nodes in fail", Tags: tags},
+		{Name: "redis_cluster_slots_covered", Type: types.Gauge, Help: "slots served by masters which are connected and not failing", Tags: tags},
+		{Name: "redis_cluster_slots_coverage_ratio", Type: types.Gauge, Unit: "ratio", Help: "slots_covered of 16384", Tags: tags},
+		{Name: "redis_cluster_migrating_slots", Type: types.Gauge, Help: "slots being migrated to another node", Tags: tags},
+		{Name: "redis_cluster_importing_slots", Type: types.Gauge, Help: "slots being imported from another node", Tags: tags},
+		{Name: "redis_cluster_nodes", Type: types.Gauge, Help: "nodes by role", Tags: []string{"cluster", "mode", "role"}},
+		{Name: "redis_cluster_nodes_failing", Type: types.Gauge, Help: "nodes flagged fail or pfail", Tags: tags},
+		{Name: "redis_cluster_nodes_disconnected", Type: types.Gauge, Help: "nodes whose link is disconnected", Tags: tags},
+		{Name: "redis_cluster_masters_degraded", Type: types.Gauge, Help: "masters with slots and fewer healthy replicas than min_replicas", Tags: tags},
+		{Name: "redis_cluster_current_epoch", Type: types.Gauge, Help: "cluster_current_epoch of CLUSTER INFO", Tags: tags},
+		{Name: "redis_cluster_master_slots", Type: types.Gauge, Help: "slots served by a master", Tags: []string{"cluster", "mode", "node"}},
+		{Name: "redis_cluster_master_replicas", Type: types.Gauge, Help: "healthy replicas of a master", Tags: []string{"cluster", "mode", "node"}},
+		{Name: "redis_cluster_master_down", Type: types.Gauge, Help: "the master is subjectively or objectively down, sentinel only", Tags: master},
+		{Name: "redis_cluster_failover_in_progress", Type: types.Gauge, Help: "a failover of the master is in progress, sentinel only", Tags: master},
+		{Name: "redis_cluster_quorum", Type: types.Gauge, Help: "sentinels needed to agree the master is down, sentinel only", Tags: master},
+		{Name: "redis_cluster_quorum_ok", Type: types.Gauge, Help: "SENTINEL CKQUORUM succeeded, sentinel only", Tags: master},
+		{Name: "redis_cluster_sentinels", Type: types.Gauge, Help: "sentinels monitoring the master, sentinel only", Tags: master},
+		{Name: "redis_cluster_replicas", Type: types.Gauge, Help: "replicas of the master, sentinel only", Tags: master},
+		{Name: "redis_cluster_replicas_healthy", Type: types.Gauge, Help: "replicas which are up and linked to the master, sentinel only", Tags: master},
+	}
+}
@@ -0,0 +1,116 @@
+package redis_cluster
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+const nodesText = `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master - 0 1426238318243 3 connected 10923-16383
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@31005 slave,fail 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 disconnected
+824fe116063bc5fcf9f4ffd895bc17aee7731ac3 127.0.0.1:30006@31006 slave 292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 0 1426238317741 6 connected
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460 [5460->-292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f]
+`
+
+func TestParseClusterNodes(t *testing.T) {
+	nodes, err := parseClusterNodes(nodesText)
+	require.NoError(t, err)
+	require.Len(t, nodes, 6)
+
+	myself := nodes[5]
+	require.Equal(t, "127.0.0.1:30001", myself.addr)
+	require.True(t, myself.master())
+	require.Equal(t, 5461, myself.slots)
+	require.Equal(t, 1, myself.migrating)
+
+	require.True(t, nodes[3].failing())
+	require.False(t, nodes[3].healthy())
+
+	_, err = parseClusterNodes("abc 127.0.0.1:30001 master")
+	require.Error(t, err)
+}
+
+func TestEvaluateCluster(t *testing.T) {
+	nodes, err := parseClusterNodes(nodesText)
+	require.NoError(t, err)
+	info := parseClusterInfo("cluster_state:ok\r\ncluster_slots_assigned:16384\r\n")
+
+	h := evaluateCluster(info, nodes, 0)
+	require.Equal(t, clusterSlots, h.slotsCovered)
+	require.Equal(t, 3, h.masters)
+	require.Equal(t, 3, h.replicas)
+	require.Equal(t, 1, h.failing)
+	require.Equal(t, 1, h.disconnected)
+	require.Equal(t, 0, h.degraded)
+	// a failing replica is a failing node
+	require.False(t, h.ok())
+
+	h = evaluateCluster(info, nodes, 1)
+	require.Equal(t, 1, h.degraded)
+	require.Equal(t, 0, h.masterReplicas["67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1"])
+
+	// a master in fail leaves its slots uncovered
+	nodes[1].flags["fail"] = true
+	h = evaluateCluster(info, nodes, 0)
+	require.Equal(t, clusterSlots-5462, h.slotsCovered)
+}
+
+func TestSentinelMaster(t *testing.T) {
+	m := parseSentinelMaster(map[string]string{
+		"name":                "mymaster",
+		"flags":               "master",
+		"quorum":              "2",
+		"num-other-sentinels": "2",
+	})
+	require.Equal(t, int64(3), m.sentinels)
+	require.False(t, m.down())
+
+	m.quorumOK = true
+	require.True(t, m.ok(0))
+	require.False(t, m.ok(1))
+
+	m.replicasHealthy = 1
+	require.True(t, m.ok(1))
+
+	healthy := &sentinelMaster{name: "other", flags: parseFlags("master"), quorumOK: true, replicasHealthy: 1}
+	require.True(t, sentinelOK([]*sentinelMaster{m, healthy}, 1))
+	require.False(t, sentinelOK(nil, 1))
+
+	m.flags = parseFlags("master,s_down,failover_in_progress")
+	require.True(t, m.down())
+	require.True(t, m.failoverInProgress())
+	require.False(t, m.ok(1))
+	require.False(t, sentinelOK([]*sentinelMaster{m, healthy}, 1))
+
+	require.True(t, healthyReplica(map[string]string{"flags": "slave", "master-link-status": "ok"}))
+	require.False(t, healthyReplica(map[string]string{"flags": "slave", "master-link-status": "err"}))
+	require.False(t, healthyReplica(map[string]string{"flags": "slave,s_down", "master-link-status": "ok"}))
+}
+
+func TestGatherFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	for _, mode := range []string{modeCluster, modeSentinel} {
+		ins := &Instance{Mode: mode, Servers: []string{addr}, Timeout: config.Duration(100 * time.Millisecond)}
+		require.NoError(t, ins.Init())
+		slist := types.NewSampleList()
+		ins.Gather(slist)
+
+		values := map[string]interface{}{}
+		for _, s := range slist.PopBackAll() {
+			values[s.Metric] = s.Value
+		}
+		require.Equal(t, 0, values["redis_cluster_up"], mode)
+		require.Equal(t, 0, values["redis_cluster_ok"], mode)
+	}
+}
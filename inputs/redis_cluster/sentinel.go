@@ -0,0 +1,153 @@
+package redis_cluster
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"flashcat.cloud/categraf/types"
+)
+
+type sentinelMaster struct {
+	name            string
+	flags           map[string]bool
+	quorum          int64
+	sentinels       int64
+	quorumOK        bool
+	replicas        int
+	replicasHealthy int
+}
+
+func (m *sentinelMaster) down() bool {
+	return m.flags["s_down"] || m.flags["o_down"]
+}
+
+func (m *sentinelMaster) failoverInProgress() bool {
+	return m.flags["failover_in_progress"]
+}
+
+func (m *sentinelMaster) ok(minReplicas int) bool {
+	return !m.down() && !m.failoverInProgress() && m.quorumOK && m.replicasHealthy >= minReplicas
+}
+
+func (m *sentinelMaster) push(slist *types.SampleList, tags map[string]string, minReplicas int) {
+	slist.PushSamples(inputName, map[string]interface{}{
+		"master_down":          m.down(),
+		"failover_in_progress": m.failoverInProgress(),
+		"quorum":               m.quorum,
+		"quorum_ok":            m.quorumOK,
+		"sentinels":            m.sentinels,
+		"replicas":             m.replicas,
+		"replicas_healthy":     m.replicasHealthy,
+		"ok":                   m.ok(minReplicas),
+	}, tags, map[string]string{"master": m.name})
+}
+
+// parseSentinelMaster reads a master of SENTINEL MASTERS
+func parseSentinelMaster(fields map[string]string) *sentinelMaster {
+	m := &sentinelMaster{
+		name:  fields["name"],
+		flags: parseFlags(fields["flags"]),
+	}
+	m.quorum, _ = strconv.ParseInt(fields["quorum"], 10, 64)
+	others, _ := strconv.ParseInt(fields["num-other-sentinels"], 10, 64)
+	// the answering sentinel is not one of the others
+	m.sentinels = others + 1
+	return m
+}
+
+// healthyReplica tells if a replica of SENTINEL SLAVES is up and linked to
+// its master
+func healthyReplica(fields map[string]string) bool {
+	flags := parseFlags(fields["flags"])
+	if flags["s_down"] || flags["o_down"] || flags["disconnected"] {
+		return false
+	}
+	return fields["master-link-status"] == "ok"
+}
+
+func parseFlags(s string) map[string]bool {
+	flags := make(map[string]bool)
+	for _, flag := range strings.Split(s, ",") {
+		flags[flag] = true
+	}
+	return flags
+}
+
+func toMap(vals []interface{}) map[string]string {
+	m := make(map[string]string)
+	for idx := 0; idx < len(vals)-1; idx += 2 {
+		key, keyOk := vals[idx].(string)
+		value, valueOk := vals[idx+1].(string)
+		if keyOk && valueOk {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+func (ins *Instance) gatherSentinel(slist *types.SampleList, tags map[string]string) (string, error) {
+	var masters []*sentinelMaster
+	server, err := ins.firstAnswer(func(ctx context.Context, opt *redis.Options) error {
+		cli := redis.NewSentinelClient(opt)
+		defer cli.Close()
+
+		reply, err := cli.Masters(ctx).Result()
+		if err != nil {
+			return err
+		}
+		masters = masters[:0]
+		for _, item := range reply {
+			vals, ok := item.([]interface{})
+			if !ok {
+				continue
+			}
+			m := parseSentinelMaster(toMap(vals))
+			if m.name == "" {
+				continue
+			}
+
+			// CKQUORUM replies an error if the quorum or the majority to
+			// authorize a failover can't be reached
+			m.quorumOK = cli.CkQuorum(ctx, m.name).Err() == nil
+
+			replicas, err := cli.Slaves(ctx, m.name).Result()
+			if err != nil {
+				return err
+			}
+			for _, r := range replicas {
+				rvals, ok := r.([]interface{})
+				if !ok {
+					continue
+				}
+				m.replicas++
+				if healthyReplica(toMap(rvals)) {
+					m.replicasHealthy++
+				}
+			}
+			masters = append(masters, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range masters {
+		m.push(slist, tags, ins.MinReplicas)
+	}
+	slist.PushFront(types.NewSample(inputName, "ok", sentinelOK(masters, ins.MinReplicas), tags))
+	return server, nil
+}
+
+// sentinelOK tells if the sentinels monitor masters and every one of them is ok
+func sentinelOK(masters []*sentinelMaster, minReplicas int) bool {
+	for _, m := range masters {
+		if !m.ok(minReplicas) {
+			return false
+		}
+	}
+	return len(masters) > 0
+}
@@ -0,0 +1,142 @@
+package inputs
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/httpx"
+	"flashcat.cloud/categraf/pkg/tls"
+	"flashcat.cloud/categraf/types"
+)
+
+const defaultInstanceTimeout = 5 * time.Second
+
+// PluginBase is the plugin side boilerplate of an input with instances. A plugin
+// embeds it with its own instance type and only implements Clone and Name:
+//
+//	type Demo struct {
+//		inputs.PluginBase[*Instance]
+//	}
+//
+// Embedding keeps the config layout unchanged, `interval` and the processors
+// on the plugin level plus `[[instances]]`.
+type PluginBase[T Instance] struct {
+	config.PluginConfig
+	Instances []T `toml:"instances"`
+}
+
+func (p *PluginBase[T]) GetInstances() []Instance {
+	ret := make([]Instance, len(p.Instances))
+	for i := 0; i < len(p.Instances); i++ {
+		ret[i] = p.Instances[i]
+	}
+	return ret
+}
+
+// Init skips the plugin quietly when no instance is configured
+func (p *PluginBase[T]) Init() error {
+	if len(p.Instances) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	return nil
+}
+
+// Drop drops the instances which hold resources, e.g. listeners or consumers,
+// when the plugin is stopped by a reload or the shutdown
+func (p *PluginBase[T]) Drop() {
+	for _, ins := range p.Instances {
+		MayDrop(ins)
+	}
+}
+
+// InstanceBase holds the options most network instances share: labels,
+// interval_times and processors (config.InstanceConfig), timeout, basic auth,
+// extra headers, proxy and tls. Embed it instead of config.InstanceConfig and
+// call InitBase at the beginning of Init.
+type InstanceBase struct {
+	config.InstanceConfig
+
+	Timeout  config.Duration `toml:"timeout"`
+	Username string          `toml:"username"`
+	Password string          `toml:"password"`
+	// key value pairs, e.g. ["Host", "example.com", "X-Token", "abc"]
+	Headers []string `toml:"headers"`
+
+	config.HTTPProxy
+	tls.ClientConfig
+}
+
+// InitBase validates the common options, timeout falls back to defaultTimeout
+// (5s if it's zero too)
+func (b *InstanceBase) InitBase(defaultTimeout time.Duration) error {
+	if b.Timeout <= 0 {
+		if defaultTimeout <= 0 {
+			defaultTimeout = defaultInstanceTimeout
+		}
+		b.Timeout = config.Duration(defaultTimeout)
+	}
+	if len(b.Headers)%2 != 0 {
+		return fmt.Errorf("headers should be key value pairs, got %d items", len(b.Headers))
+	}
+	return nil
+}
+
+func (b *InstanceBase) GetTimeout() time.Duration {
+	return time.Duration(b.Timeout)
+}
+
+// HTTPClient creates a client honoring timeout, proxy and tls options, with
+// the pool, retries and trace header of [global.http_client]
+func (b *InstanceBase) HTTPClient(opts ...httpx.Option) (*http.Client, error) {
+	proxy, err := b.Proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]httpx.Option{httpx.Proxy(proxy), httpx.Timeout(b.GetTimeout())}, opts...)
+	if b.UseTLS {
+		tlsCfg, err := b.ClientConfig.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, httpx.TlsConfig(tlsCfg))
+	}
+	return httpx.CreateHTTPClient(opts...), nil
+}
+
+// SetRequestAuth sets basic auth and the extra headers on req
+func (b *InstanceBase) SetRequestAuth(req *http.Request) {
+	if b.Username != "" || b.Password != "" {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
+	for i := 0; i+1 < len(b.Headers); i += 2 {
+		req.Header.Set(b.Headers[i], b.Headers[i+1])
+		if b.Headers[i] == "Host" {
+			req.Host = b.Headers[i+1]
+		}
+	}
+}
+
+// GatherTargets calls gather for every target concurrently and waits for all of
+// them, a panic in one target is logged instead of crashing the agent.
+func GatherTargets(slist *types.SampleList, targets []string, gather func(slist *types.SampleList, target string)) {
+	wg := new(sync.WaitGroup)
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Println("E! panic when gathering target:", target, "error:", r)
+					PushError(slist, target, ReasonPanic)
+				}
+			}()
+			gather(slist, target)
+		}(target)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package inputs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/types"
+)
+
+type demoInstance struct {
+	InstanceBase
+	Targets []string `toml:"targets"`
+}
+
+type demoPlugin struct {
+	PluginBase[*demoInstance]
+}
+
+func TestPluginBaseDecode(t *testing.T) {
+	p := &demoPlugin{}
+	require.ErrorIs(t, p.Init(), types.ErrInstancesEmpty)
+
+	err := cfg.LoadConfigs([]cfg.ConfigWithFormat{{Format: cfg.TomlFormat, Config: `
+interval = 30
+
+[[instances]]
+targets = ["127.0.0.1:80"]
+timeout = "3s"
+username = "admin"
+headers = ["Host", "example.com"]
+interval_times = 2
+labels = { env = "test" }
+`}}, p)
+	require.NoError(t, err)
+	require.NoError(t, p.Init())
+	require.Equal(t, config.Duration(30*time.Second), p.GetInterval())
+
+	instances := p.GetInstances()
+	require.Len(t, instances, 1)
+	require.Equal(t, int64(2), instances[0].GetIntervalTimes())
+	require.Equal(t, "test", instances[0].GetLabels()["env"])
+
+	ins := p.Instances[0]
+	require.NoError(t, ins.InitBase(0))
+	require.Equal(t, 3*time.Second, ins.GetTimeout())
+	require.Equal(t, "admin", ins.Username)
+	require.Equal(t, []string{"127.0.0.1:80"}, ins.Targets)
+
+	ins.Headers = []string{"Host"}
+	require.Error(t, ins.InitBase(0))
+}
+
+func TestEnableFlag(t *testing.T) {
+	p := &demoPlugin{}
+	err := cfg.LoadConfigs([]cfg.ConfigWithFormat{{Format: cfg.TomlFormat, Config: `
+[[instances]]
+targets = ["127.0.0.1:80"]
+
+[[instances]]
+enable = false
+targets = ["127.0.0.1:81"]
+
+[[instances]]
+enable = true
+targets = ["127.0.0.1:82"]
+`}}, p)
+	require.NoError(t, err)
+	require.False(t, MayDisabled(p))
+
+	instances := p.GetInstances()
+	require.Len(t, instances, 3)
+	require.False(t, MayDisabled(instances[0]))
+	require.True(t, MayDisabled(instances[1]))
+	require.False(t, MayDisabled(instances[2]))
+
+	p = &demoPlugin{}
+	require.NoError(t, cfg.LoadConfigs([]cfg.ConfigWithFormat{{Format: cfg.TomlFormat, Config: "enable = false\n"}}, p))
+	require.True(t, MayDisabled(p))
+}
+
+type dropInstance struct {
+	config.InstanceConfig
+	dropped bool
+}
+
+func (ins *dropInstance) Drop() { ins.dropped = true }
+
+func TestPluginBaseDrop(t *testing.T) {
+	p := &PluginBase[*dropInstance]{Instances: []*dropInstance{{}, {}}}
+	MayDrop(p)
+	for _, ins := range p.Instances {
+		require.True(t, ins.dropped)
+	}
+}
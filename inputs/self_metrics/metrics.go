@@ -2,6 +2,7 @@ package categraf
 
 import (
 	"log"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -54,6 +55,13 @@ func (ins *Categraf) Gather(slist *types.SampleList) {
 	slist.PushSample(defaultPrefix, "metrics_enqueue_failed_count", ss.FailCount, vTag)
 	slist.PushSample(defaultPrefix, "current_queue_size", ss.QueueSize, vTag)
 
+	if m, on := writer.MaintenanceStatus(); on {
+		slist.PushSample(defaultPrefix, "maintenance", 1, vTag)
+		slist.PushSample(defaultPrefix, "maintenance_remaining_seconds", time.Until(m.Until).Seconds(), vTag)
+	} else {
+		slist.PushSample(defaultPrefix, "maintenance", 0, vTag)
+	}
+
 	for _, mf := range mfs {
 		metricName := mf.GetName()
 		for _, m := range mf.Metric {
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package sidecar
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// terminate asks the process group of cmd to exit, children of the exporter included
+func terminate(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func kill(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package sidecar
+
+import (
+	"os/exec"
+)
+
+// terminate kills the process right away, windows has no SIGTERM
+func terminate(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,337 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/parser/prometheus"
+	"flashcat.cloud/categraf/pkg/cmdx"
+	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName    = "sidecar"
+	acceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3,*/*;q=0.1`
+	// bytes of output kept to log when the exporter exits
+	outputTail = 4096
+)
+
+type Sidecar struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Sidecar{}
+	})
+}
+
+func (s *Sidecar) Clone() inputs.Input {
+	return &Sidecar{}
+}
+
+func (s *Sidecar) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Sidecar)
+var _ inputs.InstancesGetter = new(Sidecar)
+
+type Instance struct {
+	inputs.InstanceBase
+
+	// value of the sidecar label, defaults to the base name of command
+	SidecarName string   `toml:"name"`
+	Command     string   `toml:"command"`
+	Args        []string `toml:"args"`
+	// KEY=VALUE pairs added to the environment of categraf
+	Env     []string `toml:"env"`
+	WorkDir string   `toml:"work_dir"`
+
+	// metrics endpoint of the exporter, e.g. http://127.0.0.1:9100/metrics
+	URL             string   `toml:"url"`
+	NamePrefix      string   `toml:"name_prefix"`
+	IgnoreMetrics   []string `toml:"ignore_metrics"`
+	IgnoreLabelKeys []string `toml:"ignore_label_keys"`
+
+	// the delay before a restart doubles with every crash up to
+	// max_restart_delay, and is reset once the exporter ran that long
+	RestartDelay    config.Duration `toml:"restart_delay"`
+	MaxRestartDelay config.Duration `toml:"max_restart_delay"`
+	// the exporter is killed if it doesn't exit that long after SIGTERM
+	StopTimeout config.Duration `toml:"stop_timeout"`
+
+	ignoreMetricsFilter   filter.Filter
+	ignoreLabelKeysFilter filter.Filter
+	client                *http.Client
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	running  bool
+	started  time.Time
+	restarts uint64
+}
+
+func (ins *Instance) Init() error {
+	if ins.Command == "" {
+		return types.ErrInstancesEmpty
+	}
+	if err := ins.InitBase(5 * time.Second); err != nil {
+		return err
+	}
+	if ins.URL == "" {
+		return fmt.Errorf("url of sidecar %s is empty", ins.Command)
+	}
+	if ins.SidecarName == "" {
+		ins.SidecarName = strings.TrimSuffix(baseName(ins.Command), ".exe")
+	}
+	for _, kv := range ins.Env {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("env of sidecar %s should be KEY=VALUE, got %q", ins.SidecarName, kv)
+		}
+	}
+	if ins.RestartDelay <= 0 {
+		ins.RestartDelay = config.Duration(time.Second)
+	}
+	if ins.MaxRestartDelay < ins.RestartDelay {
+		ins.MaxRestartDelay = config.Duration(time.Minute)
+		if ins.MaxRestartDelay < ins.RestartDelay {
+			ins.MaxRestartDelay = ins.RestartDelay
+		}
+	}
+	if ins.StopTimeout <= 0 {
+		ins.StopTimeout = config.Duration(10 * time.Second)
+	}
+
+	var err error
+	if len(ins.IgnoreMetrics) > 0 {
+		if ins.ignoreMetricsFilter, err = filter.Compile(ins.IgnoreMetrics); err != nil {
+			return err
+		}
+	}
+	if len(ins.IgnoreLabelKeys) > 0 {
+		if ins.ignoreLabelKeysFilter, err = filter.Compile(ins.IgnoreLabelKeys); err != nil {
+			return err
+		}
+	}
+	if ins.client, err = ins.HTTPClient(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ins.cancel = cancel
+	ins.done = make(chan struct{})
+	go ins.supervise(ctx)
+	return nil
+}
+
+// supervise runs the exporter until ctx is done, restarting it with backoff
+// whenever it exits
+func (ins *Instance) supervise(ctx context.Context) {
+	defer close(ins.done)
+	delay := time.Duration(ins.RestartDelay)
+	for {
+		start := time.Now()
+		err := ins.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(start) >= time.Duration(ins.MaxRestartDelay) {
+			delay = time.Duration(ins.RestartDelay)
+		}
+		log.Printf("E! sidecar %s exited: %v, restarting in %s", ins.SidecarName, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		ins.mu.Lock()
+		ins.restarts++
+		ins.mu.Unlock()
+
+		delay *= 2
+		if delay > time.Duration(ins.MaxRestartDelay) {
+			delay = time.Duration(ins.MaxRestartDelay)
+		}
+	}
+}
+
+// run starts the exporter and waits for it to exit, or stops it when ctx is done
+func (ins *Instance) run(ctx context.Context) error {
+	cmd := exec.Command(ins.Command, ins.Args...)
+	cmd.Dir = ins.WorkDir
+	cmd.Env = append(os.Environ(), ins.Env...)
+	output := &tailBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmdx.CmdStart(cmd); err != nil {
+		return err
+	}
+	if ins.DebugMod {
+		log.Println("D! sidecar", ins.SidecarName, "started, pid:", cmd.Process.Pid)
+	}
+	ins.setRunning(true)
+	defer ins.setRunning(false)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if tail := output.String(); tail != "" {
+			log.Printf("W! sidecar %s output before exit: %s", ins.SidecarName, tail)
+		}
+		if err == nil {
+			err = fmt.Errorf("exit status 0")
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	if err := terminate(cmd); err != nil {
+		log.Println("W! failed to terminate sidecar", ins.SidecarName, "error:", err)
+	}
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(time.Duration(ins.StopTimeout)):
+		log.Println("W! sidecar", ins.SidecarName, "still running after", time.Duration(ins.StopTimeout), "killing it")
+		kill(cmd)
+		return <-exited
+	}
+}
+
+func (ins *Instance) setRunning(running bool) {
+	ins.mu.Lock()
+	defer ins.mu.Unlock()
+	ins.running = running
+	if running {
+		ins.started = time.Now()
+	}
+}
+
+// Drop stops the exporter and waits for it to exit
+func (ins *Instance) Drop() {
+	if ins.cancel == nil {
+		return
+	}
+	ins.cancel()
+	<-ins.done
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	tags := map[string]string{"sidecar": ins.SidecarName}
+
+	ins.mu.Lock()
+	running, started, restarts := ins.running, ins.started, ins.restarts
+	ins.mu.Unlock()
+
+	slist.PushFront(types.NewSample(inputName, "restarts_total", restarts, tags).SetType(types.Counter))
+	if !running {
+		slist.PushSample(inputName, "running", 0, tags)
+		slist.PushSample(inputName, "scrape_up", 0, tags)
+		return
+	}
+	slist.PushSample(inputName, "running", 1, tags)
+	slist.PushSample(inputName, "uptime_seconds", time.Since(started).Seconds(), tags)
+
+	start := time.Now()
+	err := ins.scrape(slist, tags)
+	slist.PushSample(inputName, "scrape_duration_seconds", time.Since(start).Seconds(), tags)
+	if err != nil {
+		log.Println("E! failed to scrape sidecar", ins.SidecarName, "url:", ins.URL, "error:", err)
+		inputs.PushGatherError(slist, ins.URL, err)
+		slist.PushSample(inputName, "scrape_up", 0, tags)
+		return
+	}
+	slist.PushSample(inputName, "scrape_up", 1, tags)
+}
+
+// scrape gathers the metrics of the exporter, labeled with the sidecar name
+func (ins *Instance) scrape(slist *types.SampleList, tags map[string]string) error {
+	req, err := http.NewRequest(http.MethodGet, ins.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	ins.SetRequestAuth(req)
+
+	resp, err := ins.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	labels := make(map[string]string, len(tags))
+	for k, v := range tags {
+		labels[k] = v
+	}
+	parser := prometheus.NewParser(ins.NamePrefix, labels, resp.Header, false, ins.ignoreMetricsFilter, ins.ignoreLabelKeysFilter)
+	return parser.Parse(body, slist)
+}
+
+// tailBuffer keeps the last outputTail bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > outputTail {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-outputTail:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
+
+func baseName(command string) string {
+	if i := strings.LastIndexAny(command, `/\`); i >= 0 {
+		return command[i+1:]
+	}
+	return command
+}
+
+// DescribeMetrics lists the metrics of sidecar, the metrics of the exporters come on top
+func (s *Sidecar) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "sidecar_running", Type: types.Gauge, Help: "whether the exporter process is running", Tags: []string{"sidecar"}},
+		{Name: "sidecar_restarts_total", Type: types.Counter, Help: "restarts of the exporter since categraf started it", Tags: []string{"sidecar"}},
+		{Name: "sidecar_uptime_seconds", Type: types.Gauge, Unit: "seconds", Help: "time since the exporter was last started", Tags: []string{"sidecar"}},
+		{Name: "sidecar_scrape_up", Type: types.Gauge, Help: "whether the metrics of the exporter were scraped", Tags: []string{"sidecar"}},
+		{Name: "sidecar_scrape_duration_seconds", Type: types.Gauge, Unit: "seconds", Help: "duration of the scrape", Tags: []string{"sidecar"}},
+	}
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+// +build !windows
+
+package sidecar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestScrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE demo_requests_total counter")
+		fmt.Fprintln(w, `demo_requests_total{code="200"} 42`)
+	}))
+	defer srv.Close()
+
+	ins := &Instance{Command: "sleep", Args: []string{"30"}, URL: srv.URL}
+	require.NoError(t, ins.Init())
+	defer ins.Drop()
+	require.Equal(t, "sleep", ins.SidecarName)
+
+	require.Eventually(t, func() bool {
+		ins.mu.Lock()
+		defer ins.mu.Unlock()
+		return ins.running
+	}, 5*time.Second, 10*time.Millisecond)
+
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+	values := map[string]interface{}{}
+	for _, s := range slist.PopBackAll() {
+		require.Equal(t, "sleep", s.Labels["sidecar"])
+		values[s.Metric] = s.Value
+	}
+	require.Equal(t, 1, values["sidecar_scrape_up"])
+	require.Equal(t, 1, values["sidecar_running"])
+	require.EqualValues(t, 42, values["demo_requests_total"])
+}
+
+func TestRestartWithBackoff(t *testing.T) {
+	ins := &Instance{
+		SidecarName:     "crashing",
+		Command:         "sh",
+		Args:            []string{"-c", "echo boom; exit 1"},
+		URL:             "http://127.0.0.1:1/metrics",
+		RestartDelay:    config.Duration(5 * time.Millisecond),
+		MaxRestartDelay: config.Duration(20 * time.Millisecond),
+	}
+	require.NoError(t, ins.Init())
+
+	require.Eventually(t, func() bool {
+		ins.mu.Lock()
+		defer ins.mu.Unlock()
+		return ins.restarts >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ins.Drop()
+	ins.mu.Lock()
+	restarts := ins.restarts
+	ins.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	ins.mu.Lock()
+	defer ins.mu.Unlock()
+	require.Equal(t, restarts, ins.restarts, "no restart after drop")
+	require.False(t, ins.running)
+}
@@ -0,0 +1,228 @@
+package snmp_interface
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	ifTableOid  = ".1.3.6.1.2.1.2.2.1"
+	ifXTableOid = ".1.3.6.1.2.1.31.1.1.1"
+
+	statusDown = 2
+)
+
+// columns of ifTable
+const (
+	ifDescr       = "2"
+	ifSpeed       = "5"
+	ifAdminStatus = "7"
+	ifOperStatus  = "8"
+	ifInOctets    = "10"
+	ifInUcastPkts = "11"
+	ifInDiscards  = "13"
+	ifInErrors    = "14"
+	ifOutOctets   = "16"
+	ifOutUcast    = "17"
+	ifOutDiscards = "19"
+	ifOutErrors   = "20"
+)
+
+// columns of ifXTable
+const (
+	ifName          = "1"
+	ifHCInOctets    = "6"
+	ifHCInUcastPkts = "7"
+	ifHCOutOctets   = "10"
+	ifHCOutUcast    = "11"
+	ifHighSpeed     = "15"
+	ifAlias         = "18"
+)
+
+// counter is a Counter32 of ifTable or a Counter64 of ifXTable
+type counter struct {
+	value uint64
+	bits  int
+	valid bool
+}
+
+// set keeps a 64 bit counter over a 32 bit one
+func (c *counter) set(value uint64, bits int) {
+	if c.valid && c.bits > bits {
+		return
+	}
+	*c = counter{value: value, bits: bits, valid: true}
+}
+
+type ifEntry struct {
+	index string
+	at    time.Time
+
+	descr  string
+	ifname string
+	alias  string
+
+	adminStatus uint64
+	operStatus  uint64
+	// bits per second
+	speed     float64
+	ifSpeed   uint64
+	highSpeed uint64
+
+	inOctets    counter
+	outOctets   counter
+	inPackets   counter
+	outPackets  counter
+	inErrors    counter
+	outErrors   counter
+	inDiscards  counter
+	outDiscards counter
+}
+
+// name is ifName, or ifDescr for devices without ifXTable
+func (e *ifEntry) name() string {
+	if e.ifname != "" {
+		return e.ifname
+	}
+	return e.descr
+}
+
+type ifTable map[string]*ifEntry
+
+func newIfTable() ifTable {
+	return make(ifTable)
+}
+
+// add is the gosnmp.WalkFunc collecting the columns of both tables
+func (t ifTable) add(pdu gosnmp.SnmpPDU) error {
+	var table, rest string
+	switch {
+	case strings.HasPrefix(pdu.Name, ifTableOid+"."):
+		table, rest = ifTableOid, strings.TrimPrefix(pdu.Name, ifTableOid+".")
+	case strings.HasPrefix(pdu.Name, ifXTableOid+"."):
+		table, rest = ifXTableOid, strings.TrimPrefix(pdu.Name, ifXTableOid+".")
+	default:
+		return nil
+	}
+	column, index, found := strings.Cut(rest, ".")
+	if !found {
+		return nil
+	}
+
+	e, has := t[index]
+	if !has {
+		e = &ifEntry{index: index}
+		t[index] = e
+	}
+
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return nil
+	}
+	number := gosnmp.ToBigInt(pdu.Value).Uint64()
+
+	if table == ifTableOid {
+		switch column {
+		case ifDescr:
+			e.descr = pduString(pdu)
+		case ifSpeed:
+			e.ifSpeed = number
+		case ifAdminStatus:
+			e.adminStatus = number
+		case ifOperStatus:
+			e.operStatus = number
+		case ifInOctets:
+			e.inOctets.set(number, 32)
+		case ifOutOctets:
+			e.outOctets.set(number, 32)
+		case ifInUcastPkts:
+			e.inPackets.set(number, 32)
+		case ifOutUcast:
+			e.outPackets.set(number, 32)
+		case ifInErrors:
+			e.inErrors.set(number, 32)
+		case ifOutErrors:
+			e.outErrors.set(number, 32)
+		case ifInDiscards:
+			e.inDiscards.set(number, 32)
+		case ifOutDiscards:
+			e.outDiscards.set(number, 32)
+		}
+		return nil
+	}
+
+	switch column {
+	case ifName:
+		e.ifname = pduString(pdu)
+	case ifAlias:
+		e.alias = pduString(pdu)
+	case ifHighSpeed:
+		e.highSpeed = number
+	case ifHCInOctets:
+		e.inOctets.set(number, 64)
+	case ifHCOutOctets:
+		e.outOctets.set(number, 64)
+	case ifHCInUcastPkts:
+		e.inPackets.set(number, 64)
+	case ifHCOutUcast:
+		e.outPackets.set(number, 64)
+	}
+	return nil
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	switch v := pdu.Value.(type) {
+	case []byte:
+		return strings.TrimRight(string(v), "\x00")
+	case string:
+		return v
+	}
+	return ""
+}
+
+// entries stamps the rows with at and works out their speed: ifSpeed
+// saturates at 4294967295 for 4.3Gbps and faster interfaces, where
+// ifHighSpeed in Mbps is the one to use
+func (t ifTable) entries(at time.Time) map[string]*ifEntry {
+	for _, e := range t {
+		e.at = at
+		if e.highSpeed > 0 {
+			e.speed = float64(e.highSpeed) * 1e6
+		} else {
+			e.speed = float64(e.ifSpeed)
+		}
+	}
+	return t
+}
+
+// counterDelta is the increase of a counter between two gathers. A 32 bit
+// counter lower than before wrapped, unless the wrapped increase is too much
+// for the speed (bps, 0 if unknown) or more than half its range, which means
+// the device restarted or the counter was cleared. A lower 64 bit counter
+// never wraps in practice, so it was reset. No delta is returned then, as
+// well as if the counter switched between 32 and 64 bits.
+func counterDelta(prev, cur counter, elapsed, speed float64) (float64, bool) {
+	if !prev.valid || !cur.valid || prev.bits != cur.bits {
+		return 0, false
+	}
+	if cur.value >= prev.value {
+		return float64(cur.value - prev.value), true
+	}
+	if cur.bits != 32 {
+		return 0, false
+	}
+
+	delta := cur.value + math.MaxUint32 + 1 - prev.value
+	if speed > 0 {
+		// octets, with some slack for rounding of the speed
+		if float64(delta)*8/elapsed > speed*1.1 {
+			return 0, false
+		}
+	} else if delta > math.MaxUint32/2 {
+		return 0, false
+	}
+	return float64(delta), true
+}
@@ -0,0 +1,91 @@
+package snmp_interface
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func walked(pdus ...gosnmp.SnmpPDU) ifTable {
+	t := newIfTable()
+	for _, pdu := range pdus {
+		t.add(pdu)
+	}
+	return t
+}
+
+func TestIfTable(t *testing.T) {
+	table := walked(
+		gosnmp.SnmpPDU{Name: ifTableOid + ".2.3", Type: gosnmp.OctetString, Value: []byte("GigabitEthernet0/3")},
+		gosnmp.SnmpPDU{Name: ifTableOid + ".5.3", Type: gosnmp.Gauge32, Value: uint(math.MaxUint32)},
+		gosnmp.SnmpPDU{Name: ifTableOid + ".10.3", Type: gosnmp.Counter32, Value: uint(100)},
+		gosnmp.SnmpPDU{Name: ifXTableOid + ".1.3", Type: gosnmp.OctetString, Value: []byte("Gi0/3")},
+		gosnmp.SnmpPDU{Name: ifXTableOid + ".6.3", Type: gosnmp.Counter64, Value: uint64(1) << 40},
+		gosnmp.SnmpPDU{Name: ifXTableOid + ".15.3", Type: gosnmp.Gauge32, Value: uint(10000)},
+		gosnmp.SnmpPDU{Name: ifXTableOid + ".18.3", Type: gosnmp.OctetString, Value: []byte("uplink")},
+		gosnmp.SnmpPDU{Name: ifTableOid + ".2.4", Type: gosnmp.OctetString, Value: []byte("Null0")},
+		gosnmp.SnmpPDU{Name: ifTableOid + ".5.4", Type: gosnmp.Gauge32, Value: uint(0)},
+	).entries(time.Now())
+
+	e := table["3"]
+	require.Equal(t, "Gi0/3", e.name())
+	require.Equal(t, "uplink", e.alias)
+	require.Equal(t, 10e9, e.speed)
+	// the 64 bit counter wins regardless of the walk order
+	require.Equal(t, counter{value: 1 << 40, bits: 64, valid: true}, e.inOctets)
+	require.Equal(t, "Null0", table["4"].name())
+	require.Zero(t, table["4"].speed)
+}
+
+func TestCounterDelta(t *testing.T) {
+	c32 := func(v uint64) counter { return counter{value: v, bits: 32, valid: true} }
+	c64 := func(v uint64) counter { return counter{value: v, bits: 64, valid: true} }
+
+	d, ok := counterDelta(c32(100), c32(600), 10, 0)
+	require.True(t, ok)
+	require.Equal(t, 500.0, d)
+
+	// wrapped 32 bit counter
+	d, ok = counterDelta(c32(math.MaxUint32-99), c32(400), 10, 1e9)
+	require.True(t, ok)
+	require.Equal(t, 500.0, d)
+
+	// too much for a 10Mbps interface in 10s, the device restarted
+	_, ok = counterDelta(c32(math.MaxUint32/4), c32(400), 10, 10e6)
+	require.False(t, ok)
+	// without speed more than half the range is a reset
+	_, ok = counterDelta(c32(math.MaxUint32/4), c32(400), 10, 0)
+	require.False(t, ok)
+
+	_, ok = counterDelta(c64(1000), c64(10), 10, 1e9)
+	require.False(t, ok)
+	_, ok = counterDelta(c32(10), c64(1000), 10, 1e9)
+	require.False(t, ok)
+	_, ok = counterDelta(counter{}, c64(1000), 10, 1e9)
+	require.False(t, ok)
+}
+
+func TestPushEntryUtilization(t *testing.T) {
+	ins := &Instance{}
+	now := time.Now()
+	prev := &ifEntry{index: "1", ifname: "eth0", at: now.Add(-10 * time.Second), speed: 1e9,
+		inOctets: counter{value: math.MaxUint32 - 99, bits: 32, valid: true}}
+	cur := &ifEntry{index: "1", ifname: "eth0", at: now, speed: 1e9,
+		inOctets: counter{value: 125e6 - 100, bits: 32, valid: true}}
+
+	slist := types.NewSampleList()
+	ins.pushEntry(slist, cur, prev, map[string]string{"agent_host": "10.0.0.1"})
+	ret := make(map[string]*types.Sample)
+	for _, s := range slist.PopBackAll() {
+		ret[s.Metric] = s
+	}
+	require.Equal(t, 1e8, ret["snmp_interface_in_bps"].Value)
+	require.Equal(t, 10.0, ret["snmp_interface_in_utilization_percent"].Value)
+	require.Equal(t, "eth0", ret["snmp_interface_in_bps"].Labels["ifname"])
+	require.NotContains(t, ret, "snmp_interface_out_bps")
+}
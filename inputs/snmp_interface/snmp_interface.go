@@ -0,0 +1,297 @@
+package snmp_interface
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/inputs/snmp"
+	"flashcat.cloud/categraf/pkg/filter"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "snmp_interface"
+
+type SnmpInterface struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &SnmpInterface{}
+	})
+}
+
+func (s *SnmpInterface) Clone() inputs.Input {
+	return &SnmpInterface{}
+}
+
+func (s *SnmpInterface) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(SnmpInterface)
+var _ inputs.InstancesGetter = new(SnmpInterface)
+
+type Instance struct {
+	config.InstanceConfig
+
+	// [SCHEME://]ADDR[:PORT], e.g. udp://10.0.0.1:161, the scheme defaults to udp
+	Agents []string `toml:"agents"`
+	// label carrying the host of the agent
+	AgentHostTag string `toml:"agent_host_tag"`
+
+	snmp.ClientConfig
+
+	// globs of ifName (ifDescr if the device has no ifName), empty means all
+	Interfaces       []string `toml:"interfaces"`
+	IgnoreInterfaces []string `toml:"ignore_interfaces"`
+	// skip interfaces which are administratively down
+	SkipAdminDown bool `toml:"skip_admin_down"`
+	// speed in Mbps of the interfaces by ifName glob, for devices reporting a
+	// wrong or no speed, e.g. { "Tunnel*" = 100 }
+	SpeedOverrides map[string]float64 `toml:"speed_overrides"`
+
+	interfacesFilter       filter.Filter
+	ignoreInterfacesFilter filter.Filter
+	speedFilters           []speedOverride
+
+	mu sync.Mutex
+	// agent -> ifIndex -> counters of the previous gather
+	last map[string]map[string]*ifEntry
+}
+
+type speedOverride struct {
+	filter filter.Filter
+	bps    float64
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Agents) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.AgentHostTag == "" {
+		ins.AgentHostTag = "agent_host"
+	}
+	// checks version and v3 options
+	if _, err := snmp.NewWrapper(ins.ClientConfig); err != nil {
+		return err
+	}
+
+	var err error
+	if len(ins.Interfaces) > 0 {
+		if ins.interfacesFilter, err = filter.Compile(ins.Interfaces); err != nil {
+			return err
+		}
+	}
+	if len(ins.IgnoreInterfaces) > 0 {
+		if ins.ignoreInterfacesFilter, err = filter.Compile(ins.IgnoreInterfaces); err != nil {
+			return err
+		}
+	}
+	for pattern, mbps := range ins.SpeedOverrides {
+		if mbps <= 0 {
+			return fmt.Errorf("speed_overrides %s should be positive, got %v", pattern, mbps)
+		}
+		f, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return err
+		}
+		ins.speedFilters = append(ins.speedFilters, speedOverride{filter: f, bps: mbps * 1e6})
+	}
+	ins.last = make(map[string]map[string]*ifEntry)
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	inputs.GatherTargets(slist, ins.Agents, ins.gatherAgent)
+}
+
+func (ins *Instance) gatherAgent(slist *types.SampleList, agent string) {
+	tags := map[string]string{ins.AgentHostTag: agentHost(agent)}
+
+	begun := time.Now()
+	entries, err := ins.walk(agent)
+	if err != nil {
+		log.Println("E! failed to walk interfaces of", agent, "error:", err)
+		inputs.PushGatherError(slist, agent, err)
+		slist.PushFront(types.NewSample(inputName, "up", 0, tags))
+		return
+	}
+	slist.PushFront(types.NewSample(inputName, "up", 1, tags))
+	slist.PushFront(types.NewSample(inputName, "walk_duration_seconds", time.Since(begun).Seconds(), tags))
+
+	ins.mu.Lock()
+	last := ins.last[agent]
+	ins.last[agent] = entries
+	ins.mu.Unlock()
+
+	for index, e := range entries {
+		if !ins.selected(e) {
+			continue
+		}
+		if bps, has := ins.speedOverride(e.name()); has {
+			e.speed = bps
+		}
+		ins.pushEntry(slist, e, last[index], tags)
+	}
+}
+
+func (ins *Instance) selected(e *ifEntry) bool {
+	name := e.name()
+	if ins.interfacesFilter != nil && !ins.interfacesFilter.Match(name) {
+		return false
+	}
+	if ins.ignoreInterfacesFilter != nil && ins.ignoreInterfacesFilter.Match(name) {
+		return false
+	}
+	if ins.SkipAdminDown && e.adminStatus == statusDown {
+		return false
+	}
+	return true
+}
+
+func (ins *Instance) speedOverride(name string) (float64, bool) {
+	for _, o := range ins.speedFilters {
+		if o.filter.Match(name) {
+			return o.bps, true
+		}
+	}
+	return 0, false
+}
+
+func (ins *Instance) pushEntry(slist *types.SampleList, e, prev *ifEntry, agentTags map[string]string) {
+	tags := map[string]string{
+		"ifindex": e.index,
+		"ifname":  e.name(),
+	}
+	if e.alias != "" {
+		tags["ifalias"] = e.alias
+	}
+
+	fields := map[string]interface{}{
+		"admin_status": e.adminStatus,
+		"oper_status":  e.operStatus,
+		"speed_bps":    e.speed,
+	}
+	for name, c := range map[string]counter{
+		"in_octets_total":    e.inOctets,
+		"out_octets_total":   e.outOctets,
+		"in_packets_total":   e.inPackets,
+		"out_packets_total":  e.outPackets,
+		"in_errors_total":    e.inErrors,
+		"out_errors_total":   e.outErrors,
+		"in_discards_total":  e.inDiscards,
+		"out_discards_total": e.outDiscards,
+	} {
+		if c.valid {
+			fields[name] = c.value
+		}
+	}
+	slist.PushSamples(inputName, fields, tags, agentTags)
+
+	if prev == nil {
+		return
+	}
+	elapsed := e.at.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	rates := make(map[string]interface{})
+	for name, pair := range map[string][2]counter{
+		"in":  {prev.inOctets, e.inOctets},
+		"out": {prev.outOctets, e.outOctets},
+	} {
+		delta, ok := counterDelta(pair[0], pair[1], elapsed, e.speed)
+		if !ok {
+			continue
+		}
+		bps := delta * 8 / elapsed
+		rates[name+"_bps"] = bps
+		if e.speed > 0 {
+			rates[name+"_utilization_percent"] = bps * 100 / e.speed
+		}
+	}
+	for name, pair := range map[string][2]counter{
+		"in_errors_rate":    {prev.inErrors, e.inErrors},
+		"out_errors_rate":   {prev.outErrors, e.outErrors},
+		"in_discards_rate":  {prev.inDiscards, e.inDiscards},
+		"out_discards_rate": {prev.outDiscards, e.outDiscards},
+	} {
+		if delta, ok := counterDelta(pair[0], pair[1], elapsed, 0); ok {
+			rates[name] = delta / elapsed
+		}
+	}
+	slist.PushSamples(inputName, rates, tags, agentTags)
+}
+
+// walk reads ifTable and ifXTable of agent
+func (ins *Instance) walk(agent string) (map[string]*ifEntry, error) {
+	gs, err := snmp.NewWrapper(ins.ClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := gs.SetAgent(agent); err != nil {
+		return nil, err
+	}
+	if err := gs.Connect(); err != nil {
+		return nil, fmt.Errorf("setting up connection: %w", err)
+	}
+	defer gs.Conn.Close()
+
+	t := newIfTable()
+	if err := gs.Walk(ifTableOid, t.add); err != nil {
+		return nil, err
+	}
+	// devices without ifXTable have 32 bit counters and ifSpeed only
+	if err := gs.Walk(ifXTableOid, t.add); err != nil && ins.DebugMod {
+		log.Println("D! failed to walk ifXTable of", agent, "error:", err)
+	}
+	return t.entries(time.Now()), nil
+}
+
+func agentHost(agent string) string {
+	if !strings.Contains(agent, "://") {
+		agent = "udp://" + agent
+	}
+	u, err := url.Parse(agent)
+	if err != nil {
+		return agent
+	}
+	return u.Hostname()
+}
+
+// DescribeMetrics lists the metrics of snmp_interface, all but up carry the
+// labels ifindex, ifname and ifalias
+func (s *SnmpInterface) DescribeMetrics() []types.MetricDesc {
+	tags := []string{"agent_host", "ifindex", "ifname", "ifalias"}
+	return []types.MetricDesc{
+		{Name: "snmp_interface_up", Type: types.Gauge, Help: "whether the interface tables of the agent were walked", Tags: []string{"agent_host"}},
+		{Name: "snmp_interface_walk_duration_seconds", Type: types.Gauge, Unit: "seconds", Help: "time taken to walk ifTable and ifXTable", Tags: []string{"agent_host"}},
+		{Name: "snmp_interface_admin_status", Type: types.Gauge, Help: "ifAdminStatus, 1 up, 2 down, 3 testing", Tags: tags},
+		{Name: "snmp_interface_oper_status", Type: types.Gauge, Help: "ifOperStatus, 1 up, 2 down, 3 testing, 5 dormant, 7 lowerLayerDown", Tags: tags},
+		{Name: "snmp_interface_speed_bps", Type: types.Gauge, Help: "speed from ifHighSpeed, ifSpeed if there is none, or speed_overrides", Tags: tags},
+		{Name: "snmp_interface_in_octets_total", Type: types.Counter, Unit: "bytes", Help: "ifHCInOctets, ifInOctets if there is none", Tags: tags},
+		{Name: "snmp_interface_out_octets_total", Type: types.Counter, Unit: "bytes", Help: "ifHCOutOctets, ifOutOctets if there is none", Tags: tags},
+		{Name: "snmp_interface_in_packets_total", Type: types.Counter, Help: "unicast packets received", Tags: tags},
+		{Name: "snmp_interface_out_packets_total", Type: types.Counter, Help: "unicast packets sent", Tags: tags},
+		{Name: "snmp_interface_in_errors_total", Type: types.Counter, Help: "ifInErrors", Tags: tags},
+		{Name: "snmp_interface_out_errors_total", Type: types.Counter, Help: "ifOutErrors", Tags: tags},
+		{Name: "snmp_interface_in_discards_total", Type: types.Counter, Help: "ifInDiscards", Tags: tags},
+		{Name: "snmp_interface_out_discards_total", Type: types.Counter, Help: "ifOutDiscards", Tags: tags},
+		{Name: "snmp_interface_in_bps", Type: types.Gauge, Help: "bits per second received since the previous gather", Tags: tags},
+		{Name: "snmp_interface_out_bps", Type: types.Gauge, Help: "bits per second sent since the previous gather", Tags: tags},
+		{Name: "snmp_interface_in_utilization_percent", Type: types.Gauge, Unit: "percent", Help: "in_bps of speed_bps", Tags: tags},
+		{Name: "snmp_interface_out_utilization_percent", Type: types.Gauge, Unit: "percent", Help: "out_bps of speed_bps", Tags: tags},
+		{Name: "snmp_interface_in_errors_rate", Type: types.Gauge, Help: "ifInErrors per second since the previous gather", Tags: tags},
+		{Name: "snmp_interface_out_errors_rate", Type: types.Gauge, Help: "ifOutErrors per second since the previous gather", Tags: tags},
+		{Name: "snmp_interface_in_discards_rate", Type: types.Gauge, Help: "ifInDiscards per second since the previous gather", Tags: tags},
+		{Name: "snmp_interface_out_discards_rate", Type: types.Gauge, Help: "ifOutDiscards per second since the previous gather", Tags: tags},
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/auth"
 	"flashcat.cloud/categraf/pkg/snmp"
 	"flashcat.cloud/categraf/types"
 )
@@ -45,13 +46,16 @@ type Instance struct {
 	// Values: "noAuthNoPriv", "authNoPriv", "authPriv"
 	SecLevel string        `toml:"sec_level"`
 	SecName  config.Secret `toml:"sec_name"`
-	// Values: "MD5", "SHA", "". Default: ""
+	// Values: "MD5", "SHA", "SHA224", "SHA256", "SHA384", "SHA512", "". Default: ""
 	AuthProtocol string        `toml:"auth_protocol"`
 	AuthPassword config.Secret `toml:"auth_password"`
 	// Values: "DES", "AES", "". Default: ""
 	PrivProtocol string        `toml:"priv_protocol"`
 	PrivPassword config.Secret `toml:"priv_password"`
 
+	// only accept traps from these sources
+	auth.IPFilter
+
 	listener *gosnmp.TrapListener
 	timeFunc func() time.Time
 	errCh    chan error
@@ -109,6 +113,9 @@ func (s *Instance) Init() error {
 	if len(s.ServiceAddress) == 0 {
 		return types.ErrInstancesEmpty
 	}
+	if err := s.InitIPFilter(); err != nil {
+		return err
+	}
 	s.slist = types.NewSampleList()
 	return s.start()
 }
@@ -154,14 +161,14 @@ func (s *Instance) start() error {
 			authenticationProtocol = gosnmp.MD5
 		case "sha":
 			authenticationProtocol = gosnmp.SHA
-		// case "sha224":
-		//	authenticationProtocol = gosnmp.SHA224
-		// case "sha256":
-		//	authenticationProtocol = gosnmp.SHA256
-		// case "sha384":
-		//	authenticationProtocol = gosnmp.SHA384
-		// case "sha512":
-		//	authenticationProtocol = gosnmp.SHA512
+		case "sha224":
+			authenticationProtocol = gosnmp.SHA224
+		case "sha256":
+			authenticationProtocol = gosnmp.SHA256
+		case "sha384":
+			authenticationProtocol = gosnmp.SHA384
+		case "sha512":
+			authenticationProtocol = gosnmp.SHA512
 		case "":
 			authenticationProtocol = gosnmp.NoAuth
 		default:
@@ -272,6 +279,13 @@ func setTrapOid(tags map[string]string, oid string, e snmp.MibEntry) {
 
 func makeTrapHandler(s *Instance, slist *types.SampleList) gosnmp.TrapHandlerFunc {
 	return func(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+		if !s.AllowIP(addr.IP) {
+			if s.DebugMod {
+				log.Println("D! drop trap from", addr.IP.String(), "not in allowed_ips")
+			}
+			return
+		}
+
 		fields := map[string]interface{}{}
 		tags := map[string]string{}
 
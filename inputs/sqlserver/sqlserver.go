@@ -158,6 +158,7 @@ func (s *Instance) initQueries() error {
 		queries["SQLServerAvailabilityReplicaStates"] = Query{ScriptName: "SQLServerAvailabilityReplicaStates", Script: sqlServerAvailabilityReplicaStates, ResultByRow: false}
 		queries["SQLServerDatabaseReplicaStates"] = Query{ScriptName: "SQLServerDatabaseReplicaStates", Script: sqlServerDatabaseReplicaStates, ResultByRow: false}
 		queries["SQLServerRecentBackups"] = Query{ScriptName: "SQLServerRecentBackups", Script: sqlServerRecentBackups, ResultByRow: false}
+		queries["SQLServerDatabaseSize"] = Query{ScriptName: "SQLServerDatabaseSize", Script: sqlServerDatabaseSize, ResultByRow: false}
 	} else {
 		// Decide if we want to run version 1 or version 2 queries
 		if s.QueryVersion == 2 {
@@ -1348,7 +1348,10 @@ SELECT
 	DATEDIFF(SECOND,{d '1970-01-01'}, bd.LastBackupTime) AS [last_differential_backup_time],
 	bd.backup_size AS [differential_backup_size_bytes],
 	DATEDIFF(SECOND,{d '1970-01-01'}, bt.LastBackupTime) AS [last_transaction_log_backup_time],
-	bt.backup_size AS [transaction_log_backup_size_bytes]
+	bt.backup_size AS [transaction_log_backup_size_bytes],
+	DATEDIFF(SECOND, bf.LastBackupTime, GETDATE()) AS [full_backup_age_seconds],
+	DATEDIFF(SECOND, bd.LastBackupTime, GETDATE()) AS [differential_backup_age_seconds],
+	DATEDIFF(SECOND, bt.LastBackupTime, GETDATE()) AS [transaction_log_backup_age_seconds]
 FROM sys.databases d
 LEFT JOIN BackupsWithSize bf ON (d.name = bf.[Database] AND (bf.Type = 'Full' OR bf.Type IS NULL))
 LEFT JOIN BackupsWithSize bd ON (d.name = bd.[Database] AND (bd.Type = 'Differential' OR bd.Type IS NULL))
@@ -1356,6 +1359,27 @@ LEFT JOIN BackupsWithSize bt ON (d.name = bt.[Database] AND (bt.Type = 'Transact
 WHERE d.name <> 'tempdb' AND d.source_database_id IS NULL
 `
 
+const sqlServerDatabaseSize string = `
+SET DEADLOCK_PRIORITY -10;
+IF SERVERPROPERTY('EngineEdition') NOT IN (2,3,4) BEGIN /*NOT IN Standard,Enterpris,Express*/
+	DECLARE @ErrorMessage AS nvarchar(500) = 'categraf - Connection string Server:'+ @@ServerName + ',Database:' + DB_NAME() +' is not a SQL Server Standard,Enterprise or Express. Check the database_type parameter in the categraf configuration.';
+	RAISERROR (@ErrorMessage,11,1)
+	RETURN
+END;
+SELECT
+	'sqlserver_database' AS [measurement],
+	REPLACE(@@SERVERNAME,'\',':') AS [sql_instance],
+	d.name AS [database_name],
+	d.state_desc AS [state],
+	SUM(CASE WHEN mf.type = 0 THEN CAST(mf.size AS bigint) * 8192 ELSE 0 END) AS [data_size_bytes],
+	SUM(CASE WHEN mf.type = 1 THEN CAST(mf.size AS bigint) * 8192 ELSE 0 END) AS [log_size_bytes],
+	SUM(CAST(mf.size AS bigint) * 8192) AS [size_bytes],
+	CAST(CASE WHEN d.state = 0 THEN 1 ELSE 0 END AS int) AS [online]
+FROM sys.databases AS d
+INNER JOIN sys.master_files AS mf ON mf.database_id = d.database_id
+GROUP BY d.name, d.state_desc, d.state
+`
+
 const sqlServerUp string = `
 SELECT 1
 `
@@ -0,0 +1,27 @@
+//go:build !no_logs
+
+package syslog
+
+import (
+	"log"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+)
+
+func registerLogsForwarding(ins *Instance) {
+	if !coreconfig.Config.Logs.Enable {
+		log.Println("W! syslog forward_to_logs is set but logs are not enabled, messages will be dropped")
+	}
+	logsconfig.RegisterChannelSource(logsSourceName(ins), ins.LogsSource, ins.LogsService, ins.LogsTags)
+}
+
+func forwardToLogs(ins *Instance, raw []byte) bool {
+	content := make([]byte, len(raw))
+	copy(content, raw)
+	return logsconfig.ForwardToChannel(logsSourceName(ins), content)
+}
+
+func logsSourceName(ins *Instance) string {
+	return inputName + "_" + ins.ServiceAddress
+}
@@ -0,0 +1,13 @@
+//go:build no_logs
+
+package syslog
+
+import "log"
+
+func registerLogsForwarding(ins *Instance) {
+	log.Println("W! syslog forward_to_logs is set but categraf is built without logs, messages will be dropped")
+}
+
+func forwardToLogs(ins *Instance, raw []byte) bool {
+	return false
+}
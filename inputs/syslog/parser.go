@@ -0,0 +1,228 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var errNoPriority = errors.New("message does not start with <PRI>")
+
+// Message is a syslog message of either RFC 5424 (Version 1) or RFC 3164 (Version 0).
+// Fields missing in the message are left empty.
+type Message struct {
+	Version        int
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	Appname        string
+	ProcID         string
+	MsgID          string
+	StructuredData string
+	Message        string
+}
+
+func (m *Message) FacilityName() string {
+	if m.Facility >= 0 && m.Facility < len(facilities) {
+		return facilities[m.Facility]
+	}
+	return strconv.Itoa(m.Facility)
+}
+
+func (m *Message) SeverityName() string {
+	if m.Severity >= 0 && m.Severity < len(severities) {
+		return severities[m.Severity]
+	}
+	return strconv.Itoa(m.Severity)
+}
+
+// parse detects the format by the version after the priority, now is used for
+// RFC 3164 timestamps which have no year and for messages without a timestamp
+func parse(b []byte, now time.Time) (*Message, error) {
+	b = bytes.TrimRight(b, "\r\n\x00")
+	if len(b) < 3 || b[0] != '<' {
+		return nil, errNoPriority
+	}
+	end := bytes.IndexByte(b[:min(len(b), 5)], '>')
+	if end < 2 {
+		return nil, errNoPriority
+	}
+	pri, err := parsePriority(b[1:end])
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Facility: pri / 8, Severity: pri % 8}
+	rest := b[end+1:]
+
+	if len(rest) > 2 && rest[0] == '1' && rest[1] == ' ' {
+		m.Version = 1
+		return m, parse5424(m, rest[2:], now)
+	}
+	parse3164(m, rest, now)
+	return m, nil
+}
+
+// parsePriority accepts 1 to 3 digits up to 191, strconv.Atoi would take a sign
+func parsePriority(b []byte) (int, error) {
+	pri := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid priority %q", b)
+		}
+		pri = pri*10 + int(c-'0')
+	}
+	if pri > 191 {
+		return 0, fmt.Errorf("invalid priority %q", b)
+	}
+	return pri, nil
+}
+
+// TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+func parse5424(m *Message, b []byte, now time.Time) error {
+	var fields [5][]byte
+	for i := range fields {
+		var ok bool
+		fields[i], b, ok = bytes.Cut(b, []byte{' '})
+		if !ok && i < len(fields)-1 {
+			return fmt.Errorf("rfc5424 header is truncated")
+		}
+	}
+	if ts := string(fields[0]); ts != "-" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return fmt.Errorf("invalid rfc5424 timestamp %q", ts)
+		}
+		m.Timestamp = t
+	} else {
+		m.Timestamp = now
+	}
+	m.Hostname = nilValue(fields[1])
+	m.Appname = nilValue(fields[2])
+	m.ProcID = nilValue(fields[3])
+	m.MsgID = nilValue(fields[4])
+
+	if len(b) == 0 {
+		return nil
+	}
+	if b[0] == '-' {
+		b = b[1:]
+	} else if b[0] == '[' {
+		n, err := structuredDataLen(b)
+		if err != nil {
+			return err
+		}
+		m.StructuredData = string(b[:n])
+		b = b[n:]
+	} else {
+		return fmt.Errorf("invalid rfc5424 structured data")
+	}
+	if len(b) > 0 && b[0] == ' ' {
+		b = b[1:]
+	}
+	// a message may start with the utf-8 byte order mark
+	m.Message = string(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+	return nil
+}
+
+// structuredDataLen returns the length of one or more SD-ELEMENTs, a ']' within
+// a quoted param value is escaped with a backslash
+func structuredDataLen(b []byte) (int, error) {
+	i := 0
+	for i < len(b) && b[i] == '[' {
+		quoted := false
+		i++
+		for ; i < len(b); i++ {
+			c := b[i]
+			if quoted && c == '\\' {
+				i++
+				continue
+			}
+			if c == '"' {
+				quoted = !quoted
+				continue
+			}
+			if c == ']' && !quoted {
+				break
+			}
+		}
+		if i >= len(b) {
+			return 0, fmt.Errorf("unterminated rfc5424 structured data")
+		}
+		i++
+	}
+	return i, nil
+}
+
+func nilValue(b []byte) string {
+	if len(b) == 1 && b[0] == '-' {
+		return ""
+	}
+	return string(b)
+}
+
+// Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG, the header is optional and some
+// senders use a RFC 3339 timestamp instead
+func parse3164(m *Message, b []byte, now time.Time) {
+	m.Timestamp = now
+	rest, ok := parse3164Timestamp(m, b, now)
+	if !ok {
+		m.Message = string(b)
+		return
+	}
+	b = rest
+
+	if host, after, ok := bytes.Cut(b, []byte{' '}); ok && len(host) > 0 && !bytes.HasSuffix(host, []byte{':'}) {
+		m.Hostname = string(host)
+		b = after
+	}
+
+	// TAG is terminated by '[', ':' or a space
+	i := bytes.IndexAny(b, "[: ")
+	if i > 0 && i <= 48 {
+		m.Appname = string(b[:i])
+		b = b[i:]
+		if b[0] == '[' {
+			if j := bytes.IndexByte(b, ']'); j > 0 {
+				m.ProcID = string(b[1:j])
+				b = b[j+1:]
+			}
+		}
+		b = bytes.TrimPrefix(b, []byte{':'})
+		b = bytes.TrimPrefix(b, []byte{' '})
+	}
+	m.Message = string(b)
+}
+
+func parse3164Timestamp(m *Message, b []byte, now time.Time) ([]byte, bool) {
+	// Jan _2 15:04:05
+	if len(b) >= 16 && b[15] == ' ' {
+		t, err := time.ParseInLocation(time.Stamp, string(b[:15]), now.Location())
+		if err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// december messages received in january
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = t
+			return b[16:], true
+		}
+	}
+	if ts, rest, ok := bytes.Cut(b, []byte{' '}); ok {
+		if t, err := time.Parse(time.RFC3339Nano, string(ts)); err == nil {
+			m.Timestamp = t
+			return rest, true
+		}
+	}
+	return b, false
+}
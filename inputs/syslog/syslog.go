@@ -0,0 +1,400 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/auth"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "syslog"
+
+const (
+	framingAuto           = ""
+	framingOctetCounting  = "octet-counting"
+	framingNonTransparent = "non-transparent"
+)
+
+type Syslog struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Syslog{}
+	})
+}
+
+func (s *Syslog) Clone() inputs.Input {
+	return &Syslog{}
+}
+
+func (s *Syslog) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Syslog)
+var _ inputs.InstancesGetter = new(Syslog)
+
+type Instance struct {
+	config.InstanceConfig
+
+	// udp://:514, tcp://:514 or tcp://:6514 with tls_cert and tls_key
+	ServiceAddress string `toml:"service_address"`
+	// framing of stream transports: octet-counting (RFC 6587 3.4.1) or non-transparent
+	// (newline delimited), empty detects it per message
+	Framing        string          `toml:"framing"`
+	MaxConnections int             `toml:"max_connections"`
+	ReadTimeout    config.Duration `toml:"read_timeout"`
+	MaxMessageSize int             `toml:"max_message_size"`
+	// distinct source, hostname and appname combinations counted, the messages
+	// of further ones are counted with the three labels set to "other"
+	MaxSeries int `toml:"max_series"`
+
+	// forward the raw messages into the logs pipeline, logs must be enabled
+	ForwardToLogs bool     `toml:"forward_to_logs"`
+	LogsSource    string   `toml:"logs_source"`
+	LogsService   string   `toml:"logs_service"`
+	LogsTags      []string `toml:"logs_tags"`
+
+	// tls and allowed_ips
+	auth.ServerConfig
+
+	network  string
+	address  string
+	udpConn  net.PacketConn
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	connsMu  sync.Mutex
+	wg       sync.WaitGroup
+	done     chan struct{}
+
+	// source is the address of the sender, so the keys are capped by max_series
+	counters   map[counterKey]uint64
+	parseErrs  map[string]uint64
+	dropped    uint64
+	countersMu sync.Mutex
+}
+
+type counterKey struct {
+	source   string
+	hostname string
+	appname  string
+	facility string
+	severity string
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.ServiceAddress) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	network, address, ok := strings.Cut(ins.ServiceAddress, "://")
+	if !ok {
+		return fmt.Errorf("invalid service_address %q, e.g. udp://:514", ins.ServiceAddress)
+	}
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return fmt.Errorf("unsupported protocol %q in %q", network, ins.ServiceAddress)
+	}
+	ins.network, ins.address = network, address
+
+	switch ins.Framing {
+	case framingAuto, framingOctetCounting, framingNonTransparent:
+	default:
+		return fmt.Errorf("unknown framing %q", ins.Framing)
+	}
+	if ins.MaxMessageSize <= 0 {
+		ins.MaxMessageSize = 64 * 1024
+	}
+	if ins.ReadTimeout <= 0 {
+		ins.ReadTimeout = config.Duration(5 * time.Minute)
+	}
+	if ins.MaxSeries <= 0 {
+		ins.MaxSeries = 10000
+	}
+	if ins.LogsSource == "" {
+		ins.LogsSource = inputName
+	}
+	if ins.LogsService == "" {
+		ins.LogsService = inputName
+	}
+	if err := ins.InitServerConfig(); err != nil {
+		return err
+	}
+	if ins.ForwardToLogs {
+		registerLogsForwarding(ins)
+	}
+
+	ins.counters = make(map[counterKey]uint64)
+	ins.parseErrs = make(map[string]uint64)
+	ins.conns = make(map[net.Conn]struct{})
+	ins.done = make(chan struct{})
+
+	if strings.HasPrefix(network, "udp") {
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return err
+		}
+		ins.udpConn = conn
+		ins.wg.Add(1)
+		go ins.serveUDP()
+	} else {
+		l, err := net.Listen(network, address)
+		if err != nil {
+			return err
+		}
+		ins.listener = ins.WrapListener(l)
+		ins.wg.Add(1)
+		go ins.serveTCP()
+	}
+	log.Println("I! syslog listening on", ins.ServiceAddress)
+	return nil
+}
+
+func (ins *Instance) Drop() {
+	if ins.done == nil {
+		return
+	}
+	close(ins.done)
+	if ins.udpConn != nil {
+		ins.udpConn.Close()
+	}
+	if ins.listener != nil {
+		ins.listener.Close()
+	}
+	ins.connsMu.Lock()
+	for c := range ins.conns {
+		c.Close()
+	}
+	ins.connsMu.Unlock()
+	ins.wg.Wait()
+}
+
+func (ins *Instance) serveUDP() {
+	defer ins.wg.Done()
+	buf := make([]byte, ins.MaxMessageSize)
+	for {
+		n, addr, err := ins.udpConn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-ins.done:
+			default:
+				log.Println("E! syslog failed to read from", ins.ServiceAddress, err)
+			}
+			return
+		}
+		ip := addrIP(addr)
+		if !ins.AllowIP(ip) {
+			if ins.DebugMod {
+				log.Println("D! syslog drop message from", addr.String(), "not in allowed_ips")
+			}
+			continue
+		}
+		ins.handle(buf[:n], ip.String())
+	}
+}
+
+func (ins *Instance) serveTCP() {
+	defer ins.wg.Done()
+	for {
+		conn, err := ins.listener.Accept()
+		if err != nil {
+			select {
+			case <-ins.done:
+			default:
+				log.Println("E! syslog failed to accept on", ins.ServiceAddress, err)
+			}
+			return
+		}
+
+		ins.connsMu.Lock()
+		if ins.MaxConnections > 0 && len(ins.conns) >= ins.MaxConnections {
+			ins.connsMu.Unlock()
+			log.Println("W! syslog reached max_connections, reject", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+		ins.conns[conn] = struct{}{}
+		ins.connsMu.Unlock()
+
+		ins.wg.Add(1)
+		go ins.serveConn(conn)
+	}
+}
+
+func (ins *Instance) serveConn(conn net.Conn) {
+	defer ins.wg.Done()
+	defer func() {
+		ins.connsMu.Lock()
+		delete(ins.conns, conn)
+		ins.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	source := addrIP(conn.RemoteAddr()).String()
+	r := bufio.NewReaderSize(conn, 4096)
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(ins.ReadTimeout)))
+		frame, err := ins.readFrame(r)
+		if len(frame) > 0 {
+			ins.handle(frame, source)
+		}
+		if err != nil {
+			if err != io.EOF && ins.DebugMod {
+				log.Println("D! syslog connection from", source, "closed:", err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads one message of a stream, octet counted frames start with
+// the message length, non-transparent ones end with a newline
+func (ins *Instance) readFrame(r *bufio.Reader) ([]byte, error) {
+	framing := ins.Framing
+	if framing == framingAuto {
+		c, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		framing = framingNonTransparent
+		if c[0] >= '1' && c[0] <= '9' {
+			framing = framingOctetCounting
+		}
+	}
+
+	if framing == framingOctetCounting {
+		head, err := r.ReadSlice(' ')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(string(head[:len(head)-1]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid octet count %q", head)
+		}
+		if n > ins.MaxMessageSize {
+			return nil, fmt.Errorf("message length %d exceeds max_message_size", n)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	var frame []byte
+	for {
+		line, err := r.ReadSlice('\n')
+		frame = append(frame, line...)
+		if len(frame) > ins.MaxMessageSize {
+			return nil, fmt.Errorf("message exceeds max_message_size")
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return frame, err
+		}
+		return frame, nil
+	}
+}
+
+func (ins *Instance) handle(raw []byte, source string) {
+	raw = bytes.TrimRight(raw, "\r\n\x00")
+	if len(raw) == 0 {
+		return
+	}
+	m, err := parse(raw, time.Now())
+	if err != nil {
+		if ins.DebugMod {
+			log.Printf("D! syslog failed to parse message from %s: %v", source, err)
+		}
+		ins.countersMu.Lock()
+		if _, has := ins.parseErrs[source]; !has && len(ins.parseErrs) >= ins.MaxSeries {
+			source = "other"
+		}
+		ins.parseErrs[source]++
+		ins.countersMu.Unlock()
+		return
+	}
+
+	key := counterKey{
+		source:   source,
+		hostname: m.Hostname,
+		appname:  m.Appname,
+		facility: m.FacilityName(),
+		severity: m.SeverityName(),
+	}
+	ins.countersMu.Lock()
+	if _, has := ins.counters[key]; !has && len(ins.counters) >= ins.MaxSeries {
+		key.source, key.hostname, key.appname = "other", "other", "other"
+	}
+	ins.counters[key]++
+	ins.countersMu.Unlock()
+
+	if ins.ForwardToLogs && !forwardToLogs(ins, raw) {
+		ins.countersMu.Lock()
+		ins.dropped++
+		ins.countersMu.Unlock()
+	}
+}
+
+// Gather reports the message counters accumulated since the instance started
+func (ins *Instance) Gather(slist *types.SampleList) {
+	ins.countersMu.Lock()
+	defer ins.countersMu.Unlock()
+
+	for k, v := range ins.counters {
+		labels := map[string]string{
+			"source":   k.source,
+			"hostname": k.hostname,
+			"appname":  k.appname,
+			"facility": k.facility,
+			"severity": k.severity,
+		}
+		slist.PushFront(types.NewSample(inputName, "messages_total", v, labels).SetType(types.Counter))
+	}
+	for source, v := range ins.parseErrs {
+		slist.PushFront(types.NewSample(inputName, "parse_errors_total", v, map[string]string{"source": source}).SetType(types.Counter))
+	}
+	if ins.ForwardToLogs {
+		slist.PushFront(types.NewSample(inputName, "forward_dropped_total", ins.dropped).SetType(types.Counter))
+	}
+}
+
+// DescribeMetrics lists the metrics of syslog
+func (s *Syslog) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "syslog_messages_total", Type: types.Counter, Help: "messages received", Tags: []string{"source", "hostname", "appname", "facility", "severity"}},
+		{Name: "syslog_parse_errors_total", Type: types.Counter, Help: "messages which are neither RFC 5424 nor RFC 3164", Tags: []string{"source"}},
+		{Name: "syslog_forward_dropped_total", Type: types.Counter, Help: "messages not forwarded to the logs pipeline because it's not running or busy"},
+	}
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
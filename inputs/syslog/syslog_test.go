@@ -0,0 +1,120 @@
+package syslog
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestParse5424(t *testing.T) {
+	m, err := parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventID="1011" msg="a \] b"][other@1 x="y"] An application event log entry`), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, m.Version)
+	require.Equal(t, "local4", m.FacilityName())
+	require.Equal(t, "notice", m.SeverityName())
+	require.Equal(t, "mymachine.example.com", m.Hostname)
+	require.Equal(t, "evntslog", m.Appname)
+	require.Equal(t, "", m.ProcID)
+	require.Equal(t, "ID47", m.MsgID)
+	require.Equal(t, `[exampleSDID@32473 iut="3" eventID="1011" msg="a \] b"][other@1 x="y"]`, m.StructuredData)
+	require.Equal(t, "An application event log entry", m.Message)
+	require.Equal(t, 2003, m.Timestamp.Year())
+
+	m, err = parse([]byte(`<34>1 - host app 12 - -`), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "12", m.ProcID)
+	require.Equal(t, "", m.Message)
+
+	_, err = parse([]byte(`<34>1 2003-10-11T22:14:15Z host`), time.Now())
+	require.Error(t, err)
+}
+
+func TestParse3164(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	m, err := parse([]byte("<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8\n"), now)
+	require.NoError(t, err)
+	require.Equal(t, 0, m.Version)
+	require.Equal(t, "auth", m.FacilityName())
+	require.Equal(t, "crit", m.SeverityName())
+	require.Equal(t, "mymachine", m.Hostname)
+	require.Equal(t, "su", m.Appname)
+	require.Equal(t, "123", m.ProcID)
+	require.Equal(t, "'su root' failed for lonvick on /dev/pts/8", m.Message)
+	// october is in the future of january, so it's last year
+	require.Equal(t, 2023, m.Timestamp.Year())
+
+	m, err = parse([]byte("<13>just a message"), now)
+	require.NoError(t, err)
+	require.Equal(t, "", m.Hostname)
+	require.Equal(t, "just a message", m.Message)
+	require.Equal(t, now, m.Timestamp)
+
+	_, err = parse([]byte("no priority"), now)
+	require.Error(t, err)
+	_, err = parse([]byte("<999>x"), now)
+	require.Error(t, err)
+	// strconv.Atoi would accept the signs
+	_, err = parse([]byte("<-1>x"), now)
+	require.Error(t, err)
+	_, err = parse([]byte("<+7>x"), now)
+	require.Error(t, err)
+
+	require.Equal(t, "8", (&Message{Severity: 8}).SeverityName())
+	require.Equal(t, "-1", (&Message{Severity: -1}).SeverityName())
+}
+
+func TestCountersCap(t *testing.T) {
+	ins := &Instance{MaxSeries: 2, counters: make(map[counterKey]uint64), parseErrs: make(map[string]uint64)}
+	for i := 0; i < 5; i++ {
+		ins.handle([]byte("<13>Oct 11 22:14:15 host"+strconv.Itoa(i)+" app: hello"), "10.0.0.1")
+	}
+	require.Len(t, ins.counters, 3)
+	require.Equal(t, uint64(3), ins.counters[counterKey{source: "other", hostname: "other", appname: "other", facility: "user", severity: "notice"}])
+}
+
+func TestTCPFraming(t *testing.T) {
+	ins := &Instance{ServiceAddress: "tcp://127.0.0.1:0"}
+	require.NoError(t, ins.Init())
+	defer ins.Drop()
+
+	conn, err := net.Dial("tcp", ins.listener.Addr().String())
+	require.NoError(t, err)
+	msg := "<34>1 - host app - - - octet\ncounted"
+	_, err = conn.Write([]byte(strconv.Itoa(len(msg)) + " " + msg + "<13>Oct 11 22:14:15 host app: newline\n"))
+	require.NoError(t, err)
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		slist := types.NewSampleList()
+		ins.Gather(slist)
+		total := 0
+		for _, s := range slist.PopBackAll() {
+			if s.Metric == "syslog_messages_total" {
+				total += int(s.Value.(uint64))
+			}
+		}
+		return total == 2
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestReloadRebinds(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := "tcp://" + l.Addr().String()
+	l.Close()
+
+	for i := 0; i < 2; i++ {
+		p := &Syslog{}
+		p.Instances = []*Instance{{ServiceAddress: address}}
+		require.NoError(t, p.Instances[0].Init())
+		// what the agent does on reload and shutdown
+		inputs.MayDrop(p)
+	}
+}
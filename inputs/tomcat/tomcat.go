@@ -10,6 +10,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -110,7 +111,7 @@ func (ins *Instance) Init() error {
 }
 
 func (ins *Instance) createHTTPClient() (*http.Client, error) {
-	trans := &http.Transport{}
+	trans := httpx.NewTransport()
 
 	if ins.UseTLS {
 		tlsConfig, err := ins.ClientConfig.TLSConfig()
@@ -121,7 +122,7 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.Timeout),
 	}
 
@@ -14,6 +14,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/types"
 )
 
@@ -73,7 +74,8 @@ func (ins *Instance) Init() error {
 	}
 
 	ins.client = &http.Client{
-		Timeout: time.Duration(ins.Timeout),
+		Transport: httpx.WrapDefault(httpx.NewTransport()),
+		Timeout:   time.Duration(ins.Timeout),
 	}
 
 	for _, target := range ins.Targets {
@@ -0,0 +1,271 @@
+package uwsgi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "uwsgi"
+
+type Uwsgi struct {
+	inputs.PluginBase[*Instance]
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Uwsgi{}
+	})
+}
+
+func (u *Uwsgi) Clone() inputs.Input {
+	return &Uwsgi{}
+}
+
+func (u *Uwsgi) Name() string {
+	return inputName
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Uwsgi)
+var _ inputs.InstancesGetter = new(Uwsgi)
+
+type Instance struct {
+	inputs.InstanceBase
+
+	// stats servers, tcp://127.0.0.1:1717, unix:///run/uwsgi/stats.sock or
+	// http://127.0.0.1:1717 for --stats-http
+	Servers []string `toml:"servers"`
+	// report every worker with the worker label besides the sums
+	PerWorker bool `toml:"per_worker"`
+
+	client *http.Client
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Servers) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if err := ins.InitBase(5 * time.Second); err != nil {
+		return err
+	}
+	for _, s := range ins.Servers {
+		u, err := url.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid uwsgi server %s: %v", s, err)
+		}
+		switch u.Scheme {
+		case "tcp", "unix", "http", "https":
+		default:
+			return fmt.Errorf("uwsgi server %s should start with tcp://, unix://, http:// or https://", s)
+		}
+	}
+	var err error
+	ins.client, err = ins.HTTPClient()
+	return err
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	inputs.GatherTargets(slist, ins.Servers, ins.gatherServer)
+}
+
+func (ins *Instance) gatherServer(slist *types.SampleList, server string) {
+	tags := map[string]string{"server": server}
+	begun := time.Now()
+	s, err := ins.fetch(server)
+	if err != nil {
+		log.Println("E! failed to read uwsgi stats of", server, "error:", err)
+		inputs.PushGatherError(slist, server, err)
+		slist.PushFront(types.NewSample(inputName, "up", 0, tags))
+		return
+	}
+	slist.PushFront(types.NewSample(inputName, "up", 1, tags))
+	slist.PushFront(types.NewSample(inputName, "scrape_duration_seconds", time.Since(begun).Seconds(), tags))
+	s.push(slist, tags, ins.PerWorker)
+}
+
+func (ins *Instance) fetch(server string) (*stats, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.ReadCloser
+	switch u.Scheme {
+	case "http", "https":
+		req, err := http.NewRequest(http.MethodGet, server, nil)
+		if err != nil {
+			return nil, err
+		}
+		ins.SetRequestAuth(req)
+		resp, err := ins.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		r = resp.Body
+	default:
+		addr := u.Host
+		if u.Scheme == "unix" {
+			addr = u.Path
+		}
+		conn, err := net.DialTimeout(u.Scheme, addr, ins.GetTimeout())
+		if err != nil {
+			return nil, err
+		}
+		// the stats server writes the json and closes the connection
+		conn.SetDeadline(time.Now().Add(ins.GetTimeout()))
+		r = conn
+	}
+	defer r.Close()
+
+	s := &stats{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("failed to decode stats: %v", err)
+	}
+	return s, nil
+}
+
+// stats is the json of the uwsgi stats server, the fields reported only
+type stats struct {
+	ListenQueue       int64    `json:"listen_queue"`
+	ListenQueueErrors int64    `json:"listen_queue_errors"`
+	SignalQueue       int64    `json:"signal_queue"`
+	Load              int64    `json:"load"`
+	Sockets           []socket `json:"sockets"`
+	Workers           []worker `json:"workers"`
+}
+
+type socket struct {
+	Name     string `json:"name"`
+	Queue    int64  `json:"queue"`
+	MaxQueue int64  `json:"max_queue"`
+}
+
+type worker struct {
+	ID            int64  `json:"id"`
+	Requests      int64  `json:"requests"`
+	Exceptions    int64  `json:"exceptions"`
+	HarakiriCount int64  `json:"harakiri_count"`
+	Status        string `json:"status"`
+	RSS           int64  `json:"rss"`
+	VSZ           int64  `json:"vsz"`
+	RespawnCount  int64  `json:"respawn_count"`
+	TX            int64  `json:"tx"`
+	// average response time in microseconds
+	AvgRT int64 `json:"avg_rt"`
+}
+
+// worker states reported even if no worker is in them
+var workerStatuses = []string{"idle", "busy", "cheap", "pause", "sig"}
+
+func (s *stats) push(slist *types.SampleList, tags map[string]string, perWorker bool) {
+	slist.PushSamples(inputName, map[string]interface{}{
+		"listen_queue":              s.ListenQueue,
+		"listen_queue_errors_total": s.ListenQueueErrors,
+		"signal_queue":              s.SignalQueue,
+		"load":                      s.Load,
+		"workers":                   len(s.Workers),
+	}, tags)
+
+	for _, sock := range s.Sockets {
+		slist.PushSamples(inputName, map[string]interface{}{
+			"socket_queue":     sock.Queue,
+			"socket_max_queue": sock.MaxQueue,
+		}, tags, map[string]string{"socket": sock.Name})
+	}
+
+	statuses := make(map[string]int)
+	for _, st := range workerStatuses {
+		statuses[st] = 0
+	}
+	var (
+		requests, exceptions, harakiri, respawns, tx, rss, vsz int64
+		rtSum                                                  float64
+		rtWorkers                                              int
+	)
+	for _, w := range s.Workers {
+		statuses[w.Status]++
+		requests += w.Requests
+		exceptions += w.Exceptions
+		harakiri += w.HarakiriCount
+		respawns += w.RespawnCount
+		tx += w.TX
+		rss += w.RSS
+		vsz += w.VSZ
+		if w.Requests > 0 {
+			rtSum += float64(w.AvgRT)
+			rtWorkers++
+		}
+		if perWorker {
+			slist.PushSamples(inputName, map[string]interface{}{
+				"worker_requests_total":            w.Requests,
+				"worker_exceptions_total":          w.Exceptions,
+				"worker_harakiri_total":            w.HarakiriCount,
+				"worker_rss_bytes":                 w.RSS,
+				"worker_busy":                      w.Status == "busy",
+				"worker_avg_response_time_seconds": float64(w.AvgRT) / 1e6,
+			}, tags, map[string]string{"worker": strconv.FormatInt(w.ID, 10)})
+		}
+	}
+	for st, n := range statuses {
+		slist.PushFront(types.NewSample(inputName, "workers_by_status", n, tags, map[string]string{"status": st}))
+	}
+
+	fields := map[string]interface{}{
+		"requests_total":   requests,
+		"exceptions_total": exceptions,
+		"harakiri_total":   harakiri,
+		"respawns_total":   respawns,
+		"tx_bytes_total":   tx,
+		"rss_bytes":        rss,
+		"vsz_bytes":        vsz,
+	}
+	if rtWorkers > 0 {
+		fields["avg_response_time_seconds"] = rtSum / float64(rtWorkers) / 1e6
+	}
+	slist.PushSamples(inputName, fields, tags)
+}
+
+func (u *Uwsgi) DescribeMetrics() []types.MetricDesc {
+	server := []string{"server"}
+	worker := []string{"server", "worker"}
+	return []types.MetricDesc{
+		{Name: "uwsgi_up", Type: types.Gauge, Help: "whether the stats server was read", Tags: server},
+		{Name: "uwsgi_scrape_duration_seconds", Type: types.Gauge, Unit: "seconds", Help: "time taken to read the stats", Tags: server},
+		{Name: "uwsgi_listen_queue", Type: types.Gauge, Help: "requests waiting in the listen queue", Tags: server},
+		{Name: "uwsgi_listen_queue_errors_total", Type: types.Counter, Help: "requests rejected since the listen queue was full", Tags: server},
+		{Name: "uwsgi_signal_queue", Type: types.Gauge, Help: "signals waiting to be handled", Tags: server},
+		{Name: "uwsgi_load", Type: types.Gauge, Help: "requests being served", Tags: server},
+		{Name: "uwsgi_workers", Type: types.Gauge, Help: "workers", Tags: server},
+		{Name: "uwsgi_workers_by_status", Type: types.Gauge, Help: "workers by status: idle, busy, cheap, pause or sig", Tags: []string{"server", "status"}},
+		{Name: "uwsgi_socket_queue", Type: types.Gauge, Help: "requests waiting in the queue of a socket", Tags: []string{"server", "socket"}},
+		{Name: "uwsgi_socket_max_queue", Type: types.Gauge, Help: "size of the queue of a socket", Tags: []string{"server", "socket"}},
+		{Name: "uwsgi_requests_total", Type: types.Counter, Help: "requests served by the workers", Tags: server},
+		{Name: "uwsgi_exceptions_total", Type: types.Counter, Help: "exceptions raised by the workers", Tags: server},
+		{Name: "uwsgi_harakiri_total", Type: types.Counter, Help: "requests killed by harakiri for taking too long", Tags: server},
+		{Name: "uwsgi_respawns_total", Type: types.Counter, Help: "respawns of the workers", Tags: server},
+		{Name: "uwsgi_tx_bytes_total", Type: types.Counter, Unit: "bytes", Help: "bytes sent by the workers", Tags: server},
+		{Name: "uwsgi_rss_bytes", Type: types.Gauge, Unit: "bytes", Help: "resident memory of all workers, needs --memory-report", Tags: server},
+		{Name: "uwsgi_vsz_bytes", Type: types.Gauge, Unit: "bytes", Help: "virtual memory of all workers, needs --memory-report", Tags: server},
+		{Name: "uwsgi_avg_response_time_seconds", Type: types.Gauge, Unit: "seconds", Help: "average of the response times of the workers which served requests", Tags: server},
+		{Name: "uwsgi_worker_requests_total", Type: types.Counter, Help: "requests served by a worker, per_worker only", Tags: worker},
+		{Name: "uwsgi_worker_exceptions_total", Type: types.Counter, Help: "exceptions raised by a worker, per_worker only", Tags: worker},
+		{Name: "uwsgi_worker_harakiri_total", Type: types.Counter, Help: "requests of a worker killed by harakiri, per_worker only", Tags: worker},
+		{Name: "uwsgi_worker_rss_bytes", Type: types.Gauge, Unit: "bytes", Help: "resident memory of a worker, per_worker only", Tags: worker},
+		{Name: "uwsgi_worker_busy", Type: types.Gauge, Help: "whether a worker is serving a request, per_worker only", Tags: worker},
+		{Name: "uwsgi_worker_avg_response_time_seconds", Type: types.Gauge, Unit: "seconds", Help: "average response time of a worker, per_worker only", Tags: worker},
+	}
+}
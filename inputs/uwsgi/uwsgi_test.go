@@ -0,0 +1,73 @@
+package uwsgi
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+const statsJSON = `{
+	"version": "2.0.21",
+	"listen_queue": 3,
+	"listen_queue_errors": 1,
+	"signal_queue": 0,
+	"load": 1,
+	"sockets": [{"name": ":8000", "proto": "uwsgi", "queue": 3, "max_queue": 100}],
+	"workers": [
+		{"id": 1, "requests": 100, "exceptions": 2, "harakiri_count": 1, "status": "busy", "rss": 1000, "vsz": 4000, "respawn_count": 1, "tx": 500, "avg_rt": 20000},
+		{"id": 2, "requests": 50, "exceptions": 0, "harakiri_count": 0, "status": "idle", "rss": 3000, "vsz": 4000, "respawn_count": 1, "tx": 300, "avg_rt": 40000},
+		{"id": 3, "requests": 0, "status": "cheap", "respawn_count": 0}
+	]
+}`
+
+func TestGatherUnix(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "stats.sock")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(statsJSON))
+			conn.Close()
+		}
+	}()
+
+	ins := &Instance{Servers: []string{"unix://" + sock}}
+	require.NoError(t, ins.Init())
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+
+	ret := make(map[string]interface{})
+	for _, s := range slist.PopBackAll() {
+		key := s.Metric
+		if st := s.Labels["status"]; st != "" {
+			key += "_" + st
+		}
+		ret[key] = s.Value
+	}
+	require.Equal(t, 1, ret["uwsgi_up"])
+	require.Equal(t, int64(3), ret["uwsgi_listen_queue"])
+	require.Equal(t, int64(3), ret["uwsgi_socket_queue"])
+	require.Equal(t, 3, ret["uwsgi_workers"])
+	require.Equal(t, 1, ret["uwsgi_workers_by_status_busy"])
+	require.Equal(t, 1, ret["uwsgi_workers_by_status_idle"])
+	require.Equal(t, 0, ret["uwsgi_workers_by_status_pause"])
+	require.Equal(t, int64(150), ret["uwsgi_requests_total"])
+	require.Equal(t, int64(1), ret["uwsgi_harakiri_total"])
+	require.Equal(t, int64(4000), ret["uwsgi_rss_bytes"])
+	require.Equal(t, 0.03, ret["uwsgi_avg_response_time_seconds"])
+	require.NotContains(t, ret, "uwsgi_worker_rss_bytes")
+}
+
+func TestInitInvalidServer(t *testing.T) {
+	ins := &Instance{Servers: []string{"udp://127.0.0.1:1717"}}
+	require.Error(t, ins.Init())
+}
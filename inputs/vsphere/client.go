@@ -15,6 +15,7 @@ import (
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/performance"
 	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/sts"
 	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/methods"
@@ -99,7 +100,7 @@ func (cf *ClientFactory) testClient(ctx context.Context) error {
 		log.Println("I! Client session seems to have time out. Reauthenticating!")
 		ctx2, cancel2 := context.WithTimeout(ctx, time.Duration(cf.parent.Timeout))
 		defer cancel2()
-		if err := cf.client.Client.SessionManager.Login(ctx2, url.UserPassword(cf.parent.Username, cf.parent.Password)); err != nil {
+		if err := login(ctx2, cf.client.Client, url.UserPassword(cf.parent.Username, cf.parent.Password), cf.parent); err != nil {
 			return fmt.Errorf("renewing authentication failed: %s", err.Error())
 		}
 	}
@@ -166,7 +167,7 @@ func NewClient(ctx context.Context, vSphereURL *url.URL, vs *Instance) (*Client,
 
 	// Only login if the URL contains user information.
 	if vSphereURL.User != nil {
-		if err := c.Login(ctx, vSphereURL.User); err != nil {
+		if err := login(ctx, c, vSphereURL.User, vs); err != nil {
 			return nil, err
 		}
 	}
@@ -208,6 +209,27 @@ func NewClient(ctx context.Context, vSphereURL *url.URL, vs *Instance) (*Client,
 	return client, nil
 }
 
+// login authenticates the session, either directly against the session manager or,
+// if use_sso is set, with a bearer token issued by the vCenter SSO (STS) service.
+func login(ctx context.Context, c *govmomi.Client, u *url.Userinfo, vs *Instance) error {
+	if !vs.UseSSO {
+		return c.Login(ctx, u)
+	}
+	tokens, err := sts.NewClient(ctx, c.Client)
+	if err != nil {
+		return fmt.Errorf("creating sso client failed: %s", err.Error())
+	}
+	signer, err := tokens.Issue(ctx, sts.TokenRequest{
+		Userinfo: u,
+		Lifetime: time.Duration(vs.SSOTokenLifetime),
+	})
+	if err != nil {
+		return fmt.Errorf("issuing sso token failed: %s", err.Error())
+	}
+	header := soap.Header{Security: signer}
+	return c.SessionManager.LoginByToken(c.Client.WithHeader(ctx, header))
+}
+
 // Close shuts down a ClientFactory and releases any resources associated with it.
 func (cf *ClientFactory) Close() {
 	cf.mux.Lock()
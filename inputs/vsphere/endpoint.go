@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -58,6 +59,7 @@ type Endpoint struct {
 	customAttrFilter  filter.Filter
 	customAttrEnabled bool
 	metricNameLookup  map[int32]string
+	metricLevels      map[int32]metricLevel
 	metricNameMux     sync.RWMutex
 }
 
@@ -77,12 +79,21 @@ type resourceKind struct {
 	getObjects       func(context.Context, *Endpoint, *ResourceFilter) (objectMap, error)
 	include          []string
 	simple           bool
+	level            int32
+	maxObjects       int
 	metrics          performance.MetricList
 	parent           string
 	latestSample     time.Time
 	lastColl         time.Time
 }
 
+// metricLevel holds the statistics levels a counter is collected at by vCenter,
+// for the aggregate value and for the per-device instances respectively.
+type metricLevel struct {
+	level          int32
+	perDeviceLevel int32
+}
+
 type metricEntry struct {
 	tags   map[string]string
 	name   string
@@ -142,6 +153,8 @@ func NewEndpoint(ctx context.Context, parent *Instance, address *url.URL) (*Endp
 			excludePaths:     parent.DatacenterExclude,
 			simple:           isSimple(parent.DatacenterMetricInclude, parent.DatacenterMetricExclude),
 			include:          parent.DatacenterMetricInclude,
+			level:            int32(parent.DatacenterMetricLevel),
+			maxObjects:       parent.DatacenterMaxObjects,
 			collectInstances: parent.DatacenterInstances,
 			getObjects:       getDatacenters,
 			parent:           "",
@@ -160,6 +173,8 @@ func NewEndpoint(ctx context.Context, parent *Instance, address *url.URL) (*Endp
 			excludePaths:     parent.ClusterExclude,
 			simple:           isSimple(parent.ClusterMetricInclude, parent.ClusterMetricExclude),
 			include:          parent.ClusterMetricInclude,
+			level:            int32(parent.ClusterMetricLevel),
+			maxObjects:       parent.ClusterMaxObjects,
 			collectInstances: parent.ClusterInstances,
 			getObjects:       getClusters,
 			parent:           "datacenter",
@@ -178,6 +193,8 @@ func NewEndpoint(ctx context.Context, parent *Instance, address *url.URL) (*Endp
 			excludePaths:     parent.ResourcePoolExclude,
 			simple:           isSimple(parent.ResourcePoolMetricInclude, parent.ResourcePoolMetricExclude),
 			include:          parent.ResourcePoolMetricInclude,
+			level:            int32(parent.ResourcePoolMetricLevel),
+			maxObjects:       parent.ResourcePoolMaxObjects,
 			collectInstances: parent.ResourcePoolInstances,
 			getObjects:       getResourcePools,
 			parent:           "cluster",
@@ -196,6 +213,8 @@ func NewEndpoint(ctx context.Context, parent *Instance, address *url.URL) (*Endp
 			excludePaths:     parent.HostExclude,
 			simple:           isSimple(parent.HostMetricInclude, parent.HostMetricExclude),
 			include:          parent.HostMetricInclude,
+			level:            int32(parent.HostMetricLevel),
+			maxObjects:       parent.HostMaxObjects,
 			collectInstances: parent.HostInstances,
 			getObjects:       getHosts,
 			parent:           "cluster",
@@ -214,6 +233,8 @@ func NewEndpoint(ctx context.Context, parent *Instance, address *url.URL) (*Endp
 			excludePaths:     parent.VMExclude,
 			simple:           isSimple(parent.VMMetricInclude, parent.VMMetricExclude),
 			include:          parent.VMMetricInclude,
+			level:            int32(parent.VMMetricLevel),
+			maxObjects:       parent.VMMaxObjects,
 			collectInstances: parent.VMInstances,
 			getObjects:       getVMs,
 			parent:           "host",
@@ -231,6 +252,8 @@ func NewEndpoint(ctx context.Context, parent *Instance, address *url.URL) (*Endp
 			excludePaths:     parent.DatastoreExclude,
 			simple:           isSimple(parent.DatastoreMetricInclude, parent.DatastoreMetricExclude),
 			include:          parent.DatastoreMetricInclude,
+			level:            int32(parent.DatastoreMetricLevel),
+			maxObjects:       parent.DatastoreMaxObjects,
 			collectInstances: parent.DatastoreInstances,
 			getObjects:       getDatastores,
 			parent:           "",
@@ -343,12 +366,32 @@ func (e *Endpoint) reloadMetricNameMap(ctx context.Context) error {
 		return err
 	}
 	e.metricNameLookup = make(map[int32]string)
+	e.metricLevels = make(map[int32]metricLevel)
 	for key, m := range mn {
 		e.metricNameLookup[key] = m.Name()
+		e.metricLevels[key] = metricLevel{level: m.Level, perDeviceLevel: m.PerDeviceLevel}
 	}
 	return nil
 }
 
+// levelAllowed reports whether a counter is collected at the statistics level configured
+// for the resource kind. Instance metrics are checked against the per-device level.
+func (e *Endpoint) levelAllowed(res *resourceKind, id int32, instance bool) bool {
+	if res.level <= 0 {
+		return true
+	}
+	e.metricNameMux.RLock()
+	defer e.metricNameMux.RUnlock()
+	l, ok := e.metricLevels[id]
+	if !ok {
+		return true
+	}
+	if instance {
+		return l.perDeviceLevel <= res.level
+	}
+	return l.level <= res.level
+}
+
 func (e *Endpoint) getMetadata(ctx context.Context, obj *objectRef, sampling int32) (performance.MetricList, error) {
 	client, err := e.clientFactory.GetClient(ctx)
 	if err != nil {
@@ -460,6 +503,11 @@ func (e *Endpoint) discover(ctx context.Context) error {
 				return err
 			}
 
+			if res.enabled && res.maxObjects > 0 && len(objects) > res.maxObjects {
+				log.Printf("W! Discovered %d %s objects, only the first %d (by name) will be collected", len(objects), res.name, res.maxObjects)
+				objects = limitObjects(objects, res.maxObjects)
+			}
+
 			// Fill in datacenter names where available (no need to do it for Datacenters)
 			if res.name != "datacenter" {
 				for k, obj := range objects {
@@ -525,6 +573,26 @@ func (e *Endpoint) discover(ctx context.Context) error {
 	return nil
 }
 
+// limitObjects keeps the first n objects ordered by name, so that the selection
+// stays stable between discoveries.
+func limitObjects(objects objectMap, n int) objectMap {
+	refs := make([]*objectRef, 0, len(objects))
+	for _, obj := range objects {
+		refs = append(refs, obj)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].name == refs[j].name {
+			return refs[i].ref.Value < refs[j].ref.Value
+		}
+		return refs[i].name < refs[j].name
+	})
+	limited := make(objectMap, n)
+	for _, obj := range refs[:n] {
+		limited[obj.ref.Value] = obj
+	}
+	return limited
+}
+
 func (e *Endpoint) simpleMetadataSelect(ctx context.Context, client *Client, res *resourceKind) {
 	if e.debug() {
 		log.Printf("D! Using fast metric metadata selection for %s", res.name)
@@ -545,6 +613,12 @@ func (e *Endpoint) simpleMetadataSelect(ctx context.Context, client *Client, res
 			} else {
 				cnt.Instance = ""
 			}
+			if !e.levelAllowed(res, pci.Key, cnt.Instance != "") {
+				if e.debug() {
+					log.Printf("D! Metric %s is above level %d for %s. Will not be collected", s, res.level, res.name)
+				}
+				continue
+			}
 			res.metrics = append(res.metrics, cnt)
 		} else {
 			log.Printf("W! Metric name %s is unknown. Will not be collected", s)
@@ -593,6 +667,9 @@ func (e *Endpoint) complexMetadataSelect(ctx context.Context, res *resourceKind,
 					} else {
 						m.Instance = ""
 					}
+					if !e.levelAllowed(res, m.CounterId, m.Instance != "") {
+						continue
+					}
 					if res.filters.Match(e.getMetricNameForID(m.CounterId)) {
 						mMap[strconv.Itoa(int(m.CounterId))+"|"+m.Instance] = m
 					}
@@ -2,6 +2,7 @@ package vsphere
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -50,37 +51,53 @@ type Instance struct {
 	Vcenter  string `toml:"vcenter"`
 	Username string `toml:"username"`
 	Password string `toml:"password"`
+	// UseSSO logs in with a SAML token issued by the vCenter SSO (STS) service
+	// instead of a plain username/password session login.
+	UseSSO           bool            `toml:"use_sso"`
+	SSOTokenLifetime config.Duration `toml:"sso_token_lifetime"`
 
 	DatacenterInstances       bool     `toml:"datacenter_instances"`
 	DatacenterMetricInclude   []string `toml:"datacenter_metric_include"`
 	DatacenterMetricExclude   []string `toml:"datacenter_metric_exclude"`
 	DatacenterInclude         []string `toml:"datacenter_include"`
 	DatacenterExclude         []string `toml:"datacenter_exclude"`
+	DatacenterMetricLevel     int      `toml:"datacenter_metric_level"`
+	DatacenterMaxObjects      int      `toml:"datacenter_max_objects"`
 	ClusterInstances          bool     `toml:"cluster_instances"`
 	ClusterMetricInclude      []string `toml:"cluster_metric_include"`
 	ClusterMetricExclude      []string `toml:"cluster_metric_exclude"`
 	ClusterInclude            []string `toml:"cluster_include"`
 	ClusterExclude            []string `toml:"cluster_exclude"`
+	ClusterMetricLevel        int      `toml:"cluster_metric_level"`
+	ClusterMaxObjects         int      `toml:"cluster_max_objects"`
 	ResourcePoolInstances     bool     `toml:"resoucepool_instances"`
 	ResourcePoolMetricInclude []string `toml:"resoucepool_metric_include"`
 	ResourcePoolMetricExclude []string `toml:"resoucepool_metric_exclude"`
 	ResourcePoolInclude       []string `toml:"resoucepool_include"`
 	ResourcePoolExclude       []string `toml:"resoucepool_exclude"`
+	ResourcePoolMetricLevel   int      `toml:"resoucepool_metric_level"`
+	ResourcePoolMaxObjects    int      `toml:"resoucepool_max_objects"`
 	HostInstances             bool     `toml:"host_instances"`
 	HostMetricInclude         []string `toml:"host_metric_include"`
 	HostMetricExclude         []string `toml:"host_metric_exclude"`
 	HostInclude               []string `toml:"host_include"`
 	HostExclude               []string `toml:"host_exclude"`
+	HostMetricLevel           int      `toml:"host_metric_level"`
+	HostMaxObjects            int      `toml:"host_max_objects"`
 	VMInstances               bool     `toml:"vm_instances"`
 	VMMetricInclude           []string `toml:"vm_metric_include"`
 	VMMetricExclude           []string `toml:"vm_metric_exclude"`
 	VMInclude                 []string `toml:"vm_include"`
 	VMExclude                 []string `toml:"vm_exclude"`
+	VMMetricLevel             int      `toml:"vm_metric_level"`
+	VMMaxObjects              int      `toml:"vm_max_objects"`
 	DatastoreInstances        bool     `toml:"datastore_instances"`
 	DatastoreMetricInclude    []string `toml:"datastore_metric_include"`
 	DatastoreMetricExclude    []string `toml:"datastore_metric_exclude"`
 	DatastoreInclude          []string `toml:"datastore_include"`
 	DatastoreExclude          []string `toml:"datastore_exclude"`
+	DatastoreMetricLevel      int      `toml:"datastore_metric_level"`
+	DatastoreMaxObjects       int      `toml:"datastore_max_objects"`
 
 	Separator               string          `toml:"separator"`
 	CustomAttributeInclude  []string        `toml:"custom_attribute_include"`
@@ -166,6 +183,15 @@ func (ins *Instance) Init() error {
 	if ins.HistoricalInterval == 0 {
 		ins.HistoricalInterval = config.Duration(time.Second * 300)
 	}
+	if ins.UseSSO && ins.SSOTokenLifetime == 0 {
+		ins.SSOTokenLifetime = config.Duration(time.Hour)
+	}
+	for _, level := range []int{ins.DatacenterMetricLevel, ins.ClusterMetricLevel, ins.ResourcePoolMetricLevel,
+		ins.HostMetricLevel, ins.VMMetricLevel, ins.DatastoreMetricLevel} {
+		if level < 0 || level > 4 {
+			return fmt.Errorf("invalid metric level %d, must be between 1 and 4 (0 disables level filtering)", level)
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	ins.cancel = cancel
@@ -16,6 +16,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/httpx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
@@ -115,18 +116,17 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
-	trans := &http.Transport{
-		DialContext:       dialer.DialContext,
-		DisableKeepAlives: true,
-		TLSClientConfig:   tlsCfg,
-	}
+	trans := httpx.NewTransport()
+	trans.DialContext = dialer.DialContext
+	trans.DisableKeepAlives = true
+	trans.TLSClientConfig = tlsCfg
 
 	if ins.UseTLS {
 		trans.TLSClientConfig = tlsCfg
 	}
 
 	client := &http.Client{
-		Transport: trans,
+		Transport: httpx.WrapDefault(trans),
 		Timeout:   time.Duration(ins.ResponseTimeout),
 	}
 
@@ -0,0 +1,48 @@
+package zookeeper
+
+import "flashcat.cloud/categraf/types"
+
+var (
+	hostTags   = []string{"zk_host", "zk_cluster", "resolved_ip"}
+	mntrDoc    = "from mntr, keys depend on zookeeper version and role"
+	leaderOnly = mntrDoc + ", leader only"
+	srvrDoc    = "from srvr sampled srvr_samples times per gather"
+)
+
+// DescribeMetrics lists the fixed metrics and the common mntr keys of zookeeper 3.4/3.5
+func (z *Zookeeper) DescribeMetrics() []types.MetricDesc {
+	return []types.MetricDesc{
+		{Name: "zk_up", Type: types.Gauge, Help: "1 if mntr succeeded, 0 if connecting failed or mntr is not whitelisted", Tags: hostTags},
+		{Name: "zk_ruok", Type: types.Gauge, Help: "1 if ruok answered imok", Tags: hostTags},
+		{Name: "zk_scrape_use_seconds", Type: types.Gauge, Unit: "seconds", Help: "time spent gathering one host", Tags: hostTags},
+		{Name: "zk_server_leader", Type: types.Gauge, Help: "1 if the server is the leader", Tags: hostTags},
+		{Name: "zk_version", Type: types.Gauge, Help: "always 1, version in tag", Tags: append([]string{"version"}, hostTags...)},
+		{Name: "zk_peer_state", Type: types.Gauge, Help: "always 1, peer state in tag (3.5+)", Tags: append([]string{"state"}, hostTags...)},
+		{Name: "zk_avg_latency", Type: types.Gauge, Unit: "milliseconds", Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_min_latency", Type: types.Gauge, Unit: "milliseconds", Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_max_latency", Type: types.Gauge, Unit: "milliseconds", Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_packets_received", Type: types.Counter, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_packets_sent", Type: types.Counter, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_num_alive_connections", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_outstanding_requests", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_znode_count", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_watch_count", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_ephemerals_count", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_approximate_data_size", Type: types.Gauge, Unit: "bytes", Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_open_file_descriptor_count", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_max_file_descriptor_count", Type: types.Gauge, Help: mntrDoc, Tags: hostTags},
+		{Name: "zk_followers", Type: types.Gauge, Help: leaderOnly, Tags: hostTags},
+		{Name: "zk_synced_followers", Type: types.Gauge, Help: leaderOnly, Tags: hostTags},
+		{Name: "zk_pending_syncs", Type: types.Gauge, Help: leaderOnly, Tags: hostTags},
+		{Name: "zk_srvr_samples", Type: types.Gauge, Help: srvrDoc + ", successful samples", Tags: hostTags},
+		{Name: "zk_srvr_request_rate", Type: types.Gauge, Help: srvrDoc + ", requests per second since the last sample of the previous gather", Tags: hostTags},
+		{Name: "zk_srvr_latency_avg_delta", Type: types.Gauge, Unit: "milliseconds", Help: srvrDoc + ", average latency of the requests since the last sample of the previous gather", Tags: hostTags},
+		{Name: "zk_srvr_latency_max", Type: types.Gauge, Unit: "milliseconds", Help: srvrDoc + ", max latency since the server started or srst", Tags: hostTags},
+		{Name: "zk_srvr_latency_max_delta", Type: types.Gauge, Unit: "milliseconds", Help: srvrDoc + ", growth of the max latency, non zero when a new max was hit", Tags: hostTags},
+		{Name: "zk_srvr_interval_latency_min", Type: types.Gauge, Unit: "milliseconds", Help: srvrDoc + ", lowest average latency of an interval between samples", Tags: hostTags},
+		{Name: "zk_srvr_interval_latency_max", Type: types.Gauge, Unit: "milliseconds", Help: srvrDoc + ", highest average latency of an interval between samples", Tags: hostTags},
+		{Name: "zk_srvr_interval_latency_bucket", Type: types.Counter, Unit: "milliseconds", Help: srvrDoc + ", histogram of the average latencies of the intervals between samples", Tags: append([]string{"le"}, hostTags...)},
+		{Name: "zk_srvr_interval_latency_count", Type: types.Counter, Help: srvrDoc + ", intervals observed", Tags: hostTags},
+		{Name: "zk_srvr_interval_latency_sum", Type: types.Counter, Unit: "milliseconds", Help: srvrDoc + ", sum of the average latencies observed", Tags: hostTags},
+	}
+}
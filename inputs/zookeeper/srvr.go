@@ -0,0 +1,261 @@
+package zookeeper
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/netx"
+	"flashcat.cloud/categraf/types"
+)
+
+var defaultLatencyBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// srvrSample is one answer of srvr. Its latencies and request count are
+// cumulative since the server started or srst, so what happened between two
+// samples is derived from their difference.
+type srvrSample struct {
+	at       time.Time
+	min      float64
+	avg      float64
+	max      float64
+	received float64
+}
+
+// latencyHistogram counts the average latency of the intervals between samples
+type latencyHistogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *latencyHistogram) observe(v float64) {
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// srvrState is kept per target address across gathers, so the first interval
+// of a gather starts at the last sample of the previous one
+type srvrState struct {
+	last      *srvrSample
+	histogram *latencyHistogram
+}
+
+type srvrSampler struct {
+	sync.Mutex
+	states map[string]*srvrState
+}
+
+func (ins *Instance) initSrvr() error {
+	if ins.SrvrSamples <= 0 {
+		return nil
+	}
+	if ins.SrvrSampleInterval <= 0 {
+		ins.SrvrSampleInterval = config.Duration(time.Second)
+	}
+	if len(ins.SrvrLatencyBuckets) == 0 {
+		ins.SrvrLatencyBuckets = defaultLatencyBuckets
+	}
+	if !sort.Float64sAreSorted(ins.SrvrLatencyBuckets) {
+		return fmt.Errorf("srvr_latency_buckets should be sorted ascending")
+	}
+	ins.srvr.states = make(map[string]*srvrState)
+	return nil
+}
+
+// gatherSrvr samples srvr srvr_samples times and reports the latency and the
+// request rate of the intervals in between, which makes spikes visible that
+// the cumulative averages of mntr flatten
+func (ins *Instance) gatherSrvr(slist *types.SampleList, target netx.Target, tags map[string]string) {
+	samples := make([]*srvrSample, 0, ins.SrvrSamples)
+	for i := 0; i < ins.SrvrSamples; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(ins.SrvrSampleInterval))
+		}
+		s, err := ins.sampleSrvr(target)
+		if err != nil {
+			log.Println("E! failed to sample srvr of zookeeper:", target.Origin, "error:", err)
+			inputs.PushGatherError(slist, target.Origin, err)
+			continue
+		}
+		samples = append(samples, s)
+	}
+	slist.PushFront(types.NewSample("", "zk_srvr_samples", len(samples), tags))
+	if len(samples) == 0 {
+		return
+	}
+
+	ins.srvr.Lock()
+	defer ins.srvr.Unlock()
+	// keyed by address, the members behind an expanded name share the origin
+	state, has := ins.srvr.states[target.Address]
+	if !has {
+		state = &srvrState{histogram: &latencyHistogram{
+			buckets: ins.SrvrLatencyBuckets,
+			counts:  make([]uint64, len(ins.SrvrLatencyBuckets)),
+		}}
+		ins.srvr.states[target.Address] = state
+	}
+	if state.last != nil {
+		samples = append([]*srvrSample{state.last}, samples...)
+	}
+	state.last = samples[len(samples)-1]
+
+	var (
+		first     *srvrSample
+		intervals int
+		minAvg    float64
+		maxAvg    float64
+	)
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		// restarted or reset by srst, the intervals before don't count
+		if cur.received < prev.received || cur.max < prev.max {
+			first = nil
+			continue
+		}
+		if first == nil {
+			first = prev
+		}
+		requests := cur.received - prev.received
+		if requests == 0 {
+			continue
+		}
+		avg := intervalAvg(prev, cur)
+		state.histogram.observe(avg)
+		if intervals == 0 || avg < minAvg {
+			minAvg = avg
+		}
+		if intervals == 0 || avg > maxAvg {
+			maxAvg = avg
+		}
+		intervals++
+	}
+
+	last := samples[len(samples)-1]
+	slist.PushFront(types.NewSample("", "zk_srvr_latency_max", last.max, tags))
+	if first != nil && last.at.After(first.at) {
+		slist.PushFront(types.NewSample("", "zk_srvr_request_rate", (last.received-first.received)/last.at.Sub(first.at).Seconds(), tags))
+		slist.PushFront(types.NewSample("", "zk_srvr_latency_max_delta", last.max-first.max, tags))
+		if last.received > first.received {
+			slist.PushFront(types.NewSample("", "zk_srvr_latency_avg_delta", intervalAvg(first, last), tags))
+		}
+	}
+	if intervals > 0 {
+		slist.PushFront(types.NewSample("", "zk_srvr_interval_latency_min", minAvg, tags))
+		slist.PushFront(types.NewSample("", "zk_srvr_interval_latency_max", maxAvg, tags))
+	}
+
+	h := state.histogram
+	for i, le := range h.buckets {
+		slist.PushFront(types.NewSample("", "zk_srvr_interval_latency_bucket", h.counts[i], tags,
+			map[string]string{"le": strconv.FormatFloat(le, 'f', -1, 64)}).SetType(types.Counter))
+	}
+	slist.PushFront(types.NewSample("", "zk_srvr_interval_latency_bucket", h.count, tags, map[string]string{"le": "+Inf"}).SetType(types.Counter))
+	slist.PushFront(types.NewSample("", "zk_srvr_interval_latency_count", h.count, tags).SetType(types.Counter))
+	slist.PushFront(types.NewSample("", "zk_srvr_interval_latency_sum", h.sum, tags).SetType(types.Counter))
+}
+
+// pruneSrvr drops the states of the targets gone, e.g. members scaled away
+func (ins *Instance) pruneSrvr(targets []netx.Target) {
+	if ins.SrvrSamples <= 0 {
+		return
+	}
+	alive := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		alive[target.Address] = struct{}{}
+	}
+	ins.srvr.Lock()
+	defer ins.srvr.Unlock()
+	for addr := range ins.srvr.states {
+		if _, has := alive[addr]; !has {
+			delete(ins.srvr.states, addr)
+		}
+	}
+}
+
+// intervalAvg is the average latency of the requests between two samples
+func intervalAvg(prev, cur *srvrSample) float64 {
+	avg := (cur.avg*cur.received - prev.avg*prev.received) / (cur.received - prev.received)
+	if avg < 0 {
+		// the averages are rounded by the server
+		return 0
+	}
+	return avg
+}
+
+func (ins *Instance) sampleSrvr(target netx.Target) (*srvrSample, error) {
+	conn, err := ins.zkConnect(target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(ins.Timeout) * time.Second))
+
+	res := sendZookeeperCmd(conn, "srvr")
+	if strings.Contains(res, cmdNotExecutedSffx) {
+		return nil, fmt.Errorf("srvr is not in 4lw.commands.whitelist")
+	}
+	s, err := parseSrvr(res)
+	if err != nil {
+		return nil, err
+	}
+	s.at = time.Now()
+	return s, nil
+}
+
+// parseSrvr reads the latencies and request count of a srvr answer like
+//
+//	Latency min/avg/max: 0/0.4417/37
+//	Received: 20231
+func parseSrvr(res string) (*srvrSample, error) {
+	s := &srvrSample{}
+	hasLatency, hasReceived := false, false
+	for _, line := range strings.Split(res, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Latency min/avg/max":
+			parts := strings.Split(value, "/")
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid srvr latency: %q", value)
+			}
+			var vs [3]float64
+			for i, p := range parts {
+				v, err := strconv.ParseFloat(p, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid srvr latency: %q", value)
+				}
+				vs[i] = v
+			}
+			s.min, s.avg, s.max = vs[0], vs[1], vs[2]
+			hasLatency = true
+		case "Received":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid srvr received: %q", value)
+			}
+			s.received = v
+			hasReceived = true
+		}
+	}
+	if !hasLatency || !hasReceived {
+		return nil, fmt.Errorf("unexpected srvr response: %q", res)
+	}
+	return s, nil
+}
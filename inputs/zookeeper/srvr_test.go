@@ -0,0 +1,82 @@
+package zookeeper
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/netx"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestGatherSrvr(t *testing.T) {
+	answers := []string{
+		"Latency min/avg/max: 0/1/5\nReceived: 100\n",
+		"Latency min/avg/max: 0/2/5\nReceived: 200\n",
+		"Latency min/avg/max: 0/2/40\nReceived: 300\n",
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for _, answer := range answers {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 4)
+			conn.Read(buf)
+			fmt.Fprintf(conn, "Zookeeper version: 3.6.3\n%sMode: standalone\n", answer)
+			conn.Close()
+		}
+	}()
+
+	ins := &Instance{
+		Addresses:          ln.Addr().String(),
+		SrvrSamples:        3,
+		SrvrSampleInterval: config.Duration(time.Millisecond),
+	}
+	require.NoError(t, ins.Init())
+
+	slist := types.NewSampleList()
+	ins.gatherSrvr(slist, netx.Target{Origin: ins.Addresses, Address: ins.Addresses}, map[string]string{"zk_host": ins.Addresses})
+
+	values := map[string]interface{}{}
+	buckets := map[string]interface{}{}
+	for _, s := range slist.PopBackAll() {
+		if s.Metric == "zk_srvr_interval_latency_bucket" {
+			buckets[s.Labels["le"]] = s.Value
+			continue
+		}
+		values[s.Metric] = s.Value
+	}
+	require.Equal(t, 3, values["zk_srvr_samples"])
+	require.Equal(t, 2.5, values["zk_srvr_latency_avg_delta"])
+	require.Equal(t, 35.0, values["zk_srvr_latency_max_delta"])
+	require.Equal(t, 2.0, values["zk_srvr_interval_latency_min"])
+	require.Equal(t, 3.0, values["zk_srvr_interval_latency_max"])
+	require.Greater(t, values["zk_srvr_request_rate"], 0.0)
+	require.EqualValues(t, 1, buckets["2"])
+	require.EqualValues(t, 2, buckets["5"])
+	require.EqualValues(t, 2, buckets["+Inf"])
+}
+
+func TestParseSrvr(t *testing.T) {
+	_, err := parseSrvr("srvr is not executed because it is not in the whitelist.")
+	require.Error(t, err)
+}
+
+func TestPruneSrvr(t *testing.T) {
+	ins := &Instance{Addresses: "zk-headless:2181", SrvrSamples: 1}
+	require.NoError(t, ins.Init())
+	ins.srvr.states["10.0.0.1:2181"] = &srvrState{}
+	ins.srvr.states["10.0.0.2:2181"] = &srvrState{}
+
+	ins.pruneSrvr([]netx.Target{{Origin: ins.Addresses, Address: "10.0.0.2:2181"}, {Origin: ins.Addresses, Address: "10.0.0.3:2181"}})
+	require.Len(t, ins.srvr.states, 1)
+	require.Contains(t, ins.srvr.states, "10.0.0.2:2181")
+}
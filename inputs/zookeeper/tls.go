@@ -0,0 +1,109 @@
+package zookeeper
+
+import (
+	crypto_tls "crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+
+	"flashcat.cloud/categraf/pkg/netx"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+// HostTLS overrides the tls options of the instance for one of its addresses,
+// e.g. for members presenting distinct certificates behind a shared vip
+type HostTLS struct {
+	// one of the addresses of the instance, as written there
+	Address    string `toml:"address"`
+	ServerName string `toml:"tls_server_name"`
+	TLSCA      string `toml:"tls_ca"`
+	TLSCert    string `toml:"tls_cert"`
+	TLSKey     string `toml:"tls_key"`
+	TLSKeyPwd  string `toml:"tls_key_pwd"`
+	DisableSNI *bool  `toml:"tls_disable_sni"`
+}
+
+func (ins *Instance) initHostTLS() error {
+	hosts := make(map[string]struct{})
+	for _, host := range ins.ZkHosts() {
+		hosts[host] = struct{}{}
+	}
+
+	ins.hostTLS = make(map[string]*HostTLS, len(ins.HostTLS))
+	for _, h := range ins.HostTLS {
+		if _, has := hosts[h.Address]; !has {
+			return fmt.Errorf("host_tls address %q is not one of addresses", h.Address)
+		}
+		if (h.TLSCert == "") != (h.TLSKey == "") {
+			return fmt.Errorf("host_tls of %s: tls_cert and tls_key must be set together", h.Address)
+		}
+		ins.hostTLS[h.Address] = h
+	}
+	return nil
+}
+
+// tlsConfig builds the client tls config of target, with the overrides of its
+// address applied
+func (ins *Instance) tlsConfig(target netx.Target) (*crypto_tls.Config, error) {
+	cc := ins.ClientConfig
+	disableSNI := ins.DisableSNI
+	if h, has := ins.hostTLS[target.Origin]; has {
+		if h.ServerName != "" {
+			cc.ServerName = h.ServerName
+		}
+		if h.TLSCA != "" {
+			cc.TLSCA = h.TLSCA
+		}
+		if h.TLSCert != "" {
+			cc.TLSCert, cc.TLSKey, cc.TLSKeyPwd = h.TLSCert, h.TLSKey, h.TLSKeyPwd
+		}
+		if h.DisableSNI != nil {
+			disableSNI = *h.DisableSNI
+		}
+	}
+
+	tlsConfig, err := cc.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	// expanded targets are dialed by ip, verify against the configured name
+	if tlsConfig.ServerName == "" && net.ParseIP(target.Host) == nil {
+		tlsConfig.ServerName = target.Host
+	}
+	if disableSNI {
+		verifyWithoutSNI(tlsConfig, &cc, target)
+	}
+	return tlsConfig, nil
+}
+
+// verifyWithoutSNI keeps the server name out of the ClientHello, crypto/tls only
+// omits it with an empty ServerName, so the certificate is verified by hand
+func verifyWithoutSNI(cfg *crypto_tls.Config, cc *tls.ClientConfig, target netx.Target) {
+	name := cfg.ServerName
+	if name == "" {
+		name = target.Host
+	}
+	cfg.ServerName = ""
+	if cc.InsecureSkipVerify {
+		return
+	}
+
+	cfg.InsecureSkipVerify = true
+	roots := cfg.RootCAs
+	cfg.VerifyConnection = func(cs crypto_tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("no certificate presented by server")
+		}
+		opts := x509.VerifyOptions{
+			DNSName:       name,
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+package zookeeper
+
+import (
+	crypto_tls "crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/pkg/netx"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+func TestHostTLSOverride(t *testing.T) {
+	disable := true
+	ins := &Instance{
+		Addresses:    "zk1:2181 zk2:2181",
+		ClientConfig: tls.ClientConfig{UseTLS: true, ServerName: "zk.example.com"},
+		HostTLS:      []*HostTLS{{Address: "zk2:2181", ServerName: "zk2.example.com", DisableSNI: &disable}},
+	}
+	require.NoError(t, ins.Init())
+
+	cfg, err := ins.tlsConfig(netx.Target{Origin: "zk1:2181", Host: "zk1"})
+	require.NoError(t, err)
+	require.Equal(t, "zk.example.com", cfg.ServerName)
+
+	cfg, err = ins.tlsConfig(netx.Target{Origin: "zk2:2181", Host: "zk2"})
+	require.NoError(t, err)
+	require.Equal(t, "", cfg.ServerName)
+	require.NotNil(t, cfg.VerifyConnection)
+
+	ins.HostTLS = []*HostTLS{{Address: "zk3:2181"}}
+	require.Error(t, ins.Init())
+}
+
+func TestVerifyWithoutSNI(t *testing.T) {
+	var sni string
+	srv := httptest.NewUnstartedServer(nil)
+	srv.TLS = &crypto_tls.Config{GetConfigForClient: func(hello *crypto_tls.ClientHelloInfo) (*crypto_tls.Config, error) {
+		sni = hello.ServerName
+		return nil, nil
+	}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	handshake := func(name string) error {
+		// the test certificate is valid for example.com
+		cfg := &crypto_tls.Config{RootCAs: roots, ServerName: name}
+		verifyWithoutSNI(cfg, &tls.ClientConfig{}, netx.Target{})
+		conn, err := crypto_tls.Dial("tcp", srv.Listener.Addr().String(), cfg)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	require.NoError(t, handshake("example.com"))
+	require.Equal(t, "", sni)
+	require.Error(t, handshake("other.example.org"))
+}
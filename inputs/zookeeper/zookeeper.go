@@ -1,6 +1,7 @@
 package zookeeper
 
 import (
+	"context"
 	crypto_tls "crypto/tls"
 	"fmt"
 	"io"
@@ -14,12 +15,14 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/netx"
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 )
 
 const (
 	inputName                 = "zookeeper"
+	defaultPort               = "2181"
 	commandNotAllowedTmpl     = "E!: %q command isn't allowed at %q, see '4lw.commands.whitelist' ZK config parameter\n"
 	instanceNotServingMessage = "This ZooKeeper instance is not currently serving requests"
 	cmdNotExecutedSffx        = "is not executed because it is not in the whitelist."
@@ -37,6 +40,20 @@ type Instance struct {
 	Timeout     int    `toml:"timeout"`
 	ClusterName string `toml:"cluster_name"`
 	tls.ClientConfig
+	// send no server name in the tls handshake, the certificate is verified anyway
+	DisableSNI bool       `toml:"tls_disable_sni"`
+	HostTLS    []*HostTLS `toml:"host_tls"`
+	netx.DialConfig
+	netx.ExpandConfig
+
+	// sample srvr that many times per gather, srvr_sample_interval apart, 0 disables it
+	SrvrSamples        int             `toml:"srvr_samples"`
+	SrvrSampleInterval config.Duration `toml:"srvr_sample_interval"`
+	// upper bounds in milliseconds of zk_srvr_interval_latency_bucket
+	SrvrLatencyBuckets []float64 `toml:"srvr_latency_buckets"`
+
+	hostTLS map[string]*HostTLS
+	srvr    srvrSampler
 }
 
 func (ins *Instance) ZkHosts() []string {
@@ -44,20 +61,39 @@ func (ins *Instance) ZkHosts() []string {
 }
 
 func (ins *Instance) ZkConnect(host string) (net.Conn, error) {
-	dialer := net.Dialer{Timeout: time.Duration(ins.Timeout) * time.Second}
-	tcpaddr, err := net.ResolveTCPAddr("tcp", host)
+	zkHost, zkPort, err := netx.SplitHostPort(host, defaultPort)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve zookeeper(cluster: %s) address: %s: %v", ins.ClusterName, host, err)
+		return nil, fmt.Errorf("failed to parse zookeeper(cluster: %s) address: %s: %v", ins.ClusterName, host, err)
 	}
+	return ins.zkConnect(netx.Target{Origin: host, Host: zkHost, Address: net.JoinHostPort(zkHost, zkPort)})
+}
+
+func (ins *Instance) zkConnect(target netx.Target) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: time.Duration(ins.Timeout) * time.Second}
 
+	ctx, cancel := context.WithTimeout(context.Background(), dialer.Timeout)
+	defer cancel()
+
+	// dial by name, so every A/AAAA record of the host can be tried
+	conn, err := ins.DialContext(ctx, dialer, "tcp", target.Address)
+	if err != nil {
+		return nil, err
+	}
 	if !ins.UseTLS {
-		return dialer.Dial("tcp", tcpaddr.String())
+		return conn, nil
 	}
-	tlsConfig, err := ins.TLSConfig()
+
+	tlsConfig, err := ins.tlsConfig(target)
 	if err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to init tls config: %v", err)
 	}
-	return crypto_tls.DialWithDialer(&dialer, "tcp", tcpaddr.String(), tlsConfig)
+	tlsConn := crypto_tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
 type Zookeeper struct {
@@ -91,6 +127,15 @@ func (ins *Instance) Init() error {
 	if len(ins.ZkHosts()) == 0 {
 		return types.ErrInstancesEmpty
 	}
+	if err := ins.CheckDialConfig(); err != nil {
+		return err
+	}
+	if err := ins.initHostTLS(); err != nil {
+		return err
+	}
+	if err := ins.initSrvr(); err != nil {
+		return err
+	}
 	// set default timeout
 	if ins.Timeout == 0 {
 		ins.Timeout = 10
@@ -104,15 +149,20 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		return
 	}
 
+	// resolved every gather, so members added behind a headless service are picked up
+	targets := ins.ExpandTargets(hosts, defaultPort)
+	ins.pruneSrvr(targets)
+
 	wg := new(sync.WaitGroup)
-	for i := 0; i < len(hosts); i++ {
+	for i := 0; i < len(targets); i++ {
 		wg.Add(1)
-		go ins.gatherOneHost(wg, slist, hosts[i])
+		go ins.gatherOneHost(wg, slist, targets[i])
 	}
 	wg.Wait()
 }
 
-func (ins *Instance) gatherOneHost(wg *sync.WaitGroup, slist *types.SampleList, zkHost string) {
+func (ins *Instance) gatherOneHost(wg *sync.WaitGroup, slist *types.SampleList, target netx.Target) {
+	zkHost := target.Origin
 	defer wg.Done()
 	defer func() {
 		if r := recover(); r != nil {
@@ -121,6 +171,9 @@ func (ins *Instance) gatherOneHost(wg *sync.WaitGroup, slist *types.SampleList,
 	}()
 
 	tags := map[string]string{"zk_host": zkHost, "zk_cluster": ins.ClusterName}
+	if target.IP != "" {
+		tags["resolved_ip"] = target.IP
+	}
 	begun := time.Now()
 
 	// scrape use seconds
@@ -130,10 +183,11 @@ func (ins *Instance) gatherOneHost(wg *sync.WaitGroup, slist *types.SampleList,
 	}(begun)
 
 	// zk_up
-	mntrConn, err := ins.ZkConnect(zkHost)
+	mntrConn, err := ins.zkConnect(target)
 	if err != nil {
 		slist.PushFront(types.NewSample("", "zk_up", 0, tags))
 		log.Println("E! failed to connect zookeeper:", zkHost, "error:", err)
+		inputs.PushGatherError(slist, zkHost, err)
 		return
 	}
 
@@ -143,11 +197,16 @@ func (ins *Instance) gatherOneHost(wg *sync.WaitGroup, slist *types.SampleList,
 
 	ins.gatherMntrResult(mntrConn, slist, tags)
 
+	if ins.SrvrSamples > 0 {
+		ins.gatherSrvr(slist, target, tags)
+	}
+
 	// zk_ruok
-	ruokConn, err := ins.ZkConnect(zkHost)
+	ruokConn, err := ins.zkConnect(target)
 	if err != nil {
 		slist.PushFront(types.NewSample("", "zk_ruok", 0, tags))
 		log.Println("E! failed to connect zookeeper:", zkHost, "error:", err)
+		inputs.PushGatherError(slist, zkHost, err)
 		return
 	}
 
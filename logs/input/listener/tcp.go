@@ -115,11 +115,14 @@ func (l *TCPListener) run() {
 
 // startListener starts a new listener, returns an error if it failed.
 func (l *TCPListener) startListener() error {
+	if err := l.source.Config.InitServerConfig(); err != nil {
+		return err
+	}
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", l.source.Config.Port))
 	if err != nil {
 		return err
 	}
-	l.listener = listener
+	l.listener = l.source.Config.WrapListener(listener)
 	return nil
 }
 
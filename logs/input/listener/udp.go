@@ -76,6 +76,9 @@ func (l *UDPListener) startNewTailer() error {
 // newUDPConnection returns a new UDP connection,
 // returns an error if the creation failed.
 func (l *UDPListener) newUDPConnection() (net.Conn, error) {
+	if err := l.source.Config.InitIPFilter(); err != nil {
+		return nil, err
+	}
 	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", l.source.Config.Port))
 	if err != nil {
 		return nil, err
@@ -86,7 +89,7 @@ func (l *UDPListener) newUDPConnection() (net.Conn, error) {
 // read reads data from the tailer connection, returns an error if it failed and reset the tailer.
 func (l *UDPListener) read(tailer *Tailer) ([]byte, error) {
 	frame := make([]byte, l.frameSize+1)
-	n, err := tailer.conn.Read(frame)
+	n, err := l.readFrame(tailer, frame)
 	switch {
 	case err != nil && isClosedConnError(err):
 		return nil, err
@@ -107,6 +110,20 @@ func (l *UDPListener) read(tailer *Tailer) ([]byte, error) {
 	}
 }
 
+// readFrame reads one datagram, datagrams from addresses not in allowed_ips are discarded.
+func (l *UDPListener) readFrame(tailer *Tailer, frame []byte) (int, error) {
+	udpConn, ok := tailer.conn.(*net.UDPConn)
+	if !ok || len(l.source.Config.AllowedIPs) == 0 {
+		return tailer.conn.Read(frame)
+	}
+	for {
+		n, addr, err := udpConn.ReadFromUDP(frame)
+		if err != nil || l.source.Config.AllowIP(addr.IP) {
+			return n, err
+		}
+	}
+}
+
 // resetTailer creates a new tailer.
 func (l *UDPListener) resetTailer() {
 	log.Printf("Resetting the UDP connection on port: %d\n", l.source.Config.Port)
@@ -24,12 +24,15 @@ import (
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/heartbeat"
 	"flashcat.cloud/categraf/pkg/osx"
+	"flashcat.cloud/categraf/pkg/state"
 	"flashcat.cloud/categraf/writer"
 )
 
 var (
 	appPath      string
 	configDir    = flag.String("configs", osx.GetEnv("CATEGRAF_CONFIGS", "conf"), "Specify configuration directory.(env:CATEGRAF_CONFIGS)")
+	configBundle = flag.String("config-bundle", osx.GetEnv("CATEGRAF_CONFIG_BUNDLE", ""), "Load all configuration from one .tar.gz or .toml bundle instead of -configs.(env:CATEGRAF_CONFIG_BUNDLE)")
+	bundleSum    = flag.String("config-bundle-sha256", "", "Expected sha256 of -config-bundle, defaults to the content of <bundle>.sha256 if it exists")
 	debugMode    = flag.Bool("debug", false, "Is debug mode?")
 	debugLevel   = flag.Int("debug-level", 0, "debug level")
 	testMode     = flag.Bool("test", false, "Is test mode? print metrics to stdout")
@@ -77,6 +80,16 @@ func initLog(output string) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(serviceCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "list-metrics" {
+		os.Exit(listMetricsCommand(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	if *showVersion {
@@ -91,6 +104,15 @@ func main() {
 		return
 	}
 
+	if *configBundle != "" {
+		sum, err := config.ExtractBundle(*configBundle, *bundleSum, bundleDir)
+		if err != nil {
+			log.Fatalln("F! failed to load config bundle:", err)
+		}
+		log.Println("I! config bundle:", *configBundle, "sha256:", sum)
+		*configDir = bundleDir
+	}
+
 	// init configs
 	if err := config.InitConfig(*configDir, *debugLevel, *debugMode, *testMode, *interval, *inputFilters); err != nil {
 		log.Fatalln("F! failed to init config:", err)
@@ -98,6 +120,7 @@ func main() {
 
 	doOSsvc()
 	printEnv()
+	state.SetPath(config.Config.Global.StateFile)
 
 	initWriters()
 
@@ -113,6 +136,9 @@ func main() {
 	runAgent(ag)
 }
 
+// -config-bundle is unpacked here, relative to the binary dir
+const bundleDir = "bundle"
+
 func initWriters() {
 	if err := writer.InitWriters(); err != nil {
 		log.Fatalln("F! failed to init writer:", err)
@@ -140,7 +166,8 @@ EXIT:
 		}
 	}
 
-	ag.Stop()
+	ag.Shutdown()
+	state.Close()
 	log.Println("I! exited")
 }
 
@@ -162,149 +189,58 @@ func (p *program) Stop(s service.Service) error {
 	return nil
 }
 
+// serviceProcess handles the legacy -install/-remove/-start/-stop/-status/-update flags
 func serviceProcess() error {
-	svcConfig := agentInstall.ServiceConfig()
-	prg := &program{}
-	s, err := service.New(prg, svcConfig)
-	if err != nil {
-		fmt.Println("generate categraf service error " + err.Error())
-		return nil
+	switch {
+	case *install:
+		return controlService("install", agentInstall.Options{})
+	case *remove:
+		return controlService("uninstall", agentInstall.Options{})
+	case *start:
+		return controlService("start", agentInstall.Options{})
+	case *stop:
+		return controlService("stop", agentInstall.Options{})
+	case *status:
+		return controlService("status", agentInstall.Options{})
+	case *update:
+		return updateService()
 	}
+	return nil
+}
 
-	if *stop {
-		if sts, err := s.Status(); err != nil {
-			log.Println("W! show categraf service status failed:", err)
-		} else {
-			switch sts {
-			case service.StatusRunning:
-				log.Println("I! categraf service status: running")
-			case service.StatusStopped:
-				log.Println("I! categraf service status: stopped")
-			default:
-				log.Println("I! categraf service status: unknown")
-			}
-		}
-		if err := s.Stop(); err != nil {
-			log.Println("E! stop categraf service failed:", err)
-		} else {
-			log.Println("I! stop categraf service ok")
-		}
-		return nil
+func updateService() error {
+	if *updateFile == "" {
+		return fmt.Errorf("please input update_url")
 	}
-
-	if *remove {
-		if sts, err := s.Status(); err != nil {
-			log.Println("W! show categraf service status failed:", err)
-		} else {
-			switch sts {
-			case service.StatusRunning:
-				log.Println("I! categraf service status: running")
-			case service.StatusStopped:
-				log.Println("I! categraf service status: stopped")
-			default:
-				log.Println("I! categraf service status: unknown")
-			}
-		}
-		if err := s.Stop(); err != nil {
-			log.Println("W! stop categraf service failed:", err)
-		} else {
-			log.Println("I! stop categraf service ok")
-		}
-		if err := s.Uninstall(); err != nil {
-			log.Println("E! remove categraf service failed:", err)
-		} else {
-			log.Println("I! remove categraf service ok")
-		}
+	s, err := newService(agentInstall.Options{})
+	if err != nil {
 		return nil
 	}
-
-	if *install {
-		if sts, err := s.Status(); err != nil {
-			log.Println("W! show categraf service status failed:", err)
-		} else {
-			switch sts {
-			case service.StatusRunning:
-				log.Println("I! categraf service status: running")
-			case service.StatusStopped:
-				log.Println("I! categraf service status: stopped")
-			default:
-				log.Println("I! categraf service status: unknown")
-			}
-		}
-		if err := s.Install(); err != nil {
-			log.Println("E! install categraf service failed:", err)
-		} else {
-			log.Println("I! install categraf service ok")
+	if sts, err := s.Status(); err != nil {
+		if strings.Contains(err.Error(), "not installed") {
+			log.Println("E! update only support mode that running in service mode")
 		}
 		return nil
-	}
-
-	if *start {
-		if sts, err := s.Status(); err != nil {
-			log.Println("W! show categraf service status failed:", err)
-		} else {
-			switch sts {
-			case service.StatusRunning:
-				log.Println("I! categraf service status: running")
-			case service.StatusStopped:
-				log.Println("I! categraf service status: stopped")
-			default:
-				log.Println("I! categraf service status: unknown")
-			}
-		}
-		if err := s.Start(); err != nil {
-			log.Println("E! start categraf service failed:", err)
-		} else {
-			log.Println("I! start categraf service ok")
+	} else {
+		switch sts {
+		case service.StatusRunning:
+			log.Println("I! categraf service status: running, version:", config.Version)
+		case service.StatusStopped:
+			log.Println("I! categraf service status: stopped, version:", config.Version)
+		default:
+			log.Println("I! categraf service status: unknown, version:", config.Version)
 		}
-		return nil
 	}
-	if *status {
-		if sts, err := s.Status(); err != nil {
-			log.Println("E! show categraf service status failed:", err)
-		} else {
-			switch sts {
-			case service.StatusRunning:
-				log.Println("I! show categraf service status: running")
-			case service.StatusStopped:
-				log.Println("I! show categraf service status: stopped")
-			default:
-				log.Println("I! show categraf service status: unknown")
-			}
-		}
-
+	err = agentUpdate.Update(*updateFile)
+	if err != nil {
+		log.Println("E! update categraf failed:", err)
 		return nil
 	}
-	if *update {
-		if *updateFile == "" {
-			return fmt.Errorf("please input update_url")
-		}
-		if sts, err := s.Status(); err != nil {
-			if strings.Contains(err.Error(), "not installed") {
-				log.Println("E! update only support mode that running in service mode")
-			}
-			return nil
-		} else {
-			switch sts {
-			case service.StatusRunning:
-				log.Println("I! categraf service status: running, version:", config.Version)
-			case service.StatusStopped:
-				log.Println("I! categraf service status: stopped, version:", config.Version)
-			default:
-				log.Println("I! categraf service status: unknown, version:", config.Version)
-			}
-		}
-		err := agentUpdate.Update(*updateFile)
-		if err != nil {
-			log.Println("E! update categraf failed:", err)
-			return nil
-		}
-		err = s.Restart()
-		if err != nil {
-			log.Println("E! restart categraf failed:", err)
-			return nil
-		}
-		log.Println("I! update categraf success")
+	err = s.Restart()
+	if err != nil {
+		log.Println("E! restart categraf failed:", err)
+		return nil
 	}
+	log.Println("I! update categraf success")
 	return nil
 }
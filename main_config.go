@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"flashcat.cloud/categraf/agent"
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/osx"
+)
+
+const configUsage = `Usage: categraf config check [options]
+       categraf config pack [options]
+
+check parses config.toml and every input.<name> directory under the config directory,
+reports type errors, missing required fields, invalid filters/relabel configs and unknown
+keys, and exits non-zero on any error so it can gate CI pipelines.
+
+pack writes the config directory as one .tar.gz bundle for -config-bundle, plus
+<bundle>.sha256 next to it.
+
+Options:
+`
+
+// configCommand implements `categraf config ...`, returns the process exit code
+func configCommand(args []string) int {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configs := fs.String("configs", osx.GetEnv("CATEGRAF_CONFIGS", "conf"), "configuration directory, relative paths are resolved against the binary dir like the agent does.(env:CATEGRAF_CONFIGS)")
+	strict := fs.Bool("strict", false, "treat unknown keys and unsupported inputs as errors")
+	dump := fs.Bool("dump", false, "print the effective config (defaults applied) as json")
+	output := fs.String("o", "categraf-conf.tar.gz", "bundle written by pack")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), configUsage)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 || (args[0] != "check" && args[0] != "pack") {
+		fs.Usage()
+		return 2
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if args[0] == "pack" {
+		sum, err := packBundle(*configs, *output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to pack config bundle:", err)
+			return 1
+		}
+		fmt.Printf("%s  %s\n", sum, *output)
+		return 0
+	}
+
+	checker := &agent.ConfigChecker{
+		ConfigDir: *configs,
+		Strict:    *strict,
+		Dump:      *dump,
+		Out:       os.Stdout,
+	}
+	if !checker.Check() {
+		return 1
+	}
+	return 0
+}
+
+// packBundle writes the bundle and its checksum file, returns the checksum
+func packBundle(dir, output string) (string, error) {
+	var buf bytes.Buffer
+	if err := config.PackBundle(dir, &buf); err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(buf.Bytes())
+	sum := hex.EncodeToString(digest[:])
+
+	if err := os.WriteFile(output, buf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(output+".sha256", []byte(sum+"  "+filepath.Base(output)+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const listMetricsUsage = `Usage: categraf list-metrics [options] [plugin...]
+
+Prints the metrics, types, units and tags the given plugins can emit.
+Without plugin, lists the plugins that describe their metrics.
+
+Options:
+`
+
+// listMetricsCommand implements `categraf list-metrics ...`, returns the process exit code
+func listMetricsCommand(args []string) int {
+	fs := flag.NewFlagSet("list-metrics", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format, text or json")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), listMetricsUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		for name, creator := range inputs.InputCreators {
+			if _, ok := inputs.MayDescribeMetrics(creator()); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		fmt.Println(strings.Join(names, "\n"))
+		return 0
+	}
+
+	descs := make(map[string][]types.MetricDesc, len(names))
+	for _, name := range names {
+		creator, has := inputs.InputCreators[name]
+		if !has {
+			fmt.Fprintln(os.Stderr, "input", name, "not supported")
+			return 1
+		}
+		ms, ok := inputs.MayDescribeMetrics(creator())
+		if !ok {
+			fmt.Fprintln(os.Stderr, "input", name, "does not describe its metrics")
+			return 1
+		}
+		descs[name] = ms
+	}
+
+	switch *format {
+	case "json":
+		json := jsoniter.ConfigCompatibleWithStandardLibrary
+		bs, err := json.MarshalIndent(descs, "", "    ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(bs))
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for i, name := range names {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "# %s\n", name)
+			fmt.Fprintln(w, "NAME\tTYPE\tUNIT\tTAGS\tHELP")
+			for _, m := range descs[name] {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Name, m.Type.String(), m.Unit, strings.Join(m.Tags, ","), m.Help)
+			}
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, "unknown format:", *format)
+		return 2
+	}
+	return 0
+}
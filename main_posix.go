@@ -3,9 +3,11 @@
 package main
 
 import (
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"flashcat.cloud/categraf/agent"
 	"flashcat.cloud/categraf/config"
@@ -22,18 +24,31 @@ func runAgent(ag *agent.Agent) {
 func doOSsvc() {
 }
 
-var (
-	pprofStart uint32
-)
+// how long SIGUSR1 and SIGUSR2 switch on debug logging and pprof
+const signalDebugTTL = 30 * time.Minute
 
+// profile toggles debug logging of all inputs on SIGUSR1 and pprof on SIGUSR2,
+// both switch off by themselves after signalDebugTTL
 func profile() {
 	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGUSR2)
+	signal.Notify(sc, syscall.SIGUSR1, syscall.SIGUSR2)
 	for {
 		sig := <-sc
 		switch sig {
+		case syscall.SIGUSR1:
+			if _, on := config.InputDebugStatus()[config.AllInputs]; on {
+				config.ClearInputDebug(config.AllInputs)
+				log.Println("I! debug logging of all inputs switched off")
+			} else {
+				until := config.SetInputDebug(config.AllInputs, signalDebugTTL)
+				log.Println("I! debug logging of all inputs switched on until", until.Format(time.RFC3339))
+			}
 		case syscall.SIGUSR2:
-			go pprof.Go()
+			if pprof.GetStatus().Running {
+				pprof.Stop()
+			} else if _, err := pprof.Start(signalDebugTTL); err != nil {
+				log.Println("E! failed to start pprof:", err)
+			}
 		}
 	}
 }
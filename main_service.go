@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kardianos/service"
+
+	agentInstall "flashcat.cloud/categraf/agent/install"
+)
+
+const serviceUsage = `Usage: categraf service <install|uninstall|start|stop|restart|status> [options]
+
+Registers categraf as a windows service, or a systemd unit (sysv script if systemd
+is absent) on linux. Options only take effect on install.
+
+Options:
+`
+
+// serviceCommand implements `categraf service ...`, returns the process exit code
+func serviceCommand(args []string) int {
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	configs := fs.String("configs", "", "configuration directory passed to the service, default is conf under the binary dir")
+	delayedStart := fs.Bool("delayed-start", false, "windows: delayed auto start; systemd: wait start-delay(default 30s) before starting")
+	startDelay := fs.Duration("start-delay", 0, "systemd: sleep before starting the agent, e.g. 30s")
+	stopTimeout := fs.Duration("stop-timeout", 30*time.Second, "systemd: time allowed for a graceful shutdown before the agent is killed")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), serviceUsage)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	opts := agentInstall.Options{
+		ConfigDir:    *configs,
+		DelayedStart: *delayedStart,
+		StartDelay:   *startDelay,
+		StopTimeout:  *stopTimeout,
+	}
+	if opts.ConfigDir != "" && !filepath.IsAbs(opts.ConfigDir) {
+		// the service manager doesn't start us in the current dir
+		if abs, err := filepath.Abs(opts.ConfigDir); err == nil {
+			opts.ConfigDir = abs
+		}
+	}
+
+	switch action {
+	case "install", "uninstall", "remove", "start", "stop", "restart", "status":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown service action: %s\n\n", action)
+		fs.Usage()
+		return 2
+	}
+
+	if err := controlService(action, opts); err != nil {
+		log.Println("E!", err)
+		return 1
+	}
+	return 0
+}
+
+func newService(opts agentInstall.Options) (service.Service, error) {
+	s, err := service.New(&program{}, agentInstall.ServiceConfig(opts))
+	if err != nil {
+		fmt.Println("generate categraf service error " + err.Error())
+		return nil, err
+	}
+	return s, nil
+}
+
+func logServiceStatus(s service.Service, prefix string) {
+	sts, err := s.Status()
+	if err != nil {
+		log.Println("W! show categraf service status failed:", err)
+		return
+	}
+	switch sts {
+	case service.StatusRunning:
+		log.Println("I!", prefix, "status: running")
+	case service.StatusStopped:
+		log.Println("I!", prefix, "status: stopped")
+	default:
+		log.Println("I!", prefix, "status: unknown")
+	}
+}
+
+func controlService(action string, opts agentInstall.Options) error {
+	s, err := newService(opts)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "status":
+		logServiceStatus(s, "show categraf service")
+		return nil
+	case "install":
+		logServiceStatus(s, "categraf service")
+		if err := s.Install(); err != nil {
+			return fmt.Errorf("install categraf service failed: %v", err)
+		}
+		log.Println("I! install categraf service ok")
+	case "uninstall", "remove":
+		logServiceStatus(s, "categraf service")
+		if err := s.Stop(); err != nil {
+			log.Println("W! stop categraf service failed:", err)
+		} else {
+			log.Println("I! stop categraf service ok")
+		}
+		if err := s.Uninstall(); err != nil {
+			return fmt.Errorf("remove categraf service failed: %v", err)
+		}
+		log.Println("I! remove categraf service ok")
+	case "start":
+		logServiceStatus(s, "categraf service")
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("start categraf service failed: %v", err)
+		}
+		log.Println("I! start categraf service ok")
+	case "stop":
+		logServiceStatus(s, "categraf service")
+		if err := s.Stop(); err != nil {
+			return fmt.Errorf("stop categraf service failed: %v", err)
+		}
+		log.Println("I! stop categraf service ok")
+	case "restart":
+		if err := s.Restart(); err != nil {
+			return fmt.Errorf("restart categraf service failed: %v", err)
+		}
+		log.Println("I! restart categraf service ok")
+	}
+	return nil
+}
@@ -38,7 +38,7 @@ func runAgent(ag *agent.Agent) {
 			initLog(config.Config.Log.FileName)
 		}
 
-		if err := winsvc.RunAsService(*flagWinSvcName, ag.Start, ag.Stop, false); err != nil {
+		if err := winsvc.RunAsService(*flagWinSvcName, ag.Start, ag.Shutdown, false); err != nil {
 			log.Fatalln("F! failed to run windows service:", err)
 		}
 		return
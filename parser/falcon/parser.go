@@ -3,7 +3,9 @@ package falcon
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
+	"flashcat.cloud/categraf/parser"
 	"flashcat.cloud/categraf/types"
 )
 
@@ -29,10 +31,18 @@ type Sample struct {
 	Timestamp int64       `json:"timestamp"`
 	Value     interface{} `json:"value"`
 	Tags      string      `json:"tags"`
+	// GAUGE or COUNTER
+	CounterType string `json:"counterType"`
 }
 
 type Parser struct{}
 
+func init() {
+	parser.Add("falcon", func() parser.Parser {
+		return NewParser()
+	})
+}
+
 func NewParser() *Parser {
 	return &Parser{}
 }
@@ -76,7 +86,22 @@ func (p *Parser) Parse(input []byte, slist *types.SampleList) error {
 			labels["endpoint"] = endpoint
 		}
 
-		slist.PushSample("", samples[i].Metric, samples[i].Value, labels)
+		sample := types.NewSample("", samples[i].Metric, samples[i].Value, labels)
+		if ts := samples[i].Timestamp; ts > 0 {
+			// if timestamp bigger than 32 bits, likely in milliseconds
+			if ts > 0xffffffff {
+				sample.SetTime(time.UnixMilli(ts))
+			} else {
+				sample.SetTime(time.Unix(ts, 0))
+			}
+		}
+		switch strings.ToUpper(samples[i].CounterType) {
+		case "GAUGE":
+			sample.SetType(types.Gauge)
+		case "COUNTER":
+			sample.SetType(types.Counter)
+		}
+		slist.PushFront(sample)
 	}
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"flashcat.cloud/categraf/parser"
 	"flashcat.cloud/categraf/types"
 	"flashcat.cloud/categraf/types/metric"
 	"github.com/influxdata/line-protocol/v2/lineprotocol"
@@ -19,6 +20,12 @@ type Parser struct {
 
 type TimeFunc func() time.Time
 
+func init() {
+	parser.Add("influx", func() parser.Parser {
+		return NewParser()
+	})
+}
+
 // NewParser returns a Parser that accepts a measurement and tagset
 func NewParser() *Parser {
 	return &Parser{
@@ -45,7 +52,7 @@ func (p *Parser) Parse(input []byte, slist *types.SampleList) error {
 		tags := m.Tags()
 		fields := m.Fields()
 		for k, v := range fields {
-			slist.PushSample(name, k, v, tags)
+			slist.PushFront(types.NewSample(name, k, v, tags).SetTime(m.Time()))
 		}
 	}
 
@@ -9,6 +9,7 @@ import (
 
 	dto "github.com/prometheus/client_model/go"
 
+	"flashcat.cloud/categraf/parser"
 	"flashcat.cloud/categraf/pkg/filter"
 	util "flashcat.cloud/categraf/pkg/metrics"
 	"flashcat.cloud/categraf/types"
@@ -27,6 +28,12 @@ type Parser struct {
 	DuplicationAllowed    bool
 }
 
+func init() {
+	parser.Add("prometheus", func() parser.Parser {
+		return EmptyParser()
+	})
+}
+
 func NewParser(namePrefix string, defaultTags map[string]string, header http.Header,
 	duplicationAllowed bool, ignoreMetricsFilter, ignoreLabelKeysFilter filter.Filter) *Parser {
 	return &Parser{
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Creator func() Parser
+
+var Parsers = map[string]Creator{}
+
+// Add registers a parser of data_format name, parsers register themselves in init
+func Add(name string, creator Creator) {
+	Parsers[name] = creator
+}
+
+// New returns a parser of data_format, empty means influx and prom* means prometheus
+func New(format string) (Parser, error) {
+	switch {
+	case format == "":
+		format = "influx"
+	case strings.HasPrefix(format, "prom"):
+		format = "prometheus"
+	}
+	creator, has := Parsers[format]
+	if !has {
+		return nil, fmt.Errorf("data_format(%s) not supported", format)
+	}
+	return creator(), nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPFilter restricts which remote addresses may talk to a listener.
+// Entries of AllowedIPs can be plain addresses or CIDR blocks, both IPv4 and IPv6.
+// An empty list allows everyone.
+type IPFilter struct {
+	AllowedIPs []string `toml:"allowed_ips" json:"allowed_ips" mapstructure:"allowed_ips"`
+
+	allowedNets []*net.IPNet
+}
+
+func (f *IPFilter) InitIPFilter() error {
+	f.allowedNets = f.allowedNets[:0]
+	for _, item := range f.AllowedIPs {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			_, ipnet, err := net.ParseCIDR(item)
+			if err != nil {
+				return fmt.Errorf("invalid allowed_ips entry %q: %v", item, err)
+			}
+			f.allowedNets = append(f.allowedNets, ipnet)
+			continue
+		}
+
+		ip := net.ParseIP(strings.Trim(item, "[]"))
+		if ip == nil {
+			return fmt.Errorf("invalid allowed_ips entry %q", item)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		f.allowedNets = append(f.allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nil
+}
+
+// AllowIP reports whether ip is permitted by the filter
+func (f *IPFilter) AllowIP(ip net.IP) bool {
+	if len(f.allowedNets) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range f.allowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowAddr is like AllowIP but accepts a host:port string such as http.Request.RemoteAddr
+func (f *IPFilter) AllowAddr(addr string) bool {
+	if len(f.allowedNets) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	// strip ipv6 zone, e.g. fe80::1%eth0
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return f.AllowIP(net.ParseIP(strings.Trim(host, "[]")))
+}
+
+// AllowConn checks the remote address of a connection
+func (f *IPFilter) AllowConn(conn net.Conn) bool {
+	if len(f.allowedNets) == 0 {
+		return true
+	}
+	switch addr := conn.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		return f.AllowIP(addr.IP)
+	case *net.UDPAddr:
+		return f.AllowIP(addr.IP)
+	default:
+		return f.AllowAddr(addr.String())
+	}
+}
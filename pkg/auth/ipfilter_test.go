@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		addr    string
+		want    bool
+	}{
+		{"empty allows all", nil, "10.0.0.1:1234", true},
+		{"blank entries allow all", []string{" ", ""}, "10.0.0.1:1234", true},
+		{"single ip", []string{"10.0.0.1"}, "10.0.0.1:1234", true},
+		{"single ip other", []string{"10.0.0.1"}, "10.0.0.2:1234", false},
+		{"cidr", []string{"192.168.0.0/16"}, "192.168.3.4:80", true},
+		{"cidr outside", []string{"192.168.0.0/16"}, "192.169.0.1:80", false},
+		{"several entries", []string{"10.0.0.1", "172.16.0.0/12"}, "172.31.255.255:80", true},
+		{"no port", []string{"10.0.0.1"}, "10.0.0.1", true},
+		{"garbage address", []string{"10.0.0.1"}, "not an address", false},
+		{"ipv6 single", []string{"::1"}, "[::1]:9100", true},
+		{"ipv6 single bracketed", []string{"[::1]"}, "[::1]:9100", true},
+		{"ipv6 single other", []string{"::1"}, "[::2]:9100", false},
+		{"ipv6 cidr", []string{"fd00::/8"}, "[fd12:3456::1]:9100", true},
+		{"ipv6 cidr outside", []string{"fd00::/8"}, "[fe80::1]:9100", false},
+		{"ipv6 zone", []string{"fe80::/10"}, "[fe80::1%eth0]:9100", true},
+		{"ipv4 mapped ipv6", []string{"10.0.0.0/8"}, "[::ffff:10.1.2.3]:80", true},
+		{"ipv4 entry ipv6 client", []string{"10.0.0.1"}, "[::1]:80", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &IPFilter{AllowedIPs: tt.allowed}
+			require.NoError(t, f.InitIPFilter())
+			require.Equal(t, tt.want, f.AllowAddr(tt.addr))
+		})
+	}
+}
+
+func TestIPFilterInvalid(t *testing.T) {
+	for _, entry := range []string{"10.0.0.300", "10.0.0.0/33", "fd00::/129", "localhost"} {
+		f := &IPFilter{AllowedIPs: []string{entry}}
+		require.Error(t, f.InitIPFilter(), entry)
+	}
+}
+
+func TestIPFilterConn(t *testing.T) {
+	f := &IPFilter{AllowedIPs: []string{"127.0.0.1"}}
+	require.NoError(t, f.InitIPFilter())
+
+	require.True(t, f.AllowIP(net.ParseIP("127.0.0.1")))
+	require.False(t, f.AllowIP(nil))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.True(t, f.AllowConn(conn))
+
+	f = &IPFilter{AllowedIPs: []string{"10.0.0.0/8"}}
+	require.NoError(t, f.InitIPFilter())
+	require.False(t, f.AllowConn(conn))
+}
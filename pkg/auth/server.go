@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/subtle"
+	crypto_tls "crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+// ServerConfig is the access control shared by listener-type inputs and the http api:
+// server side tls (tls_allowed_cacerts enables client certificate verification),
+// basic auth, bearer tokens and an ip allowlist.
+type ServerConfig struct {
+	tls.ServerConfig
+	IPFilter
+
+	BasicAuthUser string   `toml:"basic_auth_user" json:"basic_auth_user" mapstructure:"basic_auth_user"`
+	BasicAuthPass string   `toml:"basic_auth_pass" json:"basic_auth_pass" mapstructure:"basic_auth_pass"`
+	BearerTokens  []string `toml:"bearer_tokens" json:"bearer_tokens" mapstructure:"bearer_tokens"`
+
+	tlsConfig *crypto_tls.Config
+}
+
+func (c *ServerConfig) InitServerConfig() error {
+	if err := c.InitIPFilter(); err != nil {
+		return err
+	}
+
+	tlsConfig, err := c.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	c.tlsConfig = tlsConfig
+	return nil
+}
+
+// ServerTLSConfig returns the tls config built by InitServerConfig, nil if tls is not configured
+func (c *ServerConfig) ServerTLSConfig() *crypto_tls.Config {
+	return c.tlsConfig
+}
+
+// CredentialsRequired reports whether requests must carry basic auth or a bearer token
+func (c *ServerConfig) CredentialsRequired() bool {
+	return c.BasicAuthUser != "" || len(c.BearerTokens) > 0
+}
+
+// CheckCredentials validates the Authorization header of a request.
+// Either a matching basic auth pair or one of the bearer tokens is accepted.
+func (c *ServerConfig) CheckCredentials(r *http.Request) bool {
+	if !c.CredentialsRequired() {
+		return true
+	}
+
+	if c.BasicAuthUser != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			if secureEqual(user, c.BasicAuthUser) && secureEqual(pass, c.BasicAuthPass) {
+				return true
+			}
+		}
+	}
+
+	if len(c.BearerTokens) > 0 {
+		h := r.Header.Get("Authorization")
+		if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			token := strings.TrimSpace(h[7:])
+			for _, t := range c.BearerTokens {
+				if secureEqual(token, t) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Authorize checks the remote address and credentials of a request
+// and returns the http status code to reply with when it's denied.
+func (c *ServerConfig) Authorize(r *http.Request) (int, bool) {
+	if !c.AllowAddr(r.RemoteAddr) {
+		return http.StatusForbidden, false
+	}
+	if !c.CheckCredentials(r) {
+		return http.StatusUnauthorized, false
+	}
+	return http.StatusOK, true
+}
+
+// Handler wraps next with ip allowlist and credential checks
+func (c *ServerConfig) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, ok := c.Authorize(r)
+		if !ok {
+			if code == http.StatusUnauthorized && c.BasicAuthUser != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="categraf"`)
+			}
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WrapListener applies the ip allowlist and, if configured, tls to a stream listener.
+// Connections from disallowed addresses are closed right after accept.
+func (c *ServerConfig) WrapListener(l net.Listener) net.Listener {
+	if len(c.allowedNets) != 0 {
+		l = &filteredListener{Listener: l, filter: &c.IPFilter}
+	}
+	if c.tlsConfig != nil {
+		l = crypto_tls.NewListener(l, c.tlsConfig)
+	}
+	return l
+}
+
+type filteredListener struct {
+	net.Listener
+	filter *IPFilter
+}
+
+func (l *filteredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.filter.AllowConn(conn) {
+			return conn, nil
+		}
+		log.Println("W! connection from", conn.RemoteAddr().String(), "rejected by allowed_ips")
+		conn.Close()
+	}
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
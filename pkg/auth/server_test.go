@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	crypto_tls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	basic := ServerConfig{BasicAuthUser: "admin", BasicAuthPass: "secret"}
+	bearer := ServerConfig{BearerTokens: []string{"t1", "t2"}}
+	both := ServerConfig{BasicAuthUser: "admin", BasicAuthPass: "secret", BearerTokens: []string{"t1"}}
+
+	tests := []struct {
+		name   string
+		config ServerConfig
+		auth   func(r *http.Request)
+		want   bool
+	}{
+		{"none configured", ServerConfig{}, func(r *http.Request) {}, true},
+		{"basic good", basic, func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, true},
+		{"basic bad password", basic, func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, false},
+		{"basic bad user", basic, func(r *http.Request) { r.SetBasicAuth("root", "secret") }, false},
+		{"basic missing", basic, func(r *http.Request) {}, false},
+		{"basic given a token", basic, func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, false},
+		{"bearer good", bearer, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t2") }, true},
+		{"bearer lower case scheme", bearer, func(r *http.Request) { r.Header.Set("Authorization", "bearer t1") }, true},
+		{"bearer bad", bearer, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t3") }, false},
+		{"bearer prefix of a token", bearer, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t") }, false},
+		{"bearer empty", bearer, func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, false},
+		{"bearer missing", bearer, func(r *http.Request) {}, false},
+		{"bearer given basic", bearer, func(r *http.Request) { r.SetBasicAuth("t1", "") }, false},
+		{"both basic", both, func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, true},
+		{"both bearer", both, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t1") }, true},
+		{"both bad", both, func(r *http.Request) { r.SetBasicAuth("admin", "t1") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.auth(r)
+			require.Equal(t, tt.want, tt.config.CheckCredentials(r))
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     ServerConfig
+		remoteAddr string
+		user, pass string
+		code       int
+		challenge  bool
+	}{
+		{"open", ServerConfig{}, "10.0.0.1:1234", "", "", http.StatusOK, false},
+		{"allowed ip with credentials", ServerConfig{IPFilter: IPFilter{AllowedIPs: []string{"10.0.0.0/8"}}, BasicAuthUser: "admin", BasicAuthPass: "secret"},
+			"10.0.0.1:1234", "admin", "secret", http.StatusOK, false},
+		{"denied ip with credentials", ServerConfig{IPFilter: IPFilter{AllowedIPs: []string{"10.0.0.0/8"}}, BasicAuthUser: "admin", BasicAuthPass: "secret"},
+			"192.168.0.1:1234", "admin", "secret", http.StatusForbidden, false},
+		{"allowed ip bad credentials", ServerConfig{IPFilter: IPFilter{AllowedIPs: []string{"10.0.0.0/8"}}, BasicAuthUser: "admin", BasicAuthPass: "secret"},
+			"10.0.0.1:1234", "admin", "wrong", http.StatusUnauthorized, true},
+		{"bearer only no challenge", ServerConfig{BearerTokens: []string{"t1"}},
+			"10.0.0.1:1234", "", "", http.StatusUnauthorized, false},
+		{"ipv6 denied", ServerConfig{IPFilter: IPFilter{AllowedIPs: []string{"::1"}}},
+			"[::2]:1234", "", "", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.config.InitServerConfig())
+			h := tt.config.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			require.Equal(t, tt.code, w.Code)
+			require.Equal(t, tt.challenge, w.Header().Get("WWW-Authenticate") != "")
+		})
+	}
+}
+
+func TestWrapListenerFiltered(t *testing.T) {
+	c := &ServerConfig{IPFilter: IPFilter{AllowedIPs: []string{"10.0.0.0/8"}}}
+	require.NoError(t, c.InitServerConfig())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l = c.WrapListener(l)
+	defer l.Close()
+
+	accepted := make(chan struct{})
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.Close()
+			close(accepted)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the listener closes the connection instead of handing it over
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	select {
+	case <-accepted:
+		t.Fatal("connection from a denied address accepted")
+	default:
+	}
+}
+
+func TestClientCertRequired(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newCert(t, nil, nil, true)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	server, serverKey := newCert(t, ca, caKey, false)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", server.Raw)
+	writeKey(t, filepath.Join(dir, "server.key"), serverKey)
+	client, clientKey := newCert(t, ca, caKey, false)
+	other, otherKey := newCert(t, nil, nil, false)
+
+	c := &ServerConfig{ServerConfig: tls.ServerConfig{
+		TLSCert:           filepath.Join(dir, "server.pem"),
+		TLSKey:            filepath.Join(dir, "server.key"),
+		TLSAllowedCACerts: []string{filepath.Join(dir, "ca.pem")},
+	}}
+	require.NoError(t, c.InitServerConfig())
+	require.NotNil(t, c.ServerTLSConfig())
+	require.Equal(t, crypto_tls.RequireAndVerifyClientCert, c.ServerTLSConfig().ClientAuth)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))}
+	go srv.Serve(c.WrapListener(l))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	get := func(certs ...crypto_tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &crypto_tls.Config{
+			RootCAs:      pool,
+			Certificates: certs,
+		}}}
+		resp, err := client.Get("https://" + l.Addr().String())
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return nil
+	}
+
+	require.NoError(t, get(crypto_tls.Certificate{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}))
+	require.Error(t, get())
+	require.Error(t, get(crypto_tls.Certificate{Certificate: [][]byte{other.Raw}, PrivateKey: otherKey}))
+}
+
+// newCert creates a certificate for 127.0.0.1 signed by parent, self-signed if parent is nil
+func newCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "categraf test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, fpath, typ string, der []byte) {
+	require.NoError(t, os.WriteFile(fpath, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+}
+
+func writeKey(t *testing.T, fpath string, key *ecdsa.PrivateKey) {
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, fpath, "EC PRIVATE KEY", der)
+}
@@ -6,6 +6,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/koding/multiconfig"
 	"github.com/toolkits/pkg/file"
 )
@@ -58,19 +59,20 @@ func LoadConfigByDir(configDir string, configPtr interface{}) error {
 	}
 	s := NewFileScanner()
 	for _, fpath := range files {
-		switch {
-		case strings.HasSuffix(fpath, ".toml"):
-			s.Read(path.Join(configDir, fpath))
-			tBuf = append(tBuf, s.Data()...)
-			tBuf = append(tBuf, []byte("\n")...)
-		case strings.HasSuffix(fpath, ".json"):
-			loaders = append(loaders, &multiconfig.JSONLoader{Path: path.Join(configDir, fpath)})
-		case strings.HasSuffix(fpath, ".yaml") || strings.HasSuffix(fpath, ".yml"):
-			loaders = append(loaders, &multiconfig.YAMLLoader{Path: path.Join(configDir, fpath)})
+		format := GuessFormat(fpath)
+		if format == TomlFormat && !strings.HasSuffix(fpath, ".toml") {
+			continue
 		}
+		s.Read(path.Join(configDir, fpath))
 		if s.Err() != nil {
 			return s.Err()
 		}
+		data, err := ToToml(ConfigWithFormat{Config: string(s.Data()), Format: format})
+		if err != nil {
+			return fmt.Errorf("%s: %v", fpath, err)
+		}
+		tBuf = append(tBuf, []byte(data)...)
+		tBuf = append(tBuf, []byte("\n")...)
 	}
 
 	if len(tBuf) != 0 {
@@ -84,35 +86,26 @@ func LoadConfigByDir(configDir string, configPtr interface{}) error {
 	return m.Load(configPtr)
 }
 
+// LoadConfigs merges the configs as if they were one toml file, yaml and json
+// configs are translated to toml first, see ToToml
 func LoadConfigs(configs []ConfigWithFormat, configPtr interface{}) error {
-	var (
-		tBuf, yBuf, jBuf []byte
-	)
+	var tBuf []byte
 	loaders := []multiconfig.Loader{
 		&multiconfig.TagLoader{},
 		&multiconfig.EnvironmentLoader{},
 	}
 	for _, c := range configs {
-		switch c.Format {
-		case TomlFormat:
-			tBuf = append(tBuf, []byte("\n\n")...)
-			tBuf = append(tBuf, []byte(c.Config)...)
-		case YamlFormat:
-			yBuf = append(yBuf, []byte(c.Config)...)
-		case JsonFormat:
-			jBuf = append(jBuf, []byte(c.Config)...)
+		data, err := ToToml(c)
+		if err != nil {
+			return err
 		}
+		tBuf = append(tBuf, []byte("\n\n")...)
+		tBuf = append(tBuf, []byte(data)...)
 	}
 
 	if len(tBuf) != 0 {
 		loaders = append(loaders, &multiconfig.TOMLLoader{Reader: bytes.NewReader(tBuf)})
 	}
-	if len(yBuf) != 0 {
-		loaders = append(loaders, &multiconfig.YAMLLoader{Reader: bytes.NewReader(yBuf)})
-	}
-	if len(jBuf) != 0 {
-		loaders = append(loaders, &multiconfig.JSONLoader{Reader: bytes.NewReader(jBuf)})
-	}
 
 	m := multiconfig.DefaultLoader{
 		Loader:    multiconfig.MultiLoader(loaders...),
@@ -122,24 +115,24 @@ func LoadConfigs(configs []ConfigWithFormat, configPtr interface{}) error {
 }
 
 func LoadSingleConfig(c ConfigWithFormat, configPtr interface{}) error {
-	loaders := []multiconfig.Loader{
-		&multiconfig.TagLoader{},
-		&multiconfig.EnvironmentLoader{},
-	}
-
-	switch c.Format {
-	case TomlFormat:
-		loaders = append(loaders, &multiconfig.TOMLLoader{Reader: bytes.NewReader([]byte(c.Config))})
-	case YamlFormat:
-		loaders = append(loaders, &multiconfig.YAMLLoader{Reader: bytes.NewReader([]byte(c.Config))})
-	case JsonFormat:
-		loaders = append(loaders, &multiconfig.JSONLoader{Reader: bytes.NewReader([]byte(c.Config))})
+	return LoadConfigs([]ConfigWithFormat{c}, configPtr)
+}
 
+// UndecodedKeys decodes a toml config into configPtr and returns the keys that
+// have no matching field, e.g. a typo like `intervel` or an option of another
+// plugin. yaml and json configs are checked by their toml translation.
+func UndecodedKeys(c ConfigWithFormat, configPtr interface{}) ([]string, error) {
+	data, err := ToToml(c)
+	if err != nil {
+		return nil, err
 	}
-
-	m := multiconfig.DefaultLoader{
-		Loader:    multiconfig.MultiLoader(loaders...),
-		Validator: multiconfig.MultiValidator(&multiconfig.RequiredValidator{}),
+	md, err := toml.Decode(data, configPtr)
+	if err != nil {
+		return nil, err
 	}
-	return m.Load(configPtr)
+	keys := make([]string, 0, len(md.Undecoded()))
+	for _, k := range md.Undecoded() {
+		keys = append(keys, k.String())
+	}
+	return keys, nil
 }
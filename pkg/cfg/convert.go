@@ -0,0 +1,112 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ToToml translates a yaml or json config into the equivalent toml, so all
+// formats are decoded by the toml tags and hooks (e.g. config.Duration) of the
+// config structs. Tables become maps and arrays of tables lists of maps:
+//
+//	interval: 15s
+//	instances:
+//	  - urls: ["http://127.0.0.1:9100/metrics"]
+//	    labels:
+//	      env: prod
+func ToToml(c ConfigWithFormat) (string, error) {
+	var (
+		m   map[string]interface{}
+		err error
+	)
+	switch c.Format {
+	case YamlFormat:
+		err = yaml.Unmarshal([]byte(c.Config), &m)
+	case JsonFormat:
+		dec := json.NewDecoder(bytes.NewReader([]byte(c.Config)))
+		dec.UseNumber()
+		if err = dec.Decode(&m); err == io.EOF {
+			err = nil
+		}
+	default:
+		return c.Config, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s config: %v", c.Format, err)
+	}
+	if len(m) == 0 {
+		return "", nil
+	}
+
+	value, err := normalize(m)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(value); err != nil {
+		return "", fmt.Errorf("failed to convert %s config to toml: %v", c.Format, err)
+	}
+	return buf.String(), nil
+}
+
+// normalize turns decoded yaml and json values into values toml can encode,
+// null values are dropped like absent keys
+func normalize(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			if item == nil {
+				continue
+			}
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = n
+		}
+		return ret, nil
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			ret[fmt.Sprint(k)] = item
+		}
+		return normalize(ret)
+	case []interface{}:
+		if len(x) == 0 {
+			return x, nil
+		}
+		tables := make([]map[string]interface{}, 0, len(x))
+		values := make([]interface{}, 0, len(x))
+		for _, item := range x {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			if table, ok := n.(map[string]interface{}); ok {
+				tables = append(tables, table)
+			}
+			values = append(values, n)
+		}
+		switch len(tables) {
+		case len(x):
+			return tables, nil
+		case 0:
+			return values, nil
+		}
+		return nil, fmt.Errorf("array mixing tables and values: %v", x)
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		return x.Float64()
+	case int:
+		return int64(x), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,63 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testInstance struct {
+	URLs    []string          `toml:"urls"`
+	Labels  map[string]string `toml:"labels"`
+	Timeout time.Duration     `toml:"timeout_ns"`
+	Weight  float64           `toml:"weight"`
+}
+
+type testInput struct {
+	Interval  int64           `toml:"interval"`
+	Instances []*testInstance `toml:"instances"`
+}
+
+func TestLoadConfigsFormats(t *testing.T) {
+	yamlConfig := `
+interval: 15
+instances:
+  - urls: ["http://a:9100/metrics"]
+    labels:
+      env: "prod"
+    weight: 0.5
+`
+	jsonConfig := `{"instances": [{"urls": ["http://b:9100/metrics"], "timeout_ns": 3000000000, "labels": null}]}`
+	tomlConfig := `
+[[instances]]
+urls = ["http://c:9100/metrics"]
+`
+
+	var in testInput
+	err := LoadConfigs([]ConfigWithFormat{
+		{Config: yamlConfig, Format: YamlFormat},
+		{Config: jsonConfig, Format: JsonFormat},
+		{Config: tomlConfig, Format: TomlFormat},
+	}, &in)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 15, in.Interval)
+	require.Len(t, in.Instances, 3)
+	require.Equal(t, []string{"http://a:9100/metrics"}, in.Instances[0].URLs)
+	require.Equal(t, "prod", in.Instances[0].Labels["env"])
+	require.Equal(t, 0.5, in.Instances[0].Weight)
+	require.Equal(t, 3*time.Second, in.Instances[1].Timeout)
+	require.Equal(t, []string{"http://c:9100/metrics"}, in.Instances[2].URLs)
+}
+
+func TestUndecodedKeysYaml(t *testing.T) {
+	keys, err := UndecodedKeys(ConfigWithFormat{Config: "intervel: 15\n", Format: YamlFormat}, &testInput{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"intervel"}, keys)
+}
+
+func TestToTomlMixedArray(t *testing.T) {
+	_, err := ToToml(ConfigWithFormat{Config: `{"instances": [{"a": 1}, 2]}`, Format: JsonFormat})
+	require.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RequiredKeys reports the toml keys of the fields of v tagged required:"true",
+// split into the ones which are set and the ones left empty. Fields of embedded
+// structs count as fields of v; the structs in slices are checked one by one,
+// their missing keys prefixed with the slice key and index, e.g.
+// topic_parsing[0].topic, and never counted as set.
+func RequiredKeys(v interface{}) (set, missing []string) {
+	requiredKeys(reflect.ValueOf(v), "", &set, &missing)
+	return set, missing
+}
+
+func requiredKeys(v reflect.Value, prefix string, set, missing *[]string) {
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := v.Field(i)
+
+		if sf.Anonymous {
+			requiredKeys(f, prefix, set, missing)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		key := tomlKey(sf)
+		if key == "-" {
+			continue
+		}
+
+		if f.Kind() == reflect.Slice {
+			for j := 0; j < f.Len(); j++ {
+				var nested []string
+				requiredKeys(f.Index(j), fmt.Sprintf("%s%s[%d].", prefix, key, j), &nested, missing)
+			}
+		}
+
+		if sf.Tag.Get("required") != "true" {
+			continue
+		}
+		if isEmpty(f) {
+			*missing = append(*missing, prefix+key)
+		} else if prefix == "" {
+			*set = append(*set, key)
+		}
+	}
+}
+
+func tomlKey(sf reflect.StructField) string {
+	key := strings.Split(sf.Tag.Get("toml"), ",")[0]
+	if key == "" {
+		return sf.Name
+	}
+	return key
+}
+
+// isEmpty treats nil pointers and pointers to zero values, e.g. to "", the same
+func isEmpty(f reflect.Value) bool {
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return true
+		}
+		f = f.Elem()
+	}
+	switch f.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return f.Len() == 0
+	}
+	return f.IsZero()
+}
@@ -0,0 +1,47 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCredential struct {
+	Token *string `toml:"token" required:"true"`
+}
+
+type testParsing struct {
+	Topic string `toml:"topic" required:"true"`
+}
+
+type testRequired struct {
+	testCredential
+
+	Servers []string       `toml:"servers" required:"true"`
+	Port    int            `toml:"port"`
+	Parsing []*testParsing `toml:"parsing"`
+}
+
+func TestRequiredKeys(t *testing.T) {
+	empty := ""
+	token := "secret"
+
+	set, missing := RequiredKeys(&testRequired{})
+	require.Empty(t, set)
+	require.Equal(t, []string{"token", "servers"}, missing)
+
+	set, missing = RequiredKeys(&testRequired{
+		testCredential: testCredential{Token: &empty},
+		Servers:        []string{"127.0.0.1:1883"},
+		Parsing:        []*testParsing{{Topic: "a/#"}, {}},
+	})
+	require.Equal(t, []string{"servers"}, set)
+	require.Equal(t, []string{"token", "parsing[1].topic"}, missing)
+
+	set, missing = RequiredKeys(&testRequired{
+		testCredential: testCredential{Token: &token},
+		Servers:        []string{"127.0.0.1:1883"},
+	})
+	require.Equal(t, []string{"token", "servers"}, set)
+	require.Empty(t, missing)
+}
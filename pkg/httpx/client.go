@@ -71,35 +71,43 @@ func (c *ResetClient) checkReset() {
 	c.httpClient = c.httpClientFactory()
 }
 
-type Option func(client *http.Client)
+// Option customizes a client created by CreateHTTPClient
+type Option func(b *builder)
+
+type builder struct {
+	client     *http.Client
+	transport  *http.Transport
+	middleware Settings
+}
 
 func Timeout(timeout time.Duration) Option {
-	return func(client *http.Client) {
-		client.Timeout = timeout
+	return func(b *builder) {
+		b.client.Timeout = timeout
 	}
 }
 
 func TlsConfig(tlsCfg *tls.Config) Option {
-	return func(client *http.Client) {
-		client.Transport.(*http.Transport).TLSClientConfig = tlsCfg
+	return func(b *builder) {
+		b.transport.TLSClientConfig = tlsCfg
 	}
 }
 
 func Proxy(proxy func(r *http.Request) (*url.URL, error)) Option {
-	return func(client *http.Client) {
-		client.Transport.(*http.Transport).Proxy = proxy
+	return func(b *builder) {
+		b.transport.Proxy = proxy
 	}
 }
 
 func NetDialer(dialer *net.Dialer) Option {
-	return func(client *http.Client) {
-		client.Transport.(*http.Transport).DialContext = dialer.DialContext
+	return func(b *builder) {
+		b.transport.DialContext = dialer.DialContext
 	}
 }
+
 func FollowRedirects(followRedirects bool) Option {
-	return func(client *http.Client) {
+	return func(b *builder) {
 		if !followRedirects {
-			client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			b.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
 			}
 		}
@@ -107,17 +115,30 @@ func FollowRedirects(followRedirects bool) Option {
 }
 
 func DisableKeepAlives(disableKeepAlives bool) Option {
-	return func(client *http.Client) {
-		client.Transport.(*http.Transport).DisableKeepAlives = disableKeepAlives
+	return func(b *builder) {
+		b.transport.DisableKeepAlives = disableKeepAlives
+	}
+}
+
+// Retries overrides the retries of the defaults, 0 disables them for clients
+// measuring their requests, e.g. probes
+func Retries(retries int) Option {
+	return func(b *builder) {
+		b.middleware.Retries = retries
 	}
 }
 
+// CreateHTTPClient creates a client with the pool, retries, logging and trace
+// header of the defaults, see SetDefaults
 func CreateHTTPClient(opts ...Option) *http.Client {
-	client := &http.Client{
-		Transport: &http.Transport{},
+	b := &builder{
+		client:     &http.Client{},
+		transport:  NewTransport(),
+		middleware: getDefaults(),
 	}
 	for _, opt := range opts {
-		opt(client)
+		opt(b)
 	}
-	return client
+	b.client.Transport = Wrap(b.transport, b.middleware)
+	return b.client
 }
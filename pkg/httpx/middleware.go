@@ -0,0 +1,213 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings are shared by the http clients of the inputs and writers, set once
+// from the [global.http_client] section by SetDefaults
+type Settings struct {
+	// connection pool of every client
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	// idempotent requests failing with a network error, 429, 502, 503 or 504
+	// are retried, the delay doubles with every retry. Other requests only if
+	// their context is marked by WithRetry
+	Retries    int
+	RetryDelay time.Duration
+
+	// log every request at debug level
+	LogRequests bool
+	// header carrying the trace id of a request, empty disables it
+	TraceHeader string
+}
+
+var (
+	defaultsLock sync.RWMutex
+	defaults     = Settings{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 5,
+		IdleConnTimeout:     90 * time.Second,
+		RetryDelay:          200 * time.Millisecond,
+	}
+)
+
+// SetDefaults sets the settings of the clients created afterwards
+func SetDefaults(s Settings) {
+	defaultsLock.Lock()
+	defer defaultsLock.Unlock()
+	defaults = s
+}
+
+func getDefaults() Settings {
+	defaultsLock.RLock()
+	defer defaultsLock.RUnlock()
+	return defaults
+}
+
+// NewTransport returns a transport with the connection pool of the defaults,
+// callers with their own transport pass it to Wrap after configuring it
+func NewTransport() *http.Transport {
+	s := getDefaults()
+	return &http.Transport{
+		MaxIdleConns:        s.MaxIdleConns,
+		MaxIdleConnsPerHost: s.MaxIdleConnsPerHost,
+		IdleConnTimeout:     s.IdleConnTimeout,
+	}
+}
+
+// WrapDefault wraps base with the retries, logging and trace header of the defaults
+func WrapDefault(base http.RoundTripper) http.RoundTripper {
+	return Wrap(base, getDefaults())
+}
+
+// Wrap wraps base with the retries, logging and trace header of s
+func Wrap(base http.RoundTripper, s Settings) http.RoundTripper {
+	if s.Retries <= 0 && !s.LogRequests && s.TraceHeader == "" {
+		return base
+	}
+	return &middleware{base: base, settings: s}
+}
+
+type traceKey struct{}
+
+type retryKey struct{}
+
+// WithRetry returns a context whose requests are retried like idempotent ones
+// whatever their method, for callers knowing that sending a request twice does
+// no harm, e.g. remote write. The body must be replayable, see http.NewRequest.
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+// WithTrace returns a context whose requests carry id in the trace header
+// instead of a generated one, e.g. to correlate a gather with its requests
+func WithTrace(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+type middleware struct {
+	base     http.RoundTripper
+	settings Settings
+}
+
+func (m *middleware) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := ""
+	if h := m.settings.TraceHeader; h != "" {
+		if trace = req.Header.Get(h); trace == "" {
+			trace = traceValue(req.Context(), h)
+			// a RoundTripper must not modify the request of the caller
+			req = req.Clone(req.Context())
+			req.Header.Set(h, trace)
+		}
+	}
+
+	retries := m.settings.Retries
+	if !retryable(req) {
+		retries = 0
+	}
+	delay := m.settings.RetryDelay
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := m.base.RoundTrip(req)
+		if m.settings.LogRequests {
+			logRequest(req, resp, err, time.Since(start), trace, attempt)
+		}
+		if attempt >= retries || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// CloseIdleConnections lets http.Client close the connections of base
+func (m *middleware) CloseIdleConnections() {
+	if c, ok := m.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// retryable tells if req may be sent again, only idempotent methods, or the
+// requests marked by WithRetry, whose body can be replayed are
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if marked, _ := req.Context().Value(retryKey{}).(bool); !marked {
+			return false
+		}
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// traceValue returns the trace id of ctx or a random one, in the w3c format
+// if the header is traceparent
+func traceValue(ctx context.Context, header string) string {
+	id, _ := ctx.Value(traceKey{}).(string)
+	if id == "" {
+		id = randomHex(16)
+	}
+	if strings.EqualFold(header, "traceparent") && !strings.HasPrefix(id, "00-") {
+		return "00-" + id + "-" + randomHex(8) + "-01"
+	}
+	return id
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func logRequest(req *http.Request, resp *http.Response, err error, took time.Duration, trace string, attempt int) {
+	// credentials in the url are not logged
+	u := *req.URL
+	u.User = nil
+	status := ""
+	if err != nil {
+		status = "error: " + err.Error()
+	} else {
+		status = resp.Status
+	}
+	log.Printf("D! http %s %s %s took %s attempt %d trace %q", req.Method, u.String(), status, took, attempt+1, trace)
+}
@@ -0,0 +1,83 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Wrap(NewTransport(), Settings{Retries: 2, RetryDelay: time.Millisecond})}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// POST is not idempotent, it's sent once
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("x"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// unless the caller opts in, the body is sent again with every retry
+	var bodies []string
+	replay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(bs))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer replay.Close()
+	req, err := http.NewRequestWithContext(WithRetry(context.Background()), http.MethodPost, replay.URL, bytes.NewReader([]byte("x")))
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"x", "x", "x"}, bodies)
+}
+
+func TestTraceHeader(t *testing.T) {
+	headers := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Wrap(NewTransport(), Settings{TraceHeader: "traceparent"})}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, <-headers)
+
+	id := "0af7651916cd43dd8448eb211c80319c"
+	req, err := http.NewRequestWithContext(WithTrace(context.Background(), id), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, strings.HasPrefix(<-headers, "00-"+id+"-"))
+	require.Empty(t, req.Header.Get("traceparent"), "request of the caller is not modified")
+}
@@ -0,0 +1,222 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// SplitHostPort is net.SplitHostPort that also accepts addresses without port
+// ("zk1", "::1", "[fe80::1%eth0]"), in which case defaultPort is returned.
+func SplitHostPort(addr, defaultPort string) (host, port string, err error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return "", "", errors.New("empty address")
+	}
+
+	host, port, err = net.SplitHostPort(addr)
+	if err == nil {
+		return host, port, nil
+	}
+
+	// bracketed ipv6 literal without port
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		return addr[1 : len(addr)-1], defaultPort, nil
+	}
+
+	// bare ipv6 literal, e.g. ::1 or 2001:db8::1
+	if strings.Count(addr, ":") > 1 {
+		if ip := net.ParseIP(stripZone(addr)); ip != nil {
+			return addr, defaultPort, nil
+		}
+		return "", "", fmt.Errorf("address %s: ipv6 literal must be enclosed in brackets when a port is given", addr)
+	}
+
+	if !strings.Contains(addr, ":") {
+		return addr, defaultPort, nil
+	}
+	return "", "", err
+}
+
+// NormalizeHostPort returns addr as host:port, using defaultPort if addr has none
+// and bracketing ipv6 literals.
+func NormalizeHostPort(addr, defaultPort string) (string, error) {
+	host, port, err := SplitHostPort(addr, defaultPort)
+	if err != nil {
+		return "", err
+	}
+	if port == "" {
+		return "", fmt.Errorf("address %s: missing port", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func stripZone(host string) string {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		return host[:i]
+	}
+	return host
+}
+
+// DialConfig controls which address family is tried first when a host name
+// resolves to both A and AAAA records. Without a preference the standard
+// library behaviour (RFC 6724 ordering with happy eyeballs) is used.
+type DialConfig struct {
+	PreferIPv4 bool `toml:"prefer_ipv4"`
+	PreferIPv6 bool `toml:"prefer_ipv6"`
+}
+
+func (c *DialConfig) CheckDialConfig() error {
+	if c.PreferIPv4 && c.PreferIPv6 {
+		return errors.New("prefer_ipv4 and prefer_ipv6 can not be both true")
+	}
+	return nil
+}
+
+// DialContext dials address (host:port) with d. When a preference is configured,
+// all A/AAAA records are resolved, the preferred family is dialed first, and the
+// other family is raced after d.FallbackDelay (default 300ms) like happy eyeballs.
+func (c *DialConfig) DialContext(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	if !c.PreferIPv4 && !c.PreferIPv6 {
+		return d.DialContext(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(stripZone(host)) != nil {
+		return d.DialContext(ctx, network, address)
+	}
+
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var v4, v6 []net.IP
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			v4 = append(v4, a.IP)
+		} else {
+			v6 = append(v6, a.IP)
+		}
+	}
+
+	primaries, fallbacks := v4, v6
+	if c.PreferIPv6 {
+		primaries, fallbacks = v6, v4
+	}
+	if len(primaries) == 0 {
+		primaries, fallbacks = fallbacks, nil
+	}
+	if len(primaries) == 0 {
+		return nil, fmt.Errorf("no address found for host %s", host)
+	}
+
+	if len(fallbacks) == 0 {
+		return dialSerial(ctx, d, network, primaries, port)
+	}
+
+	delay := d.FallbackDelay
+	if delay <= 0 {
+		delay = defaultFallbackDelay
+	}
+	return dialParallel(ctx, d, network, primaries, fallbacks, port, delay)
+}
+
+type dialResult struct {
+	net.Conn
+	error
+	primary bool
+}
+
+func dialParallel(ctx context.Context, d *net.Dialer, network string, primaries, fallbacks []net.IP, port string, delay time.Duration) (net.Conn, error) {
+	results := make(chan dialResult)
+	returned := make(chan struct{})
+	defer close(returned)
+
+	race := func(ctx context.Context, primary bool, ips []net.IP) {
+		conn, err := dialSerial(ctx, d, network, ips, port)
+		select {
+		case results <- dialResult{Conn: conn, error: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go race(primaryCtx, true, primaries)
+
+	fallbackTimer := time.NewTimer(delay)
+	defer fallbackTimer.Stop()
+
+	var primaryErr error
+	var primaryDone, fallbackDone, fallbackStarted bool
+	fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+	defer fallbackCancel()
+
+	for {
+		select {
+		case <-fallbackTimer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				go race(fallbackCtx, false, fallbacks)
+			}
+		case res := <-results:
+			if res.error == nil {
+				return res.Conn, nil
+			}
+			if res.primary {
+				primaryDone, primaryErr = true, res.error
+			} else {
+				fallbackDone = true
+			}
+			if primaryDone && fallbackDone {
+				return nil, primaryErr
+			}
+			// primary family failed fast, don't wait for the timer
+			if res.primary && !fallbackStarted {
+				fallbackStarted = true
+				go race(fallbackCtx, false, fallbacks)
+			}
+		}
+	}
+}
+
+func dialSerial(ctx context.Context, d *net.Dialer, network string, ips []net.IP, port string) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range ips {
+		if err := ctx.Err(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,36 @@
+package netx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+		port string
+		err  bool
+	}{
+		{addr: "127.0.0.1:2181", host: "127.0.0.1", port: "2181"},
+		{addr: "zk1", host: "zk1", port: "2181"},
+		{addr: "zk1:2182", host: "zk1", port: "2182"},
+		{addr: "[::1]:2181", host: "::1", port: "2181"},
+		{addr: "[fe80::1%eth0]", host: "fe80::1%eth0", port: "2181"},
+		{addr: "2001:db8::1", host: "2001:db8::1", port: "2181"},
+		{addr: "2001:db8::zz", err: true},
+		{addr: "", err: true},
+	}
+
+	for _, tt := range tests {
+		host, port, err := SplitHostPort(tt.addr, "2181")
+		if tt.err {
+			require.Error(t, err, tt.addr)
+			continue
+		}
+		require.NoError(t, err, tt.addr)
+		require.Equal(t, tt.host, host, tt.addr)
+		require.Equal(t, tt.port, port, tt.addr)
+	}
+}
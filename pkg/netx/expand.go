@@ -0,0 +1,92 @@
+package netx
+
+import (
+	"context"
+	"log"
+	"net"
+	"sort"
+	"time"
+)
+
+const defaultResolveTimeout = 5 * time.Second
+
+// lookupIPAddr resolves the names to expand, replaced by tests
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Target is one dial address produced from a configured address
+type Target struct {
+	// Origin is the address as configured, e.g. zk-headless.ns.svc:2181
+	Origin string
+	// Host is the host part of Origin, used as tls server name
+	Host string
+	// Address is what to dial, host:port or ip:port after expansion
+	Address string
+	// IP is the resolved ip, empty when the target was not expanded
+	IP string
+}
+
+// ExpandConfig expands a DNS name that resolves to several addresses (e.g. a
+// headless kubernetes service) into one target per ip. Call ExpandTargets on
+// every gather so scaling events are picked up.
+type ExpandConfig struct {
+	ExpandDNS bool `toml:"expand_dns"`
+}
+
+func (c *ExpandConfig) ExpandTargets(addrs []string, defaultPort string) []Target {
+	targets := make([]Target, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := SplitHostPort(addr, defaultPort)
+		if err != nil {
+			// keep it and let the dial report the error
+			targets = append(targets, Target{Origin: addr, Host: addr, Address: addr})
+			continue
+		}
+		hostPort := net.JoinHostPort(host, port)
+
+		if !c.ExpandDNS || net.ParseIP(stripZone(host)) != nil {
+			targets = append(targets, Target{Origin: addr, Host: host, Address: hostPort})
+			continue
+		}
+
+		ips, err := lookupIPs(host)
+		if err != nil || len(ips) == 0 {
+			log.Println("W! failed to expand address", addr, "error:", err)
+			targets = append(targets, Target{Origin: addr, Host: host, Address: hostPort})
+			continue
+		}
+
+		for _, ip := range ips {
+			targets = append(targets, Target{
+				Origin:  addr,
+				Host:    host,
+				Address: net.JoinHostPort(ip, port),
+				IP:      ip,
+			})
+		}
+	}
+	return targets
+}
+
+func lookupIPs(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultResolveTimeout)
+	defer cancel()
+
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(addrs))
+	ips := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		ip := a.IP.String()
+		if _, has := seen[ip]; has {
+			continue
+		}
+		seen[ip] = struct{}{}
+		ips = append(ips, ip)
+	}
+	// stable order keeps the gather fan-out deterministic
+	sort.Strings(ips)
+	return ips, nil
+}
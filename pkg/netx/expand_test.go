@@ -0,0 +1,78 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandTargets(t *testing.T) {
+	defer func(f func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddr = f }(lookupIPAddr)
+	lookupIPAddr = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "zk-headless.ns.svc":
+			// unsorted with a duplicate, as resolvers may return them
+			return []net.IPAddr{
+				{IP: net.ParseIP("10.0.0.2")},
+				{IP: net.ParseIP("10.0.0.1")},
+				{IP: net.ParseIP("10.0.0.2")},
+			}, nil
+		case "zk1":
+			return []net.IPAddr{{IP: net.ParseIP("10.0.0.9")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		name   string
+		expand bool
+		addr   string
+		want   []Target
+	}{
+		{
+			name: "plain host",
+			addr: "zk1",
+			want: []Target{{Origin: "zk1", Host: "zk1", Address: "zk1:2181"}},
+		},
+		{
+			name: "host and port",
+			addr: "zk1:2182",
+			want: []Target{{Origin: "zk1:2182", Host: "zk1", Address: "zk1:2182"}},
+		},
+		{
+			name:   "ip literal is not resolved",
+			expand: true,
+			addr:   "[::1]:2181",
+			want:   []Target{{Origin: "[::1]:2181", Host: "::1", Address: "[::1]:2181"}},
+		},
+		{
+			name:   "expanded name",
+			expand: true,
+			addr:   "zk-headless.ns.svc:2181",
+			want: []Target{
+				{Origin: "zk-headless.ns.svc:2181", Host: "zk-headless.ns.svc", Address: "10.0.0.1:2181", IP: "10.0.0.1"},
+				{Origin: "zk-headless.ns.svc:2181", Host: "zk-headless.ns.svc", Address: "10.0.0.2:2181", IP: "10.0.0.2"},
+			},
+		},
+		{
+			name:   "unresolvable name is kept",
+			expand: true,
+			addr:   "gone.ns.svc",
+			want:   []Target{{Origin: "gone.ns.svc", Host: "gone.ns.svc", Address: "gone.ns.svc:2181"}},
+		},
+		{
+			name:   "invalid entry is kept for the dial to fail",
+			expand: true,
+			addr:   "2001:db8::zz",
+			want:   []Target{{Origin: "2001:db8::zz", Host: "2001:db8::zz", Address: "2001:db8::zz"}},
+		},
+	}
+
+	for _, tt := range tests {
+		c := &ExpandConfig{ExpandDNS: tt.expand}
+		require.Equal(t, tt.want, c.ExpandTargets([]string{tt.addr}, "2181"), tt.name)
+	}
+}
@@ -5,31 +5,102 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"sync/atomic"
+	_ "net/http/pprof"
+	"sync"
+	"time"
 )
 
+// Status of the pprof server, Until is zero if it runs until stopped
+type Status struct {
+	Running bool      `json:"running"`
+	Addr    string    `json:"addr,omitempty"`
+	Until   time.Time `json:"until,omitempty"`
+}
+
 var (
-	pprof uint32
-	addr  string
+	lock   sync.Mutex
+	server *http.Server
+	timer  *time.Timer
+	status Status
+	// bumped by every Start, an expired ttl only stops the server it was set for
+	generation int
 )
 
+// Go starts the pprof server until the process exits
 func Go() {
+	if _, err := Start(0); err != nil {
+		log.Println("E! failed to start pprof:", err)
+	}
+}
 
-	if !atomic.CompareAndSwapUint32(&pprof, 0, 1) {
-		log.Println("pprofile already started,", addr)
-		return
+// Start serves /debug/pprof on a random port of 127.0.0.1 for ttl, 0 means
+// until Stop. If it's running already only its ttl is changed.
+func Start(ttl time.Duration) (Status, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if server == nil {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return status, err
+		}
+		srv := &http.Server{Handler: http.DefaultServeMux}
+		server = srv
+		status = Status{
+			Running: true,
+			Addr:    fmt.Sprintf("http://127.0.0.1:%d/debug/pprof", listener.Addr().(*net.TCPAddr).Port),
+		}
+		log.Printf("I! pprof started at %s", status.Addr)
+		go func() {
+			if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Println("E! pprof server stopped:", err)
+			}
+		}()
 	}
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		log.Println(err)
-		return
+
+	if timer != nil {
+		timer.Stop()
+		timer = nil
 	}
-	addr = fmt.Sprintf("http://127.0.0.1:%d/debug/pprof", listener.Addr().(*net.TCPAddr).Port)
-	log.Printf("pprof started at %s", addr)
+	generation++
+	status.Until = time.Time{}
+	if ttl > 0 {
+		gen := generation
+		status.Until = time.Now().Add(ttl)
+		timer = time.AfterFunc(ttl, func() {
+			lock.Lock()
+			defer lock.Unlock()
+			if gen == generation {
+				stop()
+			}
+		})
+	}
+	return status, nil
+}
+
+// Stop closes the pprof server if it's running
+func Stop() {
+	lock.Lock()
+	defer lock.Unlock()
+	stop()
+}
 
-	err = http.Serve(listener, nil)
-	if err != nil {
-		log.Println(err)
+func stop() {
+	if server == nil {
 		return
 	}
+	if timer != nil {
+		timer.Stop()
+		timer = nil
+	}
+	server.Close()
+	server = nil
+	status = Status{}
+	log.Println("I! pprof stopped")
+}
+
+func GetStatus() Status {
+	lock.Lock()
+	defer lock.Unlock()
+	return status
 }
@@ -0,0 +1,144 @@
+// Package state is a small key value store persisted across restarts, for
+// inputs which have to remember something, e.g. offsets or checksums. Every
+// input uses its own namespace, the file is opened on first use.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store is a bolt database with one bucket per namespace
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the store at path, it fails if another process holds it
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Namespace returns the keys of name, e.g. the name of an input
+func (s *Store) Namespace(name string) *Namespace {
+	return &Namespace{store: s, bucket: []byte(name)}
+}
+
+type Namespace struct {
+	store  *Store
+	bucket []byte
+}
+
+// Get returns a copy of the value of key, nil if there is none
+func (n *Namespace) Get(key string) ([]byte, error) {
+	var ret []byte
+	err := n.store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(n.bucket)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(key)); v != nil {
+			ret = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func (n *Namespace) Put(key string, value []byte) error {
+	return n.store.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(n.bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+func (n *Namespace) Delete(key string) error {
+	return n.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(n.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// GetJSON decodes the value of key into v, false if there is none
+func (n *Namespace) GetJSON(key string, v interface{}) (bool, error) {
+	bs, err := n.Get(key)
+	if err != nil || bs == nil {
+		return false, err
+	}
+	return true, json.Unmarshal(bs, v)
+}
+
+func (n *Namespace) PutJSON(key string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return n.Put(key, bs)
+}
+
+// ForEach calls fn for every key, the value is only valid during the call
+func (n *Namespace) ForEach(fn func(key string, value []byte) error) error {
+	return n.store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(n.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+var (
+	defaultPath  string
+	defaultOnce  sync.Once
+	defaultStore *Store
+	defaultErr   error
+)
+
+// SetPath sets the file of the default store, before it's used the first time
+func SetPath(path string) {
+	defaultPath = path
+}
+
+// Default returns the store shared by the inputs, opened on first use
+func Default() (*Store, error) {
+	defaultOnce.Do(func() {
+		if defaultPath == "" {
+			defaultErr = errors.New("path of state store not set")
+			return
+		}
+		defaultStore, defaultErr = Open(defaultPath)
+	})
+	return defaultStore, defaultErr
+}
+
+// Close closes the default store if it was opened
+func Close() {
+	if defaultStore != nil {
+		defaultStore.Close()
+	}
+}
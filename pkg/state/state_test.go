@@ -0,0 +1,50 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "state.db")
+	s, err := Open(path)
+	require.NoError(t, err)
+
+	offsets := s.Namespace("tail")
+	v, err := offsets.Get("/var/log/messages")
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	require.NoError(t, offsets.Put("/var/log/messages", []byte("1024")))
+	require.NoError(t, s.Namespace("kafka").PutJSON("orders/0", map[string]int64{"offset": 42}))
+	require.NoError(t, s.Close())
+
+	// survives a restart
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	v, err = s.Namespace("tail").Get("/var/log/messages")
+	require.NoError(t, err)
+	require.Equal(t, "1024", string(v))
+
+	var lag map[string]int64
+	has, err := s.Namespace("kafka").GetJSON("orders/0", &lag)
+	require.NoError(t, err)
+	require.True(t, has)
+	require.Equal(t, int64(42), lag["offset"])
+
+	keys := 0
+	require.NoError(t, s.Namespace("tail").ForEach(func(key string, value []byte) error {
+		keys++
+		return nil
+	}))
+	require.Equal(t, 1, keys)
+
+	require.NoError(t, s.Namespace("tail").Delete("/var/log/messages"))
+	v, err = s.Namespace("tail").Get("/var/log/messages")
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
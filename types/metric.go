@@ -1,6 +1,7 @@
 package types
 
 import (
+	"strings"
 	"time"
 )
 
@@ -17,6 +18,40 @@ const (
 	Histogram
 )
 
+var valueTypeNames = map[ValueType]string{
+	Counter:   "counter",
+	Gauge:     "gauge",
+	Untyped:   "untyped",
+	Summary:   "summary",
+	Histogram: "histogram",
+}
+
+func (t ValueType) String() string {
+	if name, has := valueTypeNames[t]; has {
+		return name
+	}
+	return "untyped"
+}
+
+func (t ValueType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *ValueType) UnmarshalText(text []byte) error {
+	*t = ParseValueType(string(text))
+	return nil
+}
+
+// ParseValueType returns Untyped for unknown names
+func ParseValueType(name string) ValueType {
+	for t, n := range valueTypeNames {
+		if strings.EqualFold(n, name) {
+			return t
+		}
+	}
+	return Untyped
+}
+
 // Tag represents a single tag key and value.
 type Tag struct {
 	Key   string
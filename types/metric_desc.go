@@ -0,0 +1,12 @@
+package types
+
+// MetricDesc describes one metric an input can emit. It's only metadata for
+// documentation (`categraf list-metrics`), samples are not checked against it.
+type MetricDesc struct {
+	Name string    `json:"name"`
+	Type ValueType `json:"type"`
+	Unit string    `json:"unit,omitempty"`
+	Help string    `json:"help,omitempty"`
+	// tags set by the input itself, agent_hostname, global and instance labels are not listed
+	Tags []string `json:"tags,omitempty"`
+}
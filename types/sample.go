@@ -1,6 +1,7 @@
 package types
 
 import (
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -22,10 +23,19 @@ type (
 		Timestamp time.Time         `json:"timestamp"`
 		Value     interface{}       `json:"value"`
 		Labels    map[string]string `json:"labels"`
+		// Type and Unit are optional, zero Type means untyped
+		Type ValueType `json:"type,omitempty"`
+		Unit string    `json:"unit,omitempty"`
 	}
 )
 
+// staleNaNBits is the bit pattern prometheus uses as staleness marker
+const staleNaNBits uint64 = 0x7ff0000000000002
+
 var (
+	// StaleNaN marks a series as gone, see prometheus/model/value
+	StaleNaN = math.Float64frombits(staleNaNBits)
+
 	labelReplacer  = strings.NewReplacer("-", "_", ".", "_", " ", "_", "/", "_")
 	metricReplacer = strings.NewReplacer("-", "_", ".", "_", " ", "_", "'", "_", "\"", "_")
 	zeroTime       = time.Unix(0, 0)
@@ -112,3 +122,29 @@ func (s *Sample) SetTime(t time.Time) *Sample {
 	s.Timestamp = t
 	return s
 }
+
+func (s *Sample) SetType(t ValueType) *Sample {
+	s.Type = t
+	return s
+}
+
+func (s *Sample) SetUnit(unit string) *Sample {
+	s.Unit = unit
+	return s
+}
+
+// IsCounter reports whether the value is monotonic, counters, histogram buckets
+// and the _sum/_count of histograms and summaries
+func (s *Sample) IsCounter() bool {
+	switch s.Type {
+	case Counter, Histogram:
+		return true
+	case Summary:
+		return strings.HasSuffix(s.Metric, "_sum") || strings.HasSuffix(s.Metric, "_count")
+	}
+	return false
+}
+
+func IsStaleNaN(v float64) bool {
+	return math.Float64bits(v) == staleNaNBits
+}
@@ -0,0 +1,197 @@
+package writer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// CardinalityEntry is a metric name or label key and the unique series having it
+type CardinalityEntry struct {
+	Name   string `json:"name"`
+	Series int    `json:"series"`
+	// distinct values of a label key
+	Values int `json:"values,omitempty"`
+}
+
+// CardinalityReport counts the unique series written over the last windows
+type CardinalityReport struct {
+	Enabled bool      `json:"enabled"`
+	Until   time.Time `json:"until,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+	Windows int       `json:"windows"`
+	Series  int       `json:"series"`
+	// series left out since a window reached max_series
+	Dropped   uint64             `json:"dropped"`
+	Metrics   []CardinalityEntry `json:"metrics"`
+	LabelKeys []CardinalityEntry `json:"label_keys"`
+}
+
+type trackedSeries struct {
+	name   string
+	labels []prompb.Label
+}
+
+type cardinalityWindow struct {
+	start  time.Time
+	series map[uint64]*trackedSeries
+}
+
+var cardinality struct {
+	sync.Mutex
+	// set by the api, zero if tracking follows the config only
+	until   time.Time
+	windows []*cardinalityWindow
+	dropped uint64
+}
+
+// StartCardinality tracks the written series for ttl, on top of cardinality.enable
+func StartCardinality(ttl time.Duration) {
+	cardinality.Lock()
+	defer cardinality.Unlock()
+	cardinality.until = time.Now().Add(ttl)
+}
+
+// StopCardinality ends the tracking started by the api and forgets the series
+func StopCardinality() {
+	cardinality.Lock()
+	defer cardinality.Unlock()
+	cardinality.until = time.Time{}
+	cardinality.windows = nil
+	cardinality.dropped = 0
+}
+
+func cardinalityEnabled(now time.Time) bool {
+	return config.Config.Cardinality.Enable || cardinality.until.After(now)
+}
+
+// trackCardinality records the series of a write, if tracking is on
+func trackCardinality(timeSeries []prompb.TimeSeries) {
+	now := time.Now()
+	cardinality.Lock()
+	defer cardinality.Unlock()
+	if !cardinalityEnabled(now) {
+		cardinality.windows = nil
+		cardinality.dropped = 0
+		return
+	}
+
+	opt := config.Config.Cardinality
+	var cur, prev *cardinalityWindow
+	if n := len(cardinality.windows); n > 0 {
+		cur = cardinality.windows[n-1]
+		if n > 1 {
+			prev = cardinality.windows[n-2]
+		}
+	}
+	if cur == nil || now.Sub(cur.start) >= config.GetInterval() {
+		prev = cur
+		cur = &cardinalityWindow{start: now, series: make(map[uint64]*trackedSeries)}
+		cardinality.windows = append(cardinality.windows, cur)
+		if len(cardinality.windows) > opt.Windows {
+			cardinality.windows = cardinality.windows[len(cardinality.windows)-opt.Windows:]
+		}
+	}
+
+	for i := range timeSeries {
+		h := seriesHash(timeSeries[i])
+		if _, has := cur.series[h]; has {
+			continue
+		}
+		if len(cur.series) >= opt.MaxSeries {
+			cardinality.dropped++
+			continue
+		}
+		// series seen before share their copy across windows
+		if prev != nil {
+			if s, has := prev.series[h]; has {
+				cur.series[h] = s
+				continue
+			}
+		}
+		cur.series[h] = newTrackedSeries(timeSeries[i])
+	}
+}
+
+func newTrackedSeries(ts prompb.TimeSeries) *trackedSeries {
+	s := &trackedSeries{labels: make([]prompb.Label, 0, len(ts.Labels))}
+	for _, l := range ts.Labels {
+		if l.Name == model.MetricNameLabel {
+			s.name = l.Value
+			continue
+		}
+		s.labels = append(s.labels, l)
+	}
+	return s
+}
+
+// CardinalityStatus reports the k metric names and label keys with the most
+// unique series over the tracked windows
+func CardinalityStatus(k int) CardinalityReport {
+	now := time.Now()
+	cardinality.Lock()
+	defer cardinality.Unlock()
+
+	report := CardinalityReport{
+		Enabled: cardinalityEnabled(now),
+		Windows: len(cardinality.windows),
+		Dropped: cardinality.dropped,
+	}
+	if cardinality.until.After(now) {
+		report.Until = cardinality.until
+	}
+	if !report.Enabled || len(cardinality.windows) == 0 {
+		return report
+	}
+	report.Since = cardinality.windows[0].start
+
+	all := make(map[uint64]*trackedSeries)
+	for _, w := range cardinality.windows {
+		for h, s := range w.series {
+			all[h] = s
+		}
+	}
+	report.Series = len(all)
+
+	metrics := make(map[string]int)
+	keys := make(map[string]int)
+	values := make(map[string]map[string]struct{})
+	for _, s := range all {
+		metrics[s.name]++
+		for _, l := range s.labels {
+			keys[l.Name]++
+			if values[l.Name] == nil {
+				values[l.Name] = make(map[string]struct{})
+			}
+			values[l.Name][l.Value] = struct{}{}
+		}
+	}
+
+	for name, n := range metrics {
+		report.Metrics = append(report.Metrics, CardinalityEntry{Name: name, Series: n})
+	}
+	for name, n := range keys {
+		report.LabelKeys = append(report.LabelKeys, CardinalityEntry{Name: name, Series: n, Values: len(values[name])})
+	}
+	report.Metrics = topEntries(report.Metrics, k)
+	report.LabelKeys = topEntries(report.LabelKeys, k)
+	return report
+}
+
+func topEntries(entries []CardinalityEntry, k int) []CardinalityEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Series != entries[j].Series {
+			return entries[i].Series > entries[j].Series
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > k {
+		entries = entries[:k]
+	}
+	return entries
+}
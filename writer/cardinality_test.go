@@ -0,0 +1,61 @@
+package writer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func labeledSeries(name string, labels ...string) prompb.TimeSeries {
+	ts := series(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: labels[i], Value: labels[i+1]})
+	}
+	return ts
+}
+
+func TestCardinality(t *testing.T) {
+	config.Config = &config.ConfigType{Cardinality: config.CardinalityConfig{Windows: 2, TopK: 10, MaxSeries: 100}}
+	defer func() {
+		StopCardinality()
+		config.Config = nil
+	}()
+
+	batch := []prompb.TimeSeries{labeledSeries("cpu_usage_idle", "cpu", "cpu0")}
+	trackCardinality(batch)
+	require.False(t, CardinalityStatus(10).Enabled)
+	require.Zero(t, CardinalityStatus(10).Windows)
+
+	StartCardinality(time.Minute)
+	for i := 0; i < 5; i++ {
+		batch = append(batch, labeledSeries("http_requests_total", "path", fmt.Sprintf("/%d", i), "code", "200"))
+	}
+	trackCardinality(batch)
+	trackCardinality(batch)
+
+	report := CardinalityStatus(1)
+	require.True(t, report.Enabled)
+	require.Equal(t, 6, report.Series)
+	require.Equal(t, []CardinalityEntry{{Name: "http_requests_total", Series: 5}}, report.Metrics)
+	require.Equal(t, []CardinalityEntry{{Name: "code", Series: 5, Values: 1}}, report.LabelKeys)
+
+	report = CardinalityStatus(10)
+	require.Len(t, report.LabelKeys, 3)
+	require.Equal(t, CardinalityEntry{Name: "path", Series: 5, Values: 5}, report.LabelKeys[1])
+
+	config.Config.Cardinality.MaxSeries = 2
+	StopCardinality()
+	StartCardinality(time.Minute)
+	trackCardinality(batch)
+	report = CardinalityStatus(10)
+	require.Equal(t, 2, report.Series)
+	require.Equal(t, uint64(4), report.Dropped)
+
+	StopCardinality()
+	require.False(t, CardinalityStatus(10).Enabled)
+}
@@ -0,0 +1,49 @@
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressor encodes the marshaled write request, Content-Encoding is its name
+type compressor func(data []byte) ([]byte, error)
+
+// zstd encoders are safe for concurrent EncodeAll, one is enough for all writers
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// newCompressor returns the content encoding and compressor of name. The
+// remote write protocol only defines snappy, zstd and gzip are accepted by
+// VictoriaMetrics and several gateways, and compress better on slow links.
+func newCompressor(name string) (string, compressor, error) {
+	switch name {
+	case "none":
+		return "", func(data []byte) ([]byte, error) {
+			return data, nil
+		}, nil
+	case "", "snappy":
+		return "snappy", func(data []byte) ([]byte, error) {
+			return snappy.Encode(nil, data), nil
+		}, nil
+	case "zstd":
+		return "zstd", func(data []byte) ([]byte, error) {
+			return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+		}, nil
+	case "gzip":
+		return "gzip", func(data []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			if _, err := gw.Write(data); err != nil {
+				return nil, err
+			}
+			if err := gw.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}, nil
+	}
+	return "", nil, fmt.Errorf("unknown compression %q, should be snappy, zstd, gzip or none", name)
+}
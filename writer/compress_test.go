@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/httpx"
+)
+
+func decompress(t *testing.T, encoding string, body []byte) []byte {
+	switch encoding {
+	case "snappy":
+		data, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		return data
+	case "zstd":
+		dec, err := zstd.NewReader(nil)
+		require.NoError(t, err)
+		defer dec.Close()
+		data, err := dec.DecodeAll(body, nil)
+		require.NoError(t, err)
+		return data
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		data, err := io.ReadAll(gr)
+		require.NoError(t, err)
+		return data
+	}
+	t.Fatalf("unexpected encoding %q", encoding)
+	return nil
+}
+
+func TestWriteCompression(t *testing.T) {
+	for _, compression := range []string{"", "zstd", "gzip"} {
+		var (
+			mu      sync.Mutex
+			batches []int
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var req prompb.WriteRequest
+			require.NoError(t, proto.Unmarshal(decompress(t, r.Header.Get("Content-Encoding"), body), &req))
+			mu.Lock()
+			batches = append(batches, len(req.Timeseries))
+			mu.Unlock()
+		}))
+
+		w, err := newWriter(config.WriterOption{Url: srv.URL, Compression: compression, Batch: 2, Timeout: 5000, DialTimeout: 1000})
+		require.NoError(t, err)
+
+		items := make([]prompb.TimeSeries, 5)
+		for i := range items {
+			items[i] = prompb.TimeSeries{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []prompb.Sample{{Value: float64(i), Timestamp: 1}},
+			}
+		}
+		w.Write(items)
+		srv.Close()
+
+		require.Equal(t, []int{2, 2, 1}, batches, compression)
+	}
+
+	_, err := newWriter(config.WriterOption{Url: "http://localhost", Compression: "lz4"})
+	require.Error(t, err)
+}
+
+func TestWriteRetry(t *testing.T) {
+	httpx.SetDefaults(httpx.Settings{MaxIdleConns: 100, MaxIdleConnsPerHost: 5, Retries: 2, RetryDelay: time.Millisecond})
+	defer httpx.SetDefaults(httpx.Settings{MaxIdleConns: 100, MaxIdleConnsPerHost: 5, IdleConnTimeout: 90 * time.Second, RetryDelay: 200 * time.Millisecond})
+
+	for _, retry := range []bool{false, true} {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		w, err := newWriter(config.WriterOption{Url: srv.URL, Retry: retry, Timeout: 5000, DialTimeout: 1000})
+		require.NoError(t, err)
+		w.Write([]prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1}},
+		}})
+		srv.Close()
+
+		// remote write is a POST, only retried if the writer opts in
+		if retry {
+			require.EqualValues(t, 2, calls)
+		} else {
+			require.EqualValues(t, 1, calls)
+		}
+	}
+}
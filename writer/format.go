@@ -0,0 +1,192 @@
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// formats of the writers
+const (
+	// prometheus remote write
+	FormatPrometheus = "prometheus"
+	// json lines of the VictoriaMetrics /api/v1/import api
+	FormatVMImport = "vm_import"
+	// line protocol of the InfluxDB v1 /write and v2 /api/v2/write apis
+	FormatInfluxDB = "influxdb"
+	// graphite plaintext protocol over tcp
+	FormatGraphite = "graphite"
+	// json of the OpenTSDB /api/put api
+	FormatOpenTSDB = "opentsdb"
+	// put lines of the OpenTSDB telnet protocol over tcp
+	FormatOpenTSDBTelnet = "opentsdb_telnet"
+)
+
+// streamed tells whether the format is written to a tcp connection instead of http
+func streamed(format string) bool {
+	return format == FormatGraphite || format == FormatOpenTSDBTelnet
+}
+
+// writeURL adds the database, bucket etc. of the influxdb options to the url
+func writeURL(opt config.WriterOption) (string, error) {
+	switch opt.Format {
+	case "", FormatPrometheus, FormatVMImport, FormatOpenTSDB:
+		return opt.Url, nil
+	case FormatGraphite, FormatOpenTSDBTelnet:
+		// host:port or tcp://host:port
+		return strings.TrimPrefix(opt.Url, "tcp://"), nil
+	case FormatInfluxDB:
+	default:
+		return "", fmt.Errorf("unknown format %q, should be prometheus, vm_import, influxdb, graphite, opentsdb or opentsdb_telnet", opt.Format)
+	}
+
+	u, err := url.Parse(opt.Url)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	params := map[string]string{
+		"db":     opt.InfluxDatabase,
+		"rp":     opt.InfluxRetentionPolicy,
+		"org":    opt.InfluxOrg,
+		"bucket": opt.InfluxBucket,
+	}
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	q.Set("precision", "ms")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// marshal encodes items in the format of the writer
+func (w Writer) marshal(items []prompb.TimeSeries) ([]byte, error) {
+	switch w.Opts.Format {
+	case FormatVMImport:
+		return marshalVMImport(items)
+	case FormatInfluxDB:
+		return marshalInfluxDB(items), nil
+	case FormatGraphite:
+		return w.marshalGraphite(items), nil
+	case FormatOpenTSDB:
+		return marshalOpenTSDB(items)
+	case FormatOpenTSDBTelnet:
+		return marshalOpenTSDBTelnet(items), nil
+	}
+
+	req := &prompb.WriteRequest{
+		Timeseries: items,
+	}
+	if w.Opts.SendMetadata {
+		req.Metadata = metadataOf(items)
+	}
+	return proto.Marshal(req)
+}
+
+func (w Writer) contentType() string {
+	switch w.Opts.Format {
+	case FormatVMImport:
+		return "application/stream+json"
+	case FormatInfluxDB:
+		return "text/plain; charset=utf-8"
+	case FormatOpenTSDB:
+		return "application/json"
+	}
+	return "application/x-protobuf"
+}
+
+type vmImportLine struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+// marshalVMImport writes one json line per series, json has no NaN, so stale
+// markers and other NaN or Inf samples are skipped
+func marshalVMImport(items []prompb.TimeSeries) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := jsoniter.ConfigCompatibleWithStandardLibrary.NewEncoder(&buf)
+	for i := range items {
+		line := vmImportLine{Metric: make(map[string]string, len(items[i].Labels))}
+		for _, l := range items[i].Labels {
+			line.Metric[l.Name] = l.Value
+		}
+		for _, s := range items[i].Samples {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			line.Values = append(line.Values, s.Value)
+			line.Timestamps = append(line.Timestamps, s.Timestamp)
+		}
+		if len(line.Values) == 0 {
+			continue
+		}
+		if err := enc.Encode(line); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// marshalInfluxDB writes one line per sample, the metric name is the
+// measurement, labels are tags and the value is the field "value"
+func marshalInfluxDB(items []prompb.TimeSeries) []byte {
+	var buf bytes.Buffer
+	for i := range items {
+		var (
+			name   string
+			labels = make([]prompb.Label, 0, len(items[i].Labels))
+		)
+		for _, l := range items[i].Labels {
+			if l.Name == model.MetricNameLabel {
+				name = l.Value
+			} else if l.Value != "" {
+				labels = append(labels, l)
+			}
+		}
+		if name == "" {
+			continue
+		}
+		sort.Slice(labels, func(a, b int) bool { return labels[a].Name < labels[b].Name })
+
+		var series bytes.Buffer
+		series.WriteString(measurementEscaper.Replace(name))
+		for _, l := range labels {
+			series.WriteByte(',')
+			series.WriteString(tagEscaper.Replace(l.Name))
+			series.WriteByte('=')
+			series.WriteString(tagEscaper.Replace(l.Value))
+		}
+
+		for _, s := range items[i].Samples {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			buf.Write(series.Bytes())
+			buf.WriteString(" value=")
+			buf.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatInt(s.Timestamp, 10))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
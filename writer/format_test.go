@@ -0,0 +1,76 @@
+package writer
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+var formatSeries = []prompb.TimeSeries{
+	{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: "cpu_usage_idle"},
+			{Name: "ident", Value: "web 01"},
+			{Name: "cpu", Value: "cpu-total"},
+			{Name: "empty", Value: ""},
+		},
+		Samples: []prompb.Sample{{Value: 97.5, Timestamp: 1700000000000}, {Value: math.NaN(), Timestamp: 1700000015000}},
+	},
+	{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+		Samples: []prompb.Sample{{Value: math.NaN(), Timestamp: 1700000000000}},
+	},
+}
+
+func TestMarshalInfluxDB(t *testing.T) {
+	require.Equal(t, "cpu_usage_idle,cpu=cpu-total,ident=web\\ 01 value=97.5 1700000000000\n",
+		string(marshalInfluxDB(formatSeries)))
+}
+
+func TestMarshalVMImport(t *testing.T) {
+	data, err := marshalVMImport(formatSeries)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"metric":{"__name__":"cpu_usage_idle","ident":"web 01","cpu":"cpu-total","empty":""},"values":[97.5],"timestamps":[1700000000000]}`, string(data))
+}
+
+func TestWriteInfluxDB(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := newWriter(config.WriterOption{
+		Url:          srv.URL + "/api/v2/write",
+		Format:       FormatInfluxDB,
+		InfluxToken:  "secret",
+		InfluxOrg:    "ops",
+		InfluxBucket: "metrics",
+		Timeout:      5000,
+		DialTimeout:  1000,
+	})
+	require.NoError(t, err)
+	w.Write(formatSeries)
+
+	require.NotNil(t, got)
+	require.Equal(t, "/api/v2/write", got.URL.Path)
+	require.Equal(t, "ops", got.URL.Query().Get("org"))
+	require.Equal(t, "metrics", got.URL.Query().Get("bucket"))
+	require.Equal(t, "ms", got.URL.Query().Get("precision"))
+	require.Equal(t, "Token secret", got.Header.Get("Authorization"))
+	require.Empty(t, got.Header.Get("Content-Encoding"))
+	require.Contains(t, string(body), "cpu_usage_idle,")
+
+	_, err = newWriter(config.WriterOption{Url: srv.URL, Format: "carbon"})
+	require.Error(t, err)
+}
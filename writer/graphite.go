@@ -0,0 +1,144 @@
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/pkg/filter"
+)
+
+// graphiteTemplate maps the series of the metrics matching filter to dot
+// paths, parts are label names, "name" for the metric name and "tags" for the
+// values of the other labels sorted by label name
+type graphiteTemplate struct {
+	filter filter.Filter
+	parts  []string
+}
+
+// compileGraphiteTemplates parses templates like "cpu_* ident.name.cpu", the
+// first one whose filter matches is used, a template without filter matches all
+func compileGraphiteTemplates(templates []string) ([]graphiteTemplate, error) {
+	ret := make([]graphiteTemplate, 0, len(templates))
+	for _, t := range templates {
+		fields := strings.Fields(t)
+		var tpl graphiteTemplate
+		switch len(fields) {
+		case 1:
+			tpl.parts = strings.Split(fields[0], ".")
+		case 2:
+			f, err := filter.Compile([]string{fields[0]})
+			if err != nil {
+				return nil, fmt.Errorf("graphite template %q: %v", t, err)
+			}
+			tpl.filter = f
+			tpl.parts = strings.Split(fields[1], ".")
+		default:
+			return nil, fmt.Errorf("graphite template %q should be [filter] template", t)
+		}
+		ret = append(ret, tpl)
+	}
+	return ret, nil
+}
+
+var graphiteSanitizer = strings.NewReplacer(".", "_", " ", "_", "/", "_", ";", "_", "=", "_", "~", "_", "\n", "_")
+
+// graphitePath returns the dot path of the series, or the tagged form
+// name;label=value;... if no template matches
+func (w Writer) graphitePath(ts prompb.TimeSeries) string {
+	var (
+		name   string
+		labels = make([]prompb.Label, 0, len(ts.Labels))
+	)
+	for _, l := range ts.Labels {
+		if l.Name == model.MetricNameLabel {
+			name = l.Value
+		} else if l.Value != "" {
+			labels = append(labels, l)
+		}
+	}
+	if name == "" {
+		return ""
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+	var tpl *graphiteTemplate
+	for i := range w.graphite {
+		if w.graphite[i].filter == nil || w.graphite[i].filter.Match(name) {
+			tpl = &w.graphite[i]
+			break
+		}
+	}
+
+	var sb strings.Builder
+	if w.Opts.GraphitePrefix != "" {
+		sb.WriteString(w.Opts.GraphitePrefix)
+		sb.WriteByte('.')
+	}
+	if tpl == nil {
+		sb.WriteString(graphiteSanitizer.Replace(name))
+		for _, l := range labels {
+			sb.WriteByte(';')
+			sb.WriteString(graphiteSanitizer.Replace(l.Name))
+			sb.WriteByte('=')
+			sb.WriteString(graphiteSanitizer.Replace(l.Value))
+		}
+		return sb.String()
+	}
+
+	used := make(map[string]struct{}, len(tpl.parts))
+	for _, part := range tpl.parts {
+		used[part] = struct{}{}
+	}
+	var path []string
+	for _, part := range tpl.parts {
+		switch part {
+		case "name":
+			path = append(path, name)
+		case "tags":
+			for _, l := range labels {
+				if _, has := used[l.Name]; !has {
+					path = append(path, l.Value)
+				}
+			}
+		default:
+			if v := labelValue(ts, part); v != "" {
+				path = append(path, v)
+			}
+		}
+	}
+	for i := range path {
+		path[i] = graphiteSanitizer.Replace(path[i])
+	}
+	sb.WriteString(strings.Join(path, "."))
+	return sb.String()
+}
+
+// marshalGraphite writes the plaintext protocol, timestamps in seconds
+func (w Writer) marshalGraphite(items []prompb.TimeSeries) []byte {
+	var buf bytes.Buffer
+	for i := range items {
+		path := w.graphitePath(items[i])
+		if path == "" {
+			continue
+		}
+		for _, s := range items[i].Samples {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			buf.WriteString(path)
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatInt(s.Timestamp/1000, 10))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
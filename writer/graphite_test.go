@@ -0,0 +1,72 @@
+package writer
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestGraphitePath(t *testing.T) {
+	graphite, err := compileGraphiteTemplates([]string{"cpu_* ident.name.cpu", "ident.tags.name"})
+	require.NoError(t, err)
+	w := Writer{Opts: config.WriterOption{GraphitePrefix: "categraf"}, graphite: graphite}
+
+	ts := prompb.TimeSeries{Labels: []prompb.Label{
+		{Name: "__name__", Value: "cpu_usage_idle"},
+		{Name: "ident", Value: "web01.bj"},
+		{Name: "cpu", Value: "cpu-total"},
+	}}
+	require.Equal(t, "categraf.web01_bj.cpu_usage_idle.cpu-total", w.graphitePath(ts))
+
+	ts = prompb.TimeSeries{Labels: []prompb.Label{
+		{Name: "__name__", Value: "disk_used_percent"},
+		{Name: "path", Value: "/data"},
+		{Name: "ident", Value: "web01"},
+		{Name: "fstype", Value: "ext4"},
+	}}
+	require.Equal(t, "categraf.web01.ext4._data.disk_used_percent", w.graphitePath(ts))
+
+	w = Writer{}
+	require.Equal(t, "disk_used_percent;fstype=ext4;ident=web01;path=_data", w.graphitePath(ts))
+
+	_, err = compileGraphiteTemplates([]string{"a b c"})
+	require.Error(t, err)
+}
+
+func TestWriteGraphite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	w, err := newWriter(config.WriterOption{Url: "tcp://" + ln.Addr().String(), Format: FormatGraphite, Timeout: 5000, DialTimeout: 1000})
+	require.NoError(t, err)
+	w.Write(formatSeries)
+
+	var got []string
+	for l := range lines {
+		got = append(got, l)
+	}
+	require.Equal(t, []string{"cpu_usage_idle;cpu=cpu-total;ident=web_01 97.5 1700000000"}, got)
+
+	_, err = newWriter(config.WriterOption{Url: ln.Addr().String(), Format: FormatGraphite, Compression: "gzip"})
+	require.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package writer
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// self metrics are still written during maintenance in drop mode
+const selfMetricsPrefix = "categraf_"
+
+// Maintenance silences the host for planned work, either set by the api or
+// by the marker file
+type Maintenance struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+	Source string    `json:"source"`
+	Mode   string    `json:"mode"`
+}
+
+var maintenance struct {
+	sync.Mutex
+	// set by the api
+	api Maintenance
+	// the marker file is checked at most once a second
+	file      Maintenance
+	fileCheck time.Time
+}
+
+// SetMaintenance starts a maintenance of ttl, capped by max_ttl
+func SetMaintenance(ttl time.Duration, reason string) Maintenance {
+	opt := config.Config.Maintenance
+	if ttl <= 0 {
+		ttl = time.Duration(opt.DefaultTTL)
+	}
+	if ttl > time.Duration(opt.MaxTTL) {
+		ttl = time.Duration(opt.MaxTTL)
+	}
+
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	maintenance.api = Maintenance{
+		Until:  time.Now().Add(ttl),
+		Reason: reason,
+		Source: "api",
+		Mode:   opt.Mode,
+	}
+	return maintenance.api
+}
+
+// ClearMaintenance ends the maintenance set by the api, the marker file has to be removed
+func ClearMaintenance() {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	maintenance.api = Maintenance{}
+}
+
+// MaintenanceStatus returns the maintenance in effect, the one ending last if
+// both api and marker file set one
+func MaintenanceStatus() (Maintenance, bool) {
+	if config.Config == nil {
+		return Maintenance{}, false
+	}
+	now := time.Now()
+	maintenance.Lock()
+	defer maintenance.Unlock()
+
+	if now.Sub(maintenance.fileCheck) >= time.Second {
+		maintenance.fileCheck = now
+		maintenance.file = readMarkerFile(config.Config.Maintenance)
+	}
+
+	m := maintenance.api
+	if maintenance.file.Until.After(m.Until) {
+		m = maintenance.file
+	}
+	return m, m.Until.After(now)
+}
+
+// readMarkerFile returns the maintenance of the marker file, which lasts the ttl
+// written in the file or default_ttl since its modification
+func readMarkerFile(opt config.MaintenanceConfig) Maintenance {
+	if opt.MarkerFile == "" {
+		return Maintenance{}
+	}
+	info, err := os.Stat(opt.MarkerFile)
+	if err != nil {
+		return Maintenance{}
+	}
+
+	ttl := time.Duration(opt.DefaultTTL)
+	if bs, err := os.ReadFile(opt.MarkerFile); err == nil {
+		if s := strings.TrimSpace(string(bs)); s != "" {
+			if d, err := time.ParseDuration(s); err == nil && d > 0 {
+				ttl = d
+			}
+		}
+	}
+	if ttl > time.Duration(opt.MaxTTL) {
+		ttl = time.Duration(opt.MaxTTL)
+	}
+	return Maintenance{
+		Until:  info.ModTime().Add(ttl),
+		Reason: opt.MarkerFile,
+		Source: "file",
+		Mode:   opt.Mode,
+	}
+}
+
+// applyMaintenance drops or tags the series during maintenance
+func applyMaintenance(timeSeries []prompb.TimeSeries) []prompb.TimeSeries {
+	m, on := MaintenanceStatus()
+	if !on {
+		return timeSeries
+	}
+
+	ret := make([]prompb.TimeSeries, 0, len(timeSeries))
+	for _, ts := range timeSeries {
+		if m.Mode == "tag" {
+			ts.Labels = withLabel(ts.Labels, "maintenance", "true")
+			ret = append(ret, ts)
+			continue
+		}
+		if strings.HasPrefix(seriesName(ts), selfMetricsPrefix) {
+			ret = append(ret, ts)
+		}
+	}
+	return ret
+}
+
+// withLabel returns a copy of the sorted labels with name set to value, kept
+// sorted since remote write receivers reject labels out of order
+func withLabel(labels []prompb.Label, name, value string) []prompb.Label {
+	i := sort.Search(len(labels), func(i int) bool { return labels[i].Name >= name })
+	ret := make([]prompb.Label, 0, len(labels)+1)
+	ret = append(ret, labels[:i]...)
+	ret = append(ret, prompb.Label{Name: name, Value: value})
+	if i < len(labels) && labels[i].Name == name {
+		i++
+	}
+	return append(ret, labels[i:]...)
+}
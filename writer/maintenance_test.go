@@ -0,0 +1,73 @@
+package writer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func series(name string) prompb.TimeSeries {
+	return prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: name}}}
+}
+
+func TestApplyMaintenance(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "maintenance")
+	config.Config = &config.ConfigType{Maintenance: config.MaintenanceConfig{
+		Mode:       "drop",
+		MarkerFile: marker,
+		DefaultTTL: config.Duration(time.Hour),
+		MaxTTL:     config.Duration(2 * time.Hour),
+	}}
+	defer func() { config.Config = nil }()
+
+	input := []prompb.TimeSeries{series("cpu_usage_idle"), series("categraf_info")}
+	require.Len(t, applyMaintenance(input), 2)
+
+	m := SetMaintenance(10*time.Hour, "kernel upgrade")
+	require.WithinDuration(t, time.Now().Add(2*time.Hour), m.Until, time.Minute)
+	out := applyMaintenance(input)
+	require.Equal(t, []prompb.TimeSeries{series("categraf_info")}, out)
+
+	config.Config.Maintenance.Mode = "tag"
+	SetMaintenance(0, "")
+	out = applyMaintenance(input)
+	require.Len(t, out, 2)
+	// __name__ sorts before maintenance
+	require.Equal(t, prompb.Label{Name: "maintenance", Value: "true"}, out[0].Labels[1])
+	require.Len(t, input[0].Labels, 1)
+
+	ClearMaintenance()
+	require.Len(t, applyMaintenance(input)[0].Labels, 1)
+
+	// the marker file counts from its mtime
+	require.NoError(t, os.WriteFile(marker, []byte("30m\n"), 0644))
+	maintenance.fileCheck = time.Time{}
+	m, on := MaintenanceStatus()
+	require.True(t, on)
+	require.Equal(t, "file", m.Source)
+	require.WithinDuration(t, time.Now().Add(30*time.Minute), m.Until, time.Minute)
+
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(marker, old, old))
+	maintenance.fileCheck = time.Time{}
+	_, on = MaintenanceStatus()
+	require.False(t, on)
+}
+
+func TestWithLabel(t *testing.T) {
+	labels := []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "ident", Value: "web01"}, {Name: "zone", Value: "a"}}
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "ident", Value: "web01"},
+		{Name: "maintenance", Value: "true"}, {Name: "zone", Value: "a"}}, withLabel(labels, "maintenance", "true"))
+	require.Len(t, labels, 3)
+
+	// an existing label is replaced, not duplicated
+	labels = []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "maintenance", Value: "false"}}
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "maintenance", Value: "true"}},
+		withLabel(labels, "maintenance", "true"))
+}
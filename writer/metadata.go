@@ -0,0 +1,160 @@
+package writer
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// metadata of the series not written for metadataTTL is forgotten
+const metadataTTL = time.Hour
+
+var (
+	// *metadataEntry of the typed series seen, keyed by series name
+	metadata sync.Map
+	// 1 if any writer sends metadata, nothing is recorded otherwise
+	metadataEnabled int32
+)
+
+type metadataEntry struct {
+	md prompb.MetricMetadata
+	// unix seconds the series was last seen
+	seen int64
+}
+
+func enableMetadata() {
+	if atomic.CompareAndSwapInt32(&metadataEnabled, 0, 1) {
+		go pruneMetadataLoop()
+	}
+}
+
+func pruneMetadataLoop() {
+	ticker := time.NewTicker(metadataTTL / 6)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		pruneMetadata(now.Add(-metadataTTL))
+	}
+}
+
+// pruneMetadata forgets the metadata of the series not seen since before
+func pruneMetadata(before time.Time) {
+	metadata.Range(func(k, v interface{}) bool {
+		if atomic.LoadInt64(&v.(*metadataEntry).seen) < before.Unix() {
+			metadata.Delete(k)
+		}
+		return true
+	})
+}
+
+func storeMetadata(name string, md prompb.MetricMetadata, now int64) {
+	if v, ok := metadata.Load(name); ok {
+		e := v.(*metadataEntry)
+		if e.md.Type == md.Type && e.md.MetricFamilyName == md.MetricFamilyName && e.md.Unit == md.Unit && e.md.Help == md.Help {
+			atomic.StoreInt64(&e.seen, now)
+			return
+		}
+	}
+	metadata.Store(name, &metadataEntry{md: md, seen: now})
+}
+
+var metadataTypes = map[types.ValueType]prompb.MetricMetadata_MetricType{
+	types.Counter:   prompb.MetricMetadata_COUNTER,
+	types.Gauge:     prompb.MetricMetadata_GAUGE,
+	types.Histogram: prompb.MetricMetadata_HISTOGRAM,
+	types.Summary:   prompb.MetricMetadata_SUMMARY,
+}
+
+func recordMetadata(s *types.Sample) {
+	if atomic.LoadInt32(&metadataEnabled) == 0 {
+		return
+	}
+	typ, has := metadataTypes[s.Type]
+	if !has && s.Unit == "" {
+		return
+	}
+
+	md := prompb.MetricMetadata{
+		Type:             typ,
+		MetricFamilyName: familyName(s.Metric, s.Type),
+		Unit:             s.Unit,
+	}
+	storeMetadata(s.Metric, md, time.Now().Unix())
+}
+
+// RecordMetadata keeps the metadata of forwarded remote write requests
+func RecordMetadata(mds []prompb.MetricMetadata) {
+	if atomic.LoadInt32(&metadataEnabled) == 0 {
+		return
+	}
+	now := time.Now().Unix()
+	for _, md := range mds {
+		if md.MetricFamilyName == "" {
+			continue
+		}
+		storeMetadata(md.MetricFamilyName, md, now)
+		switch md.Type {
+		case prompb.MetricMetadata_HISTOGRAM, prompb.MetricMetadata_GAUGEHISTOGRAM:
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				storeMetadata(md.MetricFamilyName+suffix, md, now)
+			}
+		case prompb.MetricMetadata_SUMMARY:
+			for _, suffix := range []string{"_sum", "_count"} {
+				storeMetadata(md.MetricFamilyName+suffix, md, now)
+			}
+		}
+	}
+}
+
+// metadataOf returns the metadata of the metric families in items, one per family
+func metadataOf(items []prompb.TimeSeries) []prompb.MetricMetadata {
+	var (
+		ret  []prompb.MetricMetadata
+		seen = make(map[string]struct{})
+	)
+	for i := range items {
+		name := seriesName(items[i])
+		v, ok := metadata.Load(name)
+		if !ok {
+			continue
+		}
+		md := v.(*metadataEntry).md
+		if _, has := seen[md.MetricFamilyName]; has {
+			continue
+		}
+		seen[md.MetricFamilyName] = struct{}{}
+		ret = append(ret, md)
+	}
+	return ret
+}
+
+func seriesName(ts prompb.TimeSeries) string {
+	for _, l := range ts.Labels {
+		if l.Name == model.MetricNameLabel {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// familyName strips the series suffixes of histograms and summaries
+func familyName(metric string, typ types.ValueType) string {
+	var suffixes []string
+	switch typ {
+	case types.Histogram:
+		suffixes = []string{"_bucket", "_sum", "_count"}
+	case types.Summary:
+		suffixes = []string{"_sum", "_count"}
+	}
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(metric, suffix) {
+			return strings.TrimSuffix(metric, suffix)
+		}
+	}
+	return metric
+}
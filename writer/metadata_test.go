@@ -0,0 +1,33 @@
+package writer
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestRecordMetadata(t *testing.T) {
+	defer atomic.StoreInt32(&metadataEnabled, 0)
+	ts := []prompb.TimeSeries{{Labels: []prompb.Label{{Name: "__name__", Value: "http_requests_total"}}}}
+	sample := types.NewSample("", "http_requests_total", 1).SetType(types.Counter)
+
+	// no writer sends metadata
+	recordMetadata(sample)
+	require.Empty(t, metadataOf(ts))
+
+	atomic.StoreInt32(&metadataEnabled, 1)
+	recordMetadata(sample)
+	mds := metadataOf(ts)
+	require.Len(t, mds, 1)
+	require.Equal(t, prompb.MetricMetadata_COUNTER, mds[0].Type)
+
+	pruneMetadata(time.Now().Add(-time.Minute))
+	require.Len(t, metadataOf(ts), 1)
+	pruneMetadata(time.Now().Add(time.Minute))
+	require.Empty(t, metadataOf(ts))
+}
@@ -0,0 +1,93 @@
+package writer
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// openTSDBSanitize replaces the characters opentsdb rejects in names and tag values
+func openTSDBSanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./", r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// openTSDBPoints converts the series, skipping NaN and Inf which opentsdb can't store
+func openTSDBPoints(items []prompb.TimeSeries) []openTSDBPoint {
+	var points []openTSDBPoint
+	for i := range items {
+		var metric string
+		tags := make(map[string]string, len(items[i].Labels))
+		for _, l := range items[i].Labels {
+			if l.Name == model.MetricNameLabel {
+				metric = openTSDBSanitize(l.Value)
+			} else if l.Value != "" {
+				tags[openTSDBSanitize(l.Name)] = openTSDBSanitize(l.Value)
+			}
+		}
+		if metric == "" {
+			continue
+		}
+		for _, s := range items[i].Samples {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			points = append(points, openTSDBPoint{Metric: metric, Timestamp: s.Timestamp, Value: s.Value, Tags: tags})
+		}
+	}
+	return points
+}
+
+// marshalOpenTSDB encodes the body of the /api/put http api, timestamps in milliseconds
+func marshalOpenTSDB(items []prompb.TimeSeries) ([]byte, error) {
+	points := openTSDBPoints(items)
+	if points == nil {
+		points = []openTSDBPoint{}
+	}
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(points)
+}
+
+// marshalOpenTSDBTelnet writes put lines of the telnet protocol
+func marshalOpenTSDBTelnet(items []prompb.TimeSeries) []byte {
+	var buf bytes.Buffer
+	for _, p := range openTSDBPoints(items) {
+		buf.WriteString("put ")
+		buf.WriteString(p.Metric)
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(p.Timestamp, 10))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(p.Value, 'g', -1, 64))
+
+		keys := make([]string, 0, len(p.Tags))
+		for k := range p.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteByte(' ')
+			buf.WriteString(k)
+			buf.WriteByte('=')
+			buf.WriteString(p.Tags[k])
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,15 @@
+package writer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalOpenTSDB(t *testing.T) {
+	data, err := marshalOpenTSDB(formatSeries)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"metric":"cpu_usage_idle","timestamp":1700000000000,"value":97.5,"tags":{"cpu":"cpu-total","ident":"web_01"}}]`, string(data))
+
+	require.Equal(t, "put cpu_usage_idle 1700000000000 97.5 cpu=cpu-total ident=web_01\n", string(marshalOpenTSDBTelnet(formatSeries)))
+}
@@ -0,0 +1,101 @@
+package writer
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// batches queued for a writer at least, however small chan_size is
+const minWriterQueueSize = 16
+
+var (
+	writeDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_dropped_series_total",
+		Help: "Series not written since the queue of the writer was full.",
+	}, []string{"url"})
+
+	writeQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "writer_queued_series",
+		Help: "Series waiting in the queue of the writer or being written.",
+	}, []string{"url"})
+)
+
+func init() {
+	prometheus.MustRegister(writeDropped, writeQueued)
+}
+
+// writerQueueSize is the batches queued for a writer: writer_opt.chan_size
+// series in batches of writer_opt.batch, so a slow or down writer buffers as
+// many series as the queue all samples pass through
+func writerQueueSize() int {
+	opt := config.Config.WriterOpt
+	size := minWriterQueueSize
+	if opt.Batch > 0 && opt.ChanSize/opt.Batch > size {
+		size = opt.ChanSize / opt.Batch
+	}
+	return size
+}
+
+// writerQueue feeds a writer from its own goroutine, so a slow or rate
+// limited endpoint only holds back its own series
+type writerQueue struct {
+	url   string
+	write func([]prompb.TimeSeries)
+	queue chan []prompb.TimeSeries
+	// batches queued or being written
+	pending int32
+	// series queued or being written
+	series int64
+}
+
+func newWriterQueue(w Writer) *writerQueue {
+	q := &writerQueue{
+		url:   w.Opts.Url,
+		write: w.Write,
+		queue: make(chan []prompb.TimeSeries, writerQueueSize()),
+	}
+	go q.loop()
+	return q
+}
+
+func (q *writerQueue) loop() {
+	for batch := range q.queue {
+		q.write(batch)
+		q.done(batch)
+	}
+}
+
+// offer queues batch without waiting, it's dropped if the queue is full
+func (q *writerQueue) offer(batch []prompb.TimeSeries) bool {
+	atomic.AddInt32(&q.pending, 1)
+	atomic.AddInt64(&q.series, int64(len(batch)))
+	writeQueued.WithLabelValues(q.url).Add(float64(len(batch)))
+	select {
+	case q.queue <- batch:
+		return true
+	default:
+		q.done(batch)
+		writeDropped.WithLabelValues(q.url).Add(float64(len(batch)))
+		return false
+	}
+}
+
+func (q *writerQueue) done(batch []prompb.TimeSeries) {
+	atomic.AddInt32(&q.pending, -1)
+	atomic.AddInt64(&q.series, -int64(len(batch)))
+	writeQueued.WithLabelValues(q.url).Sub(float64(len(batch)))
+}
+
+// queued is the series waiting for the writer
+func (q *writerQueue) queued() int64 {
+	return atomic.LoadInt64(&q.series)
+}
+
+// idle reports if every batch offered is written
+func (q *writerQueue) idle() bool {
+	return atomic.LoadInt32(&q.pending) == 0
+}
@@ -0,0 +1,63 @@
+package writer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestDispatchSlowWriter(t *testing.T) {
+	config.Config = &config.ConfigType{}
+	defer func() { config.Config = nil }()
+
+	release := make(chan struct{})
+	written := make(chan int, 4)
+	slow := &writerQueue{url: "slow-test", queue: make(chan []prompb.TimeSeries, 1),
+		write: func([]prompb.TimeSeries) { <-release }}
+	fast := &writerQueue{url: "fast-test", queue: make(chan []prompb.TimeSeries, 4),
+		write: func(batch []prompb.TimeSeries) { written <- len(batch) }}
+	go slow.loop()
+	go fast.loop()
+	dropped := testutil.ToFloat64(writeDropped.WithLabelValues("slow-test"))
+	ws := &Writers{queues: map[string]*writerQueue{"slow": slow, "fast": fast}}
+
+	batch := []prompb.TimeSeries{labeledSeries("up", "env", "prod")}
+	batches := map[string][]prompb.TimeSeries{"slow": batch, "fast": batch}
+	ws.dispatch(batches)
+	require.Eventually(t, func() bool { return len(slow.queue) == 0 }, time.Second, time.Millisecond)
+	ws.dispatch(batches)
+	ws.dispatch(batches)
+	// the slow writer holds one batch, queues one and drops the last
+	for i := 0; i < 3; i++ {
+		select {
+		case n := <-written:
+			require.Equal(t, 1, n)
+		case <-time.After(time.Second):
+			t.Fatal("the fast writer waited for the slow one")
+		}
+	}
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(writeDropped.WithLabelValues("slow-test")) == dropped+1
+	}, time.Second, 10*time.Millisecond)
+	require.False(t, ws.idle())
+	// one batch being written and one queued
+	require.Equal(t, int64(2), ws.backlog())
+	require.Equal(t, 2.0, testutil.ToFloat64(writeQueued.WithLabelValues("slow-test")))
+
+	close(release)
+	require.Eventually(t, ws.idle, time.Second, 10*time.Millisecond)
+}
+
+func TestWriterQueueSize(t *testing.T) {
+	config.Config = &config.ConfigType{WriterOpt: config.WriterOpt{Batch: 1000, ChanSize: 1000000}}
+	defer func() { config.Config = nil }()
+	require.Equal(t, 1000, writerQueueSize())
+
+	config.Config.WriterOpt.ChanSize = 2000
+	require.Equal(t, minWriterQueueSize, writerQueueSize())
+}
@@ -0,0 +1,94 @@
+package writer
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// batches a shadow writer buffers, further ones are dropped
+const shadowQueueSize = 64
+
+var shadowDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "writer_shadow_dropped_series_total",
+	Help: "Series not copied to a shadow writer since its queue was full.",
+}, []string{"url"})
+
+func init() {
+	prometheus.MustRegister(shadowDropped)
+}
+
+// shadowWriter writes a copy of the series in the background, so a slow or
+// failing endpoint doesn't hold back the other writers. Its requests are
+// counted by writer_requests_total of its url like any writer.
+type shadowWriter struct {
+	Writer
+	// series with a hash below are copied
+	threshold uint64
+	queue     chan []prompb.TimeSeries
+}
+
+func checkShadow(opt config.WriterOption) error {
+	if opt.ShadowPercent < 0 || opt.ShadowPercent > 100 {
+		return fmt.Errorf("writer %s: shadow_percent should be in [0, 100], got %v", opt.Url, opt.ShadowPercent)
+	}
+	if opt.ShardGroup != "" || opt.Fallback {
+		return fmt.Errorf("writer %s: a shadow writer can't have shard_group or fallback", opt.Url)
+	}
+	return nil
+}
+
+func newShadowWriter(w Writer) *shadowWriter {
+	percent := w.Opts.ShadowPercent
+	if percent == 0 {
+		percent = 100
+	}
+	s := &shadowWriter{
+		Writer: w,
+		queue:  make(chan []prompb.TimeSeries, shadowQueueSize),
+	}
+	if percent >= 100 {
+		s.threshold = ^uint64(0)
+	} else {
+		s.threshold = uint64(percent / 100 * float64(^uint64(0)))
+	}
+	go s.loop()
+	return s
+}
+
+func (s *shadowWriter) loop() {
+	for batch := range s.queue {
+		s.Write(batch)
+	}
+}
+
+// offer queues the copied part of timeSeries without waiting
+func (s *shadowWriter) offer(timeSeries []prompb.TimeSeries) {
+	var batch []prompb.TimeSeries
+	for i := range timeSeries {
+		if s.copies(timeSeries[i]) {
+			batch = append(batch, timeSeries[i])
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	select {
+	case s.queue <- batch:
+	default:
+		shadowDropped.WithLabelValues(s.Opts.Url).Add(float64(len(batch)))
+	}
+}
+
+func (s *shadowWriter) copies(ts prompb.TimeSeries) bool {
+	if !s.matches(ts) {
+		return false
+	}
+	if s.threshold == ^uint64(0) {
+		return true
+	}
+	return mix64(seriesHash(ts)) < s.threshold
+}
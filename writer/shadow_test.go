@@ -0,0 +1,45 @@
+package writer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestShadowWriter(t *testing.T) {
+	require.Error(t, checkShadow(config.WriterOption{Url: "a", Shadow: true, ShadowPercent: 120}))
+	require.Error(t, checkShadow(config.WriterOption{Url: "a", Shadow: true, ShardGroup: "vm"}))
+	require.NoError(t, checkShadow(config.WriterOption{Url: "a", Shadow: true, ShadowPercent: 10}))
+
+	input := make([]prompb.TimeSeries, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		input = append(input, labeledSeries("cpu_usage_idle", "cpu", fmt.Sprint(i), "env", "prod"))
+	}
+
+	// not started, so the queue fills up
+	s := &shadowWriter{Writer: Writer{Opts: config.WriterOption{Url: "shadow-test"}}, queue: make(chan []prompb.TimeSeries, 1)}
+	s.threshold = ^uint64(0) / 4
+	s.offer(input)
+	batch := <-s.queue
+	require.InDelta(t, 250, len(batch), 60)
+
+	// the same series are picked every time
+	s.offer(input)
+	require.Equal(t, batch, <-s.queue)
+
+	matchers, err := compileMatchers(map[string]string{"env": "test"})
+	require.NoError(t, err)
+	s.matchers = matchers
+	s.offer(input)
+	require.Empty(t, s.queue)
+
+	s.matchers = nil
+	s.offer(input)
+	s.offer(input)
+	require.Equal(t, float64(len(batch)), testutil.ToFloat64(shadowDropped.WithLabelValues("shadow-test")))
+}
@@ -0,0 +1,123 @@
+package writer
+
+import (
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// route splits series among the writers. Writers without shard_group receive
+// every series, writers of the same shard_group share them: each series goes
+// to one member picked by rendezvous hashing of its labels, so adding or
+// removing an endpoint only moves the series of that endpoint. Writers with
+// match_labels or fallback only take part for the series they accept.
+func (ws *Writers) route(timeSeries []prompb.TimeSeries) map[string][]prompb.TimeSeries {
+	batches := make(map[string][]prompb.TimeSeries, len(ws.writerMap))
+	matched := ws.matched(timeSeries)
+	for _, key := range ws.broadcast {
+		w := ws.writerMap[key]
+		if !w.routed() {
+			batches[key] = timeSeries
+			continue
+		}
+		for i := range timeSeries {
+			if w.accepts(timeSeries[i], matched[i]) {
+				batches[key] = append(batches[key], timeSeries[i])
+			}
+		}
+	}
+
+	for _, members := range ws.shardGroups {
+		if len(members) == 1 && !ws.writerMap[members[0]].routed() {
+			batches[members[0]] = timeSeries
+			continue
+		}
+		candidates := make([]string, 0, len(members))
+		for i := range timeSeries {
+			candidates = candidates[:0]
+			for _, m := range members {
+				if ws.writerMap[m].accepts(timeSeries[i], matched[i]) {
+					candidates = append(candidates, m)
+				}
+			}
+			if len(candidates) == 0 {
+				continue
+			}
+			key := pickShard(seriesHash(timeSeries[i]), candidates)
+			batches[key] = append(batches[key], timeSeries[i])
+		}
+	}
+	return batches
+}
+
+// matched tells for each series whether a writer with match_labels receives
+// it, only computed if there are fallback writers
+func (ws *Writers) matched(timeSeries []prompb.TimeSeries) []bool {
+	var selective []Writer
+	fallback := false
+	for _, w := range ws.writerMap {
+		if w.Opts.Fallback {
+			fallback = true
+		} else if len(w.matchers) > 0 {
+			selective = append(selective, w)
+		}
+	}
+	if !fallback {
+		return make([]bool, len(timeSeries))
+	}
+
+	ret := make([]bool, len(timeSeries))
+	for i := range timeSeries {
+		for _, w := range selective {
+			if w.matches(timeSeries[i]) {
+				ret[i] = true
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func pickShard(h uint64, members []string) string {
+	var (
+		best  string
+		score uint64
+	)
+	for _, m := range members {
+		s := xxhash.Sum64String(m) ^ h
+		// mix again so members with similar names don't correlate
+		s = mix64(s)
+		if best == "" || s > score {
+			best, score = m, s
+		}
+	}
+	return best
+}
+
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// seriesHash hashes metric name and labels regardless of the label order
+func seriesHash(ts prompb.TimeSeries) uint64 {
+	labels := ts.Labels
+	if !sort.SliceIsSorted(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name }) {
+		labels = append([]prompb.Label(nil), labels...)
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	}
+
+	d := xxhash.New()
+	for _, l := range labels {
+		d.WriteString(l.Name)
+		d.Write([]byte{0xff})
+		d.WriteString(l.Value)
+		d.Write([]byte{0xff})
+	}
+	return d.Sum64()
+}
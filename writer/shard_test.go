@@ -0,0 +1,74 @@
+package writer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestRoute(t *testing.T) {
+	ws := &Writers{
+		writerMap: map[string]Writer{
+			"http://all/write": {},
+			"http://vm1/write": {},
+			"http://vm2/write": {},
+			"http://vm3/write": {},
+		},
+		broadcast:   []string{"http://all/write"},
+		shardGroups: map[string][]string{"vm": {"http://vm1/write", "http://vm2/write", "http://vm3/write"}},
+	}
+
+	series := make([]prompb.TimeSeries, 300)
+	for i := range series {
+		series[i].Labels = []prompb.Label{
+			{Name: "__name__", Value: "cpu_usage_idle"},
+			{Name: "ident", Value: fmt.Sprintf("host-%d", i)},
+		}
+	}
+
+	batches := ws.route(series)
+	require.Len(t, batches["http://all/write"], len(series))
+
+	total := 0
+	for _, key := range ws.shardGroups["vm"] {
+		require.NotEmpty(t, batches[key])
+		total += len(batches[key])
+	}
+	require.Equal(t, len(series), total)
+
+	// label order doesn't matter
+	reversed := prompb.TimeSeries{Labels: []prompb.Label{series[0].Labels[1], series[0].Labels[0]}}
+	require.Equal(t, seriesHash(series[0]), seriesHash(reversed))
+}
+
+func TestRouteByLabels(t *testing.T) {
+	newTestWriter := func(opt config.WriterOption) Writer {
+		matchers, err := compileMatchers(opt.MatchLabels)
+		require.NoError(t, err)
+		return Writer{Opts: opt, matchers: matchers}
+	}
+	ws := &Writers{
+		writerMap: map[string]Writer{
+			"http://team-a/write": newTestWriter(config.WriterOption{MatchLabels: map[string]string{"team": "a"}}),
+			"http://prod/write":   newTestWriter(config.WriterOption{MatchLabels: map[string]string{"env": "prod|staging"}}),
+			"http://other/write":  newTestWriter(config.WriterOption{Fallback: true}),
+		},
+		broadcast: []string{"http://team-a/write", "http://prod/write", "http://other/write"},
+	}
+
+	series := []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "a"}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "a"}, {Name: "env", Value: "prod"}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "ab"}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}}},
+	}
+
+	batches := ws.route(series)
+	require.Equal(t, []prompb.TimeSeries{series[0], series[1]}, batches["http://team-a/write"])
+	require.Equal(t, []prompb.TimeSeries{series[1]}, batches["http://prod/write"])
+	require.Equal(t, []prompb.TimeSeries{series[2], series[3]}, batches["http://other/write"])
+}
@@ -0,0 +1,40 @@
+package writer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stats of the remote write requests, reported by the self_metrics input
+var (
+	writeRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_requests_total",
+		Help: "Remote write requests sent, by writer and result.",
+	}, []string{"url", "result"})
+
+	writeUncompressedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_uncompressed_bytes_total",
+		Help: "Bytes of the marshaled remote write requests before compression.",
+	}, []string{"url"})
+
+	writeCompressedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_compressed_bytes_total",
+		Help: "Bytes of the remote write requests sent on the wire.",
+	}, []string{"url", "compression"})
+
+	writeBatchSeries = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "writer_batch_series",
+		Help:    "Series per remote write request.",
+		Buckets: prometheus.ExponentialBuckets(10, 4, 7),
+	}, []string{"url"})
+
+	writeBatchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "writer_batch_duration_seconds",
+		Help:    "Latency of remote write requests, including compression.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"url"})
+)
+
+func init() {
+	prometheus.MustRegister(writeRequests, writeUncompressedBytes, writeCompressedBytes,
+		writeBatchSeries, writeBatchDuration)
+}
@@ -0,0 +1,73 @@
+package writer
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const defaultTenantHeader = "X-Scope-OrgID"
+
+// compileMatchers anchors the regexps of match_labels like prometheus does
+func compileMatchers(matchLabels map[string]string) (map[string]*regexp.Regexp, error) {
+	if len(matchLabels) == 0 {
+		return nil, nil
+	}
+	matchers := make(map[string]*regexp.Regexp, len(matchLabels))
+	for name, expr := range matchLabels {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("match_labels %s: %v", name, err)
+		}
+		matchers[name] = re
+	}
+	return matchers, nil
+}
+
+// routed tells whether the writer filters series by match_labels or fallback
+func (w Writer) routed() bool {
+	return len(w.matchers) > 0 || w.Opts.Fallback
+}
+
+// matches reports whether ts matches every match_labels of the writer, a
+// missing label is taken as empty
+func (w Writer) matches(ts prompb.TimeSeries) bool {
+	for name, re := range w.matchers {
+		if !re.MatchString(labelValue(ts, name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// accepts reports whether the writer receives ts, matched tells whether a
+// writer with match_labels receives it
+func (w Writer) accepts(ts prompb.TimeSeries, matched bool) bool {
+	if w.Opts.Fallback && matched {
+		return false
+	}
+	return w.matches(ts)
+}
+
+// byTenant groups the series by the value of tenant_label
+func (w Writer) byTenant(items []prompb.TimeSeries) map[string][]prompb.TimeSeries {
+	groups := make(map[string][]prompb.TimeSeries)
+	for i := range items {
+		tenant := labelValue(items[i], w.Opts.TenantLabel)
+		if tenant == "" {
+			tenant = w.Opts.DefaultTenant
+		}
+		groups[tenant] = append(groups[tenant], items[i])
+	}
+	return groups
+}
+
+func labelValue(ts prompb.TimeSeries, name string) string {
+	for _, l := range ts.Labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package writer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestWriteTenantHeader(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		tenants []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tenants = append(tenants, r.Header.Get("X-Scope-OrgID"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	w, err := newWriter(config.WriterOption{Url: srv.URL, TenantLabel: "team", DefaultTenant: "infra", Timeout: 5000, DialTimeout: 1000})
+	require.NoError(t, err)
+
+	w.Write([]prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "a"}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "b"}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "a"}, {Name: "env", Value: "prod"}}},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "up"}}},
+	})
+
+	sort.Strings(tenants)
+	require.Equal(t, []string{"a", "b", "infra"}, tenants)
+
+	// without tenant_label every series goes to default_tenant
+	tenants = nil
+	w, err = newWriter(config.WriterOption{Url: srv.URL, DefaultTenant: "infra", Timeout: 5000, DialTimeout: 1000})
+	require.NoError(t, err)
+	w.Write([]prompb.TimeSeries{{Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "a"}}}})
+	require.Equal(t, []string{"infra"}, tenants)
+}
@@ -7,31 +7,81 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
-	"github.com/golang/protobuf/proto"
-	"github.com/golang/snappy"
 	"github.com/prometheus/client_golang/api"
 	"github.com/prometheus/prometheus/prompb"
+	"golang.org/x/time/rate"
 
 	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/httpx"
 )
 
 type Writer struct {
 	Opts   config.WriterOption
 	Client api.Client
+
+	// nil if rate_limit is not set
+	limiter *rate.Limiter
+
+	// content encoding and compressor of the compression option
+	encoding string
+	compress compressor
+
+	// compiled match_labels
+	matchers map[string]*regexp.Regexp
+
+	// url with the query parameters of the format, host:port of tcp formats
+	url string
+	// compiled graphite_templates
+	graphite []graphiteTemplate
 }
 
 // newWriter creates a new Writer from config.WriterOption
 func newWriter(opt config.WriterOption) (Writer, error) {
-	tr := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout: time.Duration(opt.DialTimeout) * time.Millisecond,
-		}).DialContext,
-		ResponseHeaderTimeout: time.Duration(opt.Timeout) * time.Millisecond,
-		MaxIdleConnsPerHost:   opt.MaxIdleConnsPerHost,
+	if opt.Format == "" {
+		opt.Format = FormatPrometheus
+	}
+	url, err := writeURL(opt)
+	if err != nil {
+		return Writer{}, fmt.Errorf("writer %s: %v", opt.Url, err)
+	}
+	compression := opt.Compression
+	if compression == "" && opt.Format != FormatPrometheus {
+		compression = "none"
+	}
+	if streamed(opt.Format) && compression != "none" {
+		return Writer{}, fmt.Errorf("writer %s: compression is not supported by %s", opt.Url, opt.Format)
+	}
+	graphite, err := compileGraphiteTemplates(opt.GraphiteTemplates)
+	if err != nil {
+		return Writer{}, fmt.Errorf("writer %s: %v", opt.Url, err)
+	}
+	encoding, compress, err := newCompressor(compression)
+	if err != nil {
+		return Writer{}, fmt.Errorf("writer %s: %v", opt.Url, err)
+	}
+	matchers, err := compileMatchers(opt.MatchLabels)
+	if err != nil {
+		return Writer{}, fmt.Errorf("writer %s: %v", opt.Url, err)
+	}
+	if (opt.TenantLabel != "" || opt.DefaultTenant != "") && opt.TenantHeader == "" {
+		opt.TenantHeader = defaultTenantHeader
+	}
+	proxy, err := opt.Proxy()
+	if err != nil {
+		return Writer{}, err
+	}
+	tr := httpx.NewTransport()
+	tr.Proxy = proxy
+	tr.DialContext = (&net.Dialer{
+		Timeout: time.Duration(opt.DialTimeout) * time.Millisecond,
+	}).DialContext
+	tr.ResponseHeaderTimeout = time.Duration(opt.Timeout) * time.Millisecond
+	if opt.MaxIdleConnsPerHost > 0 {
+		tr.MaxIdleConnsPerHost = opt.MaxIdleConnsPerHost
 	}
 	if opt.UseTLS || strings.HasPrefix(opt.Url, "https") {
 		opt.UseTLS = true
@@ -43,17 +93,30 @@ func newWriter(opt config.WriterOption) (Writer, error) {
 	}
 	cli, err := api.NewClient(api.Config{
 		Address:      opt.Url,
-		RoundTripper: tr,
+		RoundTripper: httpx.WrapDefault(tr),
 	})
 
 	if err != nil {
 		return Writer{}, err
 	}
 
-	return Writer{
-		Opts:   opt,
-		Client: cli,
-	}, nil
+	w := Writer{
+		Opts:     opt,
+		Client:   cli,
+		encoding: encoding,
+		compress: compress,
+		matchers: matchers,
+		url:      url,
+		graphite: graphite,
+	}
+	if opt.RateLimit > 0 {
+		burst := opt.RateBurst
+		if burst <= 0 {
+			burst = opt.RateLimit
+		}
+		w.limiter = rate.NewLimiter(rate.Limit(opt.RateLimit), burst)
+	}
+	return w, nil
 }
 
 func (w Writer) Write(items []prompb.TimeSeries) {
@@ -61,33 +124,102 @@ func (w Writer) Write(items []prompb.TimeSeries) {
 		return
 	}
 
-	req := &prompb.WriteRequest{
-		Timeseries: items,
+	if w.Opts.TenantLabel == "" {
+		w.writeBatches(items, w.Opts.DefaultTenant)
+		return
 	}
+	for tenant, group := range w.byTenant(items) {
+		w.writeBatches(group, tenant)
+	}
+}
 
-	data, err := proto.Marshal(req)
+func (w Writer) writeBatches(items []prompb.TimeSeries, tenant string) {
+	// smaller requests for this writer, e.g. for a slow link or a gateway with a body limit
+	if w.Opts.Batch > 0 {
+		for len(items) > w.Opts.Batch {
+			w.limitedWrite(items[:w.Opts.Batch], tenant)
+			items = items[w.Opts.Batch:]
+		}
+	}
+	w.limitedWrite(items, tenant)
+}
+
+func (w Writer) limitedWrite(items []prompb.TimeSeries, tenant string) {
+	if w.limiter == nil {
+		w.write(items, tenant)
+		return
+	}
+
+	// wait for tokens, a batch larger than the burst is sent in pieces
+	for len(items) > 0 {
+		n := len(items)
+		if n > w.limiter.Burst() {
+			n = w.limiter.Burst()
+		}
+		if err := w.limiter.WaitN(context.Background(), n); err != nil {
+			log.Println("W! rate limit of", w.Opts.Url, "got error:", err)
+			return
+		}
+		w.write(items[:n], tenant)
+		items = items[n:]
+	}
+}
+
+func (w Writer) write(items []prompb.TimeSeries, tenant string) {
+	start := time.Now()
+
+	data, err := w.marshal(items)
 	if err != nil {
-		log.Println("W! marshal prom data to proto got error:", err, "data:", items)
+		log.Println("W! marshal prom data to", w.Opts.Format, "got error:", err, "data:", items)
 		return
 	}
 
-	if err := w.post(snappy.Encode(nil, data)); err != nil {
+	body, err := w.compress(data)
+	if err != nil {
+		log.Println("W! compress prom data with", w.encoding, "got error:", err)
+		return
+	}
+	writeUncompressedBytes.WithLabelValues(w.Opts.Url).Add(float64(len(data)))
+	compression := w.encoding
+	if compression == "" {
+		compression = "none"
+	}
+	writeCompressedBytes.WithLabelValues(w.Opts.Url, compression).Add(float64(len(body)))
+	writeBatchSeries.WithLabelValues(w.Opts.Url).Observe(float64(len(items)))
+
+	if streamed(w.Opts.Format) {
+		err = w.send(body)
+	} else {
+		err = w.post(body, tenant)
+	}
+	writeBatchDuration.WithLabelValues(w.Opts.Url).Observe(time.Since(start).Seconds())
+	if err != nil {
+		writeRequests.WithLabelValues(w.Opts.Url, "failed").Inc()
 		log.Println("W! post to", w.Opts.Url, "got error:", err)
 		log.Println("W! example timeseries:", items[0].String())
+		return
 	}
+	writeRequests.WithLabelValues(w.Opts.Url, "success").Inc()
 }
 
-func (w Writer) post(req []byte) error {
-	httpReq, err := http.NewRequest("POST", w.Opts.Url, bytes.NewReader(req))
+func (w Writer) post(req []byte, tenant string) error {
+	httpReq, err := http.NewRequest("POST", w.url, bytes.NewReader(req))
 	if err != nil {
 		log.Println("W! create remote write request got error:", err)
 		return err
 	}
 
-	httpReq.Header.Add("Content-Encoding", "snappy")
-	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	if w.encoding != "" {
+		httpReq.Header.Add("Content-Encoding", w.encoding)
+	}
+	httpReq.Header.Set("Content-Type", w.contentType())
 	httpReq.Header.Set("User-Agent", "categraf")
-	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.Opts.Format == FormatPrometheus {
+		httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+	if w.Opts.InfluxToken != "" {
+		httpReq.Header.Set("Authorization", "Token "+w.Opts.InfluxToken)
+	}
 
 	for i := 0; i < len(w.Opts.Headers); i += 2 {
 		httpReq.Header.Add(w.Opts.Headers[i], w.Opts.Headers[i+1])
@@ -96,11 +228,20 @@ func (w Writer) post(req []byte) error {
 		}
 	}
 
+	if tenant != "" {
+		httpReq.Header.Set(w.Opts.TenantHeader, tenant)
+	}
+
 	if w.Opts.BasicAuthUser != "" {
 		httpReq.SetBasicAuth(w.Opts.BasicAuthUser, w.Opts.BasicAuthPass)
 	}
 
-	resp, body, err := w.Client.Do(context.Background(), httpReq)
+	// the body is replayed from req on every retry
+	ctx := context.Background()
+	if w.Opts.Retry {
+		ctx = httpx.WithRetry(ctx)
+	}
+	resp, body, err := w.Client.Do(ctx, httpReq)
 	if err != nil {
 		log.Println("W! push data with remote write request got error:", err, "response body:", string(body))
 		return err
@@ -113,3 +254,19 @@ func (w Writer) post(req []byte) error {
 
 	return nil
 }
+
+// send writes the lines of graphite or opentsdb telnet to a new connection,
+// these protocols have no response, so errors of the receiver go unnoticed
+func (w Writer) send(data []byte) error {
+	conn, err := net.DialTimeout("tcp", w.url, time.Duration(w.Opts.DialTimeout)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if w.Opts.Timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(time.Duration(w.Opts.Timeout) * time.Millisecond))
+	}
+	_, err = conn.Write(data)
+	return err
+}
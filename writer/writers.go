@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/prometheus/prompb"
@@ -18,7 +19,17 @@ import (
 type (
 	Writers struct {
 		writerMap map[string]Writer
-		queue     *types.SafeListLimited[*prompb.TimeSeries]
+		// url -> queue feeding the writer in the background
+		queues map[string]*writerQueue
+		// writers receiving every series
+		broadcast []string
+		// shard_group -> writers sharing the series of the group
+		shardGroups map[string][]string
+		// writers getting a copy of the series in the background
+		shadows []*shadowWriter
+		queue   *types.SafeListLimited[*prompb.TimeSeries]
+		// 1 while LoopRead holds series popped from the queue
+		busy int32
 		sync.Mutex
 
 		Snapshot
@@ -37,18 +48,53 @@ var writers *Writers
 
 func InitWriters() error {
 	writerMap := map[string]Writer{}
+	var broadcast []string
+	shardGroups := make(map[string][]string)
+	var shadows []*shadowWriter
+	urls := make(map[string]struct{})
 	opts := config.Config.Writers
 	for _, opt := range opts {
+		if opt.Shadow {
+			if err := checkShadow(opt); err != nil {
+				return err
+			}
+		}
 		writer, err := newWriter(opt)
 		if err != nil {
 			return err
 		}
+		if opt.SendMetadata {
+			enableMetadata()
+		}
+		if _, has := urls[opt.Url]; has {
+			log.Println("W! duplicate writer url:", opt.Url)
+			continue
+		}
+		urls[opt.Url] = struct{}{}
+		if opt.Shadow {
+			shadows = append(shadows, newShadowWriter(writer))
+			continue
+		}
 		writerMap[opt.Url] = writer
+		if opt.ShardGroup == "" {
+			broadcast = append(broadcast, opt.Url)
+		} else {
+			shardGroups[opt.ShardGroup] = append(shardGroups[opt.ShardGroup], opt.Url)
+		}
+	}
+
+	queues := make(map[string]*writerQueue, len(writerMap))
+	for url, writer := range writerMap {
+		queues[url] = newWriterQueue(writer)
 	}
 
 	writers = &Writers{
-		writerMap: writerMap,
-		queue:     types.NewSafeListLimited[*prompb.TimeSeries](config.Config.WriterOpt.ChanSize),
+		writerMap:   writerMap,
+		queues:      queues,
+		broadcast:   broadcast,
+		shardGroups: shardGroups,
+		shadows:     shadows,
+		queue:       types.NewSafeListLimited[*prompb.TimeSeries](config.Config.WriterOpt.ChanSize),
 	}
 
 	go writers.LoopRead()
@@ -57,8 +103,10 @@ func InitWriters() error {
 
 func (ws *Writers) LoopRead() {
 	for {
+		atomic.StoreInt32(&ws.busy, 1)
 		series := ws.queue.PopBackN(config.Config.WriterOpt.Batch)
 		if len(series) == 0 {
+			atomic.StoreInt32(&ws.busy, 0)
 			time.Sleep(time.Millisecond * 100)
 			continue
 		}
@@ -69,6 +117,25 @@ func (ws *Writers) LoopRead() {
 		}
 
 		WriteTimeSeries(items)
+		atomic.StoreInt32(&ws.busy, 0)
+	}
+}
+
+// Flush waits at most timeout for the queued series to be written, and
+// reports if the queues drained. Shadow writers are best effort and not waited for.
+func Flush(timeout time.Duration) bool {
+	if writers == nil {
+		return true
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if writers.queue.Len() == 0 && atomic.LoadInt32(&writers.busy) == 0 && writers.idle() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
 }
 
@@ -91,6 +158,7 @@ func WriteSamples(samples []*types.Sample) {
 		if item == nil || len(item.Labels) == 0 {
 			continue
 		}
+		recordMetadata(sample)
 		items = append(items, item)
 	}
 	success := writers.queue.PushFrontN(items)
@@ -98,7 +166,7 @@ func WriteSamples(samples []*types.Sample) {
 	if !success {
 		log.Printf("E! write %d samples failed, please increase queue size(%d)", len(items), l)
 	}
-	go snapshot(uint64(len(items)), uint64(l), success)
+	go snapshot(uint64(len(items)), uint64(int64(l)+writers.backlog()), success)
 }
 
 func snapshot(count, size uint64, success bool) {
@@ -121,24 +189,53 @@ func QueueMetrics() *Snapshot {
 
 // WriteTimeSeries write prompb.TimeSeries to all writers
 func WriteTimeSeries(timeSeries []prompb.TimeSeries) {
+	timeSeries = applyMaintenance(timeSeries)
 	if len(timeSeries) == 0 {
 		return
 	}
-
-	now := time.Now()
-	wg := sync.WaitGroup{}
-	for key := range writers.writerMap {
-		wg.Add(1)
-		go func(key string) {
-			defer wg.Done()
-			writers.writerMap[key].Write(timeSeries)
-		}(key)
+	trackCardinality(timeSeries)
+	for _, s := range writers.shadows {
+		s.offer(timeSeries)
 	}
-	wg.Wait()
+
+	writers.dispatch(writers.route(timeSeries))
 	if config.Config.DebugMode {
-		log.Println("D!, write", len(timeSeries), "time series to all writers, cost:",
-			time.Since(now).Milliseconds(), "ms")
+		log.Println("D!, queued", len(timeSeries), "time series to all writers")
+	}
+}
+
+// dispatch hands the batches to the queues of their writers without waiting
+// for the writes, a writer whose queue is full drops its batch
+func (ws *Writers) dispatch(batches map[string][]prompb.TimeSeries) {
+	for key, batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+		if !ws.queues[key].offer(batch) && config.Config.DebugMode {
+			log.Println("D! queue of writer", key, "is full,", len(batch), "time series dropped")
+		}
+	}
+}
+
+// backlog is the series queued for the writer furthest behind
+func (ws *Writers) backlog() int64 {
+	var max int64
+	for _, q := range ws.queues {
+		if n := q.queued(); n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// idle reports if the writers have written every batch dispatched
+func (ws *Writers) idle() bool {
+	for _, q := range ws.queues {
+		if !q.idle() {
+			return false
+		}
 	}
+	return true
 }
 
 func printTestMetrics(samples []*types.Sample) {